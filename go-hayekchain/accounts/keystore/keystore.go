@@ -283,11 +283,9 @@ func (ks *KeyStore) SignTx(a accounts.Account, tx *types.Transaction, chainID *b
 	if !found {
 		return nil, ErrLocked
 	}
-	// Depending on the presence of the chain ID, sign with EIP155 or homestead
-	if chainID != nil {
-		return types.SignTx(tx, types.NewEIP155Signer(chainID), unlockedKey.PrivateKey)
-	}
-	return types.SignTx(tx, types.HomesteadSigner{}, unlockedKey.PrivateKey)
+	// Depending on the presence of the chain ID, sign with 2718 or homestead
+	signer := types.LatestSignerForChainID(chainID)
+	return types.SignTx(tx, signer, unlockedKey.PrivateKey)
 }
 
 // SignHashWithPassphrase signs hash if the private key matching the given address
@@ -311,11 +309,36 @@ func (ks *KeyStore) SignTxWithPassphrase(a accounts.Account, passphrase string,
 	}
 	defer zeroKey(key.PrivateKey)
 
-	// Depending on the presence of the chain ID, sign with EIP155 or homestead
-	if chainID != nil {
-		return types.SignTx(tx, types.NewEIP155Signer(chainID), key.PrivateKey)
+	// Depending on the presence of the chain ID, sign with 2718 or homestead
+	signer := types.LatestSignerForChainID(chainID)
+	return types.SignTx(tx, signer, key.PrivateKey)
+}
+
+// SignFeePayerTx signs the given sponsored transaction as its fee payer with the
+// requested account. The transaction must already be signed by its sender.
+func (ks *KeyStore) SignFeePayerTx(a accounts.Account, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	// Look up the key to sign with and abort if it cannot be found
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	unlockedKey, found := ks.unlocked[a.Address]
+	if !found {
+		return nil, ErrLocked
+	}
+	return types.SignFeePayerTx(tx, types.NewSponsorSigner(chainID), unlockedKey.PrivateKey)
+}
+
+// SignFeePayerTxWithPassphrase signs the sponsored transaction as its fee payer
+// if the private key matching the given address can be decrypted with the given
+// passphrase.
+func (ks *KeyStore) SignFeePayerTxWithPassphrase(a accounts.Account, passphrase string, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	_, key, err := ks.getDecryptedKey(a, passphrase)
+	if err != nil {
+		return nil, err
 	}
-	return types.SignTx(tx, types.HomesteadSigner{}, key.PrivateKey)
+	defer zeroKey(key.PrivateKey)
+
+	return types.SignFeePayerTx(tx, types.NewSponsorSigner(chainID), key.PrivateKey)
 }
 
 // Unlock unlocks the given account indefinitely.
@@ -33,10 +33,13 @@ import (
 	"github.com/hayekchain/go-hayekchain/core/rawdb"
 	"github.com/hayekchain/go-hayekchain/core/state"
 	"github.com/hayekchain/go-hayekchain/core/types"
-	"github.com/hayekchain/go-hayekchain/hyk/downloader"
 	"github.com/hayekchain/go-hayekchain/event"
+	"github.com/hayekchain/go-hayekchain/hyk/downloader"
+	"github.com/hayekchain/go-hayekchain/hykdb"
+	"github.com/hayekchain/go-hayekchain/internal/era"
 	"github.com/hayekchain/go-hayekchain/log"
 	"github.com/hayekchain/go-hayekchain/metrics"
+	"github.com/hayekchain/go-hayekchain/params"
 	"github.com/hayekchain/go-hayekchain/trie"
 	"gopkg.in/urfave/cli.v1"
 )
@@ -94,6 +97,8 @@ The dumpgenesis command dumps the genesis block configuration in JSON format to
 			utils.MetricsInfluxDBPasswordFlag,
 			utils.MetricsInfluxDBTagsFlag,
 			utils.TxLookupLimitFlag,
+			utils.ParallelExecutionFlag,
+			utils.StateDiffsFlag,
 		},
 		Category: "BLOCKCHAIN COMMANDS",
 		Description: `
@@ -120,6 +125,43 @@ Optional second and third arguments control the first and
 last block to write. In this mode, the file will be appended
 if already existing. If the file ends with .gz, the output will
 be gzipped.`,
+	}
+	importHistoryCommand = cli.Command{
+		Action:    utils.MigrateFlags(importHistory),
+		Name:      "import-history",
+		Usage:     "Import chain history from era archives into the ancient store",
+		ArgsUsage: "<dir>",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.AncientFlag,
+			utils.CacheFlag,
+			utils.SyncModeFlag,
+		},
+		Category: "BLOCKCHAIN COMMANDS",
+		Description: `
+The import-history command imports the era archives of the configured network found
+in the given directory. Headers, bodies, receipts and total difficulties are written
+straight into the ancient store, continuing from its current end. The blocks are
+validated against the local genesis and each other, and their headers and seals are
+verified in batches before being stored, but the blocks are not re-executed.`,
+	}
+	exportHistoryCommand = cli.Command{
+		Action:    utils.MigrateFlags(exportHistory),
+		Name:      "export-history",
+		Usage:     "Export chain history from the ancient store into era archives",
+		ArgsUsage: "<dir> [<blockNumFirst> <blockNumLast>]",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.AncientFlag,
+			utils.CacheFlag,
+			utils.SyncModeFlag,
+		},
+		Category: "BLOCKCHAIN COMMANDS",
+		Description: `
+The export-history command writes the frozen chain history into the given directory,
+one era archive per epoch of 8192 blocks. Optional second and third arguments select
+the first and last block to export, the first one must start an epoch. By default all
+complete epochs in the ancient store are exported.`,
 	}
 	importPreimagesCommand = cli.Command{
 		Action:    utils.MigrateFlags(importPreimages),
@@ -403,6 +445,92 @@ func exportChain(ctx *cli.Context) error {
 	return nil
 }
 
+// importHistory imports era archives into the ancient store.
+func importHistory(ctx *cli.Context) error {
+	if len(ctx.Args()) < 1 {
+		utils.Fatalf("This command requires an argument.")
+	}
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	db := utils.MakeChainDatabase(ctx, stack)
+	defer db.Close()
+
+	config := rawdb.ReadChainConfig(db, rawdb.ReadCanonicalHash(db, 0))
+	if config == nil {
+		utils.Fatalf("Database has no chain config, init the chain first")
+	}
+	engine := utils.MakeEngine(ctx, stack, config, db)
+	defer engine.Close()
+	start := time.Now()
+
+	if err := utils.ImportHistory(db, engine, ctx.Args().First(), historyNetwork(db)); err != nil {
+		utils.Fatalf("Import error: %v\n", err)
+	}
+	fmt.Printf("Import done in %v\n", time.Since(start))
+	return nil
+}
+
+// exportHistory exports the ancient store into era archives.
+func exportHistory(ctx *cli.Context) error {
+	if len(ctx.Args()) != 1 && len(ctx.Args()) != 3 {
+		utils.Fatalf("This command requires one or three arguments.")
+	}
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	db := utils.MakeChainDatabase(ctx, stack)
+	defer db.Close()
+
+	var first, last uint64
+	if len(ctx.Args()) == 3 {
+		var ferr, lerr error
+		first, ferr = strconv.ParseUint(ctx.Args().Get(1), 10, 64)
+		last, lerr = strconv.ParseUint(ctx.Args().Get(2), 10, 64)
+		if ferr != nil || lerr != nil {
+			utils.Fatalf("Export error in parsing parameters: block number not an integer\n")
+		}
+	} else {
+		frozen, err := db.Ancients()
+		if err != nil {
+			utils.Fatalf("Failed to retrieve ancient store size: %v", err)
+		}
+		if frozen < era.MaxEraBatchSize {
+			utils.Fatalf("Export error: no complete epoch in the ancient store\n")
+		}
+		if tail, err := db.AncientTail(); err == nil {
+			first = (tail + era.MaxEraBatchSize - 1) / era.MaxEraBatchSize * era.MaxEraBatchSize
+		}
+		last = frozen/era.MaxEraBatchSize*era.MaxEraBatchSize - 1
+	}
+	start := time.Now()
+
+	if err := utils.ExportHistory(db, ctx.Args().First(), historyNetwork(db), first, last, era.MaxEraBatchSize); err != nil {
+		utils.Fatalf("Export error: %v\n", err)
+	}
+	fmt.Printf("Export done in %v\n", time.Since(start))
+	return nil
+}
+
+// historyNetwork returns the name prefixing the era archives of the chain in
+// the database. Unknown networks are named after their genesis hash.
+func historyNetwork(db hykdb.Database) string {
+	switch genesis := rawdb.ReadCanonicalHash(db, 0); genesis {
+	case params.MainnetGenesisHash:
+		return "mainnet"
+	case params.RopstenGenesisHash:
+		return "ropsten"
+	case params.RinkebyGenesisHash:
+		return "rinkeby"
+	case params.GoerliGenesisHash:
+		return "goerli"
+	case params.YoloV2GenesisHash:
+		return "yolo-v2"
+	default:
+		return fmt.Sprintf("%x", genesis[:4])
+	}
+}
+
 // importPreimages imports preimage data from the specified file.
 func importPreimages(ctx *cli.Context) error {
 	if len(ctx.Args()) < 1 {
@@ -575,7 +703,7 @@ func dump(ctx *cli.Context) error {
 			fmt.Println("{}")
 			utils.Fatalf("block not found")
 		} else {
-			state, err := state.New(block.Root(), state.NewDatabase(chainDb), nil)
+			state, err := state.New(block.Root(), chain.StateCache(), nil)
 			if err != nil {
 				utils.Fatalf("could not create new state: %v", err)
 			}
@@ -66,6 +66,29 @@ var customGenesisTests = []struct {
 		query:  "hyk.getBlock(0).nonce",
 		result: "0x0000000000001339",
 	},
+	// Genesis file with a hykash reward schedule and fork parameters
+	{
+		genesis: `{
+			"alloc"      : {},
+			"coinbase"   : "0x0000000000000000000000000000000000000000",
+			"difficulty" : "0x20000",
+			"extraData"  : "",
+			"gasLimit"   : "0x2fefd8",
+			"nonce"      : "0x000000000000133a",
+			"mixhash"    : "0x0000000000000000000000000000000000000000000000000000000000000000",
+			"parentHash" : "0x0000000000000000000000000000000000000000000000000000000000000000",
+			"timestamp"  : "0x00",
+			"config"     : {
+				"hykash" : {
+					"rewards"   : [{"block": 0, "reward": 50000000000000000000, "halvingInterval": 210000}],
+					"uncles"    : [{"block": 100, "maxUncles": 1, "maxDepth": 3}],
+					"retargets" : [{"block": 1, "algorithm": "lwma", "blockTime": 60, "window": 45}]
+				}
+			}
+		}`,
+		query:  "hyk.getBlock(0).nonce",
+		result: "0x000000000000133a",
+	},
 }
 
 // Tests that initializing Ghyk with a custom genesis block and chain definitions
@@ -77,10 +77,13 @@ var (
 		utils.HayekashDatasetsInMemoryFlag,
 		utils.HayekashDatasetsOnDiskFlag,
 		utils.HayekashDatasetsLockMmapFlag,
+		utils.HayekashStratumFlag,
+		utils.HayekashStratumDifficultyFlag,
 		utils.TxPoolLocalsFlag,
 		utils.TxPoolNoLocalsFlag,
 		utils.TxPoolJournalFlag,
 		utils.TxPoolRejournalFlag,
+		utils.TxPoolRemoteJournalFlag,
 		utils.TxPoolPriceLimitFlag,
 		utils.TxPoolPriceBumpFlag,
 		utils.TxPoolAccountSlotsFlag,
@@ -88,11 +91,18 @@ var (
 		utils.TxPoolAccountQueueFlag,
 		utils.TxPoolGlobalQueueFlag,
 		utils.TxPoolLifetimeFlag,
+		utils.TxPoolPrivateSlotsFlag,
+		utils.TxPoolPrivateLifetimeFlag,
+		utils.TxPoolBundleSlotsFlag,
+		utils.TxPoolAdmissionPolicyFlag,
 		utils.SyncModeFlag,
 		utils.ExitWhenSyncedFlag,
 		utils.GCModeFlag,
 		utils.SnapshotFlag,
 		utils.TxLookupLimitFlag,
+		utils.HistoryRetentionFlag,
+		utils.ParallelExecutionFlag,
+		utils.StateDiffsFlag,
 		utils.LightServeFlag,
 		utils.LegacyLightServFlag,
 		utils.LightIngressFlag,
@@ -132,6 +142,7 @@ var (
 		utils.LegacyMinerExtraDataFlag,
 		utils.MinerRecommitIntervalFlag,
 		utils.MinerNoVerfiyFlag,
+		utils.MinerOrderingFlag,
 		utils.NATFlag,
 		utils.NoDiscoverFlag,
 		utils.DiscoveryV5Flag,
@@ -224,6 +235,8 @@ func init() {
 		initCommand,
 		importCommand,
 		exportCommand,
+		importHistoryCommand,
+		exportHistoryCommand,
 		importPreimagesCommand,
 		exportPreimagesCommand,
 		copydbCommand,
@@ -241,11 +254,14 @@ func init() {
 		// See misccmd.go:
 		makecacheCommand,
 		makedagCommand,
+		powCommand,
 		versionCommand,
 		versionCheckCommand,
 		licenseCommand,
 		// See config.go
 		dumpConfigCommand,
+		// See snapshot.go
+		snapshotCommand,
 		// See cmd/utils/flags_legacy.go
 		utils.ShowDeprecated,
 	}
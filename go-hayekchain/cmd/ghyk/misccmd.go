@@ -18,15 +18,22 @@ package main
 
 import (
 	"fmt"
+	"math/big"
 	"os"
 	"runtime"
 	"strconv"
 	"strings"
 
 	"github.com/hayekchain/go-hayekchain/cmd/utils"
+	"github.com/hayekchain/go-hayekchain/common"
+	"github.com/hayekchain/go-hayekchain/common/hexutil"
 	"github.com/hayekchain/go-hayekchain/consensus/hykash"
+	"github.com/hayekchain/go-hayekchain/core"
+	"github.com/hayekchain/go-hayekchain/core/rawdb"
+	"github.com/hayekchain/go-hayekchain/core/types"
 	"github.com/hayekchain/go-hayekchain/hyk"
 	"github.com/hayekchain/go-hayekchain/params"
+	"github.com/hayekchain/go-hayekchain/rlp"
 	"gopkg.in/urfave/cli.v1"
 )
 
@@ -65,10 +72,69 @@ Regular users do not need to execute it.
 		Description: `
 The makedag command generates an hykash DAG in <outputDir>.
 
+Blocks past a double SHA256 algorithm switch in the chain config are verified
+without caches or DAGs, nodes only following such blocks never need one.
+
 This command exists to support the system testing project.
 Regular users do not need to execute it.
 `,
 	}
+	powCommand = cli.Command{
+		Name:      "pow",
+		Usage:     "Inspect hykash proof-of-work seals",
+		ArgsUsage: "",
+		Category:  "MISCELLANEOUS COMMANDS",
+		Description: `
+The pow commands verify, compute and explain the proof-of-work of hykash
+headers, helping operators and pool developers debug rejected seals and shares
+offline. Seals are checked by the algorithm switches and merged mining rules of
+the chain in the data directory, or of the selected network if it holds none.`,
+		Subcommands: []cli.Command{
+			{
+				Name:      "verify",
+				Usage:     "Verify the proof-of-work of a header",
+				ArgsUsage: "<blockHash | blockNum | headerRlp>",
+				Action:    utils.MigrateFlags(powVerify),
+				Category:  "MISCELLANEOUS COMMANDS",
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+				},
+				Description: `
+    ghyk pow verify <blockHash | blockNum | headerRlp>
+
+checks the seal of a header against the compact target derived from its
+difficulty. The header is either looked up in the local database by hash or
+number, or decoded from a hex encoded RLP header or block.`,
+			},
+			{
+				Name:      "target",
+				Usage:     "Convert between difficulty, compact bits and target",
+				ArgsUsage: "<difficulty | bits | target>",
+				Action:    utils.MigrateFlags(powTarget),
+				Category:  "MISCELLANEOUS COMMANDS",
+				Description: `
+    ghyk pow target <difficulty | bits | target>
+
+converts a decimal difficulty, a hex compact target of at most 8 digits (e.g.
+0x1d00ffff) or a longer hex full target into all three representations.`,
+			},
+			{
+				Name:      "preimage",
+				Usage:     "Print the 80 byte proof-of-work preimage of a header",
+				ArgsUsage: "<blockHash | blockNum | headerRlp> [nonce]",
+				Action:    utils.MigrateFlags(powPreimage),
+				Category:  "MISCELLANEOUS COMMANDS",
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+				},
+				Description: `
+    ghyk pow preimage <blockHash | blockNum | headerRlp> [nonce]
+
+prints the 80 byte seed hashed by the double SHA256 proof-of-work, field by
+field, using the nonce of the header unless one is given.`,
+			},
+		},
+	}
 	versionCommand = cli.Command{
 		Action:    utils.MigrateFlags(version),
 		Name:      "version",
@@ -133,6 +199,193 @@ func makedag(ctx *cli.Context) error {
 	return nil
 }
 
+// powEngine creates an hykash engine for verifying seals offline, following the
+// proof-of-work rules of the given chain.
+func powEngine(config *params.ChainConfig) *hykash.Hayekash {
+	return hykash.New(hykash.Config{CachesInMem: 1, PowMode: hykash.ModeNormal, PowSwitches: config.Hayekash}, nil, false)
+}
+
+// powHeader resolves a header from a hex encoded RLP header or block, or from
+// the local database by hash or number. It also returns the configuration of
+// the chain in the local database, falling back to the selected network's if
+// the database holds no chain.
+func powHeader(ctx *cli.Context, arg string) (*types.Header, *params.ChainConfig) {
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	db := utils.MakeChainDatabase(ctx, stack)
+	defer db.Close()
+
+	config := rawdb.ReadChainConfig(db, rawdb.ReadCanonicalHash(db, 0))
+	if config == nil {
+		genesis := utils.MakeGenesis(ctx)
+		if genesis == nil {
+			genesis = core.DefaultGenesisBlock()
+		}
+		config = genesis.Config
+	}
+	if strings.HasPrefix(arg, "0x") && len(arg) > 2+2*common.HashLength {
+		blob, err := hexutil.Decode(arg)
+		if err != nil {
+			utils.Fatalf("Invalid RLP: %v", err)
+		}
+		header := new(types.Header)
+		if err := rlp.DecodeBytes(blob, header); err == nil {
+			return header, config
+		}
+		block := new(types.Block)
+		if err := rlp.DecodeBytes(blob, block); err != nil {
+			utils.Fatalf("Invalid RLP header or block: %v", err)
+		}
+		return block.Header(), config
+	}
+	var hash common.Hash
+	if hashish(arg) {
+		hash = common.HexToHash(arg)
+	} else {
+		number, err := strconv.ParseUint(arg, 0, 64)
+		if err != nil {
+			utils.Fatalf("Invalid block number: %v", err)
+		}
+		hash = rawdb.ReadCanonicalHash(db, number)
+	}
+	number := rawdb.ReadHeaderNumber(db, hash)
+	if number == nil {
+		utils.Fatalf("Block %s not found", arg)
+	}
+	return rawdb.ReadHeader(db, hash, *number), config
+}
+
+// powVerify checks the proof-of-work of a header against its target, using the
+// algorithm the chain seals the header with.
+func powVerify(ctx *cli.Context) error {
+	if len(ctx.Args()) != 1 {
+		utils.Fatalf("Usage: ghyk pow verify <blockHash | blockNum | headerRlp>")
+	}
+	header, config := powHeader(ctx, ctx.Args().First())
+
+	engine := powEngine(config)
+	defer engine.Close()
+
+	fmt.Printf("Header:     #%v [%x]\n", header.Number, header.Hash())
+	fmt.Printf("Seal hash:  %x\n", engine.SealHash(header))
+
+	switch {
+	case config.Hayekash.Algorithm(header.Number) != params.PowSHA256d:
+		fmt.Printf("Algorithm:  hashimoto\n")
+		fmt.Printf("Nonce:      %d\n", header.Nonce.Uint64())
+		fmt.Printf("Mix digest: %x\n", header.MixDigest)
+
+	case header.AuxPow != nil:
+		bits := uint32(engine.Preimage(header, 0).Bits)
+		pow := hykash.AuxPowHash(header.AuxPow)
+		fmt.Printf("Algorithm:  double SHA256, merge mined\n")
+		fmt.Printf("Bits:       %#08x\n", bits)
+		fmt.Printf("Target:     %x\n", common.BigToHash(hykash.CompactToBig(bits)))
+		fmt.Printf("PoW hash:   %x (meets target: %v)\n", pow, hykash.CheckPOW(pow[:], bits))
+		fmt.Printf("Mix digest: %x\n", header.MixDigest)
+		fmt.Printf("Parent:     %x\n", header.AuxPow.ParentHeader)
+
+	default:
+		nonce := header.Nonce.Uint64()
+		pow := engine.Preimage(header, uint32(nonce))
+		fmt.Printf("Algorithm:  double SHA256\n")
+		fmt.Printf("Nonce:      %d\n", nonce)
+		fmt.Printf("Bits:       %#08x\n", uint64(pow.Bits))
+		fmt.Printf("Target:     %x\n", common.BigToHash(hykash.CompactToBig(uint32(pow.Bits))))
+		fmt.Printf("PoW hash:   %x (meets target: %v)\n", pow.Hash, hykash.CheckPOW(pow.Hash[:], uint32(pow.Bits)))
+		fmt.Printf("Mix digest: %x\n", header.MixDigest)
+		fmt.Printf("Preimage:   %x\n", []byte(pow.Preimage))
+	}
+	if err := engine.VerifySeal(nil, header); err != nil {
+		utils.Fatalf("Invalid proof-of-work: %v", err)
+	}
+	fmt.Println("Proof-of-work is valid")
+	return nil
+}
+
+// powTarget converts between difficulty, compact bits and the full target.
+func powTarget(ctx *cli.Context) error {
+	if len(ctx.Args()) != 1 {
+		utils.Fatalf("Usage: ghyk pow target <difficulty | bits | target>")
+	}
+	var (
+		arg       = ctx.Args().First()
+		maxTarget = hykash.DiffToTarget(big.NewInt(1))
+		bits      uint32
+	)
+	switch {
+	case strings.HasPrefix(arg, "0x") && len(arg) <= 10:
+		compact, err := strconv.ParseUint(arg[2:], 16, 32)
+		if err != nil {
+			utils.Fatalf("Invalid compact bits: %v", err)
+		}
+		bits = uint32(compact)
+
+	case strings.HasPrefix(arg, "0x"):
+		target, ok := new(big.Int).SetString(arg[2:], 16)
+		if !ok || target.Sign() == 0 {
+			utils.Fatalf("Invalid target: %v", arg)
+		}
+		bits = hykash.BigToCompact(target)
+
+	default:
+		difficulty, ok := new(big.Int).SetString(arg, 10)
+		if !ok || difficulty.Sign() <= 0 {
+			utils.Fatalf("Invalid difficulty: %v", arg)
+		}
+		bits = hykash.BigToCompact(hykash.DiffToTarget(difficulty))
+	}
+	target := hykash.CompactToBig(bits)
+	if target.Sign() <= 0 || target.BitLen() > 256 {
+		utils.Fatalf("Compact bits %#08x encode no valid target", bits)
+	}
+	fmt.Printf("Difficulty: %v (%.8f)\n", new(big.Int).Div(maxTarget, target), hykash.GetDifficulty(bits))
+	fmt.Printf("Bits:       %#08x\n", bits)
+	fmt.Printf("Target:     %x\n", common.BigToHash(target))
+	return nil
+}
+
+// powPreimage prints the proof-of-work preimage of a header field by field.
+func powPreimage(ctx *cli.Context) error {
+	args := ctx.Args()
+	if len(args) != 1 && len(args) != 2 {
+		utils.Fatalf("Usage: ghyk pow preimage <blockHash | blockNum | headerRlp> [nonce]")
+	}
+	header, config := powHeader(ctx, args[0])
+	if config.Hayekash.Algorithm(header.Number) != params.PowSHA256d {
+		utils.Fatalf("Block #%v is sealed with hashimoto, which has no 80 byte preimage", header.Number)
+	}
+	nonce := header.Nonce.Uint64()
+	if len(args) == 2 {
+		if header.AuxPow != nil {
+			utils.Fatalf("Merge mined headers carry no nonce of their own")
+		}
+		n, err := strconv.ParseUint(args[1], 0, 32)
+		if err != nil {
+			utils.Fatalf("Invalid nonce: %v", err)
+		}
+		nonce = n
+	}
+	engine := powEngine(config)
+	defer engine.Close()
+
+	seed := engine.Preimage(header, uint32(nonce)).Preimage
+	if header.AuxPow != nil {
+		// Merge mined seals hash the parent chain header instead
+		fmt.Println("Merge mined, showing the parent chain header")
+		seed = header.AuxPow.ParentHeader
+	}
+	fmt.Printf("Version:     %x\n", []byte(seed[0:4]))
+	fmt.Printf("Prev hash:   %x\n", []byte(seed[4:36]))
+	fmt.Printf("Merkle root: %x\n", []byte(seed[36:68]))
+	fmt.Printf("Timestamp:   %x\n", []byte(seed[68:72]))
+	fmt.Printf("Bits:        %x\n", []byte(seed[72:76]))
+	fmt.Printf("Nonce:       %x\n", []byte(seed[76:80]))
+	fmt.Printf("Preimage:    %x\n", []byte(seed))
+	return nil
+}
+
 func version(ctx *cli.Context) error {
 	fmt.Println(strings.Title(clientIdentifier))
 	fmt.Println("Version:", params.VersionWithMeta)
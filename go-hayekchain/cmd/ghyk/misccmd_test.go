@@ -0,0 +1,132 @@
+// Copyright 2021 The go-hayekchain Authors
+// This file is part of go-hayekchain.
+//
+// go-hayekchain is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-hayekchain is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-hayekchain. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hayekchain/go-hayekchain/common"
+	"github.com/hayekchain/go-hayekchain/common/hexutil"
+	"github.com/hayekchain/go-hayekchain/consensus/hykash"
+	"github.com/hayekchain/go-hayekchain/core/types"
+	"github.com/hayekchain/go-hayekchain/params"
+	"github.com/hayekchain/go-hayekchain/rlp"
+)
+
+// powGenesis is a genesis sealing every block with double SHA256, accepting
+// merge mined seals from block 1 on.
+const powGenesis = `{
+	"alloc"      : {},
+	"coinbase"   : "0x0000000000000000000000000000000000000000",
+	"difficulty" : "0x20000",
+	"extraData"  : "",
+	"gasLimit"   : "0x2fefd8",
+	"nonce"      : "0x0000000000000042",
+	"mixhash"    : "0x0000000000000000000000000000000000000000000000000000000000000000",
+	"parentHash" : "0x0000000000000000000000000000000000000000000000000000000000000000",
+	"timestamp"  : "0x00",
+	"config"     : {
+		"hykash" : {
+			"algorithms"    : [{"block": 0, "algorithm": "sha256d"}],
+			"auxPowBlock"   : %s,
+			"auxPowChainId" : 7
+		}
+	}
+}`
+
+// initPowDatadir creates a data directory initialized with powGenesis, merged
+// mining activating at the given block.
+func initPowDatadir(t *testing.T, auxPowBlock string) string {
+	datadir := tmpdir(t)
+	json := filepath.Join(datadir, "genesis.json")
+	if err := ioutil.WriteFile(json, []byte(fmt.Sprintf(powGenesis, auxPowBlock)), 0600); err != nil {
+		t.Fatalf("failed to write genesis file: %v", err)
+	}
+	runGhyk(t, "--nousb", "--datadir", datadir, "init", json).WaitExit()
+	return datadir
+}
+
+// powHeaderRLP returns the hex encoded RLP of a header at block 1. Headers not
+// merge mined carry their double SHA256 hash as mix digest, leaving the target
+// as the only check they fail.
+func powHeaderRLP(t *testing.T, auxpow *types.AuxPow) string {
+	header := &types.Header{
+		Number:     big.NewInt(1),
+		Difficulty: big.NewInt(1),
+		GasLimit:   3141592,
+		Time:       1600000000,
+		AuxPow:     auxpow,
+	}
+	if auxpow == nil {
+		rules := &params.HayekashConfig{Algorithms: []params.HayekashAlgorithm{{Block: common.Big0, Algorithm: params.PowSHA256d}}}
+		engine := hykash.New(hykash.Config{PowMode: hykash.ModeNormal, PowSwitches: rules}, nil, false)
+		defer engine.Close()
+
+		header.MixDigest = engine.Preimage(header, 0).Hash
+	}
+	blob, err := rlp.EncodeToBytes(header)
+	if err != nil {
+		t.Fatalf("failed to encode header: %v", err)
+	}
+	return hexutil.Encode(blob)
+}
+
+// Tests that pow verify checks seals by the rules of the chain in the data
+// directory instead of assuming double SHA256 without merged mining.
+func TestPowVerify(t *testing.T) {
+	datadir := initPowDatadir(t, "1")
+	defer os.RemoveAll(datadir)
+
+	// A plain header is verified with double SHA256 as the genesis demands
+	ghyk := runGhyk(t, "--nousb", "--datadir", datadir, "pow", "verify", powHeaderRLP(t, nil))
+	ghyk.ExpectRegexp(`Algorithm:  double SHA256\n`)
+	ghyk.ExpectRegexp(`Invalid proof-of-work: invalid proof-of-work`)
+	ghyk.ExpectExit()
+
+	// A merge mined header is checked against the auxpow rules
+	auxpow := &types.AuxPow{ParentHeader: []byte{0x01, 0x02}, Coinbase: []byte{0x03}}
+	ghyk = runGhyk(t, "--nousb", "--datadir", datadir, "pow", "verify", powHeaderRLP(t, auxpow))
+	ghyk.ExpectRegexp(`Algorithm:  double SHA256, merge mined\n`)
+	ghyk.ExpectRegexp(`Invalid proof-of-work: invalid auxpow parent header`)
+	ghyk.ExpectExit()
+}
+
+// Tests that pow verify rejects merge mined headers before merged mining of the
+// chain in the data directory activates.
+func TestPowVerifyAuxPowInactive(t *testing.T) {
+	datadir := initPowDatadir(t, "100")
+	defer os.RemoveAll(datadir)
+
+	auxpow := &types.AuxPow{ParentHeader: []byte{0x01, 0x02}, Coinbase: []byte{0x03}}
+	ghyk := runGhyk(t, "--nousb", "--datadir", datadir, "pow", "verify", powHeaderRLP(t, auxpow))
+	ghyk.ExpectRegexp(`Invalid proof-of-work: merged mining not active`)
+	ghyk.ExpectExit()
+}
+
+// Tests that pow target converts between difficulties, compact bits and targets.
+func TestPowTarget(t *testing.T) {
+	for _, arg := range []string{"1", "0x1d00ffff", "0x00000000ffff0000000000000000000000000000000000000000000000000000"} {
+		ghyk := runGhyk(t, "pow", "target", arg)
+		ghyk.ExpectRegexp(`Difficulty: 1 \(1\.00000000\)\nBits:       0x1d00ffff\nTarget:     00000000ffff0000000000000000000000000000000000000000000000000000\n`)
+		ghyk.ExpectExit()
+	}
+}
@@ -48,6 +48,9 @@ var AppHelpFlagGroups = []flags.FlagGroup{
 			utils.ExitWhenSyncedFlag,
 			utils.GCModeFlag,
 			utils.TxLookupLimitFlag,
+			utils.HistoryRetentionFlag,
+			utils.ParallelExecutionFlag,
+			utils.StateDiffsFlag,
 			utils.HykStatsURLFlag,
 			utils.IdentityFlag,
 			utils.LightKDFFlag,
@@ -85,6 +88,8 @@ var AppHelpFlagGroups = []flags.FlagGroup{
 			utils.HayekashDatasetsInMemoryFlag,
 			utils.HayekashDatasetsOnDiskFlag,
 			utils.HayekashDatasetsLockMmapFlag,
+			utils.HayekashStratumFlag,
+			utils.HayekashStratumDifficultyFlag,
 		},
 	},
 	{
@@ -94,6 +99,7 @@ var AppHelpFlagGroups = []flags.FlagGroup{
 			utils.TxPoolNoLocalsFlag,
 			utils.TxPoolJournalFlag,
 			utils.TxPoolRejournalFlag,
+			utils.TxPoolRemoteJournalFlag,
 			utils.TxPoolPriceLimitFlag,
 			utils.TxPoolPriceBumpFlag,
 			utils.TxPoolAccountSlotsFlag,
@@ -101,6 +107,10 @@ var AppHelpFlagGroups = []flags.FlagGroup{
 			utils.TxPoolAccountQueueFlag,
 			utils.TxPoolGlobalQueueFlag,
 			utils.TxPoolLifetimeFlag,
+			utils.TxPoolPrivateSlotsFlag,
+			utils.TxPoolPrivateLifetimeFlag,
+			utils.TxPoolBundleSlotsFlag,
+			utils.TxPoolAdmissionPolicyFlag,
 		},
 	},
 	{
@@ -183,6 +193,7 @@ var AppHelpFlagGroups = []flags.FlagGroup{
 			utils.MinerExtraDataFlag,
 			utils.MinerRecommitIntervalFlag,
 			utils.MinerNoVerfiyFlag,
+			utils.MinerOrderingFlag,
 		},
 	},
 	{
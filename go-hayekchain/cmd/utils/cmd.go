@@ -19,24 +19,31 @@ package utils
 
 import (
 	"compress/gzip"
+	"errors"
 	"fmt"
 	"io"
+	"math/big"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"runtime"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/hayekchain/go-hayekchain/common"
+	"github.com/hayekchain/go-hayekchain/consensus"
 	"github.com/hayekchain/go-hayekchain/core"
 	"github.com/hayekchain/go-hayekchain/core/rawdb"
 	"github.com/hayekchain/go-hayekchain/core/types"
 	"github.com/hayekchain/go-hayekchain/crypto"
 	"github.com/hayekchain/go-hayekchain/hykdb"
 	"github.com/hayekchain/go-hayekchain/internal/debug"
+	"github.com/hayekchain/go-hayekchain/internal/era"
 	"github.com/hayekchain/go-hayekchain/log"
 	"github.com/hayekchain/go-hayekchain/node"
 	"github.com/hayekchain/go-hayekchain/rlp"
+	"github.com/hayekchain/go-hayekchain/trie"
 )
 
 const (
@@ -312,3 +319,309 @@ func ExportPreimages(db hykdb.Database, fn string) error {
 	log.Info("Exported preimages", "file", fn)
 	return nil
 }
+
+// ExportHistory exports the frozen chain segment [first, last] into era archives
+// in the given directory, one file per epoch of step blocks.
+func ExportHistory(db hykdb.Database, dir, network string, first, last, step uint64) error {
+	log.Info("Exporting history", "dir", dir, "first", first, "last", last)
+
+	if step == 0 || step > era.MaxEraBatchSize {
+		return fmt.Errorf("invalid epoch size %d", step)
+	}
+	if first%step != 0 {
+		return fmt.Errorf("first block %d not aligned to epoch size %d", first, step)
+	}
+	if first > last {
+		return fmt.Errorf("invalid block range %d-%d", first, last)
+	}
+	frozen, err := db.Ancients()
+	if err != nil {
+		return err
+	}
+	if last >= frozen {
+		return fmt.Errorf("block %d not in ancient store, frozen %d", last, frozen)
+	}
+	if tail, err := db.AncientTail(); err == nil && first < tail {
+		return fmt.Errorf("block %d below history tail %d: %w", first, tail, core.ErrHistoryPruned)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	var (
+		start  = time.Now()
+		logged = time.Now()
+	)
+	for from := first; from <= last; from += step {
+		to := from + step - 1
+		if to > last {
+			to = last
+		}
+		// Write the epoch into a temporary file, naming it after the checksum
+		// once complete
+		tmp := filepath.Join(dir, fmt.Sprintf("%s-%05d.era.tmp", network, from/step))
+		fh, err := os.Create(tmp)
+		if err != nil {
+			return err
+		}
+		builder := era.NewBuilder(fh)
+		for n := from; n <= to; n++ {
+			var (
+				hash     = rawdb.ReadCanonicalHash(db, n)
+				header   = rawdb.ReadHeaderRLP(db, hash, n)
+				body     = rawdb.ReadBodyRLP(db, hash, n)
+				receipts = rawdb.ReadReceiptsRLP(db, hash, n)
+				td       = rawdb.ReadTd(db, hash, n)
+			)
+			if len(header) == 0 || len(body) == 0 || len(receipts) == 0 || td == nil {
+				fh.Close()
+				os.Remove(tmp)
+				return fmt.Errorf("block %d missing from database", n)
+			}
+			if err := builder.Add(n, header, body, receipts, td); err != nil {
+				fh.Close()
+				os.Remove(tmp)
+				return err
+			}
+			if time.Since(logged) > 8*time.Second {
+				log.Info("Exporting history", "number", n, "elapsed", common.PrettyDuration(time.Since(start)))
+				logged = time.Now()
+			}
+		}
+		checksum, err := builder.Finalize()
+		if err == nil {
+			err = fh.Sync()
+		}
+		if cerr := fh.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			os.Remove(tmp)
+			return err
+		}
+		if err := os.Rename(tmp, filepath.Join(dir, era.Filename(network, int(from/step), checksum))); err != nil {
+			return err
+		}
+	}
+	log.Info("Exported history", "dir", dir, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// ImportHistory imports the era archives of the given network from a directory
+// straight into the ancient store, continuing from its current end. The blocks
+// are checked against the local genesis and each other, and their headers and
+// seals are verified by the consensus engine in batches before being stored, but
+// the blocks are not re-executed.
+func ImportHistory(db hykdb.Database, engine consensus.Engine, dir, network string) error {
+	log.Info("Importing history", "dir", dir)
+
+	files, err := era.ReadDir(dir, network)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no %s era files found in %s", network, dir)
+	}
+	genesis := rawdb.ReadCanonicalHash(db, 0)
+	genesisTd := rawdb.ReadTd(db, genesis, 0)
+	if genesis == (common.Hash{}) || genesisTd == nil {
+		return errors.New("database has no genesis block, init the chain first")
+	}
+	config := rawdb.ReadChainConfig(db, genesis)
+	if config == nil {
+		return errors.New("database has no chain config, init the chain first")
+	}
+	chain, err := core.NewHeaderChain(db, config, engine, func() bool { return false })
+	if err != nil {
+		return err
+	}
+	frozen, err := db.Ancients()
+	if err != nil {
+		return err
+	}
+	var (
+		parent   common.Hash
+		parentTd *big.Int
+		start    = time.Now()
+		logged   = time.Now()
+		imported int
+	)
+	if frozen > 0 {
+		parent = rawdb.ReadCanonicalHash(db, frozen-1)
+		parentTd = rawdb.ReadTd(db, parent, frozen-1)
+	}
+	for _, file := range files {
+		e, err := era.Open(file)
+		if err != nil {
+			return err
+		}
+		if e.Start()+e.Count() <= frozen {
+			e.Close()
+			continue // Already contained in the ancient store
+		}
+		if e.Start() > frozen {
+			e.Close()
+			return fmt.Errorf("%s: gap between ancient store end %d and era start %d", file, frozen, e.Start())
+		}
+		if err := e.Verify(); err != nil {
+			e.Close()
+			return fmt.Errorf("%s: %v", file, err)
+		}
+		for frozen < e.Start()+e.Count() {
+			// Read and link up the next batch of blocks
+			var blocks []*historyBlock
+			for n := frozen; n < e.Start()+e.Count() && len(blocks) < importBatchSize; n++ {
+				block, td, blobs, err := readHistoryBlock(e, n)
+				if err != nil {
+					e.Close()
+					return fmt.Errorf("%s: block %d: %v", file, n, err)
+				}
+				hash := block.Hash()
+				switch {
+				case n == 0 && hash != genesis:
+					err = fmt.Errorf("genesis mismatch: have %x, want %x", hash, genesis)
+				case n > 0 && block.ParentHash() != parent:
+					err = fmt.Errorf("parent hash mismatch: have %x, want %x", block.ParentHash(), parent)
+				case n == 0 && td.Cmp(genesisTd) != 0, n > 0 && td.Cmp(new(big.Int).Add(parentTd, block.Difficulty())) != 0:
+					err = fmt.Errorf("total difficulty mismatch: %v", td)
+				}
+				if local := rawdb.ReadCanonicalHash(db, n); err == nil && local != (common.Hash{}) && local != hash {
+					err = fmt.Errorf("conflicting local block %x", local)
+				}
+				if err != nil {
+					e.Close()
+					return fmt.Errorf("%s: block %d: %v", file, n, err)
+				}
+				blocks = append(blocks, &historyBlock{block: block, td: td, blobs: blobs})
+				parent, parentTd = hash, td
+			}
+			// Verify the headers and seals of the batch before storing any of it
+			if err := verifyHistoryBlocks(chain, engine, blocks); err != nil {
+				e.Close()
+				return fmt.Errorf("%s: %v", file, err)
+			}
+			batch := db.NewBatch()
+			for _, block := range blocks {
+				n, hash := block.block.NumberU64(), block.block.Hash()
+
+				tdBlob, err := rlp.EncodeToBytes(block.td)
+				if err != nil {
+					e.Close()
+					return err
+				}
+				if err := db.AppendAncient(n, hash.Bytes(), block.blobs[0], block.blobs[1], block.blobs[2], tdBlob); err != nil {
+					e.Close()
+					return err
+				}
+				rawdb.WriteHeaderNumber(batch, hash, n)
+				rawdb.WriteTxLookupEntriesByBlock(batch, block.block)
+				frozen = n + 1
+				imported++
+
+				if time.Since(logged) > 8*time.Second {
+					log.Info("Importing history", "number", n, "elapsed", common.PrettyDuration(time.Since(start)))
+					logged = time.Now()
+				}
+			}
+			// Flush the ancients before the indices referencing them
+			if err := db.Sync(); err != nil {
+				e.Close()
+				return err
+			}
+			if err := batch.Write(); err != nil {
+				e.Close()
+				return err
+			}
+		}
+		e.Close()
+	}
+	// Move the header and fast sync heads up to the imported history, leaving
+	// the full block head alone as there's no state for it
+	if head := rawdb.ReadHeaderNumber(db, rawdb.ReadHeadHeaderHash(db)); imported > 0 && (head == nil || *head < frozen-1) {
+		rawdb.WriteHeadHeaderHash(db, parent)
+		rawdb.WriteHeadFastBlockHash(db, parent)
+	}
+	log.Info("Imported history", "dir", dir, "blocks", imported, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// historyBlock is a block read from an era archive, along with its total
+// difficulty and raw header, body and receipt blobs.
+type historyBlock struct {
+	block *types.Block
+	td    *big.Int
+	blobs [3][]byte
+}
+
+// verifyHistoryBlocks checks the headers and seals of a linked batch of blocks
+// with the consensus engine. The genesis block is not verified, it was matched
+// against the local one instead.
+func verifyHistoryBlocks(chain consensus.ChainHeaderReader, engine consensus.Engine, blocks []*historyBlock) error {
+	headers := make([]*types.Header, 0, len(blocks))
+	for _, block := range blocks {
+		if block.block.NumberU64() > 0 {
+			headers = append(headers, block.block.Header())
+		}
+	}
+	if len(headers) == 0 {
+		return nil
+	}
+	seals := make([]bool, len(headers))
+	for i := range seals {
+		seals[i] = true
+	}
+	abort, results := engine.VerifyHeaders(chain, headers, seals)
+	defer close(abort)
+
+	for _, header := range headers {
+		if err := <-results; err != nil {
+			return fmt.Errorf("block %d: %v", header.Number, err)
+		}
+	}
+	return nil
+}
+
+// readHistoryBlock retrieves a block from an era, validating its body and receipts
+// against the header. The raw header, body and receipt blobs are returned too.
+func readHistoryBlock(e *era.Era, number uint64) (*types.Block, *big.Int, [3][]byte, error) {
+	var blobs [3][]byte
+
+	header, body, receipts, td, err := e.GetRawBlock(number)
+	if err != nil {
+		return nil, nil, blobs, err
+	}
+	blobs = [3][]byte{header, body, receipts}
+
+	h := new(types.Header)
+	if err := rlp.DecodeBytes(header, h); err != nil {
+		return nil, nil, blobs, fmt.Errorf("invalid header: %v", err)
+	}
+	if h.Number.Uint64() != number {
+		return nil, nil, blobs, fmt.Errorf("header number mismatch: have %d", h.Number)
+	}
+	b := new(types.Body)
+	if err := rlp.DecodeBytes(body, b); err != nil {
+		return nil, nil, blobs, fmt.Errorf("invalid body: %v", err)
+	}
+	if hash := types.DeriveSha(types.Transactions(b.Transactions), trie.NewStackTrie(nil)); hash != h.TxHash {
+		return nil, nil, blobs, fmt.Errorf("transaction root mismatch: have %x, want %x", hash, h.TxHash)
+	}
+	if hash := types.CalcUncleHash(b.Uncles); hash != h.UncleHash {
+		return nil, nil, blobs, fmt.Errorf("uncle root mismatch: have %x, want %x", hash, h.UncleHash)
+	}
+	var stored []*types.ReceiptForStorage
+	if err := rlp.DecodeBytes(receipts, &stored); err != nil {
+		return nil, nil, blobs, fmt.Errorf("invalid receipts: %v", err)
+	}
+	if len(stored) != len(b.Transactions) {
+		return nil, nil, blobs, fmt.Errorf("receipt count mismatch: have %d, want %d", len(stored), len(b.Transactions))
+	}
+	rs := make(types.Receipts, len(stored))
+	for i, receipt := range stored {
+		rs[i] = (*types.Receipt)(receipt)
+		rs[i].Type = b.Transactions[i].Type()
+	}
+	if hash := types.DeriveSha(rs, trie.NewStackTrie(nil)); hash != h.ReceiptHash {
+		return nil, nil, blobs, fmt.Errorf("receipt root mismatch: have %x, want %x", hash, h.ReceiptHash)
+	}
+	return types.NewBlockWithHeader(h).WithBody(b.Transactions, b.Uncles), td, blobs, nil
+}
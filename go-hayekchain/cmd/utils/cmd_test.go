@@ -0,0 +1,222 @@
+// Copyright 2021 The go-hayekchain Authors
+// This file is part of go-hayekchain.
+//
+// go-hayekchain is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-hayekchain is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-hayekchain. If not, see <http://www.gnu.org/licenses/>.
+
+package utils
+
+import (
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hayekchain/go-hayekchain/common"
+	"github.com/hayekchain/go-hayekchain/consensus/hykash"
+	"github.com/hayekchain/go-hayekchain/core"
+	"github.com/hayekchain/go-hayekchain/core/rawdb"
+	"github.com/hayekchain/go-hayekchain/core/types"
+	"github.com/hayekchain/go-hayekchain/core/vm"
+	"github.com/hayekchain/go-hayekchain/crypto"
+	"github.com/hayekchain/go-hayekchain/hykdb"
+	"github.com/hayekchain/go-hayekchain/internal/era"
+	"github.com/hayekchain/go-hayekchain/params"
+)
+
+// Tests that frozen chain history can be exported into era archives and imported
+// into a fresh ancient store.
+func TestHistoryExportImport(t *testing.T) {
+	var (
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		gspec   = &core.Genesis{Config: params.TestChainConfig, Alloc: core.GenesisAlloc{address: {Balance: big.NewInt(1000000000)}}}
+		signer  = types.NewEIP155Signer(gspec.Config.ChainID)
+	)
+	gendb := rawdb.NewMemoryDatabase()
+	genesis := gspec.MustCommit(gendb)
+	blocks, receipts := core.GenerateChain(gspec.Config, genesis, hykash.NewFaker(), gendb, 40, func(i int, block *core.BlockGen) {
+		tx, err := types.SignTx(types.NewTransaction(block.TxNonce(address), common.Address{0x01}, big.NewInt(1000), params.TxGas, nil, nil), signer, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		block.AddTx(tx)
+	})
+	root, err := ioutil.TempDir("", "history-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	newDatabase := func(name string) hykdb.Database {
+		db, err := rawdb.NewDatabaseWithFreezer(rawdb.NewMemoryDatabase(), filepath.Join(root, name), "")
+		if err != nil {
+			t.Fatalf("failed to create database: %v", err)
+		}
+		gspec.MustCommit(db)
+		return db
+	}
+	// Freeze the whole chain in the source database
+	srcdb := newDatabase("src")
+	defer srcdb.Close()
+
+	chain, err := core.NewBlockChain(srcdb, nil, gspec.Config, hykash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	headers := make([]*types.Header, len(blocks))
+	for i, block := range blocks {
+		headers[i] = block.Header()
+	}
+	if n, err := chain.InsertHeaderChain(headers, 0); err != nil {
+		t.Fatalf("failed to insert header %d: %v", n, err)
+	}
+	if n, err := chain.InsertReceiptChain(blocks, receipts, uint64(len(blocks))); err != nil {
+		t.Fatalf("failed to insert block %d: %v", n, err)
+	}
+	chain.Stop()
+
+	// Export two epochs and import them into an empty ancient store
+	dir := filepath.Join(root, "era")
+	if err := ExportHistory(srcdb, dir, "test", 0, 31, 16); err != nil {
+		t.Fatalf("failed to export history: %v", err)
+	}
+	if files, err := era.ReadDir(dir, "test"); err != nil || len(files) != 2 {
+		t.Fatalf("era files mismatch: have %d (%v), want 2", len(files), err)
+	}
+	dstdb := newDatabase("dst")
+	defer dstdb.Close()
+
+	if err := ImportHistory(dstdb, hykash.NewFaker(), dir, "test"); err != nil {
+		t.Fatalf("failed to import history: %v", err)
+	}
+	if frozen, _ := dstdb.Ancients(); frozen != 32 {
+		t.Fatalf("ancient count mismatch: have %d, want 32", frozen)
+	}
+	if head := rawdb.ReadHeadHeaderHash(dstdb); head != blocks[30].Hash() {
+		t.Fatalf("head header mismatch: have %x, want %x", head, blocks[30].Hash())
+	}
+	for _, block := range blocks[:31] {
+		number, hash := block.NumberU64(), block.Hash()
+		if have := rawdb.ReadBlock(dstdb, hash, number); have == nil || have.Hash() != hash {
+			t.Fatalf("block %d: missing or mismatched block", number)
+		}
+		if have := rawdb.ReadRawReceipts(dstdb, hash, number); len(have) != len(block.Transactions()) {
+			t.Fatalf("block %d: receipt count mismatch: have %d, want %d", number, len(have), len(block.Transactions()))
+		}
+		if have, want := rawdb.ReadTd(dstdb, hash, number), rawdb.ReadTd(srcdb, hash, number); have.Cmp(want) != 0 {
+			t.Fatalf("block %d: td mismatch: have %v, want %v", number, have, want)
+		}
+		for _, tx := range block.Transactions() {
+			if rawdb.ReadTxLookupEntry(dstdb, tx.Hash()) == nil {
+				t.Fatalf("block %d: missing tx index", number)
+			}
+		}
+	}
+	// Importing again should be a noop, unaligned or unfrozen exports should fail
+	if err := ImportHistory(dstdb, hykash.NewFaker(), dir, "test"); err != nil {
+		t.Fatalf("failed to reimport history: %v", err)
+	}
+	if err := ExportHistory(srcdb, dir, "test", 8, 31, 16); err == nil {
+		t.Fatalf("unaligned export succeeded")
+	}
+	if err := ExportHistory(srcdb, dir, "test", 32, 64, 16); err == nil {
+		t.Fatalf("unfrozen export succeeded")
+	}
+}
+
+// Tests that era archives of a different chain are rejected.
+func TestHistoryImportGenesisMismatch(t *testing.T) {
+	root, err := ioutil.TempDir("", "history-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	srcdb, err := rawdb.NewDatabaseWithFreezer(rawdb.NewMemoryDatabase(), filepath.Join(root, "src"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srcdb.Close()
+	genesis := (&core.Genesis{Config: params.TestChainConfig, ExtraData: []byte("src")}).MustCommit(srcdb)
+	rawdb.WriteAncientBlock(srcdb, genesis, nil, genesis.Difficulty())
+
+	dir := filepath.Join(root, "era")
+	if err := ExportHistory(srcdb, dir, "test", 0, 0, 16); err != nil {
+		t.Fatalf("failed to export history: %v", err)
+	}
+	dstdb, err := rawdb.NewDatabaseWithFreezer(rawdb.NewMemoryDatabase(), filepath.Join(root, "dst"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dstdb.Close()
+	(&core.Genesis{Config: params.TestChainConfig, ExtraData: []byte("dst")}).MustCommit(dstdb)
+
+	if err := ImportHistory(dstdb, hykash.NewFaker(), dir, "test"); err == nil {
+		t.Fatalf("foreign history imported")
+	}
+	if frozen, _ := dstdb.Ancients(); frozen != 0 {
+		t.Fatalf("ancient count mismatch: have %d, want 0", frozen)
+	}
+}
+
+// Tests that history failing header verification is rejected before it reaches
+// the ancient store, keeping the batches verified before the failure.
+func TestHistoryImportInvalidSeal(t *testing.T) {
+	gspec := &core.Genesis{Config: params.TestChainConfig, Difficulty: big.NewInt(1)}
+	gendb := rawdb.NewMemoryDatabase()
+	genesis := gspec.MustCommit(gendb)
+	blocks, _ := core.GenerateChain(gspec.Config, genesis, hykash.NewFaker(), gendb, 40, nil)
+
+	root, err := ioutil.TempDir("", "history-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	srcdb, err := rawdb.NewDatabaseWithFreezer(rawdb.NewMemoryDatabase(), filepath.Join(root, "src"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srcdb.Close()
+	gspec.MustCommit(srcdb)
+
+	td := new(big.Int).Set(genesis.Difficulty())
+	rawdb.WriteAncientBlock(srcdb, genesis, nil, td)
+	for _, block := range blocks[:31] {
+		td.Add(td, block.Difficulty())
+		rawdb.WriteAncientBlock(srcdb, block, nil, td)
+	}
+	dir := filepath.Join(root, "era")
+	if err := ExportHistory(srcdb, dir, "test", 0, 31, 16); err != nil {
+		t.Fatalf("failed to export history: %v", err)
+	}
+	dstdb, err := rawdb.NewDatabaseWithFreezer(rawdb.NewMemoryDatabase(), filepath.Join(root, "dst"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dstdb.Close()
+	gspec.MustCommit(dstdb)
+
+	// Block 20 lives in the second era, the first one should be imported
+	if err := ImportHistory(dstdb, hykash.NewFakeFailer(20), dir, "test"); err == nil {
+		t.Fatalf("history with invalid seal imported")
+	}
+	if frozen, _ := dstdb.Ancients(); frozen != 16 {
+		t.Fatalf("ancient count mismatch: have %d, want 16", frozen)
+	}
+	if rawdb.ReadHeaderNumber(dstdb, blocks[19].Hash()) != nil {
+		t.Fatalf("unverified block indexed")
+	}
+}
@@ -187,7 +187,7 @@ var (
 	defaultSyncMode = hyk.DefaultConfig.SyncMode
 	SyncModeFlag    = TextMarshalerFlag{
 		Name:  "syncmode",
-		Usage: `Blockchain sync mode ("fast", "full", or "light")`,
+		Usage: `Blockchain sync mode ("fast", "full", "snap" or "light")`,
 		Value: &defaultSyncMode,
 	}
 	GCModeFlag = cli.StringFlag{
@@ -204,6 +204,19 @@ var (
 		Usage: "Number of recent blocks to maintain transactions index by-hash for (default = index all blocks)",
 		Value: 0,
 	}
+	HistoryRetentionFlag = cli.Uint64Flag{
+		Name:  "history.retention",
+		Usage: "Number of recent blocks to retain bodies and receipts for, older ones are pruned (default = keep all blocks)",
+		Value: 0,
+	}
+	ParallelExecutionFlag = cli.BoolFlag{
+		Name:  "parallel.execution",
+		Usage: "Execute the transactions of imported blocks optimistically in parallel",
+	}
+	StateDiffsFlag = cli.BoolFlag{
+		Name:  "state.diffs",
+		Usage: "Record reverse state diffs of all blocks to serve historic state without an archive node (requires --snapshot)",
+	}
 	LightKDFFlag = cli.BoolFlag{
 		Name:  "lightkdf",
 		Usage: "Reduce key-derivation RAM & CPU usage at some expense of KDF strength",
@@ -289,6 +302,15 @@ var (
 		Name:  "hykash.dagslockmmap",
 		Usage: "Lock memory maps for recent hykash mining DAGs",
 	}
+	HayekashStratumFlag = cli.StringFlag{
+		Name:  "hykash.stratum",
+		Usage: "Stratum mining server listening address for double SHA256 sealing (e.g. 0.0.0.0:3333)",
+	}
+	HayekashStratumDifficultyFlag = cli.Uint64Flag{
+		Name:  "hykash.stratumdiff",
+		Usage: "Initial share difficulty of stratum mining sessions",
+		Value: hyk.DefaultConfig.Hayekash.StratumDifficulty,
+	}
 	// Transaction pool settings
 	TxPoolLocalsFlag = cli.StringFlag{
 		Name:  "txpool.locals",
@@ -305,9 +327,14 @@ var (
 	}
 	TxPoolRejournalFlag = cli.DurationFlag{
 		Name:  "txpool.rejournal",
-		Usage: "Time interval to regenerate the local transaction journal",
+		Usage: "Time interval to regenerate the local and remote transaction journals",
 		Value: core.DefaultTxPoolConfig.Rejournal,
 	}
+	TxPoolRemoteJournalFlag = cli.StringFlag{
+		Name:  "txpool.remotejournal",
+		Usage: "Disk journal for remote transactions to survive node restarts (disabled if empty)",
+		Value: core.DefaultTxPoolConfig.RemoteJournal,
+	}
 	TxPoolPriceLimitFlag = cli.Uint64Flag{
 		Name:  "txpool.pricelimit",
 		Usage: "Minimum gas price limit to enforce for acceptance into the pool",
@@ -343,6 +370,26 @@ var (
 		Usage: "Maximum amount of time non-executable transaction are queued",
 		Value: hyk.DefaultConfig.TxPool.Lifetime,
 	}
+	TxPoolPrivateSlotsFlag = cli.Uint64Flag{
+		Name:  "txpool.privateslots",
+		Usage: "Maximum number of transactions in the non-gossiped private lane",
+		Value: hyk.DefaultConfig.TxPool.PrivateSlots,
+	}
+	TxPoolPrivateLifetimeFlag = cli.Uint64Flag{
+		Name:  "txpool.privatelifetime",
+		Usage: "Default number of blocks private transactions are offered to the miner",
+		Value: hyk.DefaultConfig.TxPool.PrivateLifetime,
+	}
+	TxPoolBundleSlotsFlag = cli.Uint64Flag{
+		Name:  "txpool.bundleslots",
+		Usage: "Maximum number of transaction bundles waiting for their target block",
+		Value: hyk.DefaultConfig.TxPool.BundleSlots,
+	}
+	TxPoolAdmissionPolicyFlag = cli.StringFlag{
+		Name:  "txpool.admission",
+		Usage: "Policy file filtering transactions by sender, recipient and method (disabled if empty)",
+		Value: hyk.DefaultConfig.TxPool.AdmissionPolicy,
+	}
 	// Performance tuning settings
 	CacheFlag = cli.IntFlag{
 		Name:  "cache",
@@ -387,6 +434,11 @@ var (
 		Name:  "cache.preimages",
 		Usage: "Enable recording the SHA3/keccak preimages of trie keys (default: true)",
 	}
+	BloomFilterSizeFlag = cli.Uint64Flag{
+		Name:  "bloomfilter.size",
+		Usage: "Megabytes of memory allocated to bloom-filter for pruning",
+		Value: 2048,
+	}
 	// Miner settings
 	MiningEnabledFlag = cli.BoolFlag{
 		Name:  "mine",
@@ -434,6 +486,11 @@ var (
 		Name:  "miner.noverify",
 		Usage: "Disable remote sealing verification",
 	}
+	MinerOrderingFlag = cli.StringFlag{
+		Name:  "miner.ordering",
+		Usage: `Transaction ordering policy for block building ("price", "fifo" or "fairshare")`,
+		Value: miner.OrderingPrice,
+	}
 	// Account settings
 	UnlockedAccountFlag = cli.StringFlag{
 		Name:  "unlock",
@@ -1324,6 +1381,9 @@ func setTxPool(ctx *cli.Context, cfg *core.TxPoolConfig) {
 	if ctx.GlobalIsSet(TxPoolRejournalFlag.Name) {
 		cfg.Rejournal = ctx.GlobalDuration(TxPoolRejournalFlag.Name)
 	}
+	if ctx.GlobalIsSet(TxPoolRemoteJournalFlag.Name) {
+		cfg.RemoteJournal = ctx.GlobalString(TxPoolRemoteJournalFlag.Name)
+	}
 	if ctx.GlobalIsSet(TxPoolPriceLimitFlag.Name) {
 		cfg.PriceLimit = ctx.GlobalUint64(TxPoolPriceLimitFlag.Name)
 	}
@@ -1345,6 +1405,18 @@ func setTxPool(ctx *cli.Context, cfg *core.TxPoolConfig) {
 	if ctx.GlobalIsSet(TxPoolLifetimeFlag.Name) {
 		cfg.Lifetime = ctx.GlobalDuration(TxPoolLifetimeFlag.Name)
 	}
+	if ctx.GlobalIsSet(TxPoolPrivateSlotsFlag.Name) {
+		cfg.PrivateSlots = ctx.GlobalUint64(TxPoolPrivateSlotsFlag.Name)
+	}
+	if ctx.GlobalIsSet(TxPoolPrivateLifetimeFlag.Name) {
+		cfg.PrivateLifetime = ctx.GlobalUint64(TxPoolPrivateLifetimeFlag.Name)
+	}
+	if ctx.GlobalIsSet(TxPoolBundleSlotsFlag.Name) {
+		cfg.BundleSlots = ctx.GlobalUint64(TxPoolBundleSlotsFlag.Name)
+	}
+	if ctx.GlobalIsSet(TxPoolAdmissionPolicyFlag.Name) {
+		cfg.AdmissionPolicy = ctx.GlobalString(TxPoolAdmissionPolicyFlag.Name)
+	}
 }
 
 func setHayekash(ctx *cli.Context, cfg *hyk.Config) {
@@ -1372,6 +1444,12 @@ func setHayekash(ctx *cli.Context, cfg *hyk.Config) {
 	if ctx.GlobalIsSet(HayekashDatasetsLockMmapFlag.Name) {
 		cfg.Hayekash.DatasetsLockMmap = ctx.GlobalBool(HayekashDatasetsLockMmapFlag.Name)
 	}
+	if ctx.GlobalIsSet(HayekashStratumFlag.Name) {
+		cfg.Hayekash.StratumAddr = ctx.GlobalString(HayekashStratumFlag.Name)
+	}
+	if ctx.GlobalIsSet(HayekashStratumDifficultyFlag.Name) {
+		cfg.Hayekash.StratumDifficulty = ctx.GlobalUint64(HayekashStratumDifficultyFlag.Name)
+	}
 }
 
 func setMiner(ctx *cli.Context, cfg *miner.Config) {
@@ -1408,6 +1486,12 @@ func setMiner(ctx *cli.Context, cfg *miner.Config) {
 	if ctx.GlobalIsSet(MinerNoVerfiyFlag.Name) {
 		cfg.Noverify = ctx.GlobalBool(MinerNoVerfiyFlag.Name)
 	}
+	if ctx.GlobalIsSet(MinerOrderingFlag.Name) {
+		cfg.Ordering = ctx.GlobalString(MinerOrderingFlag.Name)
+		if _, err := miner.NewOrderingPolicy(cfg.Ordering); err != nil {
+			Fatalf("Invalid --%s: %v", MinerOrderingFlag.Name, err)
+		}
+	}
 }
 
 func setWhitelist(ctx *cli.Context, cfg *hyk.Config) {
@@ -1491,10 +1575,12 @@ func SetHykConfig(ctx *cli.Context, stack *node.Node, cfg *hyk.Config) {
 	CheckExclusive(ctx, LegacyLightServFlag, LightServeFlag, SyncModeFlag, "light")
 	CheckExclusive(ctx, DeveloperFlag, ExternalSignerFlag) // Can't use both ephemeral unlocked and external signer
 	CheckExclusive(ctx, GCModeFlag, "archive", TxLookupLimitFlag)
+	CheckExclusive(ctx, GCModeFlag, "archive", HistoryRetentionFlag)
 	// todo(rjl493456442) make it available for les server
 	// Ancient tx indices pruning is not available for les server now
 	// since light client relies on the server for transaction status query.
 	CheckExclusive(ctx, LegacyLightServFlag, LightServeFlag, TxLookupLimitFlag)
+	CheckExclusive(ctx, LegacyLightServFlag, LightServeFlag, HistoryRetentionFlag)
 	var ks *keystore.KeyStore
 	if keystores := stack.AccountManager().Backends(keystore.KeyStoreType); len(keystores) > 0 {
 		ks = keystores[0].(*keystore.KeyStore)
@@ -1539,6 +1625,18 @@ func SetHykConfig(ctx *cli.Context, stack *node.Node, cfg *hyk.Config) {
 	if ctx.GlobalIsSet(TxLookupLimitFlag.Name) {
 		cfg.TxLookupLimit = ctx.GlobalUint64(TxLookupLimitFlag.Name)
 	}
+	if ctx.GlobalIsSet(HistoryRetentionFlag.Name) {
+		cfg.HistoryRetention = ctx.GlobalUint64(HistoryRetentionFlag.Name)
+	}
+	if ctx.GlobalIsSet(ParallelExecutionFlag.Name) {
+		cfg.ParallelExecution = ctx.GlobalBool(ParallelExecutionFlag.Name)
+	}
+	if ctx.GlobalIsSet(StateDiffsFlag.Name) {
+		if !ctx.GlobalIsSet(SnapshotFlag.Name) {
+			Fatalf("--%s requires --%s", StateDiffsFlag.Name, SnapshotFlag.Name)
+		}
+		cfg.StateDiffs = ctx.GlobalBool(StateDiffsFlag.Name)
+	}
 	if ctx.GlobalIsSet(CacheFlag.Name) || ctx.GlobalIsSet(CacheTrieFlag.Name) {
 		cfg.TrieCleanCache = ctx.GlobalInt(CacheFlag.Name) * ctx.GlobalInt(CacheTrieFlag.Name) / 100
 	}
@@ -1555,8 +1653,15 @@ func SetHykConfig(ctx *cli.Context, stack *node.Node, cfg *hyk.Config) {
 		cfg.SnapshotCache = ctx.GlobalInt(CacheFlag.Name) * ctx.GlobalInt(CacheSnapshotFlag.Name) / 100
 	}
 	if !ctx.GlobalIsSet(SnapshotFlag.Name) {
-		cfg.TrieCleanCache += cfg.SnapshotCache
-		cfg.SnapshotCache = 0 // Disabled
+		if cfg.SyncMode == downloader.SnapSync {
+			log.Info("Snap sync requested, enabling --snapshot")
+		} else {
+			cfg.TrieCleanCache += cfg.SnapshotCache
+			cfg.SnapshotCache = 0 // Disabled
+		}
+	}
+	if cfg.SyncMode == downloader.SnapSync && cfg.SnapshotCache == 0 {
+		Fatalf("--%s=snap requires a snapshot cache, raise --%s", SyncModeFlag.Name, CacheSnapshotFlag.Name)
 	}
 	if ctx.GlobalIsSet(DocRootFlag.Name) {
 		cfg.DocRoot = ctx.GlobalString(DocRootFlag.Name)
@@ -1810,6 +1915,27 @@ func MakeGenesis(ctx *cli.Context) *core.Genesis {
 	return genesis
 }
 
+// MakeEngine creates the consensus engine of a chain from set command line flags.
+func MakeEngine(ctx *cli.Context, stack *node.Node, config *params.ChainConfig, chainDb hykdb.Database) consensus.Engine {
+	if config.Clique != nil {
+		return clique.New(config.Clique, chainDb)
+	}
+	if ctx.GlobalBool(FakePoWFlag.Name) {
+		return hykash.NewFaker()
+	}
+	return hykash.New(hykash.Config{
+		CacheDir:         stack.ResolvePath(hyk.DefaultConfig.Hayekash.CacheDir),
+		CachesInMem:      hyk.DefaultConfig.Hayekash.CachesInMem,
+		CachesOnDisk:     hyk.DefaultConfig.Hayekash.CachesOnDisk,
+		CachesLockMmap:   hyk.DefaultConfig.Hayekash.CachesLockMmap,
+		DatasetDir:       stack.ResolvePath(hyk.DefaultConfig.Hayekash.DatasetDir),
+		DatasetsInMem:    hyk.DefaultConfig.Hayekash.DatasetsInMem,
+		DatasetsOnDisk:   hyk.DefaultConfig.Hayekash.DatasetsOnDisk,
+		DatasetsLockMmap: hyk.DefaultConfig.Hayekash.DatasetsLockMmap,
+		PowSwitches:      config.Hayekash,
+	}, nil, false)
+}
+
 // MakeChain creates a chain manager from set command line flags.
 func MakeChain(ctx *cli.Context, stack *node.Node, readOnly bool) (chain *core.BlockChain, chainDb hykdb.Database) {
 	var err error
@@ -1818,24 +1944,7 @@ func MakeChain(ctx *cli.Context, stack *node.Node, readOnly bool) (chain *core.B
 	if err != nil {
 		Fatalf("%v", err)
 	}
-	var engine consensus.Engine
-	if config.Clique != nil {
-		engine = clique.New(config.Clique, chainDb)
-	} else {
-		engine = hykash.NewFaker()
-		if !ctx.GlobalBool(FakePoWFlag.Name) {
-			engine = hykash.New(hykash.Config{
-				CacheDir:         stack.ResolvePath(hyk.DefaultConfig.Hayekash.CacheDir),
-				CachesInMem:      hyk.DefaultConfig.Hayekash.CachesInMem,
-				CachesOnDisk:     hyk.DefaultConfig.Hayekash.CachesOnDisk,
-				CachesLockMmap:   hyk.DefaultConfig.Hayekash.CachesLockMmap,
-				DatasetDir:       stack.ResolvePath(hyk.DefaultConfig.Hayekash.DatasetDir),
-				DatasetsInMem:    hyk.DefaultConfig.Hayekash.DatasetsInMem,
-				DatasetsOnDisk:   hyk.DefaultConfig.Hayekash.DatasetsOnDisk,
-				DatasetsLockMmap: hyk.DefaultConfig.Hayekash.DatasetsLockMmap,
-			}, nil, false)
-		}
-	}
+	engine := MakeEngine(ctx, stack, config, chainDb)
 	if gcmode := ctx.GlobalString(GCModeFlag.Name); gcmode != "full" && gcmode != "archive" {
 		Fatalf("--%s must be either 'full' or 'archive'", GCModeFlag.Name)
 	}
@@ -1847,6 +1956,8 @@ func MakeChain(ctx *cli.Context, stack *node.Node, readOnly bool) (chain *core.B
 		TrieTimeLimit:       hyk.DefaultConfig.TrieTimeout,
 		SnapshotLimit:       hyk.DefaultConfig.SnapshotCache,
 		Preimages:           ctx.GlobalBool(CachePreimagesFlag.Name),
+		ParallelExecution:   ctx.GlobalBool(ParallelExecutionFlag.Name),
+		StateDiffs:          ctx.GlobalBool(StateDiffsFlag.Name),
 	}
 	if cache.TrieDirtyDisabled && !cache.Preimages {
 		cache.Preimages = true
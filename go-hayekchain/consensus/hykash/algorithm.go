@@ -19,11 +19,6 @@ package hykash
 import (
 	"crypto/sha256"
 	"encoding/binary"
-	"fmt"
-
-	//"fmt"
-
-	//"fmt"
 	"hash"
 	"math/big"
 	"reflect"
@@ -339,7 +334,9 @@ func generateDataset(dest []uint32, epoch uint64, cache []uint32) {
 	pend.Wait()
 }
 
-func dsha256(hash []byte, nonce uint32, ts uint32, target uint32, merkroot []byte) ([]byte, []byte) {
+// dsha256Preimage assembles the 80 byte block header preimage hashed by the
+// double SHA256 proof-of-work.
+func dsha256Preimage(hash []byte, nonce uint32, ts uint32, target uint32, merkroot []byte) []byte {
 	/*
 	 *     total seed length is 80 byte
 	 *     seed in memory:
@@ -364,7 +361,6 @@ func dsha256(hash []byte, nonce uint32, ts uint32, target uint32, merkroot []byt
 	for i:= 0; i< mrLen; i++ {
 		reverseMerkroot = append(reverseMerkroot, merkroot[mrLen - i -1])
 	}
-	//fmt.Println("reverse merkroot", fmt.Sprintf("%x", reverseMerkroot))
 
 	copy(seed[4:4+32], reverseHash)
 	copy(seed[4+32:4+32+32], reverseMerkroot)
@@ -372,23 +368,21 @@ func dsha256(hash []byte, nonce uint32, ts uint32, target uint32, merkroot []byt
 	binary.LittleEndian.PutUint32(seed[4+32+32+4:4+32+32+4+4], target)
 	binary.LittleEndian.PutUint32(seed[4+32+32+4+4:4+32+32+4+4+4], nonce)
 
-	fmt.Println("nonce", nonce, "hex nonce", fmt.Sprintf("%x", nonce), "hex seed", fmt.Sprintf("%x", seed))
-	h := sha256.New()
-	h.Write(seed)
-	sum := h.Sum(nil)
-	//fmt.Println("once hash:", fmt.Sprintf("%x", sum))
+	return seed
+}
+
+func dsha256(hash []byte, nonce uint32, ts uint32, target uint32, merkroot []byte) ([]byte, []byte) {
+	seed := dsha256Preimage(hash, nonce, ts, target, merkroot)
 
-	dh := sha256.New()
-	dh.Write(sum)
-	dsum := dh.Sum(nil)
-	//fmt.Println("double hash:", fmt.Sprintf("%x", dsum))
+	sum := sha256.Sum256(seed)
+	dsum := sha256.Sum256(sum[:])
 
 	var resultReserve []byte
 	rLen := len(dsum)
 	for i:=0; i<rLen; i++ {
 		resultReserve = append(resultReserve, dsum[rLen-i-1])
 	}
-	return dsum, resultReserve
+	return dsum[:], resultReserve
 }
 
 // hashimoto aggregates data from the full dataset in order to produce our final
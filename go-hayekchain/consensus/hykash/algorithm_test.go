@@ -729,7 +729,7 @@ func TestConcurrentDiskCacheGeneration(t *testing.T) {
 
 		go func(idx int) {
 			defer pend.Done()
-			hykash := New(Config{cachedir, 0, 1, false, "", 0, 0, false, ModeNormal, nil}, nil, false)
+			hykash := New(Config{cachedir, 0, 1, false, "", 0, 0, false, ModeNormal, nil, "", 0, nil}, nil, false)
 			defer hykash.Close()
 			if err := hykash.VerifySeal(nil, block.Header()); err != nil {
 				t.Errorf("proc %d: block verification failed: %v", idx, err)
@@ -18,13 +18,17 @@ package hykash
 
 import (
 	"errors"
+	"math"
 
 	"github.com/hayekchain/go-hayekchain/common"
 	"github.com/hayekchain/go-hayekchain/common/hexutil"
 	"github.com/hayekchain/go-hayekchain/core/types"
 )
 
-var errHayekashStopped = errors.New("hykash stopped")
+var (
+	errHayekashStopped = errors.New("hykash stopped")
+	errInvalidNonce    = errors.New("nonce exceeds 32 bits")
+)
 
 // API exposes hykash related methods for the RPC interface.
 type API struct {
@@ -40,6 +44,9 @@ type API struct {
 //   result[3] - hex encoded block number
 //   result[4], 32 bytes hex encoded current block header stateRoot
 //   result[5], hex encoded block timestamp
+//
+// When sealing with double SHA256, result[1] is the parent hash and result[2]
+// the target expanded from the compact bits of the preimage.
 func (api *API) GetWork() ([6]string, error) {
 	if api.hykash.remote == nil {
 		return [6]string{}, errors.New("not supported")
@@ -85,6 +92,58 @@ func (api *API) SubmitWork(nonce types.BlockNonce, hash, digest common.Hash) boo
 	return err == nil
 }
 
+// GetAuxWork returns a merged mining work package for double SHA256 pools,
+// mirroring the createauxblock call of merge mined chains. The pool commits to
+// the returned hash in the coinbase of its parent chain block template.
+func (api *API) GetAuxWork() (*AuxWork, error) {
+	if api.hykash.remote == nil {
+		return nil, errors.New("not supported")
+	}
+
+	var (
+		workCh = make(chan *AuxWork, 1)
+		errc   = make(chan error, 1)
+	)
+	select {
+	case api.hykash.remote.fetchAuxCh <- &auxSealWork{errc: errc, res: workCh}:
+	case <-api.hykash.remote.exitCh:
+		return nil, errHayekashStopped
+	}
+	select {
+	case work := <-workCh:
+		return work, nil
+	case err := <-errc:
+		return nil, err
+	}
+}
+
+// SubmitAuxWork can be used by merged mining pools to submit the parent chain
+// proof-of-work of a work package, mirroring the submitauxblock call of merge
+// mined chains. The proof is expected in the standard auxpow serialization.
+// It returns an indication if the work was accepted.
+func (api *API) SubmitAuxWork(hash common.Hash, auxpow hexutil.Bytes) bool {
+	if api.hykash.remote == nil {
+		return false
+	}
+	proof, err := decodeAuxPow(auxpow)
+	if err != nil {
+		api.hykash.config.Log.Warn("Invalid merged mining proof submitted", "sealhash", hash, "err", err)
+		return false
+	}
+	var errc = make(chan error, 1)
+	select {
+	case api.hykash.remote.submitAuxCh <- &auxResult{
+		auxpow: proof,
+		hash:   hash,
+		errc:   errc,
+	}:
+	case <-api.hykash.remote.exitCh:
+		return false
+	}
+	err = <-errc
+	return err == nil
+}
+
 // SubmitHashrate can be used for remote miners to submit their hash rate.
 // This enables the node to report the combined hash rate of all miners
 // which submit work through this node.
@@ -112,3 +171,31 @@ func (api *API) SubmitHashRate(rate hexutil.Uint64, id common.Hash) bool {
 func (api *API) GetHashrate() uint64 {
 	return uint64(api.hykash.Hashrate())
 }
+
+// GetStratumWorkers returns the share accounting of the workers mining through
+// the stratum server.
+func (api *API) GetStratumWorkers() (map[string]StratumWorker, error) {
+	if api.hykash.stratum == nil {
+		return nil, errStratumNotRunning
+	}
+	return api.hykash.StratumWorkers(), nil
+}
+
+// DebugAPI exposes hykash proof-of-work diagnostics for the debug namespace.
+type DebugAPI struct {
+	hykash *Hayekash
+}
+
+// HykashPreimage returns the exact 80 byte double SHA256 preimage of a header
+// for the given nonce, allowing a seal to be verified offline. If no nonce is
+// given, the one in the header is used.
+func (api *DebugAPI) HykashPreimage(header *types.Header, nonce *hexutil.Uint64) (*PowPreimage, error) {
+	n := header.Nonce.Uint64()
+	if nonce != nil {
+		n = uint64(*nonce)
+	}
+	if n > math.MaxUint32 {
+		return nil, errInvalidNonce
+	}
+	return api.hykash.Preimage(header, uint32(n)), nil
+}
@@ -0,0 +1,317 @@
+// Copyright 2021 The go-hayekchain Authors
+// This file is part of the go-hayekchain library.
+//
+// The go-hayekchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hayekchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hayekchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package hykash
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/hayekchain/go-hayekchain/common"
+	"github.com/hayekchain/go-hayekchain/common/hexutil"
+	"github.com/hayekchain/go-hayekchain/core/types"
+)
+
+// Merged mining follows the auxiliary proof-of-work scheme of Namecoin, so that
+// double SHA256 pools can secure the chain with their existing tooling. A block
+// is sealed by a parent chain block whose coinbase transaction contains
+//
+//   magic  - 0xfabe6d6d ("\xfa\xbemm")
+//   root   - merged mining tree root over the seal hashes of the auxiliary chains
+//   size   - number of leaves of the merged mining tree, little endian uint32
+//   nonce  - merged mining tree nonce, little endian uint32
+//
+// and whose header meets the compact target of the block. Each auxiliary chain
+// occupies the tree slot derived from its chain ID and the tree nonce.
+const auxPowMaxBranch = 30 // Maximum depth of the merkle branches of a proof
+
+// auxPowMagic marks the merged mining commitment in the parent coinbase.
+var auxPowMagic = []byte{0xfa, 0xbe, 'm', 'm'}
+
+var (
+	errAuxPowInactive   = errors.New("merged mining not active")
+	errAuxPowNonce      = errors.New("merge mined header with nonce")
+	errAuxPowHeader     = errors.New("invalid auxpow parent header")
+	errAuxPowBranch     = errors.New("auxpow merkle branch too deep")
+	errAuxPowCoinbase   = errors.New("auxpow coinbase not in parent block")
+	errAuxPowCommitment = errors.New("auxpow commitment not in coinbase")
+	errAuxPowChainIndex = errors.New("auxpow chain index mismatch")
+)
+
+// AuxWork is a merged mining work package, mirroring the createauxblock call of
+// merge mined chains.
+type AuxWork struct {
+	Hash              common.Hash    `json:"hash"`              // Merged mining seal hash the parent coinbase commits to
+	ChainID           hexutil.Uint64 `json:"chainid"`           // Chain ID selecting the merged mining tree slot
+	PreviousBlockHash common.Hash    `json:"previousblockhash"` // Parent hash of the block being sealed
+	Bits              hexutil.Uint64 `json:"bits"`              // Compact target the parent header must meet
+	Target            common.Hash    `json:"target"`            // Target expanded from the bits, in big endian order
+	Height            hexutil.Uint64 `json:"height"`            // Number of the block being sealed
+}
+
+// auxWork creates the merged mining work package sealing a block.
+func (hykash *Hayekash) auxWork(block *types.Block) (*AuxWork, error) {
+	if !hykash.config.PowSwitches.IsAuxPow(block.Number()) || hykash.algorithm(block.Number()) != AlgorithmSHA256d {
+		return nil, errAuxPowInactive
+	}
+	bits := hykash.compactTarget(block.Difficulty())
+	return &AuxWork{
+		Hash:              auxSealHash(block.Header()),
+		ChainID:           hexutil.Uint64(hykash.config.PowSwitches.AuxPowChainID),
+		PreviousBlockHash: block.ParentHash(),
+		Bits:              hexutil.Uint64(bits),
+		Target:            common.BigToHash(CompactToBig(bits)),
+		Height:            hexutil.Uint64(block.NumberU64()),
+	}, nil
+}
+
+// AuxPowHash returns the double SHA256 proof-of-work hash of the parent header of
+// a merged mining proof, in big endian order.
+func AuxPowHash(auxpow *types.AuxPow) common.Hash {
+	sum := dsha256Sum(auxpow.ParentHeader)
+	return common.BytesToHash(reverseBytes(sum[:]))
+}
+
+// verifyAuxPow checks whether a header is sealed by a valid merged mining proof
+// meeting its compact target. Merge mined seals carry no work of their own, so
+// the nonce must be left empty to avoid malleable seals, and the mix digest
+// carries the proof-of-work hash of the parent header.
+func (hykash *Hayekash) verifyAuxPow(header *types.Header) error {
+	if !hykash.config.PowSwitches.IsAuxPow(header.Number) || hykash.algorithm(header.Number) != AlgorithmSHA256d {
+		return errAuxPowInactive
+	}
+	if header.Nonce != (types.BlockNonce{}) {
+		return errAuxPowNonce
+	}
+	auxpow := header.AuxPow
+	if len(auxpow.ParentHeader) != 80 {
+		return errAuxPowHeader
+	}
+	if len(auxpow.CoinbaseBranch) > auxPowMaxBranch || len(auxpow.ChainBranch) > auxPowMaxBranch {
+		return errAuxPowBranch
+	}
+	// The coinbase is the first transaction of the parent block
+	root := auxMerkleRoot(dsha256Sum(auxpow.Coinbase), auxpow.CoinbaseBranch, 0)
+	if !bytes.Equal(root[:], auxpow.ParentHeader[36:68]) {
+		return errAuxPowCoinbase
+	}
+	if err := hykash.verifyAuxCommitment(header, auxpow); err != nil {
+		return err
+	}
+	// The parent header must meet the target of the block
+	result := AuxPowHash(auxpow)
+	if header.MixDigest != result {
+		return errInvalidMixDigest
+	}
+	if !CheckPOW(result[:], hykash.compactTarget(header.Difficulty)) {
+		return errInvalidPoW
+	}
+	return nil
+}
+
+// verifyAuxCommitment checks whether the coinbase of a merged mining proof
+// commits to the merged mining seal hash of the header, timestamp included, in
+// the tree slot of the chain.
+func (hykash *Hayekash) verifyAuxCommitment(header *types.Header, auxpow *types.AuxPow) error {
+	var leaf [32]byte
+	copy(leaf[:], reverseBytes(auxSealHash(header).Bytes()))
+	root := auxMerkleRoot(leaf, auxpow.ChainBranch, auxpow.ChainIndex)
+
+	// A second commitment could smuggle in another tree, allow a single one only
+	pos := bytes.Index(auxpow.Coinbase, auxPowMagic)
+	if pos < 0 || bytes.Contains(auxpow.Coinbase[pos+len(auxPowMagic):], auxPowMagic) {
+		return errAuxPowCommitment
+	}
+	commitment := auxpow.Coinbase[pos+len(auxPowMagic):]
+	if len(commitment) < 40 || !bytes.Equal(commitment[:32], reverseBytes(root[:])) {
+		return errAuxPowCommitment
+	}
+	var (
+		size  = binary.LittleEndian.Uint32(commitment[32:])
+		nonce = binary.LittleEndian.Uint32(commitment[36:])
+	)
+	if uint64(size) != uint64(1)<<uint(len(auxpow.ChainBranch)) {
+		return errAuxPowChainIndex
+	}
+	if auxpow.ChainIndex != auxChainIndex(nonce, hykash.config.PowSwitches.AuxPowChainID, len(auxpow.ChainBranch)) {
+		return errAuxPowChainIndex
+	}
+	return nil
+}
+
+// dsha256Sum returns the double SHA256 hash of data, in internal byte order.
+func dsha256Sum(data []byte) [32]byte {
+	sum := sha256.Sum256(data)
+	return sha256.Sum256(sum[:])
+}
+
+// auxMerkleRoot folds a merkle branch into the root of the tree holding the leaf
+// at the given index, hashing the nodes the way the parent chain does.
+func auxMerkleRoot(leaf [32]byte, branch []common.Hash, index uint32) [32]byte {
+	var pair [64]byte
+	for _, sibling := range branch {
+		if index&1 == 1 {
+			copy(pair[:32], sibling[:])
+			copy(pair[32:], leaf[:])
+		} else {
+			copy(pair[:32], leaf[:])
+			copy(pair[32:], sibling[:])
+		}
+		leaf = dsha256Sum(pair[:])
+		index >>= 1
+	}
+	return leaf
+}
+
+// auxChainIndex returns the slot of a chain in a merged mining tree of the given
+// height, derived from the tree nonce and the chain ID like merged mining pools
+// do. Fixing the slot prevents a single tree committing to competing blocks.
+func auxChainIndex(nonce uint32, chainID uint32, height int) uint32 {
+	rand := nonce
+	rand = rand*1103515245 + 12345
+	rand += chainID
+	rand = rand*1103515245 + 12345
+	return rand % (uint32(1) << uint(height))
+}
+
+// auxPowReader decodes the parent chain serialization of merged mining proofs.
+type auxPowReader struct {
+	blob []byte
+	pos  int
+	err  error
+}
+
+// read returns the next n bytes, or nil once the input is exhausted.
+func (r *auxPowReader) read(n uint64) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n > uint64(len(r.blob)-r.pos) {
+		r.err = errors.New("unexpected end of auxpow")
+		return nil
+	}
+	b := r.blob[r.pos : r.pos+int(n)]
+	r.pos += int(n)
+	return b
+}
+
+// varint decodes a parent chain compact size integer.
+func (r *auxPowReader) varint() uint64 {
+	b := r.read(1)
+	if b == nil {
+		return 0
+	}
+	switch b[0] {
+	case 0xfd:
+		if b = r.read(2); b != nil {
+			return uint64(binary.LittleEndian.Uint16(b))
+		}
+	case 0xfe:
+		if b = r.read(4); b != nil {
+			return uint64(binary.LittleEndian.Uint32(b))
+		}
+	case 0xff:
+		if b = r.read(8); b != nil {
+			return binary.LittleEndian.Uint64(b)
+		}
+	default:
+		return uint64(b[0])
+	}
+	return 0
+}
+
+// bytes decodes a length prefixed byte string.
+func (r *auxPowReader) bytes() []byte {
+	return r.read(r.varint())
+}
+
+// uint32 decodes a little endian 32 bit integer.
+func (r *auxPowReader) uint32() uint32 {
+	if b := r.read(4); b != nil {
+		return binary.LittleEndian.Uint32(b)
+	}
+	return 0
+}
+
+// branch decodes a merkle branch.
+func (r *auxPowReader) branch() []common.Hash {
+	n := r.varint()
+	if n > auxPowMaxBranch {
+		r.err = errAuxPowBranch
+		return nil
+	}
+	branch := make([]common.Hash, n)
+	for i := range branch {
+		copy(branch[i][:], r.read(common.HashLength))
+	}
+	return branch
+}
+
+// transaction skips over a transaction without witness data, returning its
+// serialization.
+func (r *auxPowReader) transaction() []byte {
+	start := r.pos
+	r.read(4) // version
+	inputs := r.varint()
+	if inputs == 0 && r.err == nil {
+		r.err = errors.New("witness serialized auxpow coinbase")
+		return nil
+	}
+	for i := uint64(0); i < inputs && r.err == nil; i++ {
+		r.read(36) // previous output
+		r.bytes()  // signature script
+		r.read(4)  // sequence
+	}
+	outputs := r.varint()
+	for i := uint64(0); i < outputs && r.err == nil; i++ {
+		r.read(8) // value
+		r.bytes() // public key script
+	}
+	r.read(4) // lock time
+	if r.err != nil {
+		return nil
+	}
+	return r.blob[start:r.pos]
+}
+
+// decodeAuxPow decodes a merged mining proof from the serialization used by the
+// submitauxblock call of merge mined chains: the parent coinbase transaction,
+// the parent block hash, the coinbase merkle branch and index, the merged mining
+// merkle branch and index and the 80 byte parent header.
+func decodeAuxPow(blob []byte) (*types.AuxPow, error) {
+	r := &auxPowReader{blob: blob}
+
+	auxpow := &types.AuxPow{Coinbase: common.CopyBytes(r.transaction())}
+	r.read(common.HashLength) // parent block hash, implied by the parent header
+	auxpow.CoinbaseBranch = r.branch()
+	if index := r.uint32(); index != 0 && r.err == nil {
+		r.err = errAuxPowCoinbase
+	}
+	auxpow.ChainBranch = r.branch()
+	auxpow.ChainIndex = r.uint32()
+	auxpow.ParentHeader = common.CopyBytes(r.read(80))
+
+	if r.err == nil && r.pos != len(blob) {
+		r.err = fmt.Errorf("%d trailing auxpow bytes", len(blob)-r.pos)
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return auxpow, nil
+}
@@ -0,0 +1,249 @@
+// Copyright 2021 The go-hayekchain Authors
+// This file is part of the go-hayekchain library.
+//
+// The go-hayekchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hayekchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hayekchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package hykash
+
+import (
+	"encoding/binary"
+	"math/big"
+	"math/rand"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hayekchain/go-hayekchain/common"
+	"github.com/hayekchain/go-hayekchain/core/types"
+	"github.com/hayekchain/go-hayekchain/rlp"
+)
+
+// newAuxPowTester creates a test mode double SHA256 engine accepting merge mined
+// seals from block 1 onwards.
+func newAuxPowTester() *Hayekash {
+	hykash := NewTester(nil, false)
+	hykash.config.PowSwitches = sha256dRules()
+	hykash.config.PowSwitches.AuxPowBlock = big.NewInt(1)
+	hykash.config.PowSwitches.AuxPowChainID = 7
+	return hykash
+}
+
+// makeAuxPow merge mines a header on a fake parent chain block, placing it into
+// a merged mining tree of the given height.
+func makeAuxPow(hykash *Hayekash, header *types.Header, height int) *types.AuxPow {
+	// Build the merged mining tree with the header in the chain's slot
+	treeNonce := rand.Uint32()
+	index := auxChainIndex(treeNonce, hykash.config.PowSwitches.AuxPowChainID, height)
+
+	var leaf [32]byte
+	copy(leaf[:], reverseBytes(auxSealHash(header).Bytes()))
+	chainBranch := make([]common.Hash, height)
+	for i := range chainBranch {
+		rand.Read(chainBranch[i][:])
+	}
+	root := auxMerkleRoot(leaf, chainBranch, index)
+
+	// Commit to the tree in a coinbase with a single input and output
+	script := append([]byte{0x03, 0x01, 0x02, 0x03}, auxPowMagic...)
+	script = append(script, reverseBytes(root[:])...)
+	script = append(script, make([]byte, 8)...)
+	binary.LittleEndian.PutUint32(script[len(script)-8:], uint32(1)<<uint(height))
+	binary.LittleEndian.PutUint32(script[len(script)-4:], treeNonce)
+
+	coinbase := []byte{0x01, 0x00, 0x00, 0x00, 0x01}
+	coinbase = append(coinbase, make([]byte, 36)...)
+	coinbase = append(coinbase, byte(len(script)))
+	coinbase = append(coinbase, script...)
+	coinbase = append(coinbase, 0xff, 0xff, 0xff, 0xff, 0x01)
+	coinbase = append(coinbase, make([]byte, 8)...)
+	coinbase = append(coinbase, 0x01, 0x51)
+	coinbase = append(coinbase, make([]byte, 4)...)
+
+	// Place the coinbase into a parent block and grind its nonce
+	coinbaseBranch := make([]common.Hash, 2)
+	for i := range coinbaseBranch {
+		rand.Read(coinbaseBranch[i][:])
+	}
+	merkleRoot := auxMerkleRoot(dsha256Sum(coinbase), coinbaseBranch, 0)
+
+	parent := make([]byte, 80)
+	binary.LittleEndian.PutUint32(parent, 0x20000000)
+	rand.Read(parent[4:36])
+	copy(parent[36:68], merkleRoot[:])
+	binary.LittleEndian.PutUint32(parent[68:], uint32(header.Time))
+	binary.LittleEndian.PutUint32(parent[72:], 0x1d00ffff)
+
+	auxpow := &types.AuxPow{
+		ParentHeader:   parent,
+		Coinbase:       coinbase,
+		CoinbaseBranch: coinbaseBranch,
+		ChainBranch:    chainBranch,
+		ChainIndex:     index,
+	}
+	bits := hykash.compactTarget(header.Difficulty)
+	for nonce := uint32(0); ; nonce++ {
+		binary.LittleEndian.PutUint32(parent[76:], nonce)
+		if hash := AuxPowHash(auxpow); CheckPOW(hash[:], bits) {
+			return auxpow
+		}
+	}
+}
+
+// encodeAuxPow serializes a merged mining proof like merged mining pools do.
+func encodeAuxPow(auxpow *types.AuxPow) []byte {
+	blob := append([]byte{}, auxpow.Coinbase...)
+	blob = append(blob, make([]byte, common.HashLength)...)
+	blob = append(blob, byte(len(auxpow.CoinbaseBranch)))
+	for _, hash := range auxpow.CoinbaseBranch {
+		blob = append(blob, hash[:]...)
+	}
+	blob = append(blob, 0, 0, 0, 0)
+	blob = append(blob, byte(len(auxpow.ChainBranch)))
+	for _, hash := range auxpow.ChainBranch {
+		blob = append(blob, hash[:]...)
+	}
+	blob = append(blob, make([]byte, 4)...)
+	binary.LittleEndian.PutUint32(blob[len(blob)-4:], auxpow.ChainIndex)
+	return append(blob, auxpow.ParentHeader...)
+}
+
+// Tests that merge mined seals are verified against the parent chain block and
+// the merged mining commitment.
+func TestAuxPowVerify(t *testing.T) {
+	hykash := newAuxPowTester()
+	defer hykash.Close()
+
+	header := &types.Header{Number: big.NewInt(1), Difficulty: big.NewInt(100), Time: 1600000000}
+	header.AuxPow = makeAuxPow(hykash, header, 3)
+	header.MixDigest = AuxPowHash(header.AuxPow)
+
+	if err := hykash.VerifySeal(nil, header); err != nil {
+		t.Fatalf("valid merge mined seal rejected: %v", err)
+	}
+	tests := []struct {
+		tamper func(header *types.Header)
+		err    error
+	}{
+		{func(h *types.Header) { h.Number = big.NewInt(0) }, errAuxPowInactive},
+		{func(h *types.Header) { h.Nonce = types.EncodeNonce(1) }, errAuxPowNonce},
+		{func(h *types.Header) { h.AuxPow.ParentHeader = h.AuxPow.ParentHeader[:79] }, errAuxPowHeader},
+		{func(h *types.Header) { h.AuxPow.CoinbaseBranch = make([]common.Hash, auxPowMaxBranch+1) }, errAuxPowBranch},
+		{func(h *types.Header) { h.AuxPow.Coinbase[0]++ }, errAuxPowCoinbase},
+		{func(h *types.Header) { h.Coinbase = common.Address{0x01} }, errAuxPowCommitment},
+		{func(h *types.Header) { h.Time++ }, errAuxPowCommitment}, // Parent header holds the parent chain's time
+		{func(h *types.Header) { h.AuxPow.ChainIndex ^= 1 }, errAuxPowCommitment},
+		{func(h *types.Header) { h.MixDigest = common.Hash{} }, errInvalidMixDigest},
+		{func(h *types.Header) {
+			for bits := hykash.compactTarget(h.Difficulty); ; h.AuxPow.ParentHeader[76]++ {
+				if h.MixDigest = AuxPowHash(h.AuxPow); !CheckPOW(h.MixDigest[:], bits) {
+					return
+				}
+			}
+		}, errInvalidPoW},
+	}
+	for i, tt := range tests {
+		tampered := types.CopyHeader(header)
+		tt.tamper(tampered)
+		if err := hykash.VerifySeal(nil, tampered); err != tt.err {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, tt.err)
+		}
+	}
+	// Another chain's slot of the same tree must not be accepted
+	for id := uint32(8); ; id++ {
+		hykash.config.PowSwitches.AuxPowChainID = id
+		if err := hykash.VerifySeal(nil, header); err == errAuxPowChainIndex {
+			break
+		} else if err != nil || id == 100 {
+			t.Fatalf("foreign chain slot error mismatch: have %v, want %v", err, errAuxPowChainIndex)
+		}
+	}
+}
+
+// Tests that merged mining proofs survive the pool serialization, and that
+// malformed ones are rejected.
+func TestDecodeAuxPow(t *testing.T) {
+	hykash := newAuxPowTester()
+	defer hykash.Close()
+
+	header := &types.Header{Number: big.NewInt(1), Difficulty: big.NewInt(100), Time: 1600000000}
+	auxpow := makeAuxPow(hykash, header, 2)
+	blob := encodeAuxPow(auxpow)
+
+	decoded, err := decodeAuxPow(blob)
+	if err != nil {
+		t.Fatalf("failed to decode proof: %v", err)
+	}
+	if !reflect.DeepEqual(decoded, auxpow) {
+		t.Fatalf("decoded proof mismatch: have %+v, want %+v", decoded, auxpow)
+	}
+	if _, err := decodeAuxPow(blob[:len(blob)-1]); err == nil {
+		t.Errorf("truncated proof accepted")
+	}
+	if _, err := decodeAuxPow(append(blob, 0x00)); err == nil {
+		t.Errorf("proof with trailing bytes accepted")
+	}
+	witness := append(append([]byte{}, blob[:4]...), append([]byte{0x00, 0x01}, blob[4:]...)...)
+	if _, err := decodeAuxPow(witness); err == nil {
+		t.Errorf("witness serialized coinbase accepted")
+	}
+}
+
+// Tests that merged mining pools can fetch work and submit proofs through the
+// remote sealer, and that the sealed header survives its RLP encoding.
+func TestRemoteAuxWork(t *testing.T) {
+	hykash := newAuxPowTester()
+	hykash.SetThreads(-1)
+	defer hykash.Close()
+
+	api := &API{hykash}
+	if _, err := api.GetAuxWork(); err != errNoMiningWork {
+		t.Fatalf("pending work error mismatch: have %v, want %v", err, errNoMiningWork)
+	}
+	header := &types.Header{Number: big.NewInt(1), Difficulty: big.NewInt(100), Time: 1600000000}
+	results := make(chan *types.Block, 1)
+	hykash.Seal(nil, types.NewBlockWithHeader(header), results, nil)
+
+	work, err := api.GetAuxWork()
+	if err != nil {
+		t.Fatalf("failed to retrieve merged mining work: %v", err)
+	}
+	if work.Hash != auxSealHash(header) || uint32(work.ChainID) != 7 || uint64(work.Height) != 1 {
+		t.Fatalf("merged mining work mismatch: %+v", work)
+	}
+	if api.SubmitAuxWork(work.Hash, []byte{0x01}) {
+		t.Fatalf("malformed proof accepted")
+	}
+	if !api.SubmitAuxWork(work.Hash, encodeAuxPow(makeAuxPow(hykash, header, 0))) {
+		t.Fatalf("valid proof rejected")
+	}
+	select {
+	case block := <-results:
+		blob, err := rlp.EncodeToBytes(block.Header())
+		if err != nil {
+			t.Fatalf("failed to encode sealed header: %v", err)
+		}
+		decoded := new(types.Header)
+		if err := rlp.DecodeBytes(blob, decoded); err != nil {
+			t.Fatalf("failed to decode sealed header: %v", err)
+		}
+		if decoded.Hash() != block.Hash() || decoded.AuxPow == nil {
+			t.Fatalf("sealed header lost its proof in encoding")
+		}
+		if err := hykash.VerifySeal(nil, decoded); err != nil {
+			t.Fatalf("sealed header rejected: %v", err)
+		}
+	case <-time.NewTimer(2 * time.Second).C:
+		t.Fatalf("sealing result timeout")
+	}
+}
@@ -46,7 +46,6 @@ func GetCompact(b *big.Int) uint32 {
 	size := uint32(len(b.Bytes()))
 	var compact uint32
 
-	fmt.Println("GetCompact", "difficulty", b, "size", size)
 	if size <= 3 {
 		compact = uint32(b.Int64() << uint(8*(3-size)))
 	} else {
@@ -84,3 +84,41 @@ func TestCalcDifficulty(t *testing.T) {
 		}
 	}
 }
+
+// Tests that the block reward follows the configured schedule and halvings,
+// falling back to the stock fork rewards outside of it.
+func TestBlockReward(t *testing.T) {
+	config := &params.ChainConfig{
+		ByzantiumBlock: big.NewInt(5),
+		Hayekash: &params.HayekashConfig{
+			Rewards: []params.HayekashReward{
+				{Block: big.NewInt(10), Reward: big.NewInt(800), HalvingInterval: 100},
+				{Block: big.NewInt(1000), Reward: big.NewInt(25)},
+			},
+		},
+	}
+	tests := []struct {
+		number int64
+		reward *big.Int
+	}{
+		{0, FrontierBlockReward},
+		{5, ByzantiumBlockReward},
+		{10, big.NewInt(800)},
+		{109, big.NewInt(800)},
+		{110, big.NewInt(400)},
+		{310, big.NewInt(100)},
+		{999, big.NewInt(1)},
+		{1000, big.NewInt(25)},
+		{1000000, big.NewInt(25)},
+	}
+	for i, tt := range tests {
+		if reward := BlockReward(config, big.NewInt(tt.number)); reward.Cmp(tt.reward) != 0 {
+			t.Errorf("test %d: reward mismatch: have %v, want %v", i, reward, tt.reward)
+		}
+	}
+	// Rewards must run out instead of wrapping after enough halvings
+	config.Hayekash.Rewards = config.Hayekash.Rewards[:1]
+	if reward := BlockReward(config, big.NewInt(1000000)); reward.Sign() != 0 {
+		t.Errorf("exhausted reward mismatch: have %v, want 0", reward)
+	}
+}
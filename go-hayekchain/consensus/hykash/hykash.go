@@ -37,6 +37,7 @@ import (
 	"github.com/hayekchain/go-hayekchain/consensus"
 	"github.com/hayekchain/go-hayekchain/log"
 	"github.com/hayekchain/go-hayekchain/metrics"
+	"github.com/hayekchain/go-hayekchain/params"
 	"github.com/hayekchain/go-hayekchain/rpc"
 	"github.com/hashicorp/golang-lru/simplelru"
 )
@@ -47,8 +48,11 @@ var (
 	// two256 is a big integer representing 2^256
 	two256 = new(big.Int).Exp(big.NewInt(2), big.NewInt(256), big.NewInt(0))
 
+	// two256m1 is a big integer representing 2^256-1
+	two256m1 = new(big.Int).Sub(two256, big.NewInt(1))
+
 	// sharedHayekash is a full instance that can be shared between multiple users.
-	sharedHayekash = New(Config{"", 3, 0, false, "", 1, 0, false, ModeNormal, nil}, nil, false)
+	sharedHayekash = New(Config{"", 3, 0, false, "", 1, 0, false, ModeNormal, nil, "", 0, nil}, nil, false)
 
 	// algorithmRevision is the data structure version used for file naming.
 	algorithmRevision = 23
@@ -399,6 +403,15 @@ const (
 	ModeFullFake
 )
 
+// Algorithm defines the proof-of-work function an hykash engine seals and
+// verifies blocks with.
+type Algorithm uint
+
+const (
+	AlgorithmHashimoto Algorithm = iota // Memory hard hashimoto over an epoch DAG
+	AlgorithmSHA256d                    // Bitcoin style double-SHA256 over an 80 byte header
+)
+
 // Config are the configuration parameters of the hykash.
 type Config struct {
 	CacheDir         string
@@ -411,6 +424,13 @@ type Config struct {
 	DatasetsLockMmap bool
 	PowMode          Mode
 
+	// Algorithm switches and merged mining rules of the chain. Blocks before
+	// the first algorithm switch are sealed with hashimoto.
+	PowSwitches *params.HayekashConfig `toml:"-"`
+
+	StratumAddr       string // Listener address of the stratum server, empty to disable
+	StratumDifficulty uint64 // Initial share difficulty of stratum sessions
+
 	Log log.Logger `toml:"-"`
 }
 
@@ -428,6 +448,7 @@ type Hayekash struct {
 	update   chan struct{} // Notification channel to update mining parameters
 	hashrate metrics.Meter // Meter tracking the average hashrate
 	remote   *remoteSealer
+	stratum  *stratumServer
 
 	// The fields below are hooks for testing
 	shared    *Hayekash       // Shared PoW verifier to avoid cache regeneration
@@ -463,6 +484,16 @@ func New(config Config, notify []string, noverify bool) *Hayekash {
 		hashrate: metrics.NewMeterForced(),
 	}
 	hykash.remote = startRemoteSealer(hykash, notify, noverify)
+
+	if config.StratumAddr != "" {
+		if !hykash.sealsSHA256d() {
+			config.Log.Warn("Stratum mining requires double SHA256 sealing", "addr", config.StratumAddr)
+		} else if stratum, err := startStratumServer(hykash, config.StratumAddr, config.StratumDifficulty); err != nil {
+			config.Log.Error("Failed to start stratum server", "addr", config.StratumAddr, "err", err)
+		} else {
+			hykash.stratum = stratum
+		}
+	}
 	return hykash
 }
 
@@ -543,6 +574,9 @@ func (hykash *Hayekash) Close() error {
 		if hykash.remote == nil {
 			return
 		}
+		if hykash.stratum != nil {
+			hykash.stratum.close()
+		}
 		close(hykash.remote.requestExit)
 		<-hykash.remote.exitCh
 	})
@@ -561,7 +595,7 @@ func (hykash *Hayekash) cache(block uint64) *cache {
 	current.generate(hykash.config.CacheDir, hykash.config.CachesOnDisk, hykash.config.CachesLockMmap, hykash.config.PowMode == ModeTest)
 
 	// If we need a new future cache, now's a good time to regenerate it.
-	if futureI != nil {
+	if futureI != nil && hykash.hashimotoEpoch(epoch+1) {
 		future := futureI.(*cache)
 		go future.generate(hykash.config.CacheDir, hykash.config.CachesOnDisk, hykash.config.CachesLockMmap, hykash.config.PowMode == ModeTest)
 	}
@@ -580,6 +614,11 @@ func (hykash *Hayekash) dataset(block uint64, async bool) *dataset {
 	currentI, futureI := hykash.datasets.get(epoch)
 	current := currentI.(*dataset)
 
+	// Don't pregenerate the future DAG if it's past a double SHA256 switch
+	if !hykash.hashimotoEpoch(epoch + 1) {
+		futureI = nil
+	}
+
 	// If async is specified, generate everything in a background thread
 	if async && !current.generated() {
 		go func() {
@@ -602,6 +641,36 @@ func (hykash *Hayekash) dataset(block uint64, async bool) *dataset {
 	return current
 }
 
+// algorithm returns the proof-of-work algorithm sealing and verifying the block
+// with the given number. It only depends on the chain's algorithm switches, so
+// all nodes agree on it, defaulting to hashimoto until the first switch.
+func (hykash *Hayekash) algorithm(number *big.Int) Algorithm {
+	if hykash.config.PowSwitches.Algorithm(number) == params.PowSHA256d {
+		return AlgorithmSHA256d
+	}
+	return AlgorithmHashimoto
+}
+
+// hashimotoEpoch reports whether the first block of an epoch is sealed with
+// hashimoto. Caches and DAGs of later epochs are only worth generating ahead of
+// time if the chain didn't switch away from hashimoto by then.
+func (hykash *Hayekash) hashimotoEpoch(epoch uint64) bool {
+	return hykash.algorithm(new(big.Int).SetUint64(epoch*epochLength)) == AlgorithmHashimoto
+}
+
+// sealsSHA256d reports whether any block of the chain is sealed with double
+// SHA256 after an algorithm switch.
+func (hykash *Hayekash) sealsSHA256d() bool {
+	if hykash.config.PowSwitches != nil {
+		for _, rule := range hykash.config.PowSwitches.Algorithms {
+			if rule.Algorithm == params.PowSHA256d {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // Threads returns the number of mining threads currently enabled. This doesn't
 // necessarily mean that mining is running!
 func (hykash *Hayekash) Threads() int {
@@ -655,6 +724,15 @@ func (hykash *Hayekash) Hashrate() float64 {
 	return hykash.hashrate.Rate1() + float64(<-res)
 }
 
+// StratumWorkers returns the share accounting of the workers mining through the
+// stratum server, or nil if it is not running.
+func (hykash *Hayekash) StratumWorkers() map[string]StratumWorker {
+	if hykash.stratum == nil {
+		return nil
+	}
+	return hykash.stratum.stats()
+}
+
 // APIs implements consensus.Engine, returning the user facing RPC APIs.
 func (hykash *Hayekash) APIs(chain consensus.ChainHeaderReader) []rpc.API {
 	// In order to ensure backward compatibility, we exposes hykash RPC APIs
@@ -672,6 +750,11 @@ func (hykash *Hayekash) APIs(chain consensus.ChainHeaderReader) []rpc.API {
 			Service:   &API{hykash},
 			Public:    true,
 		},
+		{
+			Namespace: "debug",
+			Version:   "1.0",
+			Service:   &DebugAPI{hykash},
+		},
 	}
 }
 
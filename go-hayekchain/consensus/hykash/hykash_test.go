@@ -17,6 +17,8 @@
 package hykash
 
 import (
+	"crypto/sha256"
+	"encoding/binary"
 	"io/ioutil"
 	"math/big"
 	"math/rand"
@@ -28,8 +30,16 @@ import (
 	"github.com/hayekchain/go-hayekchain/common"
 	"github.com/hayekchain/go-hayekchain/common/hexutil"
 	"github.com/hayekchain/go-hayekchain/core/types"
+	"github.com/hayekchain/go-hayekchain/params"
 )
 
+// sha256dRules returns chain rules sealing every block with double SHA256.
+func sha256dRules() *params.HayekashConfig {
+	return &params.HayekashConfig{Algorithms: []params.HayekashAlgorithm{
+		{Block: common.Big0, Algorithm: params.PowSHA256d},
+	}}
+}
+
 // Tests that hykash works correctly in test mode.
 func TestTestMode(t *testing.T) {
 	header := &types.Header{Number: big.NewInt(1), Difficulty: big.NewInt(100)}
@@ -54,6 +64,135 @@ func TestTestMode(t *testing.T) {
 	}
 }
 
+// Tests that double SHA256 sealing works correctly in test mode and that the
+// seal commits to the header contents.
+func TestTestModeSHA256d(t *testing.T) {
+	header := &types.Header{Number: big.NewInt(1), Difficulty: big.NewInt(100), Time: 1600000000}
+
+	hykash := NewTester(nil, false)
+	hykash.config.PowSwitches = sha256dRules()
+	defer hykash.Close()
+
+	results := make(chan *types.Block)
+	err := hykash.Seal(nil, types.NewBlockWithHeader(header), results, nil)
+	if err != nil {
+		t.Fatalf("failed to seal block: %v", err)
+	}
+	select {
+	case block := <-results:
+		header.Nonce = types.EncodeNonce(block.Nonce())
+		header.MixDigest = block.MixDigest()
+		if err := hykash.VerifySeal(nil, header); err != nil {
+			t.Fatalf("unexpected verification error: %v", err)
+		}
+		tampered := types.CopyHeader(header)
+		tampered.Coinbase = common.Address{0x01}
+		if err := hykash.VerifySeal(nil, tampered); err == nil {
+			t.Fatalf("tampered header passed verification")
+		}
+		tampered = types.CopyHeader(header)
+		tampered.Nonce = types.EncodeNonce(block.Nonce() | 1<<32)
+		if err := hykash.VerifySeal(nil, tampered); err != errInvalidPoW {
+			t.Fatalf("high nonce bits error mismatch: have %v, want %v", err, errInvalidPoW)
+		}
+	case <-time.NewTimer(2 * time.Second).C:
+		t.Error("sealing result timeout")
+	}
+}
+
+// Tests that the chain's algorithm switch moves sealing and verification over to
+// double SHA256, without touching the caches or DAGs past the switch.
+func TestAlgorithmSwitch(t *testing.T) {
+	hykash := NewTester(nil, false)
+	hykash.config.PowSwitches = &params.HayekashConfig{Algorithms: []params.HayekashAlgorithm{
+		{Block: big.NewInt(2), Algorithm: params.PowSHA256d},
+	}}
+	defer hykash.Close()
+
+	if !hykash.sealsSHA256d() {
+		t.Fatalf("double SHA256 switch not detected")
+	}
+	if algo := new(Hayekash).algorithm(big.NewInt(2)); algo != AlgorithmHashimoto {
+		t.Errorf("algorithm without switches mismatch: have %v, want %v", algo, AlgorithmHashimoto)
+	}
+	if hykash.hashimotoEpoch(1) {
+		t.Errorf("epoch past the switch reported as hashimoto")
+	}
+	for number := int64(1); number <= 3; number++ {
+		header := &types.Header{Number: big.NewInt(number), Difficulty: big.NewInt(100), Time: 1600000000}
+		caches, datasets := hykash.caches.cache.Len(), hykash.datasets.cache.Len()
+
+		results := make(chan *types.Block)
+		if err := hykash.Seal(nil, types.NewBlockWithHeader(header), results, nil); err != nil {
+			t.Fatalf("block %d: failed to seal: %v", number, err)
+		}
+		var sealed *types.Header
+		select {
+		case block := <-results:
+			sealed = block.Header()
+		case <-time.NewTimer(2 * time.Second).C:
+			t.Fatalf("block %d: sealing result timeout", number)
+		}
+		if err := hykash.VerifySeal(nil, sealed); err != nil {
+			t.Fatalf("block %d: unexpected verification error: %v", number, err)
+		}
+		sha256d := hykash.algorithm(sealed.Number) == AlgorithmSHA256d
+		if want := number >= 2; sha256d != want {
+			t.Errorf("block %d: double SHA256 mismatch: have %v, want %v", number, sha256d, want)
+		}
+		if sha256d && (hykash.caches.cache.Len() != caches || hykash.datasets.cache.Len() != datasets) {
+			t.Errorf("block %d: hashimoto caches touched past the switch", number)
+		}
+	}
+}
+
+// Tests that the debug preimage of a sealed header hashes to its seal, allowing
+// offline verification.
+func TestDebugPreimage(t *testing.T) {
+	header := &types.Header{Number: big.NewInt(1), Difficulty: big.NewInt(100), Time: 1600000000}
+
+	hykash := NewTester(nil, false)
+	hykash.config.PowSwitches = sha256dRules()
+	defer hykash.Close()
+
+	results := make(chan *types.Block)
+	if err := hykash.Seal(nil, types.NewBlockWithHeader(header), results, nil); err != nil {
+		t.Fatalf("failed to seal block: %v", err)
+	}
+	var sealed *types.Header
+	select {
+	case block := <-results:
+		sealed = block.Header()
+	case <-time.NewTimer(2 * time.Second).C:
+		t.Fatalf("sealing result timeout")
+	}
+	api := &DebugAPI{hykash}
+	res, err := api.HykashPreimage(sealed, nil)
+	if err != nil {
+		t.Fatalf("failed to retrieve preimage: %v", err)
+	}
+	if len(res.Preimage) != 80 {
+		t.Fatalf("preimage length mismatch: have %d, want 80", len(res.Preimage))
+	}
+	first := sha256.Sum256(res.Preimage)
+	second := sha256.Sum256(first[:])
+	if hash := common.BytesToHash(reverseBytes(second[:])); hash != sealed.MixDigest || hash != res.Hash {
+		t.Fatalf("preimage hash mismatch: have %x, want %x", hash, sealed.MixDigest)
+	}
+	if bits := binary.LittleEndian.Uint32(res.Preimage[72:]); uint64(bits) != uint64(res.Bits) {
+		t.Fatalf("preimage bits mismatch: have %#x, want %#x", bits, res.Bits)
+	}
+	// Explicit nonces override the header one, but must fit into 32 bits
+	nonce := hexutil.Uint64(sealed.Nonce.Uint64() + 1)
+	if res, err = api.HykashPreimage(sealed, &nonce); err != nil || binary.LittleEndian.Uint32(res.Preimage[76:]) != uint32(nonce) {
+		t.Fatalf("explicit nonce mismatch: %v", err)
+	}
+	nonce = 1 << 32
+	if _, err := api.HykashPreimage(sealed, &nonce); err != errInvalidNonce {
+		t.Fatalf("wide nonce error mismatch: have %v, want %v", err, errInvalidNonce)
+	}
+}
+
 // This test checks that cache lru logic doesn't crash under load.
 // It reproduces https://github.com/hayekchain/go-hayekchain/issues/14943
 func TestCacheFileEvict(t *testing.T) {
@@ -107,7 +246,7 @@ func TestRemoteSealer(t *testing.T) {
 	hykash.Seal(nil, block, results, nil)
 
 	var (
-		work [4]string
+		work [6]string
 		err  error
 	)
 	if work, err = api.GetWork(); err != nil || work[0] != sealhash.Hex() {
@@ -0,0 +1,164 @@
+// Copyright 2021 The go-hayekchain Authors
+// This file is part of the go-hayekchain library.
+//
+// The go-hayekchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hayekchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hayekchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package hykash
+
+import (
+	"math/big"
+
+	"github.com/hayekchain/go-hayekchain/common"
+	"github.com/hayekchain/go-hayekchain/consensus"
+	"github.com/hayekchain/go-hayekchain/core/types"
+	"github.com/hayekchain/go-hayekchain/params"
+)
+
+var (
+	// powLimit is the easiest target a retargeted block may have, corresponding
+	// to difficulty 1.
+	powLimit = DiffToTarget(big1)
+
+	// lwmaMaxSolveTime caps a single solve time to this many target block times,
+	// limiting the effect of timestamp manipulation.
+	lwmaMaxSolveTime = uint64(6)
+
+	// DigiShield timespan dampening and bounds, in percent of the window timespan.
+	digiShieldDampening = int64(4)
+	digiShieldMinSpan   = int64(100 - 32)
+	digiShieldMaxSpan   = int64(100 + 16)
+)
+
+// calcDifficulty is the difficulty adjustment algorithm taking the compact
+// target retargeting rules into account. Ancestors of the parent are looked up
+// in parents first (headers pending verification in the same batch, oldest
+// first) and in the chain afterwards.
+func (hykash *Hayekash) calcDifficulty(chain consensus.ChainHeaderReader, time uint64, parent *types.Header, parents []*types.Header) *big.Int {
+	config := chain.Config()
+
+	next := new(big.Int).Add(parent.Number, big1)
+	if rule := config.Hayekash.Retarget(next); rule != nil {
+		window := retargetWindow(chain, rule, parent, parents)
+		return new(big.Int).Div(powLimit, CompactToBig(RetargetBits(rule, window)))
+	}
+	return CalcDifficulty(config, time, parent)
+}
+
+// retargetWindow collects the headers a retarget rule averages over, ending in
+// the parent, oldest first. Near genesis the window is truncated.
+func retargetWindow(chain consensus.ChainHeaderReader, rule *params.HayekashRetarget, parent *types.Header, parents []*types.Header) []*types.Header {
+	size := rule.Window + 1
+	if number := parent.Number.Uint64() + 1; number < size {
+		size = number
+	}
+	pending := make(map[common.Hash]*types.Header, len(parents))
+	for _, header := range parents {
+		pending[header.Hash()] = header
+	}
+	window := make([]*types.Header, size)
+	window[size-1] = parent
+	for i := int(size) - 2; i >= 0; i-- {
+		hash, number := window[i+1].ParentHash, window[i+1].Number.Uint64()-1
+
+		header := pending[hash]
+		if header == nil {
+			header = chain.GetHeader(hash, number)
+		}
+		if header == nil {
+			return window[i+1:]
+		}
+		window[i] = header
+	}
+	return window
+}
+
+// RetargetBits calculates the compact target of the block following the given
+// window of headers (oldest first) according to a retarget rule. The oldest
+// header only contributes its timestamp.
+func RetargetBits(rule *params.HayekashRetarget, window []*types.Header) uint32 {
+	parent := window[len(window)-1]
+	if len(window) < 2 {
+		return BigToCompact(new(big.Int).Div(powLimit, parent.Difficulty))
+	}
+	var next *big.Int
+	switch rule.Algorithm {
+	case params.RetargetDigiShield:
+		next = retargetDigiShield(rule, window)
+	default:
+		next = retargetLWMA(rule, window)
+	}
+	if next.Sign() <= 0 {
+		next.Set(big1)
+	}
+	if next.Cmp(powLimit) > 0 {
+		next.Set(powLimit)
+	}
+	return BigToCompact(next)
+}
+
+// retargetLWMA implements a linearly weighted moving average retarget: the
+// average target of the window is scaled by the ratio of the weighted sum of
+// solve times, recent blocks weighing most, to its expected value.
+//
+//   next = avg(target) * sum(i * solvetime_i) / (N * (N+1) / 2 * T)
+func retargetLWMA(rule *params.HayekashRetarget, window []*types.Header) *big.Int {
+	var (
+		n        = uint64(len(window) - 1)
+		maxSolve = lwmaMaxSolveTime * rule.BlockTime
+		weighted = new(big.Int)
+		targets  = new(big.Int)
+		solve    = new(big.Int)
+	)
+	for i := uint64(1); i <= n; i++ {
+		solvetime := window[i].Time - window[i-1].Time
+		if window[i].Time < window[i-1].Time {
+			solvetime = 1
+		}
+		if solvetime > maxSolve {
+			solvetime = maxSolve
+		}
+		weighted.Add(weighted, solve.SetUint64(solvetime*i))
+		targets.Add(targets, new(big.Int).Div(powLimit, window[i].Difficulty))
+	}
+	// next = targets / n * weighted / (n * (n+1) / 2 * T), multiplied out to
+	// keep the precision
+	denominator := new(big.Int).SetUint64(n * (n * (n + 1) / 2) * rule.BlockTime)
+	next := targets.Mul(targets, weighted)
+	return next.Div(next, denominator)
+}
+
+// retargetDigiShield implements a DigiShield v3 style retarget: the average
+// target of the window is scaled by the dampened and clamped ratio of the
+// actual window timespan to the expected one.
+func retargetDigiShield(rule *params.HayekashRetarget, window []*types.Header) *big.Int {
+	var (
+		n        = int64(len(window) - 1)
+		expected = n * int64(rule.BlockTime)
+		actual   = int64(window[n].Time) - int64(window[0].Time)
+		targets  = new(big.Int)
+	)
+	for i := int64(1); i <= n; i++ {
+		targets.Add(targets, new(big.Int).Div(powLimit, window[i].Difficulty))
+	}
+	// Dampen the deviation from the expected timespan and clamp the result
+	actual = expected + (actual-expected)/digiShieldDampening
+	if min := expected * digiShieldMinSpan / 100; actual < min {
+		actual = min
+	}
+	if max := expected * digiShieldMaxSpan / 100; actual > max {
+		actual = max
+	}
+	next := targets.Mul(targets, big.NewInt(actual))
+	return next.Div(next, big.NewInt(n*expected))
+}
@@ -0,0 +1,159 @@
+// Copyright 2021 The go-hayekchain Authors
+// This file is part of the go-hayekchain library.
+//
+// The go-hayekchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hayekchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hayekchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package hykash
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/hayekchain/go-hayekchain/common"
+	"github.com/hayekchain/go-hayekchain/core/types"
+	"github.com/hayekchain/go-hayekchain/params"
+)
+
+// retargetChain is a header chain usable as a consensus.ChainHeaderReader.
+type retargetChain struct {
+	config  *params.ChainConfig
+	headers map[common.Hash]*types.Header
+}
+
+func (c *retargetChain) Config() *params.ChainConfig                    { return c.config }
+func (c *retargetChain) CurrentHeader() *types.Header                   { return nil }
+func (c *retargetChain) GetHeaderByNumber(number uint64) *types.Header  { return nil }
+func (c *retargetChain) GetHeaderByHash(hash common.Hash) *types.Header { return c.headers[hash] }
+func (c *retargetChain) GetHeader(hash common.Hash, number uint64) *types.Header {
+	return c.headers[hash]
+}
+
+// makeRetargetWindow creates a linked run of headers of the given difficulty,
+// spaced by the given solve times.
+func makeRetargetWindow(difficulty int64, solvetimes []uint64) []*types.Header {
+	window := []*types.Header{{Number: big.NewInt(100), Time: 1000000, Difficulty: big.NewInt(difficulty)}}
+	for i, solvetime := range solvetimes {
+		window = append(window, &types.Header{
+			ParentHash: window[i].Hash(),
+			Number:     big.NewInt(int64(101 + i)),
+			Time:       window[i].Time + solvetime,
+			Difficulty: big.NewInt(difficulty),
+		})
+	}
+	return window
+}
+
+func repeatSolveTime(n int, solvetime uint64) []uint64 {
+	solvetimes := make([]uint64, n)
+	for i := range solvetimes {
+		solvetimes[i] = solvetime
+	}
+	return solvetimes
+}
+
+// Tests the compact target retarget algorithms against known vectors.
+func TestRetargetBits(t *testing.T) {
+	tests := []struct {
+		algorithm  string
+		difficulty int64
+		solvetimes []uint64
+		bits       uint32
+	}{
+		// LWMA: steady, twice too slow and fast, capped solve times
+		{params.RetargetLWMA, 1000, repeatSolveTime(10, 10), 0x1b418937},
+		{params.RetargetLWMA, 1000, repeatSolveTime(10, 20), 0x1c008312},
+		{params.RetargetLWMA, 1000, repeatSolveTime(10, 5), 0x1b20c49b},
+		{params.RetargetLWMA, 1000, repeatSolveTime(10, 1000), 0x1c018937},
+		// LWMA: recent solve times weigh more than old ones
+		{params.RetargetLWMA, 1000, append(repeatSolveTime(9, 10), 100), 0x1b7d1d3b},
+		{params.RetargetLWMA, 1000, append([]uint64{100}, repeatSolveTime(9, 10)...), 0x1b477e6a},
+		// LWMA: target never exceeds the PoW limit, short windows and lone parents
+		{params.RetargetLWMA, 1, repeatSolveTime(10, 20), 0x1d00ffff},
+		{params.RetargetLWMA, 1000, repeatSolveTime(3, 10), 0x1b418937},
+		{params.RetargetLWMA, 1000, nil, 0x1b418937},
+
+		// DigiShield: steady, dampened and clamped adjustments
+		{params.RetargetDigiShield, 1000, repeatSolveTime(10, 10), 0x1b418937},
+		{params.RetargetDigiShield, 1000, repeatSolveTime(10, 20), 0x1b4c0592},
+		{params.RetargetDigiShield, 1000, repeatSolveTime(10, 5), 0x1b39abf3},
+		{params.RetargetDigiShield, 1000, repeatSolveTime(10, 1000), 0x1b4c0592},
+		{params.RetargetDigiShield, 1000, append(repeatSolveTime(9, 10), 100), 0x1b4c0592},
+		// DigiShield: target never exceeds the PoW limit, short windows and lone parents
+		{params.RetargetDigiShield, 1, repeatSolveTime(10, 20), 0x1d00ffff},
+		{params.RetargetDigiShield, 1000, repeatSolveTime(3, 10), 0x1b418937},
+		{params.RetargetDigiShield, 1000, nil, 0x1b418937},
+	}
+	for i, tt := range tests {
+		rule := &params.HayekashRetarget{Algorithm: tt.algorithm, BlockTime: 10, Window: 10}
+		if bits := RetargetBits(rule, makeRetargetWindow(tt.difficulty, tt.solvetimes)); bits != tt.bits {
+			t.Errorf("test %d (%s): bits mismatch: have %#08x, want %#08x", i, tt.algorithm, bits, tt.bits)
+		}
+	}
+}
+
+// Tests that the engine applies the retarget rules from their activation block,
+// collecting the window from both the chain and pending batch headers.
+func TestRetargetDifficulty(t *testing.T) {
+	window := makeRetargetWindow(1000, repeatSolveTime(10, 20))
+	config := &params.ChainConfig{
+		Hayekash: &params.HayekashConfig{Retargets: []params.HayekashRetarget{
+			{Block: big.NewInt(111), Algorithm: params.RetargetLWMA, BlockTime: 10, Window: 10},
+		}},
+	}
+	// Split the window between the database and a verification batch
+	chain := &retargetChain{config: config, headers: make(map[common.Hash]*types.Header)}
+	for _, header := range window[:5] {
+		chain.headers[header.Hash()] = header
+	}
+	hykash := NewFaker()
+	parent := window[len(window)-1]
+
+	if diff := hykash.calcDifficulty(chain, parent.Time+10, parent, window[5:]); diff.Uint64() != 500 {
+		t.Errorf("batch difficulty mismatch: have %v, want %v", diff, 500)
+	}
+	for _, header := range window {
+		chain.headers[header.Hash()] = header
+	}
+	if diff := hykash.CalcDifficulty(chain, parent.Time+10, parent); diff.Uint64() != 500 {
+		t.Errorf("chain difficulty mismatch: have %v, want %v", diff, 500)
+	}
+	// The block before the activation must still use the stock rules
+	if diff, want := hykash.CalcDifficulty(chain, window[9].Time+10, window[9]), CalcDifficulty(config, window[9].Time+10, window[9]); diff.Cmp(want) != 0 {
+		t.Errorf("pre-fork difficulty mismatch: have %v, want %v", diff, want)
+	}
+}
+
+// Tests that malformed retarget rules are rejected.
+func TestRetargetConfig(t *testing.T) {
+	tests := []struct {
+		rules []params.HayekashRetarget
+		fail  bool
+	}{
+		{nil, false},
+		{[]params.HayekashRetarget{{Block: big.NewInt(0), Algorithm: params.RetargetLWMA, BlockTime: 10, Window: 60}}, false},
+		{[]params.HayekashRetarget{{Algorithm: params.RetargetLWMA, BlockTime: 10, Window: 60}}, true},
+		{[]params.HayekashRetarget{{Block: big.NewInt(0), Algorithm: "asert", BlockTime: 10, Window: 60}}, true},
+		{[]params.HayekashRetarget{{Block: big.NewInt(0), Algorithm: params.RetargetLWMA, Window: 60}}, true},
+		{[]params.HayekashRetarget{
+			{Block: big.NewInt(10), Algorithm: params.RetargetLWMA, BlockTime: 10, Window: 60},
+			{Block: big.NewInt(10), Algorithm: params.RetargetDigiShield, BlockTime: 10, Window: 60},
+		}, true},
+	}
+	for i, tt := range tests {
+		config := &params.HayekashConfig{Retargets: tt.rules}
+		if err := config.CheckRetargets(); (err != nil) != tt.fail {
+			t.Errorf("test %d: failure mismatch: have %v, want failure %v", i, err, tt.fail)
+		}
+	}
+}
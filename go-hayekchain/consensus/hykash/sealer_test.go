@@ -202,3 +202,132 @@ func TestStaleSubmission(t *testing.T) {
 		}
 	}
 }
+
+// Tests that local sealing of a block can be aborted by the caller, without any
+// result delivered for it, and that the engine seals the next block afterwards.
+func TestLocalSealStop(t *testing.T) {
+	hykash := NewTester(nil, false)
+	hykash.SetThreads(1)
+	defer hykash.Close()
+
+	// A block no nonce will realistically be found for within the test
+	header := &types.Header{Number: big.NewInt(1), Difficulty: new(big.Int).Lsh(big.NewInt(1), 200)}
+
+	results, stop := make(chan *types.Block, 1), make(chan struct{})
+	if err := hykash.Seal(nil, types.NewBlockWithHeader(header), results, stop); err != nil {
+		t.Fatalf("failed to seal block: %v", err)
+	}
+	close(stop)
+
+	// Sealing an easy block afterwards must succeed
+	header = &types.Header{Number: big.NewInt(1), Difficulty: big.NewInt(100)}
+	if err := hykash.Seal(nil, types.NewBlockWithHeader(header), results, nil); err != nil {
+		t.Fatalf("failed to seal block: %v", err)
+	}
+	select {
+	case block := <-results:
+		if block.Difficulty().Cmp(header.Difficulty) != 0 {
+			t.Fatalf("aborted block sealed: difficulty %v", block.Difficulty())
+		}
+		if err := hykash.VerifySeal(nil, block.Header()); err != nil {
+			t.Fatalf("sealed block failed verification: %v", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("sealing result timeout")
+	}
+}
+
+// Tests that a valid solution submitted by a remote miner for the hashimoto
+// proof-of-work is sealed into the block and handed back to the caller.
+func TestRemoteSeal(t *testing.T) {
+	hykash := NewTester(nil, false)
+	hykash.SetThreads(-1)
+	defer hykash.Close()
+	api := &API{hykash}
+
+	header := &types.Header{Number: big.NewInt(1), Difficulty: big.NewInt(100)}
+	results := make(chan *types.Block, 1)
+	if err := hykash.Seal(nil, types.NewBlockWithHeader(header), results, nil); err != nil {
+		t.Fatalf("failed to seal block: %v", err)
+	}
+	work, err := api.GetWork()
+	if err != nil {
+		t.Fatalf("failed to retrieve work: %v", err)
+	}
+	sealhash := common.HexToHash(work[0])
+	if sealhash != hykash.SealHash(header) {
+		t.Fatalf("work seal hash mismatch: have %x, want %x", sealhash, hykash.SealHash(header))
+	}
+	// Search a solution the way an external miner would
+	var (
+		cache  = hykash.cache(header.Number.Uint64())
+		target = new(big.Int).Div(two256, header.Difficulty)
+		nonce  uint64
+		digest []byte
+	)
+	for ; ; nonce++ {
+		var result []byte
+		digest, result = hashimotoLight(32*1024, cache.cache, sealhash.Bytes(), nonce)
+		if new(big.Int).SetBytes(result).Cmp(target) <= 0 {
+			break
+		}
+	}
+	if api.SubmitWork(types.EncodeNonce(nonce+1), sealhash, common.BytesToHash(digest)) {
+		t.Fatalf("invalid solution accepted")
+	}
+	if !api.SubmitWork(types.EncodeNonce(nonce), sealhash, common.BytesToHash(digest)) {
+		t.Fatalf("valid solution rejected")
+	}
+	select {
+	case block := <-results:
+		if block.Nonce() != nonce || block.MixDigest() != common.BytesToHash(digest) {
+			t.Fatalf("sealed block mismatch: have %d/%x, want %d/%x", block.Nonce(), block.MixDigest(), nonce, digest)
+		}
+		if err := hykash.VerifySeal(nil, block.Header()); err != nil {
+			t.Fatalf("sealed block failed verification: %v", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("sealing result timeout")
+	}
+}
+
+// Tests that remote double SHA256 miners only need to submit the nonce, the
+// sealer deriving the mix digest for them.
+func TestRemoteSealSHA256d(t *testing.T) {
+	hykash := NewTester(nil, false)
+	hykash.config.PowSwitches = sha256dRules()
+	hykash.SetThreads(-1)
+	defer hykash.Close()
+	api := &API{hykash}
+
+	header := &types.Header{Number: big.NewInt(1), Difficulty: big.NewInt(100), Time: 1600000000}
+	results := make(chan *types.Block, 1)
+	if err := hykash.Seal(nil, types.NewBlockWithHeader(header), results, nil); err != nil {
+		t.Fatalf("failed to seal block: %v", err)
+	}
+	work, err := api.GetWork()
+	if err != nil {
+		t.Fatalf("failed to retrieve work: %v", err)
+	}
+	sealhash := common.HexToHash(work[0])
+
+	bits := hykash.compactTarget(header.Difficulty)
+	nonce := uint32(0)
+	for !CheckPOW(hykash.hashSHA256d(header, nonce, bits), bits) {
+		nonce++
+	}
+	if !api.SubmitWork(types.EncodeNonce(uint64(nonce)), sealhash, common.Hash{}) {
+		t.Fatalf("valid solution rejected")
+	}
+	select {
+	case block := <-results:
+		if want := common.BytesToHash(hykash.hashSHA256d(header, nonce, bits)); block.MixDigest() != want {
+			t.Fatalf("mix digest mismatch: have %x, want %x", block.MixDigest(), want)
+		}
+		if err := hykash.VerifySeal(nil, block.Header()); err != nil {
+			t.Fatalf("sealed block failed verification: %v", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("sealing result timeout")
+	}
+}
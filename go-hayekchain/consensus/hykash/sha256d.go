@@ -0,0 +1,179 @@
+// Copyright 2021 The go-hayekchain Authors
+// This file is part of the go-hayekchain library.
+//
+// The go-hayekchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hayekchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hayekchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package hykash
+
+import (
+	"crypto/sha256"
+	"encoding"
+	"encoding/binary"
+	"hash"
+	"time"
+
+	"github.com/hayekchain/go-hayekchain/common"
+	"github.com/hayekchain/go-hayekchain/common/hexutil"
+	"github.com/hayekchain/go-hayekchain/consensus"
+	"github.com/hayekchain/go-hayekchain/core/types"
+)
+
+// sha256dHasher computes the double SHA256 proof-of-work of successive nonces of
+// a single preimage. The SHA256 state after the first 64 byte block of the
+// preimage (the midstate) does not depend on the timestamp, bits or nonce, so it
+// is computed once and restored for every nonce, leaving only the compression
+// of the preimage tail and of the first hash to be done.
+type sha256dHasher struct {
+	inner    hash.Hash // SHA256 hasher of the preimage, reset to the midstate per nonce
+	midstate []byte    // Marshalled SHA256 state after the first 64 preimage bytes
+	tail     [16]byte  // Preimage tail: end of the merkle root, timestamp, bits and nonce
+	first    [32]byte  // Single SHA256 of the preimage
+	result   [32]byte  // Double SHA256 of the preimage, in little endian order
+}
+
+// newSHA256dHasher creates a hasher for the given 80 byte preimage.
+func newSHA256dHasher(preimage []byte) *sha256dHasher {
+	h := &sha256dHasher{inner: sha256.New()}
+	h.inner.Write(preimage[:64])
+	h.midstate, _ = h.inner.(encoding.BinaryMarshaler).MarshalBinary()
+	copy(h.tail[:], preimage[64:])
+	return h
+}
+
+// hash computes the double SHA256 of the preimage with the given nonce. The
+// result is in the little endian order of the preimage and only valid until
+// the next call.
+func (h *sha256dHasher) hash(nonce uint32) *[32]byte {
+	binary.LittleEndian.PutUint32(h.tail[12:], nonce)
+
+	h.inner.(encoding.BinaryUnmarshaler).UnmarshalBinary(h.midstate)
+	h.inner.Write(h.tail[:])
+	h.inner.Sum(h.first[:0])
+	h.result = sha256.Sum256(h.first[:])
+	return &h.result
+}
+
+// meetsTarget reports whether a little endian hash is at most the big endian
+// target, without converting the hash into a big integer.
+func meetsTarget(result *[32]byte, target common.Hash) bool {
+	for i := 0; i < 32; i++ {
+		if b := result[31-i]; b != target[i] {
+			return b < target[i]
+		}
+	}
+	return true
+}
+
+// nonceRange returns the slice [lo, hi) of the 32 bit nonce space searched by
+// the given miner thread.
+func nonceRange(id, threads int) (uint64, uint64) {
+	return (uint64(1) << 32) * uint64(id) / uint64(threads), (uint64(1) << 32) * uint64(id+1) / uint64(threads)
+}
+
+// rollTime returns a copy of the header with its timestamp moved forward, once
+// a miner thread exhausted its nonce range. Transactions might have executed
+// against the original timestamp, so only blocks without them are rolled, and
+// only as long as the difficulty does not depend on the new timestamp.
+func (hykash *Hayekash) rollTime(chain consensus.ChainHeaderReader, block *types.Block, header *types.Header) *types.Header {
+	if len(block.Transactions()) > 0 {
+		return nil
+	}
+	header = types.CopyHeader(header)
+	header.Time++
+	if now := uint64(time.Now().Unix()); header.Time < now {
+		header.Time = now
+	}
+	if header.Time>>32 != 0 {
+		return nil
+	}
+	if chain != nil {
+		parent := chain.GetHeader(header.ParentHash, header.Number.Uint64()-1)
+		if parent == nil || hykash.CalcDifficulty(chain, header.Time, parent).Cmp(header.Difficulty) != 0 {
+			return nil
+		}
+	}
+	return header
+}
+
+// mineSHA256d is the double SHA256 counterpart of mine. Every thread searches
+// its own slice of the 32 bit nonce space, rolling the timestamp whenever the
+// slice is exhausted.
+func (hykash *Hayekash) mineSHA256d(chain consensus.ChainHeaderReader, block *types.Block, id int, threads int, abort chan struct{}, found chan *types.Block) {
+	// Extract some data from the header
+	var (
+		header = block.Header()
+		bits   = hykash.compactTarget(header.Difficulty)
+		target = common.BigToHash(CompactToBig(bits))
+	)
+
+	// Search the nonce range until we abort or find a good one
+	var (
+		lo, hi   = nonceRange(id, threads)
+		hasher   = newSHA256dHasher(hykash.preimageSHA256d(header, uint32(lo), bits))
+		attempts = int64(0)
+		nonce    = lo
+	)
+	logger := hykash.config.Log.New("miner", id)
+	logger.Trace("Started hykash sha256d search for new nonces", "from", lo, "to", hi)
+	for {
+		select {
+		case <-abort:
+			// Mining terminated, update stats and abort
+			logger.Trace("Hayekash nonce search aborted", "attempts", nonce-lo)
+			hykash.hashrate.Mark(attempts)
+			hashMeter.Mark(attempts)
+			return
+
+		default:
+			// We don't have to update hash rate on every nonce, so update after after 2^X nonces
+			attempts++
+			if (attempts % (1 << 15)) == 0 {
+				hykash.hashrate.Mark(attempts)
+				hashMeter.Mark(attempts)
+				attempts = 0
+			}
+			// Compute the PoW value of this nonce
+			if result := hasher.hash(uint32(nonce)); meetsTarget(result, target) {
+				// Correct nonce found, create a new header with it
+				header = types.CopyHeader(header)
+				header.Nonce = types.EncodeNonce(nonce)
+				header.MixDigest = common.BytesToHash(reverseBytes(result[:]))
+
+				// Seal and return a block (if still needed)
+				select {
+				case found <- block.WithSeal(header):
+					logger.Trace("Hayekash nonce found and reported", "attempts", nonce-lo, "nonce", nonce, "preimage", hexutil.Bytes(hykash.preimageSHA256d(header, uint32(nonce), bits)))
+				case <-abort:
+					logger.Trace("Hayekash nonce found but discarded", "attempts", nonce-lo, "nonce", nonce)
+				}
+				return
+			}
+			if nonce++; nonce < hi {
+				continue
+			}
+			// Nonce range exhausted, roll the timestamp or wait for new work
+			rolled := hykash.rollTime(chain, block, header)
+			if rolled == nil {
+				logger.Debug("Hayekash nonce range exhausted", "number", header.Number, "time", header.Time)
+				hykash.hashrate.Mark(attempts)
+				hashMeter.Mark(attempts)
+				<-abort
+				return
+			}
+			logger.Trace("Hayekash nonce range exhausted, rolling timestamp", "number", header.Number, "time", rolled.Time)
+			header, nonce = rolled, lo
+			hasher = newSHA256dHasher(hykash.preimageSHA256d(header, uint32(lo), bits))
+		}
+	}
+}
@@ -0,0 +1,151 @@
+// Copyright 2021 The go-hayekchain Authors
+// This file is part of the go-hayekchain library.
+//
+// The go-hayekchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hayekchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hayekchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package hykash
+
+import (
+	"bytes"
+	"math/big"
+	"math/rand"
+	"testing"
+
+	"github.com/hayekchain/go-hayekchain/common"
+	"github.com/hayekchain/go-hayekchain/core/types"
+	"github.com/hayekchain/go-hayekchain/trie"
+)
+
+// Tests that the midstate hasher produces the same hashes as the plain double
+// SHA256 of the preimage.
+func TestSHA256dHasher(t *testing.T) {
+	for i := 0; i < 16; i++ {
+		var parent, root common.Hash
+		rand.Read(parent[:])
+		rand.Read(root[:])
+		ts, bits := rand.Uint32(), rand.Uint32()
+
+		hasher := newSHA256dHasher(dsha256Preimage(parent[:], 0, ts, bits, root[:]))
+		for j := 0; j < 16; j++ {
+			nonce := rand.Uint32()
+			want, _ := dsha256(parent[:], nonce, ts, bits, root[:])
+			if have := hasher.hash(nonce); !bytes.Equal(have[:], want) {
+				t.Fatalf("test %d/%d: hash mismatch: have %x, want %x", i, j, have, want)
+			}
+		}
+	}
+}
+
+// Tests that the byte wise target check matches the big integer one.
+func TestMeetsTarget(t *testing.T) {
+	for i := 0; i < 1024; i++ {
+		var result [32]byte
+		rand.Read(result[:])
+		bits := rand.Uint32()&0x1f7fffff | 0x01000000
+
+		// Force some of the hashes close to the target to exercise equality
+		target := common.BigToHash(CompactToBig(bits))
+		if i%4 == 0 && bits>>24 <= 32 {
+			copy(result[:], reverseBytes(target[:]))
+		}
+		if have, want := meetsTarget(&result, target), CheckPOW(reverseBytes(result[:]), bits); have != want {
+			t.Fatalf("test %d: target check mismatch for %x/%#08x: have %v, want %v", i, result, bits, have, want)
+		}
+	}
+}
+
+// Tests that the miner threads split the nonce space without gaps or overlaps.
+func TestNonceRange(t *testing.T) {
+	for _, threads := range []int{1, 2, 3, 7, 64} {
+		next := uint64(0)
+		for id := 0; id < threads; id++ {
+			lo, hi := nonceRange(id, threads)
+			if lo != next || hi <= lo {
+				t.Fatalf("threads %d, id %d: range mismatch: have [%d, %d), want start %d", threads, id, lo, hi, next)
+			}
+			next = hi
+		}
+		if next != 1<<32 {
+			t.Errorf("threads %d: nonce space end mismatch: have %d, want %d", threads, next, uint64(1)<<32)
+		}
+	}
+}
+
+// Tests that only transaction free blocks get their timestamps rolled, and that
+// rolling keeps the seal hash stable.
+func TestRollTime(t *testing.T) {
+	hykash := NewTester(nil, false)
+	hykash.config.PowSwitches = sha256dRules()
+	defer hykash.Close()
+
+	header := &types.Header{Number: big.NewInt(1), Difficulty: big.NewInt(100), Time: 1600000000}
+	rolled := hykash.rollTime(nil, types.NewBlockWithHeader(header), header)
+	if rolled == nil || rolled.Time <= header.Time {
+		t.Fatalf("empty block not rolled: %v", rolled)
+	}
+	if hykash.SealHash(rolled) != hykash.SealHash(header) {
+		t.Fatalf("rolled seal hash mismatch")
+	}
+	tx := types.NewTransaction(0, common.Address{}, big.NewInt(0), 21000, big.NewInt(1), nil)
+	block := types.NewBlock(header, []*types.Transaction{tx}, nil, nil, new(trie.Trie))
+	if rolled := hykash.rollTime(nil, block, block.Header()); rolled != nil {
+		t.Fatalf("block with transactions rolled")
+	}
+}
+
+func benchmarkSHA256dHeader() (*Hayekash, *types.Header, uint32) {
+	hykash := NewTester(nil, false)
+	hykash.config.PowSwitches = sha256dRules()
+
+	header := &types.Header{Number: big.NewInt(1), Difficulty: big.NewInt(100), Time: 1600000000}
+	return hykash, header, hykash.compactTarget(header.Difficulty)
+}
+
+func BenchmarkSHA256dHeader(b *testing.B) {
+	hykash, header, bits := benchmarkSHA256dHeader()
+	defer hykash.Close()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		hykash.hashSHA256d(header, uint32(i), bits)
+	}
+}
+
+func BenchmarkSHA256dNaive(b *testing.B) {
+	hykash, header, bits := benchmarkSHA256dHeader()
+	defer hykash.Close()
+
+	var (
+		parent = header.ParentHash.Bytes()
+		root   = hykash.SealHash(header).Bytes()
+	)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		dsha256(parent, uint32(i), uint32(header.Time), bits, root)
+	}
+}
+
+func BenchmarkSHA256dMidstate(b *testing.B) {
+	hykash, header, bits := benchmarkSHA256dHeader()
+	defer hykash.Close()
+
+	var (
+		hasher = newSHA256dHasher(hykash.preimageSHA256d(header, 0, bits))
+		target = common.BigToHash(CompactToBig(bits))
+	)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		meetsTarget(hasher.hash(uint32(i)), target)
+	}
+}
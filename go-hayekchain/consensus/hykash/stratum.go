@@ -0,0 +1,716 @@
+// Copyright 2021 The go-hayekchain Authors
+// This file is part of the go-hayekchain library.
+//
+// The go-hayekchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hayekchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hayekchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package hykash
+
+// The stratum server speaks the Stratum v1 message flow (newline delimited
+// JSON-RPC over TCP) with one deviation: the 80 byte preimage commits to the
+// header seal hash instead of a coinbase transaction, so jobs carry the merkle
+// root slot directly instead of coinbase halves and a merkle branch. Every
+// session is assigned an extranonce1 which the server appends to the header
+// extra-data itself, handing each session its own seal hash and nonce space.
+// Miners roll no extranonce2 of their own.
+//
+//   mining.subscribe      []                                   -> [[["mining.notify", id]], extranonce1, 0]
+//   mining.authorize      [worker, password]                   -> true
+//   mining.submit         [worker, job, extranonce2, ntime, nonce] -> true
+//   mining.set_difficulty [difficulty]
+//   mining.notify         [job, prevhash, merkleroot, version, nbits, ntime, clean]
+//
+// The prevhash and merkleroot fields are hex encoded in preimage byte order,
+// the 32 bit fields as big endian hex numbers like upstream Stratum does.
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hayekchain/go-hayekchain/common"
+	"github.com/hayekchain/go-hayekchain/core/types"
+	"github.com/hayekchain/go-hayekchain/event"
+	"github.com/hayekchain/go-hayekchain/metrics"
+	"github.com/hayekchain/go-hayekchain/params"
+)
+
+const (
+	stratumShareTime        = 10 * time.Second // Share interval the vardiff aims for on every session
+	stratumRetargetInterval = time.Minute      // Minimum time between two share difficulty adjustments
+	stratumIdleTimeout      = 10 * time.Minute // Time after which silent sessions are dropped
+	stratumWriteTimeout     = 10 * time.Second // Maximum time to wait for a miner to accept a message
+	stratumMaxJobs          = 16               // Number of recent jobs that shares are still accepted for
+	stratumSendQueue        = 64               // Number of messages queued for a miner before it is dropped
+	stratumMinDifficulty    = 1                // Minimum share difficulty the vardiff may set
+	stratumExtranonceSize   = 4                // Number of extra-data bytes distinguishing the sessions
+)
+
+// stratumVersion is the fixed version field of the preimage, as big endian hex.
+var stratumVersion = "20002000"
+
+var errStratumNotRunning = errors.New("stratum server not running")
+
+var (
+	stratumAcceptedMeter = metrics.NewRegisteredMeter("hykash/stratum/shares/accepted", nil)
+	stratumRejectedMeter = metrics.NewRegisteredMeter("hykash/stratum/shares/rejected", nil)
+	stratumStaleMeter    = metrics.NewRegisteredMeter("hykash/stratum/shares/stale", nil)
+
+	// stratumShareDiffHist tracks the difficulty actually reached by accepted
+	// shares, as opposed to the difficulty they were mined against.
+	stratumShareDiffHist = metrics.NewRegisteredHistogram("hykash/stratum/shares/difficulty", nil, metrics.NewExpDecaySample(1028, 0.015))
+)
+
+// stratumError is an error reported back to stratum clients, encoded as the
+// customary [code, message, traceback] triplet.
+type stratumError struct {
+	code    int
+	message string
+}
+
+func (e *stratumError) Error() string { return e.message }
+
+// MarshalJSON implements json.Marshaler.
+func (e *stratumError) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]interface{}{e.code, e.message, nil})
+}
+
+var (
+	errStratumUnknown       = &stratumError{20, "Other/Unknown"}
+	errStratumInvalidParams = &stratumError{20, "Invalid parameters"}
+	errStratumInvalidTime   = &stratumError{20, "Ntime out of range"}
+	errStratumStale         = &stratumError{21, "Job not found"}
+	errStratumDuplicate     = &stratumError{22, "Duplicate share"}
+	errStratumLowDifficulty = &stratumError{23, "Low difficulty share"}
+	errStratumUnauthorized  = &stratumError{24, "Unauthorized worker"}
+	errStratumNotSubscribed = &stratumError{25, "Not subscribed"}
+)
+
+// stratumRequest is a client to server stratum call.
+type stratumRequest struct {
+	ID     json.RawMessage   `json:"id"`
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+}
+
+// stratumResponse is a server reply to a stratum call.
+type stratumResponse struct {
+	ID     json.RawMessage `json:"id"`
+	Result interface{}     `json:"result"`
+	Error  interface{}     `json:"error"`
+}
+
+// stratumNotification is a server initiated stratum message.
+type stratumNotification struct {
+	ID     interface{}   `json:"id"`
+	Method string        `json:"method"`
+	Params []interface{} `json:"params"`
+}
+
+// StratumWorker is the share accounting of a single authorized stratum worker.
+type StratumWorker struct {
+	Accepted  uint64    `json:"accepted"`  // Number of valid shares submitted
+	Rejected  uint64    `json:"rejected"`  // Number of invalid or duplicate shares submitted
+	Stale     uint64    `json:"stale"`     // Number of shares submitted for unknown jobs
+	Blocks    uint64    `json:"blocks"`    // Number of shares that sealed a block
+	Work      uint64    `json:"work"`      // Sum of the difficulties of the accepted shares
+	LastShare time.Time `json:"lastShare"` // Time of the last accepted share
+}
+
+// stratumJob is a work package handed out to stratum miners. Every session
+// mines its own variant of the job, differing in the extranonce.
+type stratumJob struct {
+	id     string
+	block  *types.Block
+	header *types.Header
+	bits   uint32
+	works  map[*stratumSession]*stratumWork // Session variants of the job, guarded by the server lock
+}
+
+// stratumWork is the variant of a job mined by a single session.
+type stratumWork struct {
+	header   *types.Header
+	sealHash common.Hash
+	shares   map[uint32]struct{} // Nonces already submitted by the session
+}
+
+// work returns the variant of the job for a session, creating it on first use.
+// The server lock must be held.
+func (job *stratumJob) work(hykash *Hayekash, session *stratumSession) *stratumWork {
+	if work := job.works[session]; work != nil {
+		return work
+	}
+	header := types.CopyHeader(job.header)
+	header.Extra = stratumExtra(header.Extra, session.extranonce)
+
+	work := &stratumWork{
+		header:   header,
+		sealHash: hykash.SealHash(header),
+		shares:   make(map[uint32]struct{}),
+	}
+	job.works[session] = work
+	return work
+}
+
+// stratumExtra appends an extranonce to the extra-data of a block, cutting off
+// the tail of the original extra-data if it would exceed the maximum size.
+func stratumExtra(extra []byte, extranonce []byte) []byte {
+	if limit := int(params.MaximumExtraDataSize) - len(extranonce); len(extra) > limit {
+		extra = extra[:limit]
+	}
+	return append(common.CopyBytes(extra), extranonce...)
+}
+
+// params assembles the mining.notify parameters of a session variant of the job.
+func (job *stratumJob) params(work *stratumWork, clean bool) []interface{} {
+	return []interface{}{
+		job.id,
+		hex.EncodeToString(reverseBytes(job.header.ParentHash.Bytes())),
+		hex.EncodeToString(reverseBytes(work.sealHash.Bytes())),
+		stratumVersion,
+		fmt.Sprintf("%08x", job.bits),
+		fmt.Sprintf("%08x", uint32(job.header.Time)),
+		clean,
+	}
+}
+
+// stratumServer is a Stratum v1 style TCP server handing out double SHA256 work
+// packages to external miners and feeding solutions back to the remote sealer.
+type stratumServer struct {
+	hykash     *Hayekash
+	listener   net.Listener
+	difficulty uint64 // Initial share difficulty of new sessions
+
+	lock     sync.Mutex
+	sessions map[*stratumSession]struct{}
+	jobs     map[string]*stratumJob
+	order    []string // Job ids in arrival order, used for eviction
+	current  *stratumJob
+	sequence uint64
+	workers  map[string]*StratumWorker
+	nonces   uint32 // Counter handing out the session extranonces
+
+	workCh chan *types.Block
+	sub    event.Subscription
+	quit   chan struct{}
+	wg     sync.WaitGroup
+}
+
+// startStratumServer starts listening for stratum miners on the given address
+// and serving them the work packages pushed into the remote sealer.
+func startStratumServer(hykash *Hayekash, addr string, difficulty uint64) (*stratumServer, error) {
+	if hykash.remote == nil {
+		return nil, errors.New("remote sealer not running")
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if difficulty < stratumMinDifficulty {
+		difficulty = stratumMinDifficulty
+	}
+	s := &stratumServer{
+		hykash:     hykash,
+		listener:   listener,
+		difficulty: difficulty,
+		sessions:   make(map[*stratumSession]struct{}),
+		jobs:       make(map[string]*stratumJob),
+		workers:    make(map[string]*StratumWorker),
+		workCh:     make(chan *types.Block, 16),
+		quit:       make(chan struct{}),
+	}
+	s.sub = hykash.remote.workFeed.Subscribe(s.workCh)
+
+	s.wg.Add(2)
+	go s.loop()
+	go s.accept()
+
+	hykash.config.Log.Info("Stratum server started", "addr", listener.Addr(), "difficulty", difficulty)
+	return s, nil
+}
+
+// close terminates the listener and all live sessions.
+func (s *stratumServer) close() {
+	close(s.quit)
+	s.sub.Unsubscribe()
+	s.listener.Close()
+
+	s.lock.Lock()
+	for session := range s.sessions {
+		session.conn.Close()
+	}
+	s.lock.Unlock()
+
+	s.wg.Wait()
+}
+
+// loop turns the blocks pushed into the remote sealer into stratum jobs.
+func (s *stratumServer) loop() {
+	defer s.wg.Done()
+
+	for {
+		select {
+		case block := <-s.workCh:
+			// Blocks before a double SHA256 switch can't be mined over stratum
+			if s.hykash.algorithm(block.Number()) != AlgorithmSHA256d {
+				continue
+			}
+			s.newJob(block)
+		case <-s.sub.Err():
+			return
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+// accept serves the incoming stratum connections.
+func (s *stratumServer) accept() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			select {
+			case <-s.quit:
+			default:
+				s.hykash.config.Log.Warn("Stratum listener failed", "err", err)
+			}
+			return
+		}
+		s.serveConn(conn)
+	}
+}
+
+// serveConn registers a new stratum session on the connection and starts
+// serving it.
+func (s *stratumServer) serveConn(conn net.Conn) {
+	session := &stratumSession{
+		server:     s,
+		conn:       conn,
+		id:         strconv.FormatUint(uint64(time.Now().UnixNano()), 16),
+		workers:    make(map[string]struct{}),
+		difficulty: s.difficulty,
+		prevDiff:   s.difficulty,
+		since:      time.Now(),
+		sendCh:     make(chan interface{}, stratumSendQueue),
+		closed:     make(chan struct{}),
+	}
+	s.lock.Lock()
+	session.extranonce = make([]byte, stratumExtranonceSize)
+	binary.BigEndian.PutUint32(session.extranonce, s.nonces)
+	s.nonces++
+	s.sessions[session] = struct{}{}
+	s.lock.Unlock()
+
+	s.wg.Add(2)
+	go session.serve()
+	go session.writeLoop()
+}
+
+// newJob creates a stratum job out of a pending block and notifies every
+// subscribed session about it. Notifications are only queued, so slow miners
+// can't hold up the job distribution to the others.
+func (s *stratumServer) newJob(block *types.Block) {
+	header := block.Header()
+
+	s.lock.Lock()
+	s.sequence++
+	job := &stratumJob{
+		id:     strconv.FormatUint(s.sequence, 16),
+		block:  block,
+		header: header,
+		bits:   s.hykash.compactTarget(header.Difficulty),
+		works:  make(map[*stratumSession]*stratumWork),
+	}
+	clean := s.current == nil || s.current.header.ParentHash != header.ParentHash
+
+	s.jobs[job.id] = job
+	s.order = append(s.order, job.id)
+	if len(s.order) > stratumMaxJobs {
+		delete(s.jobs, s.order[0])
+		s.order = s.order[1:]
+	}
+	s.current = job
+
+	var (
+		now      = time.Now()
+		sessions []*stratumSession
+		works    []*stratumWork
+		retarget []bool
+	)
+	for session := range s.sessions {
+		if session.subscribed {
+			sessions = append(sessions, session)
+			works = append(works, job.work(s.hykash, session))
+			retarget = append(retarget, session.retarget(now))
+		}
+	}
+	s.lock.Unlock()
+
+	for i, session := range sessions {
+		if retarget[i] {
+			session.sendDifficulty()
+		}
+		session.notify("mining.notify", job.params(works[i], clean)...)
+	}
+}
+
+// submit validates a share, accounts it to the worker and forwards it to the
+// remote sealer if it satisfies the block target as well.
+func (s *stratumServer) submit(session *stratumSession, worker, id string, ntime, nonce uint32) error {
+	s.lock.Lock()
+	stats := s.workers[worker]
+	job := s.jobs[id]
+	if job == nil {
+		stats.Stale++
+		s.lock.Unlock()
+		stratumStaleMeter.Mark(1)
+		return errStratumStale
+	}
+	if uint64(ntime) != job.header.Time {
+		stats.Rejected++
+		s.lock.Unlock()
+		stratumRejectedMeter.Mark(1)
+		return errStratumInvalidTime
+	}
+	work := job.work(s.hykash, session)
+	if _, ok := work.shares[nonce]; ok {
+		stats.Rejected++
+		s.lock.Unlock()
+		stratumRejectedMeter.Mark(1)
+		return errStratumDuplicate
+	}
+	work.shares[nonce] = struct{}{}
+
+	// Accept shares mined against the previous difficulty too, miners only
+	// switch over on the next job.
+	difficulty := session.difficulty
+	if session.prevDiff < difficulty {
+		difficulty = session.prevDiff
+	}
+	s.lock.Unlock()
+
+	// Compute the PoW of the share and check it against the session target
+	_, result := dsha256(job.header.ParentHash.Bytes(), nonce, ntime, job.bits, work.sealHash.Bytes())
+	if new(big.Int).SetBytes(result).Cmp(s.hykash.target(new(big.Int).SetUint64(difficulty))) > 0 {
+		s.lock.Lock()
+		stats.Rejected++
+		s.lock.Unlock()
+		stratumRejectedMeter.Mark(1)
+		return errStratumLowDifficulty
+	}
+	reached := s.hykash.difficulty(result)
+	stratumAcceptedMeter.Mark(1)
+	if reached.IsInt64() {
+		stratumShareDiffHist.Update(reached.Int64())
+	} else {
+		stratumShareDiffHist.Update(math.MaxInt64)
+	}
+	s.hykash.config.Log.Trace("Stratum share accepted", "worker", worker, "job", id, "nonce", nonce, "difficulty", difficulty, "reached", reached)
+
+	s.lock.Lock()
+	stats.Accepted++
+	stats.Work += difficulty
+	stats.LastShare = time.Now()
+	session.shares++
+	retarget := session.retarget(stats.LastShare)
+	s.lock.Unlock()
+
+	if retarget {
+		session.sendDifficulty()
+	}
+	// If the share seals the block, register the session variant of the block
+	// with the remote sealer and push the solution through it
+	if CheckPOW(result, job.bits) {
+		select {
+		case s.hykash.remote.addWorkCh <- job.block.WithSeal(work.header):
+		case <-s.hykash.remote.exitCh:
+			return errHayekashStopped
+		}
+		errc := make(chan error, 1)
+		select {
+		case s.hykash.remote.submitWorkCh <- &mineResult{nonce: types.EncodeNonce(uint64(nonce)), hash: work.sealHash, errc: errc}:
+		case <-s.hykash.remote.exitCh:
+			return errHayekashStopped
+		}
+		if err := <-errc; err != nil {
+			s.hykash.config.Log.Warn("Stratum block rejected", "worker", worker, "number", job.header.Number, "err", err)
+			return nil
+		}
+		s.lock.Lock()
+		stats.Blocks++
+		s.lock.Unlock()
+
+		s.hykash.config.Log.Info("Stratum worker sealed block", "worker", worker, "number", job.header.Number, "sealhash", work.sealHash)
+	}
+	return nil
+}
+
+// stats returns a copy of the share accounting of all the workers.
+func (s *stratumServer) stats() map[string]StratumWorker {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	stats := make(map[string]StratumWorker, len(s.workers))
+	for name, worker := range s.workers {
+		stats[name] = *worker
+	}
+	return stats
+}
+
+// stratumSession is a single stratum miner connection.
+type stratumSession struct {
+	server     *stratumServer
+	conn       net.Conn
+	id         string
+	extranonce []byte           // Suffix of the header extra-data mined by the session
+	sendCh     chan interface{} // Outgoing messages, written by writeLoop
+	closed     chan struct{}    // Closed when the session is torn down
+
+	// The fields below are guarded by the server lock
+	subscribed bool
+	workers    map[string]struct{}
+	difficulty uint64    // Current share difficulty
+	prevDiff   uint64    // Share difficulty before the last retarget
+	shares     int       // Number of shares since the last retarget
+	since      time.Time // Time of the last retarget
+}
+
+// serve reads and answers the requests of a stratum session until it is closed.
+func (session *stratumSession) serve() {
+	s := session.server
+	defer s.wg.Done()
+	defer func() {
+		s.lock.Lock()
+		delete(s.sessions, session)
+		s.lock.Unlock()
+		session.conn.Close()
+		close(session.closed)
+	}()
+	logger := s.hykash.config.Log.New("stratum", session.conn.RemoteAddr())
+	logger.Debug("Stratum session started")
+
+	decoder := json.NewDecoder(session.conn)
+	for {
+		session.conn.SetReadDeadline(time.Now().Add(stratumIdleTimeout))
+
+		var req stratumRequest
+		if err := decoder.Decode(&req); err != nil {
+			logger.Debug("Stratum session ended", "err", err)
+			return
+		}
+		result, err := session.handle(&req)
+		res := &stratumResponse{ID: req.ID, Result: result}
+		if err != nil {
+			res.Result, res.Error = nil, err
+		}
+		if !session.send(res) {
+			logger.Debug("Failed to answer stratum request", "err", "send queue full")
+			return
+		}
+		// Hand out the difficulty and the current job right after subscribing
+		if req.Method == "mining.subscribe" && err == nil {
+			session.sendDifficulty()
+
+			s.lock.Lock()
+			job := s.current
+			var work *stratumWork
+			if job != nil {
+				work = job.work(s.hykash, session)
+			}
+			s.lock.Unlock()
+			if job != nil {
+				session.notify("mining.notify", job.params(work, true)...)
+			}
+		}
+	}
+}
+
+// handle executes a single stratum request.
+func (session *stratumSession) handle(req *stratumRequest) (interface{}, *stratumError) {
+	s := session.server
+
+	switch req.Method {
+	case "mining.subscribe":
+		s.lock.Lock()
+		session.subscribed = true
+		s.lock.Unlock()
+		return []interface{}{[][]string{{"mining.notify", session.id}}, hex.EncodeToString(session.extranonce), 0}, nil
+
+	case "mining.authorize":
+		var worker string
+		if len(req.Params) < 1 || json.Unmarshal(req.Params[0], &worker) != nil || worker == "" {
+			return nil, errStratumInvalidParams
+		}
+		s.lock.Lock()
+		session.workers[worker] = struct{}{}
+		if s.workers[worker] == nil {
+			s.workers[worker] = new(StratumWorker)
+		}
+		s.lock.Unlock()
+		return true, nil
+
+	case "mining.submit":
+		var params [5]string
+		if len(req.Params) < len(params) {
+			return nil, errStratumInvalidParams
+		}
+		for i := range params {
+			if json.Unmarshal(req.Params[i], &params[i]) != nil {
+				return nil, errStratumInvalidParams
+			}
+		}
+		ntime, err := strconv.ParseUint(params[3], 16, 32)
+		if err != nil {
+			return nil, errStratumInvalidParams
+		}
+		nonce, err := strconv.ParseUint(params[4], 16, 32)
+		if err != nil {
+			return nil, errStratumInvalidParams
+		}
+		s.lock.Lock()
+		subscribed := session.subscribed
+		_, authorized := session.workers[params[0]]
+		s.lock.Unlock()
+
+		if !subscribed {
+			return nil, errStratumNotSubscribed
+		}
+		if !authorized {
+			return nil, errStratumUnauthorized
+		}
+		if err := s.submit(session, params[0], params[1], uint32(ntime), uint32(nonce)); err != nil {
+			if serr, ok := err.(*stratumError); ok {
+				return nil, serr
+			}
+			return nil, errStratumUnknown
+		}
+		return true, nil
+
+	case "mining.extranonce.subscribe":
+		return false, nil
+
+	default:
+		return nil, &stratumError{20, "Unknown method " + req.Method}
+	}
+}
+
+// retarget adjusts the share difficulty of the session towards one share every
+// stratumShareTime, changing it at most fourfold at once. The server lock must
+// be held. It reports whether the difficulty changed.
+func (session *stratumSession) retarget(now time.Time) bool {
+	elapsed := now.Sub(session.since)
+	if elapsed < stratumRetargetInterval {
+		return false
+	}
+	next := session.difficulty / 4
+	if session.shares > 0 {
+		interval := elapsed / time.Duration(session.shares)
+		next = uint64(new(big.Int).Div(
+			new(big.Int).Mul(new(big.Int).SetUint64(session.difficulty), big.NewInt(int64(stratumShareTime))),
+			big.NewInt(int64(interval)),
+		).Uint64())
+		if max := session.difficulty * 4; next > max {
+			next = max
+		}
+		if min := session.difficulty / 4; next < min {
+			next = min
+		}
+	}
+	if next < stratumMinDifficulty {
+		next = stratumMinDifficulty
+	}
+	session.shares, session.since = 0, now
+	if next == session.difficulty {
+		return false
+	}
+	session.prevDiff, session.difficulty = session.difficulty, next
+	return true
+}
+
+// sendDifficulty notifies the miner of its current share difficulty.
+func (session *stratumSession) sendDifficulty() {
+	session.server.lock.Lock()
+	difficulty := session.difficulty
+	session.server.lock.Unlock()
+
+	session.notify("mining.set_difficulty", difficulty)
+}
+
+// notify sends a server initiated message to the miner.
+func (session *stratumSession) notify(method string, params ...interface{}) {
+	if !session.send(&stratumNotification{Method: method, Params: params}) {
+		session.server.hykash.config.Log.Debug("Failed to notify stratum miner", "method", method, "err", "send queue full")
+	}
+}
+
+// send queues a message for the miner without blocking. Miners not keeping up
+// with their queue are disconnected.
+func (session *stratumSession) send(msg interface{}) bool {
+	select {
+	case session.sendCh <- msg:
+		return true
+	case <-session.closed:
+		return false
+	default:
+		session.conn.Close()
+		return false
+	}
+}
+
+// writeLoop writes the queued messages to the miner until the session is closed.
+func (session *stratumSession) writeLoop() {
+	defer session.server.wg.Done()
+
+	for {
+		select {
+		case msg := <-session.sendCh:
+			if err := session.write(msg); err != nil {
+				session.server.hykash.config.Log.Debug("Failed to write stratum message", "err", err)
+				session.conn.Close()
+				return
+			}
+		case <-session.closed:
+			return
+		}
+	}
+}
+
+// write sends a single newline terminated JSON message to the miner.
+func (session *stratumSession) write(msg interface{}) error {
+	blob, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	session.conn.SetWriteDeadline(time.Now().Add(stratumWriteTimeout))
+	_, err = session.conn.Write(append(blob, '\n'))
+	return err
+}
+
+// reverseBytes returns a reversed copy of a byte slice.
+func reverseBytes(b []byte) []byte {
+	r := make([]byte, len(b))
+	for i := range b {
+		r[len(b)-1-i] = b[i]
+	}
+	return r
+}
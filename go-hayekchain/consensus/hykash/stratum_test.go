@@ -0,0 +1,377 @@
+// Copyright 2021 The go-hayekchain Authors
+// This file is part of the go-hayekchain library.
+//
+// The go-hayekchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hayekchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hayekchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package hykash
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/hayekchain/go-hayekchain/core/types"
+)
+
+// fakeMiner is a minimal stratum client driving a stratum server in tests.
+type fakeMiner struct {
+	t      *testing.T
+	conn   net.Conn
+	reader *bufio.Reader
+	id     int
+}
+
+type fakeMinerMessage struct {
+	ID     *int              `json:"id"`
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+	Result json.RawMessage   `json:"result"`
+	Error  []interface{}     `json:"error"`
+}
+
+func newFakeMiner(t *testing.T, addr string) *fakeMiner {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("failed to dial stratum server: %v", err)
+	}
+	return &fakeMiner{t: t, conn: conn, reader: bufio.NewReader(conn)}
+}
+
+// read returns the next message sent by the server.
+func (m *fakeMiner) read() *fakeMinerMessage {
+	m.conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	line, err := m.reader.ReadBytes('\n')
+	if err != nil {
+		m.t.Fatalf("failed to read stratum message: %v", err)
+	}
+	msg := new(fakeMinerMessage)
+	if err := json.Unmarshal(line, msg); err != nil {
+		m.t.Fatalf("failed to decode stratum message %s: %v", line, err)
+	}
+	return msg
+}
+
+// call sends a request and waits for its reply, queueing any notifications
+// received in between.
+func (m *fakeMiner) call(method string, params ...interface{}) (*fakeMinerMessage, []*fakeMinerMessage) {
+	m.id++
+	blob, _ := json.Marshal(map[string]interface{}{"id": m.id, "method": method, "params": params})
+	if _, err := m.conn.Write(append(blob, '\n')); err != nil {
+		m.t.Fatalf("failed to send stratum request: %v", err)
+	}
+	var notes []*fakeMinerMessage
+	for {
+		msg := m.read()
+		if msg.ID != nil && *msg.ID == m.id {
+			return msg, notes
+		}
+		notes = append(notes, msg)
+	}
+}
+
+// mine searches the nonce space of a mining.notify job for a solution meeting
+// the given target, assembling the preimage purely from the job fields.
+func (m *fakeMiner) mine(job *fakeMinerMessage, target *big.Int) (uint32, uint32) {
+	var fields [6]string
+	for i := range fields {
+		if err := json.Unmarshal(job.Params[i], &fields[i]); err != nil {
+			m.t.Fatalf("failed to decode job field %d: %v", i, err)
+		}
+	}
+	prevhash, _ := hex.DecodeString(fields[1])
+	merkleroot, _ := hex.DecodeString(fields[2])
+	version, _ := strconv.ParseUint(fields[3], 16, 32)
+	bits, _ := strconv.ParseUint(fields[4], 16, 32)
+	ntime, _ := strconv.ParseUint(fields[5], 16, 32)
+
+	seed := make([]byte, 80)
+	binary.LittleEndian.PutUint32(seed[0:], uint32(version))
+	copy(seed[4:], prevhash)
+	copy(seed[36:], merkleroot)
+	binary.LittleEndian.PutUint32(seed[68:], uint32(ntime))
+	binary.LittleEndian.PutUint32(seed[72:], uint32(bits))
+
+	for nonce := uint32(0); ; nonce++ {
+		binary.LittleEndian.PutUint32(seed[76:], nonce)
+		first := sha256.Sum256(seed)
+		second := sha256.Sum256(first[:])
+		if new(big.Int).SetBytes(reverseBytes(second[:])).Cmp(target) <= 0 {
+			return uint32(ntime), nonce
+		}
+	}
+}
+
+// Tests that a stratum miner can subscribe, receive work and seal a block.
+func TestStratumMining(t *testing.T) {
+	hykash := NewTester(nil, false)
+	hykash.config.PowSwitches = sha256dRules()
+	hykash.SetThreads(-1)
+	defer hykash.Close()
+
+	stratum, err := startStratumServer(hykash, "127.0.0.1:0", 1)
+	if err != nil {
+		t.Fatalf("failed to start stratum server: %v", err)
+	}
+	hykash.stratum = stratum
+
+	miner := newFakeMiner(t, stratum.listener.Addr().String())
+	defer miner.conn.Close()
+
+	if res, _ := miner.call("mining.subscribe", "fakeminer/1.0"); res.Error != nil {
+		t.Fatalf("subscription failed: %v", res.Error)
+	}
+	if res, _ := miner.call("mining.authorize", "worker", "x"); string(res.Result) != "true" {
+		t.Fatalf("authorization failed: %s %v", res.Result, res.Error)
+	}
+	if res, _ := miner.call("mining.submit", "stranger", "1", "", "0", "0"); len(res.Error) == 0 || res.Error[0] != float64(24) {
+		t.Fatalf("unauthorized submission error mismatch: %v", res.Error)
+	}
+	// Push a block to seal and wait for the job to arrive
+	header := &types.Header{Number: big.NewInt(1), Difficulty: big.NewInt(100), Time: 1600000000}
+	results := make(chan *types.Block, 1)
+	hykash.Seal(nil, types.NewBlockWithHeader(header), results, nil)
+
+	var job *fakeMinerMessage
+	for job == nil {
+		if msg := miner.read(); msg.Method == "mining.notify" {
+			job = msg
+		}
+	}
+	var id string
+	json.Unmarshal(job.Params[0], &id)
+
+	// Mine a share meeting the block target and submit it
+	ntime, nonce := miner.mine(job, CompactToBig(hykash.compactTarget(header.Difficulty)))
+	if res, _ := miner.call("mining.submit", "worker", id, "", fmt.Sprintf("%08x", ntime), fmt.Sprintf("%08x", nonce)); string(res.Result) != "true" {
+		t.Fatalf("share rejected: %v", res.Error)
+	}
+	select {
+	case block := <-results:
+		if err := hykash.VerifySeal(nil, block.Header()); err != nil {
+			t.Fatalf("stratum sealed block failed verification: %v", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("sealed block timed out")
+	}
+	// Resubmitting the same share or submitting to unknown jobs must fail
+	if res, _ := miner.call("mining.submit", "worker", id, "", fmt.Sprintf("%08x", ntime), fmt.Sprintf("%08x", nonce)); len(res.Error) == 0 || res.Error[0] != float64(22) {
+		t.Fatalf("duplicate share error mismatch: %v", res.Error)
+	}
+	if res, _ := miner.call("mining.submit", "worker", "ffff", "", fmt.Sprintf("%08x", ntime), "00000000"); len(res.Error) == 0 || res.Error[0] != float64(21) {
+		t.Fatalf("stale share error mismatch: %v", res.Error)
+	}
+	stats := hykash.StratumWorkers()["worker"]
+	if stats.Accepted != 1 || stats.Blocks != 1 || stats.Rejected != 1 || stats.Stale != 1 {
+		t.Fatalf("worker accounting mismatch: %+v", stats)
+	}
+}
+
+// Tests that every stratum session mines its own variant of a job, so miners
+// don't search the same nonce space or collide on each others shares.
+func TestStratumSessionWork(t *testing.T) {
+	hykash := NewTester(nil, false)
+	hykash.config.PowSwitches = sha256dRules()
+	hykash.SetThreads(-1)
+	defer hykash.Close()
+
+	stratum, err := startStratumServer(hykash, "127.0.0.1:0", 1)
+	if err != nil {
+		t.Fatalf("failed to start stratum server: %v", err)
+	}
+	hykash.stratum = stratum
+
+	var (
+		miners      [2]*fakeMiner
+		extranonces [2]string
+	)
+	for i := range miners {
+		miners[i] = newFakeMiner(t, stratum.listener.Addr().String())
+		defer miners[i].conn.Close()
+
+		res, _ := miners[i].call("mining.subscribe", "fakeminer/1.0")
+		var result []json.RawMessage
+		if err := json.Unmarshal(res.Result, &result); err != nil || len(result) != 3 {
+			t.Fatalf("miner %d: invalid subscription result: %s", i, res.Result)
+		}
+		json.Unmarshal(result[1], &extranonces[i])
+		if res, _ := miners[i].call("mining.authorize", fmt.Sprintf("worker%d", i), "x"); string(res.Result) != "true" {
+			t.Fatalf("miner %d: authorization failed: %v", i, res.Error)
+		}
+	}
+	if extranonces[0] == extranonces[1] {
+		t.Fatalf("sessions share extranonce %s", extranonces[0])
+	}
+	// Push a block to seal and collect the job of every session
+	header := &types.Header{Number: big.NewInt(1), Difficulty: big.NewInt(100), Time: 1600000000, Extra: []byte("hayekchain")}
+	results := make(chan *types.Block, 1)
+	hykash.Seal(nil, types.NewBlockWithHeader(header), results, nil)
+
+	var jobs [2]*fakeMinerMessage
+	for i, miner := range miners {
+		for jobs[i] == nil {
+			if msg := miner.read(); msg.Method == "mining.notify" {
+				jobs[i] = msg
+			}
+		}
+	}
+	var ids, roots [2]string
+	for i, job := range jobs {
+		json.Unmarshal(job.Params[0], &ids[i])
+		json.Unmarshal(job.Params[2], &roots[i])
+	}
+	if ids[0] != ids[1] {
+		t.Fatalf("job id mismatch: %s != %s", ids[0], ids[1])
+	}
+	if roots[0] == roots[1] {
+		t.Fatalf("sessions got the same merkle root %s", roots[0])
+	}
+	// The same share submitted by both sessions is a valid share for each
+	ntime := fmt.Sprintf("%08x", header.Time)
+	for i, miner := range miners {
+		if res, _ := miner.call("mining.submit", fmt.Sprintf("worker%d", i), ids[i], "", ntime, "00000000"); string(res.Result) != "true" {
+			t.Fatalf("miner %d: share rejected: %v", i, res.Error)
+		}
+	}
+	if res, _ := miners[1].call("mining.submit", "worker1", ids[1], "", ntime, "00000000"); len(res.Error) == 0 || res.Error[0] != float64(22) {
+		t.Fatalf("duplicate share error mismatch: %v", res.Error)
+	}
+	// A block sealed by a session carries its extranonce
+	_, nonce := miners[1].mine(jobs[1], CompactToBig(hykash.compactTarget(header.Difficulty)))
+	if res, _ := miners[1].call("mining.submit", "worker1", ids[1], "", ntime, fmt.Sprintf("%08x", nonce)); string(res.Result) != "true" {
+		t.Fatalf("block share rejected: %v", res.Error)
+	}
+	select {
+	case block := <-results:
+		if want := "hayekchain" + string(mustDecodeHex(t, extranonces[1])); string(block.Extra()) != want {
+			t.Fatalf("sealed block extra-data mismatch: have %q, want %q", block.Extra(), want)
+		}
+		if err := hykash.VerifySeal(nil, block.Header()); err != nil {
+			t.Fatalf("stratum sealed block failed verification: %v", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("sealed block timed out")
+	}
+}
+
+func mustDecodeHex(t *testing.T, s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatalf("failed to decode hex %q: %v", s, err)
+	}
+	return b
+}
+
+// Tests that a miner not reading its messages doesn't hold up the jobs sent to
+// other miners, and gets disconnected once its send queue overflows.
+func TestStratumStalledMiner(t *testing.T) {
+	hykash := NewTester(nil, false)
+	hykash.config.PowSwitches = sha256dRules()
+	hykash.SetThreads(-1)
+	defer hykash.Close()
+
+	stratum, err := startStratumServer(hykash, "127.0.0.1:0", 1)
+	if err != nil {
+		t.Fatalf("failed to start stratum server: %v", err)
+	}
+	hykash.stratum = stratum
+
+	// Subscribe a miner over an unbuffered pipe and stop reading from it
+	conn, server := net.Pipe()
+	defer conn.Close()
+	stratum.serveConn(server)
+
+	stalled := &fakeMiner{t: t, conn: conn, reader: bufio.NewReader(conn)}
+	if res, _ := stalled.call("mining.subscribe", "fakeminer/1.0"); res.Error != nil {
+		t.Fatalf("stalled subscription failed: %v", res.Error)
+	}
+	miner := newFakeMiner(t, stratum.listener.Addr().String())
+	defer miner.conn.Close()
+
+	if res, _ := miner.call("mining.subscribe", "fakeminer/1.0"); res.Error != nil {
+		t.Fatalf("subscription failed: %v", res.Error)
+	}
+	// Push enough jobs to overflow the queue of the stalled miner
+	start := time.Now()
+	for i := 0; i <= stratumSendQueue; i++ {
+		header := &types.Header{Number: big.NewInt(1), Difficulty: big.NewInt(100), Time: uint64(1600000000 + i)}
+		stratum.newJob(types.NewBlockWithHeader(header))
+	}
+	if elapsed := time.Since(start); elapsed > stratumWriteTimeout/2 {
+		t.Fatalf("job distribution blocked by stalled miner for %v", elapsed)
+	}
+	last := strconv.FormatUint(stratum.sequence, 16)
+	for {
+		msg := miner.read()
+		if msg.Method != "mining.notify" {
+			continue
+		}
+		var id string
+		json.Unmarshal(msg.Params[0], &id)
+		if id == last {
+			break
+		}
+	}
+	// The stalled miner must have been dropped
+	for i := 0; ; i++ {
+		stratum.lock.Lock()
+		sessions := len(stratum.sessions)
+		stratum.lock.Unlock()
+		if sessions == 1 {
+			break
+		}
+		if i == 100 {
+			t.Fatalf("stalled miner not dropped, %d sessions live", sessions)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// Tests that the share difficulty of a session follows its share rate.
+func TestStratumVardiff(t *testing.T) {
+	start := time.Now()
+	tests := []struct {
+		difficulty uint64
+		shares     int
+		elapsed    time.Duration
+		want       uint64
+		changed    bool
+	}{
+		{100, 6, stratumRetargetInterval, 100, false},       // on target
+		{100, 12, stratumRetargetInterval, 200, true},       // twice too fast
+		{100, 3, stratumRetargetInterval, 50, true},         // twice too slow
+		{100, 600, stratumRetargetInterval, 400, true},      // capped increase
+		{100, 1, 10 * stratumRetargetInterval, 25, true},    // capped decrease
+		{100, 0, stratumRetargetInterval, 25, true},         // no shares at all
+		{1, 0, stratumRetargetInterval, 1, false},           // minimum difficulty
+		{100, 100, stratumRetargetInterval / 2, 100, false}, // too early
+	}
+	for i, tt := range tests {
+		session := &stratumSession{difficulty: tt.difficulty, prevDiff: tt.difficulty, shares: tt.shares, since: start}
+		changed := session.retarget(start.Add(tt.elapsed))
+		if changed != tt.changed || session.difficulty != tt.want {
+			t.Errorf("test %d: difficulty mismatch: have %d/%v, want %d/%v", i, session.difficulty, changed, tt.want, tt.changed)
+		}
+		if changed && session.prevDiff != tt.difficulty {
+			t.Errorf("test %d: previous difficulty mismatch: have %d, want %d", i, session.prevDiff, tt.difficulty)
+		}
+	}
+}
@@ -130,6 +130,9 @@ type CacheConfig struct {
 	TrieTimeLimit       time.Duration // Time limit after which to flush the current in-memory trie to disk
 	SnapshotLimit       int           // Memory allowance (MB) to use for caching snapshot entries in memory
 	Preimages           bool          // Whhyker to store preimage of trie key to the disk
+	HistoryRetention    uint64        // Number of recent blocks to retain bodies and receipts for (0 = keep all)
+	ParallelExecution   bool          // Whether to execute block transactions optimistically in parallel
+	StateDiffs          bool          // Whether to record reverse state diffs for serving historic state
 
 	SnapshotWait bool // Wait for snapshot construction on startup. TODO(karalabe): This is a dirty hack for testing, nuke it
 }
@@ -221,6 +224,13 @@ func NewBlockChain(db hykdb.Database, cacheConfig *CacheConfig, chainConfig *par
 	if cacheConfig == nil {
 		cacheConfig = defaultCacheConfig
 	}
+	// Snapshots and the state diffs built on them only support hexary tries
+	if chainConfig.BinaryTrie && (cacheConfig.SnapshotLimit > 0 || cacheConfig.StateDiffs) {
+		log.Warn("Snapshots are not supported with binary state tries, disabling")
+		config := *cacheConfig
+		config.SnapshotLimit, config.StateDiffs = 0, false
+		cacheConfig = &config
+	}
 	bodyCache, _ := lru.New(bodyCacheLimit)
 	bodyRLPCache, _ := lru.New(bodyCacheLimit)
 	receiptsCache, _ := lru.New(receiptsCacheLimit)
@@ -238,6 +248,7 @@ func NewBlockChain(db hykdb.Database, cacheConfig *CacheConfig, chainConfig *par
 			Cache:     cacheConfig.TrieCleanLimit,
 			Journal:   cacheConfig.TrieCleanJournal,
 			Preimages: cacheConfig.Preimages,
+			Binary:    chainConfig.BinaryTrie,
 		}),
 		quit:           make(chan struct{}),
 		shouldPreserve: shouldPreserve,
@@ -253,7 +264,11 @@ func NewBlockChain(db hykdb.Database, cacheConfig *CacheConfig, chainConfig *par
 	}
 	bc.validator = NewBlockValidator(chainConfig, bc, engine)
 	bc.prefetcher = newStatePrefetcher(chainConfig, bc, engine)
-	bc.processor = NewStateProcessor(chainConfig, bc, engine)
+	if cacheConfig.ParallelExecution {
+		bc.processor = NewParallelStateProcessor(chainConfig, bc, engine)
+	} else {
+		bc.processor = NewStateProcessor(chainConfig, bc, engine)
+	}
 
 	var err error
 	bc.hc, err = NewHeaderChain(db, chainConfig, engine, bc.insertStopped)
@@ -376,7 +391,7 @@ func NewBlockChain(db hykdb.Database, cacheConfig *CacheConfig, chainConfig *par
 			log.Warn("Enabling snapshot recovery", "chainhead", head.NumberU64(), "diskbase", *layer)
 			recover = true
 		}
-		bc.snaps = snapshot.New(bc.db, bc.stateCache.TrieDB(), bc.cacheConfig.SnapshotLimit, head.Root(), !bc.cacheConfig.SnapshotWait, recover)
+		bc.snaps, _ = snapshot.New(bc.db, bc.stateCache.TrieDB(), bc.cacheConfig.SnapshotLimit, head.Root(), !bc.cacheConfig.SnapshotWait, true, recover)
 	}
 	// Take ownership of this particular state
 	go bc.update()
@@ -386,6 +401,15 @@ func NewBlockChain(db hykdb.Database, cacheConfig *CacheConfig, chainConfig *par
 		bc.wg.Add(1)
 		go bc.maintainTxIndex(txIndexBlock)
 	}
+	// Resume indexing reverse state diffs from the current head
+	if bc.cacheConfig.StateDiffs {
+		bc.initStateDiffs()
+	}
+	// Start pruning ancient bodies and receipts if a retention window is set
+	if bc.cacheConfig.HistoryRetention > 0 {
+		bc.wg.Add(1)
+		go bc.maintainHistory()
+	}
 	// If periodic cache journal is required, spin it up.
 	if bc.cacheConfig.TrieCleanRejournal > 0 {
 		if bc.cacheConfig.TrieCleanRejournal < time.Minute {
@@ -620,6 +644,11 @@ func (bc *BlockChain) SetHeadBeyondRoot(head uint64, root common.Hash) (uint64,
 	bc.txLookupCache.Purge()
 	bc.futureBlocks.Purge()
 
+	// Drop the reverse state diffs of the rewound blocks
+	if bc.cacheConfig.StateDiffs {
+		head := bc.CurrentBlock()
+		bc.unindexStateDiffs(head.NumberU64(), head.Hash(), true)
+	}
 	return rootNumber, bc.loadLastState()
 }
 
@@ -690,8 +719,17 @@ func (bc *BlockChain) State() (*state.StateDB, error) {
 }
 
 // StateAt returns a new mutable state based on a particular point in time.
+//
+// If the tries of the state were already pruned but reverse state diffs are
+// recorded, a read only historic state is reconstructed from them instead.
 func (bc *BlockChain) StateAt(root common.Hash) (*state.StateDB, error) {
-	return state.New(root, bc.stateCache, bc.snaps)
+	statedb, err := state.New(root, bc.stateCache, bc.snaps)
+	if err != nil && bc.cacheConfig.StateDiffs {
+		if historic, herr := bc.historicState(root); herr == nil {
+			return historic, nil
+		}
+	}
+	return statedb, err
 }
 
 // StateCache returns the caching database underpinning the blockchain instance.
@@ -781,6 +819,9 @@ func (bc *BlockChain) writeHeadBlock(block *types.Block) {
 	rawdb.WriteCanonicalHash(batch, block.Hash(), block.NumberU64())
 	rawdb.WriteTxLookupEntriesByBlock(batch, block)
 	rawdb.WriteHeadBlockHash(batch, block.Hash())
+	if bc.cacheConfig.StateDiffs {
+		bc.indexStateDiff(batch, block)
+	}
 
 	// If the block is better than our head or is on a different chain, force update heads
 	if updateHeads {
@@ -1459,6 +1500,17 @@ func (bc *BlockChain) TxLookupLimit() uint64 {
 	return bc.txLookupLimit
 }
 
+// HistoryTail retrieves the number of the first block whose body and receipts
+// are still retained by the database. Anything below was discarded by history
+// pruning.
+func (bc *BlockChain) HistoryTail() uint64 {
+	tail, err := bc.db.AncientTail()
+	if err != nil {
+		return 0
+	}
+	return tail
+}
+
 var lastWrite uint64
 
 // writeBlockWithoutState writes only the block and its metadata to the database,
@@ -1530,10 +1582,20 @@ func (bc *BlockChain) writeBlockWithState(block *types.Block, receipts []*types.
 		log.Crit("Failed to write block into disk", "err", err)
 	}
 	// Commit all cached state changes into underlying memory database.
+	if bc.cacheConfig.StateDiffs {
+		state.RecordStateDiff()
+	}
 	root, err := state.Commit(bc.chainConfig.IsEIP158(block.Number()))
 	if err != nil {
 		return NonStatTy, err
 	}
+	if diff := state.StateDiff(); diff != nil {
+		blob, err := rlp.EncodeToBytes(diff)
+		if err != nil {
+			return NonStatTy, err
+		}
+		rawdb.WriteStateDiff(bc.db, block.NumberU64(), block.Hash(), blob)
+	}
 	triedb := bc.stateCache.TrieDB()
 
 	// If we're running an archive node, always flush
@@ -2231,6 +2293,10 @@ func (bc *BlockChain) reorg(oldBlock, newBlock *types.Block) error {
 	} else {
 		log.Error("Impossible reorg, please file an issue", "oldnum", oldBlock.Number(), "oldhash", oldBlock.Hash(), "newnum", newBlock.Number(), "newhash", newBlock.Hash())
 	}
+	// Drop the reverse state diffs of the old chain before indexing the new one
+	if bc.cacheConfig.StateDiffs {
+		bc.unindexStateDiffs(commonBlock.NumberU64(), commonBlock.Hash(), false)
+	}
 	// Insert the new chain(except the head block(reverse order)),
 	// taking care of the proper incremental order.
 	for i := len(newChain) - 1; i >= 1; i-- {
@@ -2314,6 +2380,10 @@ func (bc *BlockChain) maintainTxIndex(ancients uint64) {
 		if bc.txLookupLimit != 0 && ancients > bc.txLookupLimit {
 			from = ancients - bc.txLookupLimit
 		}
+		// Bodies below the history tail are pruned, they can't be indexed
+		if tail := bc.HistoryTail(); from < tail {
+			from = tail
+		}
 		rawdb.IndexTransactions(bc.db, from, ancients, bc.quit)
 	}
 	// indexBlocks reindexes or unindexes transactions depending on user configuration
@@ -2334,15 +2404,20 @@ func (bc *BlockChain) maintainTxIndex(ancients uint64) {
 		}
 		// If a previous indexing existed, make sure that we fill in any missing entries
 		if bc.txLookupLimit == 0 || head < bc.txLookupLimit {
-			if *tail > 0 {
-				rawdb.IndexTransactions(bc.db, 0, *tail, bc.quit)
+			if from := bc.HistoryTail(); *tail > from {
+				rawdb.IndexTransactions(bc.db, from, *tail, bc.quit)
 			}
 			return
 		}
 		// Update the transaction index to the new chain state
 		if head-bc.txLookupLimit+1 < *tail {
-			// Reindex a part of missing indices and rewind index tail to HEAD-limit
-			rawdb.IndexTransactions(bc.db, head-bc.txLookupLimit+1, *tail, bc.quit)
+			// Reindex a part of missing indices and rewind index tail to HEAD-limit,
+			// skipping any blocks whose bodies were pruned
+			from := head - bc.txLookupLimit + 1
+			if tail := bc.HistoryTail(); from < tail {
+				from = tail
+			}
+			rawdb.IndexTransactions(bc.db, from, *tail, bc.quit)
 		} else {
 			// Unindex a part of stale indices and forward index tail to HEAD-limit
 			rawdb.UnindexTransactions(bc.db, *tail, head-bc.txLookupLimit+1, bc.quit)
@@ -2378,6 +2453,77 @@ func (bc *BlockChain) maintainTxIndex(ancients uint64) {
 	}
 }
 
+// maintainHistory is responsible for discarding the bodies and receipts of
+// ancient blocks falling out of the configured retention window.
+//
+// Only frozen data is ever pruned, so the effective window cannot be shorter
+// than the freezer's immutability threshold. Transaction indices of the pruned
+// blocks are removed first, since they can't be regenerated afterwards.
+func (bc *BlockChain) maintainHistory() {
+	defer bc.wg.Done()
+
+	retention := bc.cacheConfig.HistoryRetention
+
+	// pruneHistory moves the history tail up to HEAD-retention+1
+	pruneHistory := func(head uint64, done chan struct{}) {
+		defer func() { done <- struct{}{} }()
+
+		if head < retention {
+			return
+		}
+		target := head - retention + 1
+		if frozen, err := bc.db.Ancients(); err != nil {
+			return
+		} else if target > frozen {
+			target = frozen
+		}
+		if target <= bc.HistoryTail() {
+			return
+		}
+		// Drop the transaction indices of the pruned blocks first, they can't
+		// be cleaned up once the bodies are gone
+		from := uint64(0)
+		if tail := rawdb.ReadTxIndexTail(bc.db); tail != nil {
+			from = *tail
+		}
+		if from < target {
+			rawdb.UnindexTransactions(bc.db, from, target, bc.quit)
+		}
+		if err := bc.db.TruncateAncientTail(target); err != nil {
+			log.Error("Failed to prune ancient history", "tail", target, "err", err)
+			return
+		}
+		log.Info("Pruned ancient history", "tail", target, "retention", retention)
+	}
+	var (
+		done   chan struct{}                  // Non-nil if background pruning routine is active.
+		headCh = make(chan ChainHeadEvent, 1) // Buffered to avoid locking up the event feed
+	)
+	sub := bc.SubscribeChainHeadEvent(headCh)
+	if sub == nil {
+		return
+	}
+	defer sub.Unsubscribe()
+
+	for {
+		select {
+		case head := <-headCh:
+			if done == nil {
+				done = make(chan struct{})
+				go pruneHistory(head.Block.NumberU64(), done)
+			}
+		case <-done:
+			done = nil
+		case <-bc.quit:
+			if done != nil {
+				log.Info("Waiting background history pruner to exit")
+				<-done
+			}
+			return
+		}
+	}
+}
+
 // BadBlocks returns a list of the last 'bad blocks' that the client has seen on the network
 func (bc *BlockChain) BadBlocks() []*types.Block {
 	blocks := make([]*types.Block, 0, bc.badBlocks.Len())
@@ -0,0 +1,173 @@
+// Copyright 2021 The go-hayekchain Authors
+// This file is part of the go-hayekchain library.
+//
+// The go-hayekchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hayekchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hayekchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"errors"
+
+	"github.com/hayekchain/go-hayekchain/common"
+	"github.com/hayekchain/go-hayekchain/core/rawdb"
+	"github.com/hayekchain/go-hayekchain/core/state"
+	"github.com/hayekchain/go-hayekchain/core/types"
+	"github.com/hayekchain/go-hayekchain/hykdb"
+	"github.com/hayekchain/go-hayekchain/log"
+	"github.com/hayekchain/go-hayekchain/rlp"
+)
+
+// errHistoricStateUnavailable is returned if a historic state is requested that
+// is not covered by the indexed reverse state diffs.
+var errHistoricStateUnavailable = errors.New("historic state unavailable")
+
+// initStateDiffs reconciles the reverse state diff index with the current head
+// block, starting a new history from it if the two are unrelated.
+func (bc *BlockChain) initStateDiffs() {
+	if bc.snaps == nil {
+		log.Warn("Reverse state diffs require snapshots, history will not be recorded")
+	}
+	head := bc.CurrentBlock()
+
+	index := rawdb.ReadStateDiffIndex(bc.db)
+	switch {
+	case index != nil && index.Head > head.NumberU64():
+		bc.unindexStateDiffs(head.NumberU64(), head.Hash(), true)
+
+	case index == nil || index.HeadHash != head.Hash():
+		log.Info("Starting state diff history", "number", head.Number(), "hash", head.Hash())
+		rawdb.WriteStateDiffIndex(bc.db, &rawdb.StateDiffIndex{
+			Tail:     head.NumberU64(),
+			Head:     head.NumberU64(),
+			HeadHash: head.Hash(),
+		})
+	}
+	rawdb.WriteStateDiffRoot(bc.db, head.Root(), head.NumberU64())
+}
+
+// indexStateDiff adds the reverse state diff of a new canonical head block to
+// the historic state index. If the block does not extend the indexed chain or
+// has no diff recorded, the history is restarted from the block.
+func (bc *BlockChain) indexStateDiff(db hykdb.KeyValueWriter, block *types.Block) {
+	number, hash := block.NumberU64(), block.Hash()
+
+	index := rawdb.ReadStateDiffIndex(bc.db)
+	if index != nil && index.HeadHash == hash {
+		return
+	}
+	// If the block overlaps the indexed chain without a reorg having unindexed
+	// it first, drop the overlapping diffs to not serve them
+	if index != nil && index.Head >= number {
+		var floor uint64
+		if number > 0 {
+			floor = number - 1
+		}
+		bc.unindexStateDiffs(floor, common.Hash{}, false)
+		index = rawdb.ReadStateDiffIndex(bc.db)
+	}
+	var diff *state.StateDiff
+	if index != nil && index.HeadHash == block.ParentHash() {
+		if blob := rawdb.ReadStateDiff(bc.db, number, hash); len(blob) > 0 {
+			diff = new(state.StateDiff)
+			if err := rlp.DecodeBytes(blob, diff); err != nil {
+				log.Error("Invalid state diff", "number", number, "hash", hash, "err", err)
+				diff = nil
+			}
+		}
+	}
+	if diff == nil {
+		if index != nil {
+			log.Warn("Restarting state diff history", "number", number, "hash", hash)
+		}
+		index = &rawdb.StateDiffIndex{Tail: number}
+	} else {
+		for _, account := range diff.Accounts {
+			rawdb.WriteAccountHistory(db, account.Hash, number, account.Blob)
+		}
+		for _, storage := range diff.Storage {
+			for i, slot := range storage.Slots {
+				rawdb.WriteStorageHistory(db, storage.Account, slot, number, storage.Values[i])
+			}
+		}
+	}
+	index.Head, index.HeadHash = number, hash
+	rawdb.WriteStateDiffIndex(db, index)
+	rawdb.WriteStateDiffRoot(db, block.Root(), number)
+}
+
+// unindexStateDiffs removes the reverse state diffs of all the blocks above the
+// given one from the historic state index, rewinding its head to the block. If
+// prune is set, the diffs themselves are deleted too.
+func (bc *BlockChain) unindexStateDiffs(number uint64, hash common.Hash, prune bool) {
+	index := rawdb.ReadStateDiffIndex(bc.db)
+	if index == nil || index.Head <= number {
+		return
+	}
+	batch := bc.db.NewBatch()
+	for n := index.Head; n > number; n-- {
+		for _, h := range rawdb.ReadStateDiffHashes(bc.db, n) {
+			diff := new(state.StateDiff)
+			if err := rlp.DecodeBytes(rawdb.ReadStateDiff(bc.db, n, h), diff); err != nil {
+				log.Error("Invalid state diff", "number", n, "hash", h, "err", err)
+				continue
+			}
+			for _, account := range diff.Accounts {
+				rawdb.DeleteAccountHistory(batch, account.Hash, n)
+			}
+			for _, storage := range diff.Storage {
+				for _, slot := range storage.Slots {
+					rawdb.DeleteStorageHistory(batch, storage.Account, slot, n)
+				}
+			}
+			if prune {
+				rawdb.DeleteStateDiff(batch, n, h)
+			}
+		}
+		if batch.ValueSize() > hykdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				log.Crit("Failed to unindex state diffs", "err", err)
+			}
+			batch.Reset()
+		}
+	}
+	index.Head, index.HeadHash = number, hash
+	if index.Tail > number {
+		index.Tail = number
+	}
+	rawdb.WriteStateDiffIndex(batch, index)
+	if err := batch.Write(); err != nil {
+		log.Crit("Failed to unindex state diffs", "err", err)
+	}
+}
+
+// historicState reconstructs the canonical state with the given root from the
+// indexed reverse state diffs.
+func (bc *BlockChain) historicState(root common.Hash) (*state.StateDB, error) {
+	number := rawdb.ReadStateDiffRoot(bc.db, root)
+	if number == nil {
+		return nil, errHistoricStateUnavailable
+	}
+	index := rawdb.ReadStateDiffIndex(bc.db)
+	if index == nil || *number < index.Tail || *number > index.Head {
+		return nil, errHistoricStateUnavailable
+	}
+	if header := bc.GetHeaderByNumber(*number); header == nil || header.Root != root {
+		return nil, errHistoricStateUnavailable
+	}
+	head := bc.GetHeaderByHash(index.HeadHash)
+	if head == nil {
+		return nil, errHistoricStateUnavailable
+	}
+	return state.NewHistoric(root, *number, head.Root, bc.stateCache, bc.snaps)
+}
@@ -0,0 +1,174 @@
+// Copyright 2021 The go-hayekchain Authors
+// This file is part of the go-hayekchain library.
+//
+// The go-hayekchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hayekchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hayekchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/hayekchain/go-hayekchain/common"
+	"github.com/hayekchain/go-hayekchain/consensus/hykash"
+	"github.com/hayekchain/go-hayekchain/core/rawdb"
+	"github.com/hayekchain/go-hayekchain/core/state"
+	"github.com/hayekchain/go-hayekchain/core/types"
+	"github.com/hayekchain/go-hayekchain/core/vm"
+	"github.com/hayekchain/go-hayekchain/crypto"
+	"github.com/hayekchain/go-hayekchain/params"
+)
+
+var (
+	// stateDiffStoreCode stores the call value in the slot of the block number
+	// and the block number in slot 0.
+	stateDiffStoreCode = common.FromHex("3443554360005500")
+
+	// stateDiffDestructCode self destructs, sending its balance to the caller.
+	stateDiffDestructCode = common.FromHex("33ff")
+)
+
+// Tests that historic states reconstructed from the reverse state diffs match
+// the states of an archive node, across reorgs and chain rewinds.
+func TestHistoricStateDiffs(t *testing.T) {
+	var (
+		key, _   = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address  = crypto.PubkeyToAddress(key.PublicKey)
+		store    = common.HexToAddress("0x000000000000000000000000000000000000cccc")
+		destruct = common.HexToAddress("0x000000000000000000000000000000000000dddd")
+		signer   = types.HomesteadSigner{}
+		engine   = hykash.NewFaker()
+		gspec    = &Genesis{
+			Config: params.TestChainConfig,
+			Alloc: GenesisAlloc{
+				address:  {Balance: big.NewInt(params.Hayeker)},
+				store:    {Code: stateDiffStoreCode, Balance: new(big.Int)},
+				destruct: {Code: stateDiffDestructCode, Balance: big.NewInt(1), Storage: map[common.Hash]common.Hash{{1}: {1}}},
+			},
+		}
+		gendb   = rawdb.NewMemoryDatabase()
+		genesis = gspec.MustCommit(gendb)
+	)
+	// Generate a canonical chain and a fork replacing its last blocks
+	generate := func(parent *types.Block, n int, fork bool) []*types.Block {
+		blocks, _ := GenerateChain(gspec.Config, parent, engine, gendb, n, func(i int, b *BlockGen) {
+			nonce := b.TxNonce(address)
+			recipient := common.BigToAddress(b.Number())
+			if fork {
+				recipient = common.BigToAddress(new(big.Int).Add(b.Number(), big.NewInt(1000)))
+			}
+			tx, _ := types.SignTx(types.NewTransaction(nonce, recipient, big.NewInt(1000), params.TxGas, big.NewInt(1), nil), signer, key)
+			b.AddTx(tx)
+			tx, _ = types.SignTx(types.NewTransaction(nonce+1, store, b.Number(), 100000, big.NewInt(1), nil), signer, key)
+			b.AddTx(tx)
+			if b.Number().Uint64() == 5 {
+				tx, _ = types.SignTx(types.NewTransaction(nonce+2, destruct, new(big.Int), 100000, big.NewInt(1), nil), signer, key)
+				b.AddTx(tx)
+			}
+		})
+		return blocks
+	}
+	blocks := generate(genesis, 16, false)
+	forks := generate(blocks[9], 8, true)
+
+	// Import the chains into an archive node to compare against
+	archivedb := rawdb.NewMemoryDatabase()
+	gspec.MustCommit(archivedb)
+	archive, _ := NewBlockChain(archivedb, &CacheConfig{TrieDirtyDisabled: true}, gspec.Config, engine, vm.Config{}, nil, nil)
+	defer archive.Stop()
+
+	if n, err := archive.InsertChain(blocks); err != nil {
+		t.Fatalf("block %d: failed to insert into archive: %v", n, err)
+	}
+	if n, err := archive.InsertChain(forks); err != nil {
+		t.Fatalf("block %d: failed to insert fork into archive: %v", n, err)
+	}
+	// Import the chain into a node recording state diffs
+	db := rawdb.NewMemoryDatabase()
+	gspec.MustCommit(db)
+
+	cacheConfig := *defaultCacheConfig
+	cacheConfig.StateDiffs = true
+	chain, err := NewBlockChain(db, &cacheConfig, gspec.Config, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+
+	addresses := []common.Address{address, store, destruct}
+	for i := 1; i <= 32; i++ {
+		addresses = append(addresses, common.BigToAddress(big.NewInt(int64(i))), common.BigToAddress(big.NewInt(int64(i+1000))))
+	}
+	check := func(blocks []*types.Block) {
+		t.Helper()
+		for _, block := range append([]*types.Block{genesis}, blocks...) {
+			historic, err := chain.historicState(block.Root())
+			if err != nil {
+				t.Fatalf("block %d: failed to reconstruct state: %v", block.NumberU64(), err)
+			}
+			want, err := state.New(block.Root(), archive.stateCache, nil)
+			if err != nil {
+				t.Fatalf("block %d: failed to open archive state: %v", block.NumberU64(), err)
+			}
+			for _, addr := range addresses {
+				if have, want := historic.GetBalance(addr), want.GetBalance(addr); have.Cmp(want) != 0 {
+					t.Errorf("block %d, account %x: balance mismatch: have %v, want %v", block.NumberU64(), addr, have, want)
+				}
+				if have, want := historic.GetNonce(addr), want.GetNonce(addr); have != want {
+					t.Errorf("block %d, account %x: nonce mismatch: have %d, want %d", block.NumberU64(), addr, have, want)
+				}
+				if have, want := historic.GetCode(addr), want.GetCode(addr); !bytes.Equal(have, want) {
+					t.Errorf("block %d, account %x: code mismatch: have %x, want %x", block.NumberU64(), addr, have, want)
+				}
+				for slot := int64(0); slot <= 24; slot++ {
+					key := common.BigToHash(big.NewInt(slot))
+					if have, want := historic.GetState(addr, key), want.GetState(addr, key); have != want {
+						t.Errorf("block %d, account %x, slot %d: storage mismatch: have %x, want %x", block.NumberU64(), addr, slot, have, want)
+					}
+				}
+			}
+		}
+	}
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("block %d: failed to insert: %v", n, err)
+	}
+	check(blocks)
+
+	// Reorg to the fork and ensure the history follows it
+	if n, err := chain.InsertChain(forks); err != nil {
+		t.Fatalf("block %d: failed to insert fork: %v", n, err)
+	}
+	check(append(blocks[:10:10], forks...))
+	for _, block := range blocks[10:] {
+		if _, err := chain.historicState(block.Root()); err == nil {
+			t.Errorf("block %d: reorged state available", block.NumberU64())
+		}
+	}
+	// Rewind the chain and ensure the rewound states are not served anymore
+	if err := chain.SetHead(6); err != nil {
+		t.Fatalf("failed to rewind chain: %v", err)
+	}
+	check(blocks[:6])
+	for _, block := range blocks[6:10] {
+		if _, err := chain.historicState(block.Root()); err == nil {
+			t.Errorf("block %d: rewound state available", block.NumberU64())
+		}
+	}
+	// Historic states must not be committable
+	historic, _ := chain.historicState(blocks[2].Root())
+	if _, err := historic.Commit(false); err == nil {
+		t.Errorf("historic state committed")
+	}
+}
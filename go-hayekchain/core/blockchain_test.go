@@ -17,6 +17,7 @@
 package core
 
 import (
+	"crypto/sha256"
 	"errors"
 	"fmt"
 	"io/ioutil"
@@ -37,6 +38,7 @@ import (
 	"github.com/hayekchain/go-hayekchain/crypto"
 	"github.com/hayekchain/go-hayekchain/hykdb"
 	"github.com/hayekchain/go-hayekchain/params"
+	"github.com/hayekchain/go-hayekchain/rlp"
 	"github.com/hayekchain/go-hayekchain/trie"
 )
 
@@ -46,13 +48,20 @@ var (
 	forkSeed      = 2
 )
 
+// testGenesisDifficulty is the genesis difficulty of the chains whose tests rely
+// on the block time driving the difficulty. At the protocol genesis and minimum
+// difficulty of 1 the adjustment (parent / 2048) rounds down to nothing, leaving
+// every block with the same difficulty. Committed explicitly, the genesis also
+// counts towards the total difficulty of the chain.
+var testGenesisDifficulty = big.NewInt(131072)
+
 // newCanonical creates a chain database, and injects a deterministic canonical
 // chain. Depending on the full flag, if creates either a full block chain or a
 // header only chain.
 func newCanonical(engine consensus.Engine, n int, full bool) (hykdb.Database, *BlockChain, error) {
 	var (
 		db      = rawdb.NewMemoryDatabase()
-		genesis = new(Genesis).MustCommit(db)
+		genesis = (&Genesis{Difficulty: testGenesisDifficulty}).MustCommit(db)
 	)
 
 	// Initialize a fresh chain with only a genesis block
@@ -351,7 +360,7 @@ func TestReorgLongHeaders(t *testing.T) { testReorgLong(t, false) }
 func TestReorgLongBlocks(t *testing.T)  { testReorgLong(t, true) }
 
 func testReorgLong(t *testing.T, full bool) {
-	testReorg(t, []int64{0, 0, -9}, []int64{0, 0, 0, -9}, 393280, full)
+	testReorg(t, []int64{0, 0, -9}, []int64{0, 0, 0, -9}, 393280+testGenesisDifficulty.Int64(), full)
 }
 
 // Tests that reorganising a short difficult chain after a long easy one
@@ -371,7 +380,7 @@ func testReorgShort(t *testing.T, full bool) {
 	for i := 0; i < len(diff); i++ {
 		diff[i] = -9
 	}
-	testReorg(t, easy, diff, 12615120, full)
+	testReorg(t, easy, diff, 12615120+testGenesisDifficulty.Int64(), full)
 }
 
 func testReorg(t *testing.T, first, second []int64, td int64, full bool) {
@@ -996,7 +1005,7 @@ func TestLogRebirth(t *testing.T) {
 		key1, _       = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
 		addr1         = crypto.PubkeyToAddress(key1.PublicKey)
 		db            = rawdb.NewMemoryDatabase()
-		gspec         = &Genesis{Config: params.TestChainConfig, Alloc: GenesisAlloc{addr1: {Balance: big.NewInt(10000000000000)}}}
+		gspec         = &Genesis{Config: params.TestChainConfig, Difficulty: testGenesisDifficulty, Alloc: GenesisAlloc{addr1: {Balance: big.NewInt(10000000000000)}}}
 		genesis       = gspec.MustCommit(db)
 		signer        = types.NewEIP155Signer(gspec.Config.ChainID)
 		engine        = hykash.NewFaker()
@@ -1060,7 +1069,7 @@ func TestSideLogRebirth(t *testing.T) {
 		key1, _       = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
 		addr1         = crypto.PubkeyToAddress(key1.PublicKey)
 		db            = rawdb.NewMemoryDatabase()
-		gspec         = &Genesis{Config: params.TestChainConfig, Alloc: GenesisAlloc{addr1: {Balance: big.NewInt(10000000000000)}}}
+		gspec         = &Genesis{Config: params.TestChainConfig, Difficulty: testGenesisDifficulty, Alloc: GenesisAlloc{addr1: {Balance: big.NewInt(10000000000000)}}}
 		genesis       = gspec.MustCommit(db)
 		signer        = types.NewEIP155Signer(gspec.Config.ChainID)
 		blockchain, _ = NewBlockChain(db, nil, gspec.Config, hykash.NewFaker(), vm.Config{}, nil, nil)
@@ -1131,8 +1140,9 @@ func TestReorgSideEvent(t *testing.T) {
 		key1, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
 		addr1   = crypto.PubkeyToAddress(key1.PublicKey)
 		gspec   = &Genesis{
-			Config: params.TestChainConfig,
-			Alloc:  GenesisAlloc{addr1: {Balance: big.NewInt(10000000000000)}},
+			Config:     params.TestChainConfig,
+			Difficulty: testGenesisDifficulty,
+			Alloc:      GenesisAlloc{addr1: {Balance: big.NewInt(10000000000000)}},
 		}
 		genesis = gspec.MustCommit(db)
 		signer  = types.NewEIP155Signer(gspec.Config.ChainID)
@@ -1832,7 +1842,7 @@ func testInsertKnownChainData(t *testing.T, typ string) {
 	engine := hykash.NewFaker()
 
 	db := rawdb.NewMemoryDatabase()
-	genesis := new(Genesis).MustCommit(db)
+	genesis := (&Genesis{Difficulty: testGenesisDifficulty}).MustCommit(db)
 
 	blocks, receipts := GenerateChain(params.TestChainConfig, genesis, engine, db, 32, func(i int, b *BlockGen) { b.SetCoinbase(common.Address{1}) })
 	// A longer chain but total difficulty is lower.
@@ -1852,7 +1862,7 @@ func testInsertKnownChainData(t *testing.T, typ string) {
 	if err != nil {
 		t.Fatalf("failed to create temp freezer db: %v", err)
 	}
-	new(Genesis).MustCommit(chaindb)
+	(&Genesis{Difficulty: testGenesisDifficulty}).MustCommit(chaindb)
 	defer os.RemoveAll(dir)
 
 	chain, err := NewBlockChain(chaindb, nil, params.TestChainConfig, engine, vm.Config{}, nil, nil)
@@ -1955,7 +1965,7 @@ func getLongAndShortChains() (*BlockChain, []*types.Block, []*types.Block, error
 	// Generate a canonical chain to act as the main dataset
 	engine := hykash.NewFaker()
 	db := rawdb.NewMemoryDatabase()
-	genesis := new(Genesis).MustCommit(db)
+	genesis := (&Genesis{Difficulty: testGenesisDifficulty}).MustCommit(db)
 
 	// Generate and import the canonical chain,
 	// Offset the time, to keep the difficulty low
@@ -1963,7 +1973,7 @@ func getLongAndShortChains() (*BlockChain, []*types.Block, []*types.Block, error
 		b.SetCoinbase(common.Address{1})
 	})
 	diskdb := rawdb.NewMemoryDatabase()
-	new(Genesis).MustCommit(diskdb)
+	(&Genesis{Difficulty: testGenesisDifficulty}).MustCommit(diskdb)
 
 	chain, err := NewBlockChain(diskdb, nil, params.TestChainConfig, engine, vm.Config{}, nil, nil)
 	if err != nil {
@@ -2196,6 +2206,90 @@ func TestTransactionIndices(t *testing.T) {
 	}
 }
 
+// Tests that the bodies, receipts and transaction indices of ancient blocks are
+// pruned once they fall out of the configured history retention window, while
+// the headers are kept around.
+func TestHistoryPruning(t *testing.T) {
+	// Configure and generate a sample block chain
+	var (
+		gendb   = rawdb.NewMemoryDatabase()
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		funds   = big.NewInt(1000000000)
+		gspec   = &Genesis{Config: params.TestChainConfig, Alloc: GenesisAlloc{address: {Balance: funds}}}
+		genesis = gspec.MustCommit(gendb)
+		signer  = types.NewEIP155Signer(gspec.Config.ChainID)
+	)
+	height := uint64(128)
+	blocks, receipts := GenerateChain(gspec.Config, genesis, hykash.NewFaker(), gendb, int(height), func(i int, block *BlockGen) {
+		tx, err := types.SignTx(types.NewTransaction(block.TxNonce(address), common.Address{0x00}, big.NewInt(1000), params.TxGas, nil, nil), signer, key)
+		if err != nil {
+			panic(err)
+		}
+		block.AddTx(tx)
+	})
+	frdir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("failed to create temp freezer dir: %v", err)
+	}
+	defer os.RemoveAll(frdir)
+	ancientDb, err := rawdb.NewDatabaseWithFreezer(rawdb.NewMemoryDatabase(), frdir, "")
+	if err != nil {
+		t.Fatalf("failed to create temp freezer db: %v", err)
+	}
+	defer ancientDb.Close()
+	gspec.MustCommit(ancientDb)
+
+	// Import all blocks into the ancient store with a retention window of 64 blocks
+	cacheConfig := *defaultCacheConfig
+	cacheConfig.HistoryRetention = 64
+
+	l := uint64(0)
+	chain, err := NewBlockChain(ancientDb, &cacheConfig, params.TestChainConfig, hykash.NewFaker(), vm.Config{}, nil, &l)
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	defer chain.Stop()
+
+	headers := make([]*types.Header, len(blocks))
+	for i, block := range blocks {
+		headers[i] = block.Header()
+	}
+	if n, err := chain.InsertHeaderChain(headers, 0); err != nil {
+		t.Fatalf("failed to insert header %d: %v", n, err)
+	}
+	if n, err := chain.InsertReceiptChain(blocks, receipts, 128); err != nil {
+		t.Fatalf("block %d: failed to insert into chain: %v", n, err)
+	}
+	// Announce the new head to trigger the history pruner
+	chain.chainHeadFeed.Send(ChainHeadEvent{Block: blocks[len(blocks)-1]})
+	time.Sleep(100 * time.Millisecond) // Wait for pruning to finish
+
+	// HEAD is 128, so everything below 128-64+1 should be gone
+	tail := uint64(65)
+	if have := chain.HistoryTail(); have != tail {
+		t.Fatalf("history tail mismatch: have %d, want %d", have, tail)
+	}
+	for i, block := range blocks {
+		number := block.NumberU64()
+		if chain.GetHeaderByNumber(number) == nil {
+			t.Fatalf("block %d: header missing", number)
+		}
+		pruned := number < tail
+		if have := chain.GetBlockByNumber(number) == nil; have != pruned {
+			t.Fatalf("block %d: body pruned mismatch: have %v, want %v", number, have, pruned)
+		}
+		if have := chain.GetReceiptsByHash(block.Hash()) == nil; have != pruned {
+			t.Fatalf("block %d: receipts pruned mismatch: have %v, want %v", number, have, pruned)
+		}
+		for _, tx := range blocks[i].Transactions() {
+			if have := rawdb.ReadTxLookupEntry(chain.db, tx.Hash()) == nil; have != pruned {
+				t.Fatalf("block %d: tx index pruned mismatch: have %v, want %v", number, have, pruned)
+			}
+		}
+	}
+}
+
 func TestSkipStaleTxIndicesInFastSync(t *testing.T) {
 	// Configure and generate a sample block chain
 	var (
@@ -3030,3 +3124,258 @@ func TestInitThenFailCreateContract(t *testing.T) {
 		}
 	}
 }
+
+// TestEIP1559Transition tests the base fee market: the miner is only paid the
+// effective tip, while the base fee is either burned or credited to the
+// configured collector.
+func TestEIP1559Transition(t *testing.T) {
+	t.Run("burn", func(t *testing.T) { testEIP1559Transition(t, nil) })
+	t.Run("collector", func(t *testing.T) { testEIP1559Transition(t, &common.Address{0xfe}) })
+}
+
+func testEIP1559Transition(t *testing.T, collector *common.Address) {
+	var (
+		aa = common.HexToAddress("0x000000000000000000000000000000000000aaaa")
+
+		engine = hykash.NewFaker()
+		db     = rawdb.NewMemoryDatabase()
+
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		funds   = big.NewInt(params.Hayeker)
+		config  = *params.TestChainConfig
+		gspec   = &Genesis{
+			Config: &config,
+			Alloc:  GenesisAlloc{address: {Balance: funds}},
+		}
+	)
+	config.YoloV2Block = common.Big0
+	config.LondonBlock = common.Big0
+	config.BaseFeeCollector = collector
+	genesis := gspec.MustCommit(db)
+
+	signer := types.LatestSigner(gspec.Config)
+	blocks, _ := GenerateChain(gspec.Config, genesis, engine, db, 2, func(i int, b *BlockGen) {
+		// The first block is empty and only used to measure the block reward
+		b.SetCoinbase(common.Address{byte(i + 1)})
+		if i == 0 {
+			return
+		}
+		tx, _ := types.SignNewTx(key, signer, &types.DynamicFeeTx{
+			ChainID:   gspec.Config.ChainID,
+			Nonce:     0,
+			To:        &aa,
+			Gas:       30000,
+			GasFeeCap: new(big.Int).Mul(big.NewInt(5), big.NewInt(params.GWei)),
+			GasTipCap: big.NewInt(2),
+		})
+		b.AddTx(tx)
+	})
+	diskdb := rawdb.NewMemoryDatabase()
+	gspec.MustCommit(diskdb)
+
+	chain, err := NewBlockChain(diskdb, nil, gspec.Config, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	defer chain.Stop()
+
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("block %d: failed to insert into chain: %v", n, err)
+	}
+	block := chain.GetBlockByNumber(2)
+	if block.BaseFee() == nil {
+		t.Fatalf("missing base fee in london block")
+	}
+	state, _ := chain.State()
+
+	// The miner should only receive the tip on top of the block reward
+	gasUsed := new(big.Int).SetUint64(block.GasUsed())
+	reward := state.GetBalance(common.Address{1})
+	tips := new(big.Int).Sub(state.GetBalance(common.Address{2}), reward)
+	if expected := new(big.Int).Mul(gasUsed, big.NewInt(2)); tips.Cmp(expected) != 0 {
+		t.Fatalf("miner tip mismatch: have %v, want %v", tips, expected)
+	}
+	// The sender should pay both the base fee and the tip
+	baseFees := new(big.Int).Mul(gasUsed, block.BaseFee())
+	paid := new(big.Int).Sub(funds, state.GetBalance(address))
+	if expected := new(big.Int).Add(baseFees, tips); paid.Cmp(expected) != 0 {
+		t.Fatalf("sender payment mismatch: have %v, want %v", paid, expected)
+	}
+	// The base fee should be burned or end up in the collector
+	if collector != nil {
+		if have := state.GetBalance(*collector); have.Cmp(baseFees) != 0 {
+			t.Fatalf("collector balance mismatch: have %v, want %v", have, baseFees)
+		}
+	}
+}
+
+// TestSponsoredTransaction tests that the gas of sponsored transactions is paid
+// by the fee payer, while the sender only pays the transferred value.
+func TestSponsoredTransaction(t *testing.T) {
+	var (
+		aa = common.HexToAddress("0x000000000000000000000000000000000000aaaa")
+
+		engine = hykash.NewFaker()
+		db     = rawdb.NewMemoryDatabase()
+
+		senderKey, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		payerKey, _  = crypto.HexToECDSA("8a1f9a8f95be41cd7ccb6168179afb4504aefe388d1e14474d32c45c72ce7b7a")
+		sender       = crypto.PubkeyToAddress(senderKey.PublicKey)
+		payer        = crypto.PubkeyToAddress(payerKey.PublicKey)
+		funds        = big.NewInt(params.Hayeker)
+		config       = *params.TestChainConfig
+		gspec        = &Genesis{
+			Config: &config,
+			Alloc: GenesisAlloc{
+				sender: {Balance: big.NewInt(1000)},
+				payer:  {Balance: funds},
+			},
+		}
+	)
+	config.YoloV2Block = common.Big0
+	config.LondonBlock = common.Big0
+	config.SponsorBlock = common.Big0
+	genesis := gspec.MustCommit(db)
+
+	signer := types.LatestSigner(gspec.Config).(types.SponsorSigner)
+	blocks, _ := GenerateChain(gspec.Config, genesis, engine, db, 1, func(i int, b *BlockGen) {
+		b.SetCoinbase(common.Address{1})
+		tx, err := types.SignNewTx(senderKey, signer, &types.SponsoredTx{
+			ChainID:   gspec.Config.ChainID,
+			Nonce:     0,
+			To:        &aa,
+			Value:     big.NewInt(1000),
+			Gas:       30000,
+			GasFeeCap: new(big.Int).Mul(big.NewInt(5), big.NewInt(params.GWei)),
+			GasTipCap: big.NewInt(2),
+			FeePayer:  payer,
+		})
+		if err != nil {
+			t.Fatalf("failed to sign transaction: %v", err)
+		}
+		if tx, err = types.SignFeePayerTx(tx, signer, payerKey); err != nil {
+			t.Fatalf("failed to sign transaction as fee payer: %v", err)
+		}
+		b.AddTx(tx)
+	})
+	diskdb := rawdb.NewMemoryDatabase()
+	gspec.MustCommit(diskdb)
+
+	chain, err := NewBlockChain(diskdb, nil, gspec.Config, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	defer chain.Stop()
+
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("block %d: failed to insert into chain: %v", n, err)
+	}
+	block := chain.CurrentBlock()
+	state, _ := chain.State()
+
+	// The sender should only have paid the value, the fee payer all the fees
+	if have := state.GetBalance(sender); have.Sign() != 0 {
+		t.Fatalf("sender balance mismatch: have %v, want 0", have)
+	}
+	if have := state.GetBalance(aa); have.Cmp(big.NewInt(1000)) != 0 {
+		t.Fatalf("recipient balance mismatch: have %v, want 1000", have)
+	}
+	if have := state.GetNonce(sender); have != 1 {
+		t.Fatalf("sender nonce mismatch: have %d, want 1", have)
+	}
+	gasUsed := new(big.Int).SetUint64(block.GasUsed())
+	fees := new(big.Int).Mul(gasUsed, new(big.Int).Add(block.BaseFee(), big.NewInt(2)))
+	if paid := new(big.Int).Sub(funds, state.GetBalance(payer)); paid.Cmp(fees) != 0 {
+		t.Fatalf("fee payer payment mismatch: have %v, want %v", paid, fees)
+	}
+}
+
+// Tests that a chain can store its state in binary tries, selected in genesis.
+func TestBinaryTrieChain(t *testing.T) {
+	var (
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		store   = common.HexToAddress("0x000000000000000000000000000000000000cccc")
+		engine  = hykash.NewFaker()
+		config  = *params.TestChainConfig
+		gspec   = &Genesis{
+			Config: &config,
+			Alloc: GenesisAlloc{
+				address: {Balance: big.NewInt(params.Hayeker)},
+				store:   {Code: stateDiffStoreCode, Balance: new(big.Int)},
+			},
+		}
+	)
+	hexary := gspec.ToBlock(nil)
+	config.BinaryTrie = true
+
+	gendb := rawdb.NewMemoryDatabase()
+	genesis := gspec.MustCommit(gendb)
+	if genesis.Root() == hexary.Root() {
+		t.Fatalf("binary genesis root matches hexary one")
+	}
+	signer := types.HomesteadSigner{}
+	blocks, _ := GenerateChain(gspec.Config, genesis, engine, gendb, 8, func(i int, b *BlockGen) {
+		nonce := b.TxNonce(address)
+		tx, _ := types.SignTx(types.NewTransaction(nonce, common.BigToAddress(new(big.Int).Add(b.Number(), big.NewInt(0x1000))), big.NewInt(1000), params.TxGas, big.NewInt(1), nil), signer, key)
+		b.AddTx(tx)
+		tx, _ = types.SignTx(types.NewTransaction(nonce+1, store, b.Number(), 100000, big.NewInt(1), nil), signer, key)
+		b.AddTx(tx)
+	})
+	db := rawdb.NewMemoryDatabase()
+	gspec.MustCommit(db)
+
+	chain, err := NewBlockChain(db, nil, gspec.Config, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("block %d: failed to insert: %v", n, err)
+	}
+	chain.Stop()
+
+	// Reopen the chain and ensure the state is intact and provable
+	chain, err = NewBlockChain(db, nil, gspec.Config, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to reopen chain: %v", err)
+	}
+	defer chain.Stop()
+
+	head := chain.CurrentBlock()
+	if head.Hash() != blocks[len(blocks)-1].Hash() {
+		t.Fatalf("head mismatch: have %x, want %x", head.Hash(), blocks[len(blocks)-1].Hash())
+	}
+	statedb, err := chain.StateAt(head.Root())
+	if err != nil {
+		t.Fatalf("failed to open head state: %v", err)
+	}
+	for i := int64(1); i <= 8; i++ {
+		if have := statedb.GetBalance(common.BigToAddress(big.NewInt(0x1000+i))); have.Cmp(big.NewInt(1000)) != 0 {
+			t.Errorf("recipient %d: balance mismatch: have %v, want 1000", i, have)
+		}
+		if have := statedb.GetState(store, common.BigToHash(big.NewInt(i))); have != common.BigToHash(big.NewInt(i)) {
+			t.Errorf("slot %d: storage mismatch: have %x, want %x", i, have, i)
+		}
+	}
+	proof, err := statedb.GetProof(address)
+	if err != nil {
+		t.Fatalf("failed to prove account: %v", err)
+	}
+	proofs := rawdb.NewMemoryDatabase()
+	for _, node := range proof {
+		hash := sha256.Sum256(node)
+		proofs.Put(hash[:], node)
+	}
+	blob, err := trie.VerifyBinaryProof(head.Root(), crypto.Keccak256(address.Bytes()), proofs)
+	if err != nil {
+		t.Fatalf("failed to verify account proof: %v", err)
+	}
+	var account state.Account
+	if err := rlp.DecodeBytes(blob, &account); err != nil {
+		t.Fatalf("failed to decode proven account: %v", err)
+	}
+	if account.Nonce != 16 {
+		t.Fatalf("proven nonce mismatch: have %d, want 16", account.Nonce)
+	}
+}
@@ -17,6 +17,7 @@
 package core
 
 import (
+	"errors"
 	"math/big"
 	"reflect"
 	"testing"
@@ -162,3 +163,22 @@ func TestSetupGenesis(t *testing.T) {
 		}
 	}
 }
+
+// Tests that the state trie format of a stored chain can't be switched, not even
+// at block zero where compatibility errors are otherwise ignored.
+func TestSetupGenesisBinaryTrieMismatch(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+	genesis := &Genesis{Config: &params.ChainConfig{HomesteadBlock: big.NewInt(1)}}
+	genesis.MustCommit(db)
+
+	binary := *genesis.Config
+	binary.BinaryTrie = true
+	genesis.Config = &binary
+
+	if _, _, err := SetupGenesisBlock(db, genesis); !errors.Is(err, errBinaryTrieMismatch) {
+		t.Fatalf("setup error mismatch: have %v, want %v", err, errBinaryTrieMismatch)
+	}
+	if stored := rawdb.ReadChainConfig(db, rawdb.ReadCanonicalHash(db, 0)); stored.BinaryTrie {
+		t.Fatalf("binary trie flag overwritten")
+	}
+}
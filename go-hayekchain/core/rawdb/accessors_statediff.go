@@ -0,0 +1,177 @@
+// Copyright 2021 The go-hayekchain Authors
+// This file is part of the go-hayekchain library.
+//
+// The go-hayekchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hayekchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hayekchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	"github.com/hayekchain/go-hayekchain/common"
+	"github.com/hayekchain/go-hayekchain/hykdb"
+	"github.com/hayekchain/go-hayekchain/log"
+	"github.com/hayekchain/go-hayekchain/rlp"
+)
+
+// StateDiffIndex is the range of canonical blocks whose reverse state diffs are
+// indexed. Historic states from Tail up to Head can be reconstructed by applying
+// the indexed diffs on top of the state of the Head block.
+type StateDiffIndex struct {
+	Tail     uint64      // Oldest block whose state can be reconstructed
+	Head     uint64      // Newest block whose diff is indexed
+	HeadHash common.Hash // Hash of the newest indexed block
+}
+
+// ReadStateDiffIndex retrieves the range of indexed reverse state diffs.
+func ReadStateDiffIndex(db hykdb.KeyValueReader) *StateDiffIndex {
+	data, _ := db.Get(stateDiffIndexKey)
+	if len(data) == 0 {
+		return nil
+	}
+	index := new(StateDiffIndex)
+	if err := rlp.DecodeBytes(data, index); err != nil {
+		log.Error("Invalid state diff index", "err", err)
+		return nil
+	}
+	return index
+}
+
+// WriteStateDiffIndex stores the range of indexed reverse state diffs.
+func WriteStateDiffIndex(db hykdb.KeyValueWriter, index *StateDiffIndex) {
+	data, err := rlp.EncodeToBytes(index)
+	if err != nil {
+		log.Crit("Failed to encode state diff index", "err", err)
+	}
+	if err := db.Put(stateDiffIndexKey, data); err != nil {
+		log.Crit("Failed to store state diff index", "err", err)
+	}
+}
+
+// ReadStateDiff retrieves the RLP encoded reverse state diff of a block.
+func ReadStateDiff(db hykdb.KeyValueReader, number uint64, hash common.Hash) []byte {
+	data, _ := db.Get(stateDiffKey(number, hash))
+	return data
+}
+
+// ReadStateDiffHashes retrieves the hashes of all the blocks with the given
+// number which have a reverse state diff stored, canonical or not.
+func ReadStateDiffHashes(db hykdb.Iteratee, number uint64) []common.Hash {
+	prefix := stateDiffKeyPrefix(number)
+	it := db.NewIterator(prefix, nil)
+	defer it.Release()
+
+	var hashes []common.Hash
+	for it.Next() {
+		if key := it.Key(); len(key) == len(prefix)+common.HashLength {
+			hashes = append(hashes, common.BytesToHash(key[len(prefix):]))
+		}
+	}
+	return hashes
+}
+
+// WriteStateDiff stores the RLP encoded reverse state diff of a block.
+func WriteStateDiff(db hykdb.KeyValueWriter, number uint64, hash common.Hash, diff []byte) {
+	if err := db.Put(stateDiffKey(number, hash), diff); err != nil {
+		log.Crit("Failed to store state diff", "err", err)
+	}
+}
+
+// DeleteStateDiff removes the reverse state diff of a block.
+func DeleteStateDiff(db hykdb.KeyValueWriter, number uint64, hash common.Hash) {
+	if err := db.Delete(stateDiffKey(number, hash)); err != nil {
+		log.Crit("Failed to delete state diff", "err", err)
+	}
+}
+
+// ReadStateDiffRoot retrieves the number of the canonical block a state root
+// was last indexed for.
+func ReadStateDiffRoot(db hykdb.KeyValueReader, root common.Hash) *uint64 {
+	data, _ := db.Get(stateDiffRootKey(root))
+	if len(data) != 8 {
+		return nil
+	}
+	number := binary.BigEndian.Uint64(data)
+	return &number
+}
+
+// WriteStateDiffRoot stores the number of the canonical block with a state root.
+func WriteStateDiffRoot(db hykdb.KeyValueWriter, root common.Hash, number uint64) {
+	if err := db.Put(stateDiffRootKey(root), encodeBlockNumber(number)); err != nil {
+		log.Crit("Failed to store state diff root", "err", err)
+	}
+}
+
+// seekHistory returns the value of the first history entry with the given prefix
+// at or after the given block number.
+func seekHistory(db hykdb.Iteratee, prefix []byte, number uint64) ([]byte, bool) {
+	it := db.NewIterator(prefix, encodeBlockNumber(number))
+	defer it.Release()
+
+	for it.Next() {
+		if key := it.Key(); len(key) == len(prefix)+8 && bytes.HasPrefix(key, prefix) {
+			return common.CopyBytes(it.Value()), true
+		}
+	}
+	return nil, false
+}
+
+// ReadAccountHistory retrieves the slim RLP encoded account an account hash had
+// at the given block, if the account was modified by a later indexed block. An
+// empty blob means the account did not exist.
+func ReadAccountHistory(db hykdb.Iteratee, accountHash common.Hash, number uint64) ([]byte, bool) {
+	prefix := append(append([]byte{}, accountHistoryPrefix...), accountHash.Bytes()...)
+	return seekHistory(db, prefix, number+1)
+}
+
+// WriteAccountHistory stores the slim RLP encoded account an account hash had
+// before the block with the given number modified it.
+func WriteAccountHistory(db hykdb.KeyValueWriter, accountHash common.Hash, number uint64, entry []byte) {
+	if err := db.Put(accountHistoryKey(accountHash, number), entry); err != nil {
+		log.Crit("Failed to store account history", "err", err)
+	}
+}
+
+// DeleteAccountHistory removes the history entry of an account modified by the
+// block with the given number.
+func DeleteAccountHistory(db hykdb.KeyValueWriter, accountHash common.Hash, number uint64) {
+	if err := db.Delete(accountHistoryKey(accountHash, number)); err != nil {
+		log.Crit("Failed to delete account history", "err", err)
+	}
+}
+
+// ReadStorageHistory retrieves the RLP encoded value a storage slot had at the
+// given block, if the slot was modified by a later indexed block. An empty blob
+// means the slot was empty.
+func ReadStorageHistory(db hykdb.Iteratee, accountHash, storageHash common.Hash, number uint64) ([]byte, bool) {
+	prefix := append(append(append([]byte{}, storageHistoryPrefix...), accountHash.Bytes()...), storageHash.Bytes()...)
+	return seekHistory(db, prefix, number+1)
+}
+
+// WriteStorageHistory stores the RLP encoded value a storage slot had before
+// the block with the given number modified it.
+func WriteStorageHistory(db hykdb.KeyValueWriter, accountHash, storageHash common.Hash, number uint64, entry []byte) {
+	if err := db.Put(storageHistoryKey(accountHash, storageHash, number), entry); err != nil {
+		log.Crit("Failed to store storage history", "err", err)
+	}
+}
+
+// DeleteStorageHistory removes the history entry of a storage slot modified by
+// the block with the given number.
+func DeleteStorageHistory(db hykdb.KeyValueWriter, accountHash, storageHash common.Hash, number uint64) {
+	if err := db.Delete(storageHistoryKey(accountHash, storageHash, number)); err != nil {
+		log.Crit("Failed to delete storage history", "err", err)
+	}
+}
@@ -95,6 +95,11 @@ func (db *nofreezedb) AncientSize(kind string) (uint64, error) {
 	return 0, errNotSupported
 }
 
+// AncientTail returns an error as we don't have a backing chain freezer.
+func (db *nofreezedb) AncientTail() (uint64, error) {
+	return 0, errNotSupported
+}
+
 // AppendAncient returns an error as we don't have a backing chain freezer.
 func (db *nofreezedb) AppendAncient(number uint64, hash, header, body, receipts, td []byte) error {
 	return errNotSupported
@@ -105,6 +110,11 @@ func (db *nofreezedb) TruncateAncients(items uint64) error {
 	return errNotSupported
 }
 
+// TruncateAncientTail returns an error as we don't have a backing chain freezer.
+func (db *nofreezedb) TruncateAncientTail(tail uint64) error {
+	return errNotSupported
+}
+
 // Sync returns an error as we don't have a backing chain freezer.
 func (db *nofreezedb) Sync() error {
 	return errNotSupported
@@ -294,6 +304,8 @@ func InspectDatabase(db hykdb.Database) error {
 		preimages       stat
 		bloomBits       stat
 		cliqueSnaps     stat
+		stateDiffs      stat
+		stateHistory    stat
 
 		// Ancient store statistics
 		ancientHeadersSize  common.StorageSize
@@ -347,6 +359,14 @@ func InspectDatabase(db hykdb.Database) error {
 			preimages.Add(size)
 		case bytes.HasPrefix(key, bloomBitsPrefix) && len(key) == (len(bloomBitsPrefix)+10+common.HashLength):
 			bloomBits.Add(size)
+		case bytes.HasPrefix(key, stateDiffPrefix) && len(key) == (len(stateDiffPrefix)+8+common.HashLength):
+			stateDiffs.Add(size)
+		case bytes.HasPrefix(key, stateDiffRootPrefix) && len(key) == (len(stateDiffRootPrefix)+common.HashLength):
+			stateDiffs.Add(size)
+		case bytes.HasPrefix(key, accountHistoryPrefix) && len(key) == (len(accountHistoryPrefix)+common.HashLength+8):
+			stateHistory.Add(size)
+		case bytes.HasPrefix(key, storageHistoryPrefix) && len(key) == (len(storageHistoryPrefix)+2*common.HashLength+8):
+			stateHistory.Add(size)
 		case bytes.HasPrefix(key, []byte("clique-")) && len(key) == 7+common.HashLength:
 			cliqueSnaps.Add(size)
 		case bytes.HasPrefix(key, []byte("cht-")) && len(key) == 4+common.HashLength:
@@ -355,7 +375,7 @@ func InspectDatabase(db hykdb.Database) error {
 			bloomTrieNodes.Add(size)
 		default:
 			var accounted bool
-			for _, meta := range [][]byte{databaseVerisionKey, headHeaderKey, headBlockKey, headFastBlockKey, fastTrieProgressKey} {
+			for _, meta := range [][]byte{databaseVerisionKey, headHeaderKey, headBlockKey, headFastBlockKey, fastTrieProgressKey, stateDiffIndexKey} {
 				if bytes.Equal(key, meta) {
 					metadata.Add(size)
 					accounted = true
@@ -400,6 +420,8 @@ func InspectDatabase(db hykdb.Database) error {
 		{"Key-Value store", "Trie preimages", preimages.Size(), preimages.Count()},
 		{"Key-Value store", "Account snapshot", accountSnaps.Size(), accountSnaps.Count()},
 		{"Key-Value store", "Storage snapshot", storageSnaps.Size(), storageSnaps.Count()},
+		{"Key-Value store", "Reverse state diffs", stateDiffs.Size(), stateDiffs.Count()},
+		{"Key-Value store", "State history index", stateHistory.Size(), stateHistory.Count()},
 		{"Key-Value store", "Clique snapshots", cliqueSnaps.Size(), cliqueSnaps.Count()},
 		{"Key-Value store", "Singleton metadata", metadata.Size(), metadata.Count()},
 		{"Ancient store", "Headers", ancientHeadersSize.String(), ancients.String()},
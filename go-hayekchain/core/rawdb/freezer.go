@@ -17,8 +17,10 @@
 package rawdb
 
 import (
+	"encoding/binary"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"math"
 	"os"
 	"path/filepath"
@@ -46,6 +48,10 @@ var (
 	// errSymlinkDatadir is returned if the ancient directory specified by user
 	// is a symbolic link.
 	errSymlinkDatadir = errors.New("symbolic link datadir is not supported")
+
+	// errTruncatePruned is returned if the user attempts to truncate the freezer
+	// head below the pruned tail.
+	errTruncatePruned = errors.New("truncating below pruned tail")
 )
 
 const (
@@ -57,6 +63,10 @@ const (
 	// freezerBatchLimit is the maximum number of blocks to freeze in one batch
 	// before doing an fsync and deleting it from the key-value store.
 	freezerBatchLimit = 30000
+
+	// freezerTailFile is the name of the file persisting the number of the first
+	// block whose prunable data is retained.
+	freezerTailFile = "TAIL"
 )
 
 // freezer is an memory mapped append-only database to store immutable chain data
@@ -72,7 +82,9 @@ type freezer struct {
 	// so take advantage of that (https://golang.org/pkg/sync/atomic/#pkg-note-BUG).
 	frozen    uint64 // Number of blocks already frozen
 	threshold uint64 // Number of recent blocks not to freeze (params.FullImmutabilityThreshold apart from tests)
+	tail      uint64 // Number of the first block whose prunable data is retained
 
+	datadir      string                   // Directory holding the data tables
 	tables       map[string]*freezerTable // Data tables for storing everything
 	instanceLock fileutil.Releaser        // File-system lock to prevent double opens
 
@@ -107,6 +119,7 @@ func newFreezer(datadir string, namespace string) (*freezer, error) {
 	// Open all the supported data tables
 	freezer := &freezer{
 		threshold:    params.FullImmutabilityThreshold,
+		datadir:      datadir,
 		tables:       make(map[string]*freezerTable),
 		instanceLock: lock,
 		trigger:      make(chan chan struct{}),
@@ -130,7 +143,14 @@ func newFreezer(datadir string, namespace string) (*freezer, error) {
 		lock.Release()
 		return nil, err
 	}
-	log.Info("Opened ancient database", "database", datadir)
+	if err := freezer.loadTail(); err != nil {
+		for _, table := range freezer.tables {
+			table.Close()
+		}
+		lock.Release()
+		return nil, err
+	}
+	log.Info("Opened ancient database", "database", datadir, "tail", freezer.tail)
 	return freezer, nil
 }
 
@@ -157,6 +177,9 @@ func (f *freezer) Close() error {
 // HasAncient returns an indicator whether the specified ancient data exists
 // in the freezer.
 func (f *freezer) HasAncient(kind string, number uint64) (bool, error) {
+	if freezerPrunable[kind] && number < atomic.LoadUint64(&f.tail) {
+		return false, nil
+	}
 	if table := f.tables[kind]; table != nil {
 		return table.has(number), nil
 	}
@@ -165,6 +188,9 @@ func (f *freezer) HasAncient(kind string, number uint64) (bool, error) {
 
 // Ancient retrieves an ancient binary blob from the append-only immutable files.
 func (f *freezer) Ancient(kind string, number uint64) ([]byte, error) {
+	if freezerPrunable[kind] && number < atomic.LoadUint64(&f.tail) {
+		return nil, errOutOfBounds
+	}
 	if table := f.tables[kind]; table != nil {
 		return table.Retrieve(number)
 	}
@@ -184,6 +210,12 @@ func (f *freezer) AncientSize(kind string) (uint64, error) {
 	return 0, errUnknownTable
 }
 
+// AncientTail returns the number of the first block whose prunable data (bodies
+// and receipts) is still retained.
+func (f *freezer) AncientTail() (uint64, error) {
+	return atomic.LoadUint64(&f.tail), nil
+}
+
 // AppendAncient injects all binary blobs belong to block at the end of the
 // append-only immutable table files.
 //
@@ -236,6 +268,9 @@ func (f *freezer) TruncateAncients(items uint64) error {
 	if atomic.LoadUint64(&f.frozen) <= items {
 		return nil
 	}
+	if tail := atomic.LoadUint64(&f.tail); items < tail {
+		return fmt.Errorf("%w: items %d, tail %d", errTruncatePruned, items, tail)
+	}
 	for _, table := range f.tables {
 		if err := table.truncate(items); err != nil {
 			return err
@@ -245,6 +280,29 @@ func (f *freezer) TruncateAncients(items uint64) error {
 	return nil
 }
 
+// TruncateAncientTail discards the prunable data (bodies and receipts) of all
+// blocks below the provided threshold number. The new tail is persisted before
+// any data is deleted, so that a crash midway cannot expose half-pruned blocks.
+func (f *freezer) TruncateAncientTail(tail uint64) error {
+	if frozen := atomic.LoadUint64(&f.frozen); tail > frozen {
+		tail = frozen
+	}
+	if atomic.LoadUint64(&f.tail) >= tail {
+		return nil
+	}
+	if err := writeFreezerTail(f.datadir, tail); err != nil {
+		return err
+	}
+	atomic.StoreUint64(&f.tail, tail)
+
+	for name := range freezerPrunable {
+		if err := f.tables[name].truncateTail(tail); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Sync flushes all data tables to disk.
 func (f *freezer) Sync() error {
 	var errs []error
@@ -470,3 +528,61 @@ func (f *freezer) repair() error {
 	atomic.StoreUint64(&f.frozen, min)
 	return nil
 }
+
+// loadTail initializes the pruned tail from the persisted marker, falling back
+// to the tails of the prunable tables if the marker is missing or lags behind.
+func (f *freezer) loadTail() error {
+	tail, err := readFreezerTail(f.datadir)
+	if err != nil {
+		return err
+	}
+	for name := range freezerPrunable {
+		if t := f.tables[name].tail(); t > tail {
+			tail = t
+		}
+	}
+	atomic.StoreUint64(&f.tail, tail)
+	return nil
+}
+
+// readFreezerTail retrieves the persisted pruned tail of the freezer, or zero if
+// nothing was pruned yet.
+func readFreezerTail(datadir string) (uint64, error) {
+	blob, err := ioutil.ReadFile(filepath.Join(datadir, freezerTailFile))
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if len(blob) != 8 {
+		return 0, fmt.Errorf("invalid freezer tail marker length %d", len(blob))
+	}
+	return binary.BigEndian.Uint64(blob), nil
+}
+
+// writeFreezerTail atomically persists the pruned tail of the freezer.
+func writeFreezerTail(datadir string, tail uint64) error {
+	var (
+		path = filepath.Join(datadir, freezerTailFile)
+		blob = make([]byte, 8)
+	)
+	binary.BigEndian.PutUint64(blob, tail)
+
+	file, err := openFreezerFileTruncated(path + ".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(blob); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
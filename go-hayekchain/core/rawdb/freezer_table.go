@@ -23,6 +23,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
 	"sync"
 	"sync/atomic"
 
@@ -334,6 +335,12 @@ func (t *freezerTable) truncate(items uint64) error {
 	if existing <= items {
 		return nil
 	}
+	// The index only covers the items above the discarded tail
+	if items < uint64(t.itemOffset) {
+		return fmt.Errorf("%w: items %d, tail %d", errTruncatePruned, items, t.itemOffset)
+	}
+	rel := items - uint64(t.itemOffset)
+
 	// We need to truncate, save the old size for metrics tracking
 	oldSize, err := t.sizeNolock()
 	if err != nil {
@@ -345,17 +352,21 @@ func (t *freezerTable) truncate(items uint64) error {
 		log = t.logger.Warn // Only loud warn if we delete multiple items
 	}
 	log("Truncating freezer table", "items", existing, "limit", items)
-	if err := truncateFreezerFile(t.index, int64(items+1)*indexEntrySize); err != nil {
+	if err := truncateFreezerFile(t.index, int64(rel+1)*indexEntrySize); err != nil {
 		return err
 	}
 	// Calculate the new expected size of the data file and truncate it
 	buffer := make([]byte, indexEntrySize)
-	if _, err := t.index.ReadAt(buffer, int64(items*indexEntrySize)); err != nil {
+	if _, err := t.index.ReadAt(buffer, int64(rel*indexEntrySize)); err != nil {
 		return err
 	}
 	var expected indexEntry
 	expected.unmarshalBinary(buffer)
-
+	if rel == 0 {
+		// The first entry carries the tail instead of a data offset, the tail
+		// item always starts its data file
+		expected.offset = 0
+	}
 	// We might need to truncate back to older files
 	if expected.filenum != t.headId {
 		// If already open for reading, force-reopen for writing
@@ -388,6 +399,133 @@ func (t *freezerTable) truncate(items uint64) error {
 	return nil
 }
 
+// truncateTail discards any historic data below the provided threshold number.
+// Data can only be deleted at data file granularity, so the items sharing a data
+// file with the threshold item are retained. The index is rewritten to start at
+// the first retained item, persisting the new tail in its first entry.
+func (t *freezerTable) truncateTail(items uint64) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	// Ensure the table is still accessible
+	if t.index == nil || t.head == nil {
+		return errClosed
+	}
+	if existing := atomic.LoadUint64(&t.items); items > existing {
+		items = existing
+	}
+	if items <= uint64(t.itemOffset) {
+		return nil
+	}
+	// Locate the data file holding the new tail item. If everything is to be
+	// deleted, only the head file is kept.
+	stat, err := t.index.Stat()
+	if err != nil {
+		return err
+	}
+	var (
+		count  = uint64(stat.Size()/indexEntrySize) - 1 // Number of stored items
+		buffer = make([]byte, indexEntrySize)
+		entry  indexEntry
+	)
+	readEntry := func(n uint64) (indexEntry, error) {
+		var entry indexEntry
+		if _, err := t.index.ReadAt(buffer, int64(n*indexEntrySize)); err != nil {
+			return entry, err
+		}
+		entry.unmarshalBinary(buffer)
+		return entry, nil
+	}
+	filenum := atomic.LoadUint32(&t.headId)
+	if rel := items - uint64(t.itemOffset); rel < count {
+		if entry, err = readEntry(rel + 1); err != nil {
+			return err
+		}
+		filenum = entry.filenum
+	}
+	if filenum == t.tailId {
+		return nil
+	}
+	// Find the first item stored in that data file, it will become the new tail
+	var readErr error
+	first := uint64(sort.Search(int(count), func(n int) bool {
+		entry, err := readEntry(uint64(n) + 1)
+		if err != nil {
+			readErr = err
+			return true
+		}
+		return entry.filenum >= filenum
+	}))
+	if readErr != nil {
+		return readErr
+	}
+	oldSize, err := t.sizeNolock()
+	if err != nil {
+		return err
+	}
+	t.logger.Info("Truncating freezer table tail", "items", atomic.LoadUint64(&t.items), "tail", uint64(t.itemOffset)+first)
+
+	// Rewrite the index with the retained entries, using a temporary file to
+	// stay crash safe, and swap it in place of the old one
+	retained := make([]byte, (count-first)*indexEntrySize)
+	if _, err := t.index.ReadAt(retained, int64((first+1)*indexEntrySize)); err != nil {
+		return err
+	}
+	tail := indexEntry{filenum: filenum, offset: t.itemOffset + uint32(first)}
+
+	name := t.index.Name()
+	tmp, err := openFreezerFileTruncated(name + ".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(append(tail.marshallBinary(), retained...)); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	tmp.Close()
+	if err := t.index.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(name+".tmp", name); err != nil {
+		return err
+	}
+	if t.index, err = openFreezerFileForAppend(name); err != nil {
+		return err
+	}
+	// Index updated, delete all the data files before the new tail
+	for num := t.tailId; num < filenum; num++ {
+		if f, exist := t.files[num]; exist {
+			delete(t.files, num)
+			f.Close()
+			if err := os.Remove(f.Name()); err != nil {
+				t.logger.Error("Failed to remove freezer data file", "file", f.Name(), "err", err)
+			}
+		}
+	}
+	t.tailId = tail.filenum
+	t.itemOffset = tail.offset
+
+	// Retrieve the new size and update the total size counter
+	newSize, err := t.sizeNolock()
+	if err != nil {
+		return err
+	}
+	t.sizeGauge.Dec(int64(oldSize - newSize))
+	return nil
+}
+
+// tail returns the number of the first item still stored in the table.
+func (t *freezerTable) tail() uint64 {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	return uint64(t.itemOffset)
+}
+
 // Close closes all opened files.
 func (t *freezerTable) Close() error {
 	t.lock.Lock()
@@ -600,7 +738,10 @@ func (t *freezerTable) Retrieve(item uint64) ([]byte, error) {
 // has returns an indicator whether the specified number data
 // exists in the freezer table.
 func (t *freezerTable) has(number uint64) bool {
-	return atomic.LoadUint64(&t.items) > number
+	if atomic.LoadUint64(&t.items) <= number {
+		return false
+	}
+	return t.tail() <= number
 }
 
 // size returns the total data size in the freezer table.
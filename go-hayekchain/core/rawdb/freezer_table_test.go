@@ -18,6 +18,7 @@ package rawdb
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"math/rand"
 	"os"
@@ -403,6 +404,145 @@ func TestFreezerTruncate(t *testing.T) {
 
 }
 
+// TestFreezerTruncateTail tests that historic items can be discarded from the
+// tail of a table at data file granularity, and that the new tail survives a
+// restart.
+func TestFreezerTruncateTail(t *testing.T) {
+	t.Parallel()
+	rm, wm, sg := metrics.NewMeter(), metrics.NewMeter(), metrics.NewGauge()
+	fname := fmt.Sprintf("truncationtail-%d", rand.Uint64())
+
+	// Fill table, 3 items per data file
+	f, err := newCustomTable(os.TempDir(), fname, rm, wm, sg, 50, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for x := 0; x < 30; x++ {
+		f.Append(uint64(x), getChunk(15, x))
+	}
+	// Item 10 lives in the fourth data file together with items 9 and 11
+	if err := f.truncateTail(10); err != nil {
+		t.Fatal(err)
+	}
+	checkTail := func(f *freezerTable) {
+		t.Helper()
+		if tail := f.tail(); tail != 9 {
+			t.Fatalf("tail mismatch: have %d, want %d", tail, 9)
+		}
+		if f.items != 30 {
+			t.Fatalf("items mismatch: have %d, want %d", f.items, 30)
+		}
+		if _, err := f.Retrieve(8); err != errOutOfBounds {
+			t.Fatalf("pruned item retrieval error mismatch: have %v, want %v", err, errOutOfBounds)
+		}
+		if f.has(8) {
+			t.Fatalf("pruned item reported present")
+		}
+		for x := 9; x < 30; x++ {
+			got, err := f.Retrieve(uint64(x))
+			if err != nil {
+				t.Fatalf("item %d: %v", x, err)
+			}
+			if exp := getChunk(15, x); !bytes.Equal(got, exp) {
+				t.Fatalf("item %d: have %x, want %x", x, got, exp)
+			}
+		}
+		for num := 0; num < 3; num++ {
+			if _, err := os.Stat(filepath.Join(os.TempDir(), fmt.Sprintf("%s.%04d.rdat", fname, num))); !os.IsNotExist(err) {
+				t.Fatalf("data file %d not deleted: %v", num, err)
+			}
+		}
+	}
+	checkTail(f)
+
+	// Truncating below the current tail is a noop
+	if err := f.truncateTail(5); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	// Reopen and ensure the tail was persisted and appending still works
+	f, err = newCustomTable(os.TempDir(), fname, rm, wm, sg, 50, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	checkTail(f)
+
+	if err := f.Append(30, getChunk(15, 30)); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := f.Retrieve(30); err != nil || !bytes.Equal(got, getChunk(15, 30)) {
+		t.Fatalf("appended item mismatch: have %x, err %v", got, err)
+	}
+}
+
+// TestFreezerTruncateHeadAfterTail tests that the head of a table can be truncated
+// after historic items were discarded from its tail.
+func TestFreezerTruncateHeadAfterTail(t *testing.T) {
+	t.Parallel()
+	rm, wm, sg := metrics.NewMeter(), metrics.NewMeter(), metrics.NewGauge()
+	fname := fmt.Sprintf("truncationheadtail-%d", rand.Uint64())
+
+	// Fill table, 3 items per data file, and discard the first four files
+	f, err := newCustomTable(os.TempDir(), fname, rm, wm, sg, 50, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	for x := 0; x < 30; x++ {
+		f.Append(uint64(x), getChunk(15, x))
+	}
+	if err := f.truncateTail(12); err != nil {
+		t.Fatal(err)
+	}
+	check := func(items uint64) {
+		t.Helper()
+		if f.items != items {
+			t.Fatalf("items mismatch: have %d, want %d", f.items, items)
+		}
+		for x := uint64(12); x < items; x++ {
+			got, err := f.Retrieve(x)
+			if err != nil {
+				t.Fatalf("item %d: %v", x, err)
+			}
+			if exp := getChunk(15, int(x)); !bytes.Equal(got, exp) {
+				t.Fatalf("item %d: have %x, want %x", x, got, exp)
+			}
+		}
+		if _, err := f.Retrieve(items); err != errOutOfBounds {
+			t.Fatalf("truncated item retrieval error mismatch: have %v, want %v", err, errOutOfBounds)
+		}
+	}
+	// Truncate within the head file, then back into an earlier file
+	if err := f.truncate(25); err != nil {
+		t.Fatal(err)
+	}
+	check(25)
+	if err := f.truncate(16); err != nil {
+		t.Fatal(err)
+	}
+	check(16)
+
+	// Truncate to the tail, dropping all items, and refill the table
+	if err := f.truncate(12); err != nil {
+		t.Fatal(err)
+	}
+	check(12)
+	for x := 12; x < 20; x++ {
+		if err := f.Append(uint64(x), getChunk(15, x)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	check(20)
+
+	// The head cannot be truncated into the discarded items
+	if err := f.truncate(11); !errors.Is(err, errTruncatePruned) {
+		t.Fatalf("truncation error mismatch: have %v, want %v", err, errTruncatePruned)
+	}
+}
+
 // TestFreezerRepairFirstFile tests a head file with the very first item only half-written.
 // That will rewind the index, and _should_ truncate the head file
 func TestFreezerRepairFirstFile(t *testing.T) {
@@ -0,0 +1,92 @@
+// Copyright 2021 The go-hayekchain Authors
+// This file is part of the go-hayekchain library.
+//
+// The go-hayekchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hayekchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hayekchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+// Tests that pruning the freezer tail only hides bodies and receipts, and that
+// the tail marker is persisted across restarts.
+func TestFreezerTruncateAncientTail(t *testing.T) {
+	dir, err := ioutil.TempDir("", "freezer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	f, err := newFreezer(dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := uint64(0); i < 100; i++ {
+		blob := []byte{byte(i)}
+		if err := f.AppendAncient(i, blob, blob, blob, blob, blob); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := f.TruncateAncientTail(40); err != nil {
+		t.Fatal(err)
+	}
+	check := func(f *freezer) {
+		t.Helper()
+		if tail, _ := f.AncientTail(); tail != 40 {
+			t.Fatalf("tail mismatch: have %d, want %d", tail, 40)
+		}
+		for _, kind := range []string{freezerBodiesTable, freezerReceiptTable} {
+			if _, err := f.Ancient(kind, 39); err == nil {
+				t.Fatalf("%s: pruned item still retrievable", kind)
+			}
+			if ok, _ := f.HasAncient(kind, 39); ok {
+				t.Fatalf("%s: pruned item reported present", kind)
+			}
+			if _, err := f.Ancient(kind, 40); err != nil {
+				t.Fatalf("%s: retained item missing: %v", kind, err)
+			}
+		}
+		for _, kind := range []string{freezerHashTable, freezerHeaderTable, freezerDifficultyTable} {
+			if _, err := f.Ancient(kind, 0); err != nil {
+				t.Fatalf("%s: unprunable item missing: %v", kind, err)
+			}
+		}
+	}
+	check(f)
+
+	// The head cannot be truncated into the pruned range
+	if err := f.TruncateAncients(20); !errors.Is(err, errTruncatePruned) {
+		t.Fatalf("truncation error mismatch: have %v, want %v", err, errTruncatePruned)
+	}
+	closeFreezer(f)
+
+	if f, err = newFreezer(dir, ""); err != nil {
+		t.Fatal(err)
+	}
+	defer closeFreezer(f)
+	check(f)
+}
+
+// closeFreezer releases the resources of a freezer without a running background
+// freeze loop.
+func closeFreezer(f *freezer) {
+	for _, table := range f.tables {
+		table.Close()
+	}
+	f.instanceLock.Release()
+}
@@ -63,6 +63,9 @@ var (
 	// fastTxLookupLimitKey tracks the transaction lookup limit during fast sync.
 	fastTxLookupLimitKey = []byte("FastTransactionLookupLimit")
 
+	// stateDiffIndexKey tracks the range of blocks whose reverse state diffs are indexed.
+	stateDiffIndexKey = []byte("StateDiffIndex")
+
 	// Data item prefixes (use single byte to avoid mixing data types, avoid `i`, used for indexes).
 	headerPrefix       = []byte("h") // headerPrefix + num (uint64 big endian) + hash -> header
 	headerTDSuffix     = []byte("t") // headerPrefix + num (uint64 big endian) + hash + headerTDSuffix -> td
@@ -78,6 +81,11 @@ var (
 	SnapshotStoragePrefix = []byte("o") // SnapshotStoragePrefix + account hash + storage hash -> storage trie value
 	codePrefix            = []byte("c") // codePrefix + code hash -> account code
 
+	stateDiffPrefix      = []byte("d") // stateDiffPrefix + num (uint64 big endian) + hash -> reverse state diff
+	stateDiffRootPrefix  = []byte("R") // stateDiffRootPrefix + state root -> num (uint64 big endian)
+	accountHistoryPrefix = []byte("x") // accountHistoryPrefix + account hash + num (uint64 big endian) -> account before block
+	storageHistoryPrefix = []byte("y") // storageHistoryPrefix + account hash + storage hash + num (uint64 big endian) -> slot before block
+
 	preimagePrefix = []byte("secure-key-")      // preimagePrefix + hash -> preimage
 	configPrefix   = []byte("hayekchain-config-") // config prefix for the db
 
@@ -115,6 +123,14 @@ var freezerNoSnappy = map[string]bool{
 	freezerDifficultyTable: true,
 }
 
+// freezerPrunable configures which ancient-tables may have their historic data
+// pruned below the retention window. Headers, hashes and difficulties are always
+// retained to keep the chain verifiable.
+var freezerPrunable = map[string]bool{
+	freezerBodiesTable:  true,
+	freezerReceiptTable: true,
+}
+
 // LegacyTxLookupEntry is the legacy TxLookupEntry definition with some unnecessary
 // fields.
 type LegacyTxLookupEntry struct {
@@ -185,6 +201,31 @@ func storageSnapshotsKey(accountHash common.Hash) []byte {
 	return append(SnapshotStoragePrefix, accountHash.Bytes()...)
 }
 
+// stateDiffKeyPrefix = stateDiffPrefix + num (uint64 big endian)
+func stateDiffKeyPrefix(number uint64) []byte {
+	return append(stateDiffPrefix, encodeBlockNumber(number)...)
+}
+
+// stateDiffKey = stateDiffPrefix + num (uint64 big endian) + hash
+func stateDiffKey(number uint64, hash common.Hash) []byte {
+	return append(stateDiffKeyPrefix(number), hash.Bytes()...)
+}
+
+// stateDiffRootKey = stateDiffRootPrefix + state root
+func stateDiffRootKey(root common.Hash) []byte {
+	return append(stateDiffRootPrefix, root.Bytes()...)
+}
+
+// accountHistoryKey = accountHistoryPrefix + account hash + num (uint64 big endian)
+func accountHistoryKey(accountHash common.Hash, number uint64) []byte {
+	return append(append(accountHistoryPrefix, accountHash.Bytes()...), encodeBlockNumber(number)...)
+}
+
+// storageHistoryKey = storageHistoryPrefix + account hash + storage hash + num (uint64 big endian)
+func storageHistoryKey(accountHash, storageHash common.Hash, number uint64) []byte {
+	return append(append(append(storageHistoryPrefix, accountHash.Bytes()...), storageHash.Bytes()...), encodeBlockNumber(number)...)
+}
+
 // bloomBitsKey = bloomBitsPrefix + bit (uint16 big endian) + section (uint64 big endian) + hash
 func bloomBitsKey(bit uint, section uint64, hash common.Hash) []byte {
 	key := append(append(bloomBitsPrefix, make([]byte, 10)...), hash.Bytes()...)
@@ -74,6 +74,12 @@ func (t *table) AncientSize(kind string) (uint64, error) {
 	return t.db.AncientSize(kind)
 }
 
+// AncientTail is a noop passthrough that just forwards the request to the underlying
+// database.
+func (t *table) AncientTail() (uint64, error) {
+	return t.db.AncientTail()
+}
+
 // AppendAncient is a noop passthrough that just forwards the request to the underlying
 // database.
 func (t *table) AppendAncient(number uint64, hash, header, body, receipts, td []byte) error {
@@ -86,6 +92,12 @@ func (t *table) TruncateAncients(items uint64) error {
 	return t.db.TruncateAncients(items)
 }
 
+// TruncateAncientTail is a noop passthrough that just forwards the request to the
+// underlying database.
+func (t *table) TruncateAncientTail(tail uint64) error {
+	return t.db.TruncateAncientTail(tail)
+}
+
 // Sync is a noop passthrough that just forwards the request to the underlying
 // database.
 func (t *table) Sync() error {
@@ -111,13 +111,15 @@ func NewDatabase(db hykdb.Database) Database {
 
 // NewDatabaseWithConfig creates a backing store for state. The returned database
 // is safe for concurrent use and retains a lot of collapsed RLP trie nodes in a
-// large memory cache.
+// large memory cache. If the config enables binary tries, the state is stored in
+// experimental binary tries instead of hexary Merkle-Patricia ones.
 func NewDatabaseWithConfig(db hykdb.Database, config *trie.Config) Database {
 	csc, _ := lru.New(codeSizeCacheSize)
 	return &cachingDB{
 		db:            trie.NewDatabaseWithConfig(db, config),
 		codeSizeCache: csc,
 		codeCache:     fastcache.New(codeCacheSize),
+		binary:        config != nil && config.Binary,
 	}
 }
 
@@ -125,15 +127,22 @@ type cachingDB struct {
 	db            *trie.Database
 	codeSizeCache *lru.Cache
 	codeCache     *fastcache.Cache
+	binary        bool
 }
 
 // OpenTrie opens the main account trie at a specific root hash.
 func (db *cachingDB) OpenTrie(root common.Hash) (Trie, error) {
+	if db.binary {
+		return trie.NewBinary(root, db.db)
+	}
 	return trie.NewSecure(root, db.db)
 }
 
 // OpenStorageTrie opens the storage trie of an account.
 func (db *cachingDB) OpenStorageTrie(addrHash, root common.Hash) (Trie, error) {
+	if db.binary {
+		return trie.NewBinary(root, db.db)
+	}
 	return trie.NewSecure(root, db.db)
 }
 
@@ -142,6 +151,8 @@ func (db *cachingDB) CopyTrie(t Trie) Trie {
 	switch t := t.(type) {
 	case *trie.SecureTrie:
 		return t.Copy()
+	case *trie.BinaryTrie:
+		return t.Copy()
 	default:
 		panic(fmt.Errorf("unknown trie type %T", t))
 	}
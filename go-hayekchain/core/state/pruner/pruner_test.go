@@ -0,0 +1,171 @@
+// Copyright 2021 The go-hayekchain Authors
+// This file is part of the go-hayekchain library.
+//
+// The go-hayekchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hayekchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hayekchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package pruner
+
+import (
+	"io/ioutil"
+	"math/big"
+	"os"
+	"testing"
+
+	"github.com/hayekchain/go-hayekchain/common"
+	"github.com/hayekchain/go-hayekchain/consensus/hykash"
+	"github.com/hayekchain/go-hayekchain/core"
+	"github.com/hayekchain/go-hayekchain/core/rawdb"
+	"github.com/hayekchain/go-hayekchain/core/state/snapshot"
+	"github.com/hayekchain/go-hayekchain/core/types"
+	"github.com/hayekchain/go-hayekchain/core/vm"
+	"github.com/hayekchain/go-hayekchain/crypto"
+	"github.com/hayekchain/go-hayekchain/hykdb"
+	"github.com/hayekchain/go-hayekchain/params"
+	"github.com/hayekchain/go-hayekchain/trie"
+)
+
+// newPrunerTestChain creates an archive chain of the given length with a state
+// snapshot, where every block touches a fresh account so no two states share
+// the same root.
+func newPrunerTestChain(t *testing.T, blocks int) (hykdb.Database, []*types.Block) {
+	var (
+		db      = rawdb.NewMemoryDatabase()
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		funds   = big.NewInt(1000000000)
+		gspec   = &core.Genesis{
+			Config: params.TestChainConfig,
+			Alloc:  core.GenesisAlloc{address: {Balance: funds}},
+		}
+		genesis = gspec.MustCommit(db)
+		signer  = types.LatestSigner(gspec.Config)
+	)
+	chain, _ := core.GenerateChain(gspec.Config, genesis, hykash.NewFaker(), db, blocks, func(i int, block *core.BlockGen) {
+		tx, err := types.SignTx(types.NewTransaction(block.TxNonce(address), common.Address{byte(i + 1)}, big.NewInt(1000), params.TxGas, big.NewInt(1), nil), signer, key)
+		if err != nil {
+			t.Fatalf("failed to sign transaction: %v", err)
+		}
+		block.AddTx(tx)
+	})
+	cacheConfig := &core.CacheConfig{
+		TrieCleanLimit:    256,
+		TrieDirtyDisabled: true,
+		SnapshotLimit:     256,
+		SnapshotWait:      true,
+	}
+	blockchain, err := core.NewBlockChain(db, cacheConfig, gspec.Config, hykash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create blockchain: %v", err)
+	}
+	if _, err := blockchain.InsertChain(chain); err != nil {
+		t.Fatalf("failed to import chain: %v", err)
+	}
+	blockchain.Stop()
+
+	return db, append([]*types.Block{genesis}, chain...)
+}
+
+// checkPrunedState verifies that only the genesis and the target states are
+// retained in the database after pruning.
+func checkPrunedState(t *testing.T, db hykdb.Database, chain []*types.Block, target *types.Block) {
+	for _, block := range chain {
+		present := rawdb.ReadTrieNode(db, block.Root()) != nil
+		if keep := block == target || block.NumberU64() == 0; present != keep {
+			t.Errorf("block #%d: state presence mismatch: have %v, want %v", block.NumberU64(), present, keep)
+		}
+	}
+	for _, root := range []common.Hash{chain[0].Root(), target.Root()} {
+		tr, err := trie.NewSecure(root, trie.NewDatabase(db))
+		if err != nil {
+			t.Fatalf("failed to open state %x: %v", root, err)
+		}
+		it := tr.NodeIterator(nil)
+		for it.Next(true) {
+		}
+		if it.Error() != nil {
+			t.Errorf("state %x incomplete after pruning: %v", root, it.Error())
+		}
+	}
+}
+
+// Tests that pruning deletes all the state but the genesis and the target one.
+func TestPruneState(t *testing.T) {
+	db, chain := newPrunerTestChain(t, 8)
+
+	datadir, err := ioutil.TempDir("", "pruner-test")
+	if err != nil {
+		t.Fatalf("failed to create temporary datadir: %v", err)
+	}
+	defer os.RemoveAll(datadir)
+
+	pruner, err := NewPruner(db, datadir, "", 256)
+	if err != nil {
+		t.Fatalf("failed to create pruner: %v", err)
+	}
+	target := chain[len(chain)-1]
+	if err := pruner.Prune(target.Root()); err != nil {
+		t.Fatalf("failed to prune state: %v", err)
+	}
+	checkPrunedState(t, db, chain, target)
+
+	if path, _, _ := findBloomFilter(datadir); path != "" {
+		t.Errorf("state bloom left behind after pruning: %s", path)
+	}
+	// Recovering a finished pruning should be a noop
+	if err := RecoverPruning(datadir, db, ""); err != nil {
+		t.Fatalf("failed to recover finished pruning: %v", err)
+	}
+}
+
+// Tests that a pruning interrupted after committing the state bloom is resumed.
+func TestRecoverPruning(t *testing.T) {
+	db, chain := newPrunerTestChain(t, 8)
+
+	datadir, err := ioutil.TempDir("", "pruner-test")
+	if err != nil {
+		t.Fatalf("failed to create temporary datadir: %v", err)
+	}
+	defer os.RemoveAll(datadir)
+
+	// Generate and commit the state bloom, but abort before deleting anything
+	pruner, err := NewPruner(db, datadir, "", 256)
+	if err != nil {
+		t.Fatalf("failed to create pruner: %v", err)
+	}
+	target := chain[len(chain)-2]
+	if err := snapshotToBloom(pruner, target.Root()); err != nil {
+		t.Fatalf("failed to generate state bloom: %v", err)
+	}
+	if err := RecoverPruning(datadir, db, ""); err != nil {
+		t.Fatalf("failed to recover pruning: %v", err)
+	}
+	checkPrunedState(t, db, chain, target)
+
+	if path, _, _ := findBloomFilter(datadir); path != "" {
+		t.Errorf("state bloom left behind after recovery: %s", path)
+	}
+}
+
+// snapshotToBloom runs the first half of the pruning, stopping right after the
+// state bloom has been persisted.
+func snapshotToBloom(p *Pruner, root common.Hash) error {
+	if err := snapshot.GenerateTrie(p.snaptree, root, p.db, p.stateBloom); err != nil {
+		return err
+	}
+	if err := extractGenesis(p.db, p.stateBloom); err != nil {
+		return err
+	}
+	name := bloomFilterName(p.datadir, root)
+	return p.stateBloom.Commit(name, name+stateBloomFileTempSuffix)
+}
@@ -96,6 +96,13 @@ type StateDB struct {
 	// Per-transaction access list
 	accessList *accessList
 
+	// State access tracker for speculative execution, nil if disabled
+	tracker *accessTracker
+
+	// Reverse state diff recording, see RecordStateDiff
+	recordDiff bool
+	diff       *StateDiff
+
 	// Journal of state modifications. This is the backbone of
 	// Snapshot and RevertToSnapshot.
 	journal        *journal
@@ -243,18 +250,27 @@ func (s *StateDB) SubRefund(gas uint64) {
 // Exist reports whether the given account address exists in the state.
 // Notably this also returns true for suicided accounts.
 func (s *StateDB) Exist(addr common.Address) bool {
+	if s.tracker != nil {
+		s.tracker.readAccount(addr)
+	}
 	return s.getStateObject(addr) != nil
 }
 
 // Empty returns whether the state object is either non-existent
 // or empty according to the EIP161 specification (balance = nonce = code = 0)
 func (s *StateDB) Empty(addr common.Address) bool {
+	if s.tracker != nil {
+		s.tracker.readAccount(addr)
+	}
 	so := s.getStateObject(addr)
 	return so == nil || so.empty()
 }
 
 // GetBalance retrieves the balance from the given address or 0 if object not found
 func (s *StateDB) GetBalance(addr common.Address) *big.Int {
+	if s.tracker != nil {
+		s.tracker.readAccount(addr)
+	}
 	stateObject := s.getStateObject(addr)
 	if stateObject != nil {
 		return stateObject.Balance()
@@ -263,6 +279,9 @@ func (s *StateDB) GetBalance(addr common.Address) *big.Int {
 }
 
 func (s *StateDB) GetNonce(addr common.Address) uint64 {
+	if s.tracker != nil {
+		s.tracker.readAccount(addr)
+	}
 	stateObject := s.getStateObject(addr)
 	if stateObject != nil {
 		return stateObject.Nonce()
@@ -282,6 +301,9 @@ func (s *StateDB) BlockHash() common.Hash {
 }
 
 func (s *StateDB) GetCode(addr common.Address) []byte {
+	if s.tracker != nil {
+		s.tracker.readAccount(addr)
+	}
 	stateObject := s.getStateObject(addr)
 	if stateObject != nil {
 		return stateObject.Code(s.db)
@@ -290,6 +312,9 @@ func (s *StateDB) GetCode(addr common.Address) []byte {
 }
 
 func (s *StateDB) GetCodeSize(addr common.Address) int {
+	if s.tracker != nil {
+		s.tracker.readAccount(addr)
+	}
 	stateObject := s.getStateObject(addr)
 	if stateObject != nil {
 		return stateObject.CodeSize(s.db)
@@ -298,6 +323,9 @@ func (s *StateDB) GetCodeSize(addr common.Address) int {
 }
 
 func (s *StateDB) GetCodeHash(addr common.Address) common.Hash {
+	if s.tracker != nil {
+		s.tracker.readAccount(addr)
+	}
 	stateObject := s.getStateObject(addr)
 	if stateObject == nil {
 		return common.Hash{}
@@ -307,6 +335,9 @@ func (s *StateDB) GetCodeHash(addr common.Address) common.Hash {
 
 // GetState retrieves a value from the given account's storage trie.
 func (s *StateDB) GetState(addr common.Address, hash common.Hash) common.Hash {
+	if s.tracker != nil {
+		s.tracker.readSlot(addr, hash)
+	}
 	stateObject := s.getStateObject(addr)
 	if stateObject != nil {
 		return stateObject.GetState(s.db, hash)
@@ -334,6 +365,9 @@ func (s *StateDB) GetStorageProof(a common.Address, key common.Hash) ([][]byte,
 
 // GetCommittedState retrieves a value from the given account's committed storage trie.
 func (s *StateDB) GetCommittedState(addr common.Address, hash common.Hash) common.Hash {
+	if s.tracker != nil {
+		s.tracker.readSlot(addr, hash)
+	}
 	stateObject := s.getStateObject(addr)
 	if stateObject != nil {
 		return stateObject.GetCommittedState(s.db, hash)
@@ -359,6 +393,9 @@ func (s *StateDB) StorageTrie(addr common.Address) Trie {
 }
 
 func (s *StateDB) HasSuicided(addr common.Address) bool {
+	if s.tracker != nil {
+		s.tracker.readAccount(addr)
+	}
 	stateObject := s.getStateObject(addr)
 	if stateObject != nil {
 		return stateObject.suicided
@@ -372,6 +409,13 @@ func (s *StateDB) HasSuicided(addr common.Address) bool {
 
 // AddBalance adds amount to the account associated with addr.
 func (s *StateDB) AddBalance(addr common.Address, amount *big.Int) {
+	if s.tracker != nil {
+		if delta, ok := s.tracker.deltas[addr]; ok {
+			delta.Add(delta, amount)
+		} else {
+			s.tracker.readAccount(addr)
+		}
+	}
 	stateObject := s.GetOrNewStateObject(addr)
 	if stateObject != nil {
 		stateObject.AddBalance(amount)
@@ -380,6 +424,9 @@ func (s *StateDB) AddBalance(addr common.Address, amount *big.Int) {
 
 // SubBalance subtracts amount from the account associated with addr.
 func (s *StateDB) SubBalance(addr common.Address, amount *big.Int) {
+	if s.tracker != nil {
+		s.tracker.readAccount(addr)
+	}
 	stateObject := s.GetOrNewStateObject(addr)
 	if stateObject != nil {
 		stateObject.SubBalance(amount)
@@ -387,6 +434,9 @@ func (s *StateDB) SubBalance(addr common.Address, amount *big.Int) {
 }
 
 func (s *StateDB) SetBalance(addr common.Address, amount *big.Int) {
+	if s.tracker != nil {
+		s.tracker.readAccount(addr)
+	}
 	stateObject := s.GetOrNewStateObject(addr)
 	if stateObject != nil {
 		stateObject.SetBalance(amount)
@@ -394,6 +444,9 @@ func (s *StateDB) SetBalance(addr common.Address, amount *big.Int) {
 }
 
 func (s *StateDB) SetNonce(addr common.Address, nonce uint64) {
+	if s.tracker != nil {
+		s.tracker.readAccount(addr)
+	}
 	stateObject := s.GetOrNewStateObject(addr)
 	if stateObject != nil {
 		stateObject.SetNonce(nonce)
@@ -401,6 +454,9 @@ func (s *StateDB) SetNonce(addr common.Address, nonce uint64) {
 }
 
 func (s *StateDB) SetCode(addr common.Address, code []byte) {
+	if s.tracker != nil {
+		s.tracker.readAccount(addr)
+	}
 	stateObject := s.GetOrNewStateObject(addr)
 	if stateObject != nil {
 		stateObject.SetCode(crypto.Keccak256Hash(code), code)
@@ -408,6 +464,9 @@ func (s *StateDB) SetCode(addr common.Address, code []byte) {
 }
 
 func (s *StateDB) SetState(addr common.Address, key, value common.Hash) {
+	if s.tracker != nil {
+		s.tracker.readSlot(addr, key)
+	}
 	stateObject := s.GetOrNewStateObject(addr)
 	if stateObject != nil {
 		stateObject.SetState(s.db, key, value)
@@ -417,6 +476,9 @@ func (s *StateDB) SetState(addr common.Address, key, value common.Hash) {
 // SetStorage replaces the entire storage for the specified account with given
 // storage. This function should only be used for debugging.
 func (s *StateDB) SetStorage(addr common.Address, storage map[common.Hash]common.Hash) {
+	if s.tracker != nil {
+		s.tracker.readAccount(addr)
+	}
 	stateObject := s.GetOrNewStateObject(addr)
 	if stateObject != nil {
 		stateObject.SetStorage(storage)
@@ -429,6 +491,9 @@ func (s *StateDB) SetStorage(addr common.Address, storage map[common.Hash]common
 // The account's state object is still available until the state is committed,
 // getStateObject will return a non-nil account after Suicide.
 func (s *StateDB) Suicide(addr common.Address) bool {
+	if s.tracker != nil {
+		s.tracker.readAccount(addr)
+	}
 	stateObject := s.getStateObject(addr)
 	if stateObject == nil {
 		return false
@@ -609,6 +674,9 @@ func (s *StateDB) createObject(addr common.Address) (newobj, prev *stateObject)
 //
 // Carrying over the balance ensures that Hayeker doesn't disappear.
 func (s *StateDB) CreateAccount(addr common.Address) {
+	if s.tracker != nil {
+		s.tracker.readAccount(addr)
+	}
 	newObj, prev := s.createObject(addr)
 	if prev != nil {
 		newObj.setBalance(prev.data.Balance)
@@ -660,6 +728,14 @@ func (s *StateDB) Copy() *StateDB {
 		preimages:           make(map[common.Hash][]byte, len(s.preimages)),
 		journal:             newJournal(),
 	}
+	// Historic states have no trie to fall back to, keep reading the read only
+	// layer reconstructing them
+	if layer, historic := s.snap.(*historicLayer); historic {
+		state.snap = layer
+		state.snapDestructs = make(map[common.Hash]struct{})
+		state.snapAccounts = make(map[common.Hash][]byte)
+		state.snapStorage = make(map[common.Hash]map[common.Hash][]byte)
+	}
 	// Copy the dirty states, logs, and preimages
 	for addr := range s.journal.dirties {
 		// As documented [here](https://github.com/hayekchain/go-hayekchain/pull/16485#issuecomment-380438527),
@@ -711,6 +787,37 @@ func (s *StateDB) Copy() *StateDB {
 	return state
 }
 
+// CopyForCommit creates a deep copy of the state which is committed instead of
+// the original, like the state of a block sealed by the miner. Unlike Copy, it
+// carries over the snapshot and the accounts and slots modified on top of it,
+// so committing the copy extends the snapshot tree and may record a state diff.
+func (s *StateDB) CopyForCommit() *StateDB {
+	state := s.Copy()
+	if s.snap == nil {
+		return state
+	}
+	state.snaps = s.snaps
+	state.snap = s.snap
+
+	state.snapDestructs = make(map[common.Hash]struct{}, len(s.snapDestructs))
+	for hash := range s.snapDestructs {
+		state.snapDestructs[hash] = struct{}{}
+	}
+	state.snapAccounts = make(map[common.Hash][]byte, len(s.snapAccounts))
+	for hash, blob := range s.snapAccounts {
+		state.snapAccounts[hash] = blob
+	}
+	state.snapStorage = make(map[common.Hash]map[common.Hash][]byte, len(s.snapStorage))
+	for hash, slots := range s.snapStorage {
+		cpy := make(map[common.Hash][]byte, len(slots))
+		for slot, blob := range slots {
+			cpy[slot] = blob
+		}
+		state.snapStorage[hash] = cpy
+	}
+	return state
+}
+
 // Snapshot returns an identifier for the current revision of the state.
 func (s *StateDB) Snapshot() int {
 	id := s.nextRevisionId
@@ -825,6 +932,9 @@ func (s *StateDB) Commit(deleteEmptyObjects bool) (common.Hash, error) {
 	if s.dbErr != nil {
 		return common.Hash{}, fmt.Errorf("commit aborted due to earlier error: %v", s.dbErr)
 	}
+	if _, historic := s.snap.(*historicLayer); historic {
+		return common.Hash{}, errHistoricCommit
+	}
 	// Finalize any pending changes and merge everything into the tries
 	s.IntermediateRoot(deleteEmptyObjects)
 
@@ -876,6 +986,13 @@ func (s *StateDB) Commit(deleteEmptyObjects bool) (common.Hash, error) {
 		if metrics.EnabledExpensive {
 			defer func(start time.Time) { s.SnapshotCommits += time.Since(start) }(time.Now())
 		}
+		// Record the reverse diff while the parent snapshot is still intact
+		if s.recordDiff {
+			var diffErr error
+			if s.diff, diffErr = s.reverseDiff(); diffErr != nil {
+				log.Warn("Failed to record state diff", "root", root, "err", diffErr)
+			}
+		}
 		// Only update if there's a state transition (skip empty Clique blocks)
 		if parent := s.snap.Root(); parent != root {
 			if err := s.snaps.Update(root, parent, s.snapDestructs, s.snapAccounts, s.snapStorage); err != nil {
@@ -894,6 +1011,32 @@ func (s *StateDB) Commit(deleteEmptyObjects bool) (common.Hash, error) {
 	return root, err
 }
 
+// PrepareAccessList handles the preparatory steps for executing a state transition
+// with regards to both EIP-2929 and EIP-2930:
+//
+// - Add sender to access list (2929)
+// - Add destination to access list (2929)
+// - Add precompiles to access list (2929)
+// - Add the contents of the optional tx access list (2930)
+//
+// This method should only be called if YoloV2 is active at the current number.
+func (s *StateDB) PrepareAccessList(sender common.Address, dst *common.Address, precompiles []common.Address, list types.AccessList) {
+	s.AddAddressToAccessList(sender)
+	if dst != nil {
+		s.AddAddressToAccessList(*dst)
+		// If it's a create-tx, the destination will be added inside evm.create
+	}
+	for _, addr := range precompiles {
+		s.AddAddressToAccessList(addr)
+	}
+	for _, el := range list {
+		s.AddAddressToAccessList(el.Address)
+		for _, key := range el.StorageKeys {
+			s.AddSlotToAccessList(el.Address, key)
+		}
+	}
+}
+
 // AddAddressToAccessList adds the given address to the access list
 func (s *StateDB) AddAddressToAccessList(addr common.Address) {
 	if s.accessList.AddAddress(addr) {
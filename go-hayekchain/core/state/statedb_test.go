@@ -31,7 +31,9 @@ import (
 
 	"github.com/hayekchain/go-hayekchain/common"
 	"github.com/hayekchain/go-hayekchain/core/rawdb"
+	"github.com/hayekchain/go-hayekchain/core/state/snapshot"
 	"github.com/hayekchain/go-hayekchain/core/types"
+	"github.com/hayekchain/go-hayekchain/crypto"
 )
 
 // Tests that updating a state trie does not leak any database writes prior to
@@ -660,6 +662,65 @@ func TestCopyCopyCommitCopy(t *testing.T) {
 	}
 }
 
+// Tests that only copies made for committing carry the snapshot along, plain
+// copies committing into the tries alone.
+func TestCopyForCommit(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+	sdb := NewDatabase(db)
+
+	state, _ := New(common.Hash{}, sdb, nil)
+	state.SetBalance(common.Address{0x01}, big.NewInt(1))
+	root, _ := state.Commit(false)
+	sdb.TrieDB().Commit(root, false, nil)
+
+	snaps, err := snapshot.New(db, sdb.TrieDB(), 16, root, false, true, false)
+	if err != nil {
+		t.Fatalf("failed to create snapshot tree: %v", err)
+	}
+	state, _ = New(root, sdb, snaps)
+	state.SetBalance(common.Address{0x01}, big.NewInt(2))
+	state.IntermediateRoot(false)
+
+	// A plain copy commits the same state without touching the snapshots
+	plain := state.Copy()
+	plain.RecordStateDiff()
+	plainRoot, err := plain.Commit(false)
+	if err != nil {
+		t.Fatalf("failed to commit plain copy: %v", err)
+	}
+	if snaps.Snapshot(plainRoot) != nil {
+		t.Fatalf("plain copy created snapshot layer")
+	}
+	if plain.StateDiff() != nil {
+		t.Fatalf("plain copy recorded state diff")
+	}
+	// A copy for committing extends the snapshot tree with the same state
+	committed := state.CopyForCommit()
+	committed.RecordStateDiff()
+	committedRoot, err := committed.Commit(false)
+	if err != nil {
+		t.Fatalf("failed to commit copy: %v", err)
+	}
+	if committedRoot != plainRoot {
+		t.Fatalf("root mismatch: have %x, want %x", committedRoot, plainRoot)
+	}
+	layer := snaps.Snapshot(committedRoot)
+	if layer == nil {
+		t.Fatalf("committed copy created no snapshot layer")
+	}
+	account, err := layer.Account(crypto.Keccak256Hash(common.Address{0x01}.Bytes()))
+	if err != nil || account == nil || account.Balance.Cmp(big.NewInt(2)) != 0 {
+		t.Fatalf("snapshot account mismatch: %v, %v", account, err)
+	}
+	if committed.StateDiff() == nil {
+		t.Fatalf("committed copy recorded no state diff")
+	}
+	// The original state is unaffected by committing its copies
+	if state.GetBalance(common.Address{0x01}).Cmp(big.NewInt(2)) != 0 || len(state.snapAccounts) != 1 {
+		t.Fatalf("original state modified by copies")
+	}
+}
+
 // TestDeleteCreateRevert tests a weird state transition corner case that we hit
 // while changing the internals of StateDB. The workflow is that a contract is
 // self-destructed, then in a follow-up transaction (but same block) it's created
@@ -0,0 +1,322 @@
+// Copyright 2021 The go-hayekchain Authors
+// This file is part of the go-hayekchain library.
+//
+// The go-hayekchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hayekchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hayekchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+	"sync"
+
+	"github.com/hayekchain/go-hayekchain/common"
+	"github.com/hayekchain/go-hayekchain/core/rawdb"
+	"github.com/hayekchain/go-hayekchain/core/state/snapshot"
+	"github.com/hayekchain/go-hayekchain/core/types"
+	"github.com/hayekchain/go-hayekchain/hykdb"
+	"github.com/hayekchain/go-hayekchain/rlp"
+	"github.com/hayekchain/go-hayekchain/trie"
+)
+
+// errHistoricCommit is returned when attempting to commit a historic state.
+var errHistoricCommit = errors.New("historic state cannot be committed")
+
+// StateDiff is the reverse diff of the state changes made by a block: the values
+// the accounts and storage slots modified by the block had before it, keyed by
+// hash like the snapshot. Accounts are in the slim RLP format and storage values
+// RLP encoded, empty values denote missing entries.
+type StateDiff struct {
+	Accounts []DiffAccount
+	Storage  []DiffStorage
+}
+
+// DiffAccount is the value an account had before a block.
+type DiffAccount struct {
+	Hash common.Hash
+	Blob []byte
+}
+
+// DiffStorage is the values the storage slots of an account had before a block.
+type DiffStorage struct {
+	Account common.Hash
+	Slots   []common.Hash
+	Values  [][]byte
+}
+
+// RecordStateDiff makes the next Commit record the reverse diff of the state
+// changes, retrievable via StateDiff afterwards. Diffs are only recorded if the
+// state is backed by a snapshot, which tracks the modified accounts and slots.
+func (s *StateDB) RecordStateDiff() {
+	s.recordDiff = true
+}
+
+// StateDiff returns the reverse state diff recorded by the last Commit, or nil
+// if none was recorded.
+func (s *StateDB) StateDiff() *StateDiff {
+	return s.diff
+}
+
+// reverseDiff collects the values the accounts and storage slots modified since
+// the snapshot of the parent state had there. It must be called before the new
+// state is added to the snapshot tree.
+func (s *StateDB) reverseDiff() (*StateDiff, error) {
+	var (
+		parent   = &flatReader{root: s.snap.Root(), snap: s.snap, triedb: s.db.TrieDB()}
+		accounts = make(map[common.Hash][]byte)
+		storage  = make(map[common.Hash]map[common.Hash][]byte)
+	)
+	addAccount := func(hash common.Hash) error {
+		if _, ok := accounts[hash]; ok {
+			return nil
+		}
+		blob, err := parent.account(hash)
+		if err != nil {
+			return err
+		}
+		accounts[hash] = blob
+		return nil
+	}
+	addSlot := func(account, slot common.Hash, blob []byte) {
+		slots, ok := storage[account]
+		if !ok {
+			slots = make(map[common.Hash][]byte)
+			storage[account] = slots
+		}
+		slots[slot] = blob
+	}
+	// Destructed accounts lose their entire storage, record all of it
+	for hash := range s.snapDestructs {
+		if err := addAccount(hash); err != nil {
+			return nil, err
+		}
+		root, err := parent.storageRoot(hash)
+		if err != nil {
+			return nil, err
+		}
+		if root == emptyRoot {
+			continue
+		}
+		tr, err := trie.New(root, parent.triedb)
+		if err != nil {
+			return nil, err
+		}
+		it := trie.NewIterator(tr.NodeIterator(nil))
+		for it.Next() {
+			addSlot(hash, common.BytesToHash(it.Key), common.CopyBytes(it.Value))
+		}
+		if it.Err != nil {
+			return nil, it.Err
+		}
+	}
+	for hash := range s.snapAccounts {
+		if err := addAccount(hash); err != nil {
+			return nil, err
+		}
+	}
+	for hash, slots := range s.snapStorage {
+		for slot := range slots {
+			if _, ok := storage[hash][slot]; ok {
+				continue
+			}
+			blob, err := parent.storage(hash, slot)
+			if err != nil {
+				return nil, err
+			}
+			addSlot(hash, slot, blob)
+		}
+	}
+	// Flatten the diff in a deterministic order
+	diff := &StateDiff{
+		Accounts: make([]DiffAccount, 0, len(accounts)),
+		Storage:  make([]DiffStorage, 0, len(storage)),
+	}
+	for hash, blob := range accounts {
+		diff.Accounts = append(diff.Accounts, DiffAccount{Hash: hash, Blob: blob})
+	}
+	sort.Slice(diff.Accounts, func(i, j int) bool {
+		return bytes.Compare(diff.Accounts[i].Hash[:], diff.Accounts[j].Hash[:]) < 0
+	})
+	for hash, slots := range storage {
+		entry := DiffStorage{Account: hash, Slots: make([]common.Hash, 0, len(slots))}
+		for slot := range slots {
+			entry.Slots = append(entry.Slots, slot)
+		}
+		sort.Slice(entry.Slots, func(i, j int) bool {
+			return bytes.Compare(entry.Slots[i][:], entry.Slots[j][:]) < 0
+		})
+		for _, slot := range entry.Slots {
+			entry.Values = append(entry.Values, slots[slot])
+		}
+		diff.Storage = append(diff.Storage, entry)
+	}
+	sort.Slice(diff.Storage, func(i, j int) bool {
+		return bytes.Compare(diff.Storage[i].Account[:], diff.Storage[j].Account[:]) < 0
+	})
+	return diff, nil
+}
+
+// flatReader reads the accounts and storage slots of a state by hash, from its
+// snapshot layer if available, falling back to its tries otherwise.
+type flatReader struct {
+	root   common.Hash
+	snap   snapshot.Snapshot // Snapshot layer of the state, nil if unavailable
+	triedb *trie.Database
+	trie   *trie.Trie // Account trie, opened on demand
+}
+
+// account retrieves the slim RLP encoded account with the given hash.
+func (r *flatReader) account(hash common.Hash) ([]byte, error) {
+	if r.snap != nil {
+		if blob, err := r.snap.AccountRLP(hash); err == nil {
+			return blob, nil
+		}
+	}
+	if r.trie == nil {
+		tr, err := trie.New(r.root, r.triedb)
+		if err != nil {
+			return nil, err
+		}
+		r.trie = tr
+	}
+	enc, err := r.trie.TryGet(hash[:])
+	if err != nil || len(enc) == 0 {
+		return nil, err
+	}
+	var data Account
+	if err := rlp.DecodeBytes(enc, &data); err != nil {
+		return nil, err
+	}
+	return snapshot.SlimAccountRLP(data.Nonce, data.Balance, data.Root, data.CodeHash), nil
+}
+
+// storageRoot retrieves the storage root of the account with the given hash.
+func (r *flatReader) storageRoot(hash common.Hash) (common.Hash, error) {
+	blob, err := r.account(hash)
+	if err != nil || len(blob) == 0 {
+		return emptyRoot, err
+	}
+	account, err := snapshot.FullAccount(blob)
+	if err != nil {
+		return emptyRoot, err
+	}
+	return common.BytesToHash(account.Root), nil
+}
+
+// storage retrieves the RLP encoded value of a storage slot of an account.
+func (r *flatReader) storage(accountHash, storageHash common.Hash) ([]byte, error) {
+	if r.snap != nil {
+		if blob, err := r.snap.Storage(accountHash, storageHash); err == nil {
+			return blob, nil
+		}
+	}
+	root, err := r.storageRoot(accountHash)
+	if err != nil || root == emptyRoot {
+		return nil, err
+	}
+	tr, err := trie.New(root, r.triedb)
+	if err != nil {
+		return nil, err
+	}
+	return tr.TryGet(storageHash[:])
+}
+
+// historicLayer is a read only snapshot.Snapshot of a historic state. The values
+// of the accounts and storage slots modified after it are looked up in the index
+// of reverse state diffs, all others are read from the newer base state.
+type historicLayer struct {
+	root   common.Hash
+	number uint64
+	diskdb hykdb.Iteratee
+
+	base *flatReader
+	lock sync.Mutex // Protects the lazily opened tries of the base
+}
+
+// Root returns the root hash of the historic state.
+func (l *historicLayer) Root() common.Hash {
+	return l.root
+}
+
+// Account retrieves the account with the given hash in the historic state.
+func (l *historicLayer) Account(hash common.Hash) (*snapshot.Account, error) {
+	blob, err := l.AccountRLP(hash)
+	if err != nil || len(blob) == 0 {
+		return nil, err
+	}
+	account := new(snapshot.Account)
+	if err := rlp.DecodeBytes(blob, account); err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+// AccountRLP retrieves the slim RLP encoded account with the given hash in the
+// historic state.
+func (l *historicLayer) AccountRLP(hash common.Hash) ([]byte, error) {
+	if blob, ok := rawdb.ReadAccountHistory(l.diskdb, hash, l.number); ok {
+		return blob, nil
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	return l.base.account(hash)
+}
+
+// Storage retrieves the RLP encoded value of a storage slot in the historic state.
+func (l *historicLayer) Storage(accountHash, storageHash common.Hash) ([]byte, error) {
+	if blob, ok := rawdb.ReadStorageHistory(l.diskdb, accountHash, storageHash, l.number); ok {
+		return blob, nil
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	return l.base.storage(accountHash, storageHash)
+}
+
+// NewHistoric creates a read only state of the block with the given number and
+// state root, reconstructed from the reverse state diffs indexed on top of the
+// state of the given head. The tries of the historic state are not needed, but
+// neither are they available for proofs, dumps or commits.
+func NewHistoric(root common.Hash, number uint64, head common.Hash, db Database, snaps *snapshot.Tree) (*StateDB, error) {
+	tr, err := db.OpenTrie(emptyRoot)
+	if err != nil {
+		return nil, err
+	}
+	base := &flatReader{root: head, triedb: db.TrieDB()}
+	if snaps != nil {
+		base.snap = snaps.Snapshot(head)
+	}
+	return &StateDB{
+		db:   db,
+		trie: tr,
+		snap: &historicLayer{
+			root:   root,
+			number: number,
+			diskdb: db.TrieDB().DiskDB(),
+			base:   base,
+		},
+		snapDestructs:       make(map[common.Hash]struct{}),
+		snapAccounts:        make(map[common.Hash][]byte),
+		snapStorage:         make(map[common.Hash]map[common.Hash][]byte),
+		stateObjects:        make(map[common.Address]*stateObject),
+		stateObjectsPending: make(map[common.Address]struct{}),
+		stateObjectsDirty:   make(map[common.Address]struct{}),
+		logs:                make(map[common.Hash][]*types.Log),
+		preimages:           make(map[common.Hash][]byte),
+		journal:             newJournal(),
+		accessList:          newAccessList(),
+	}, nil
+}
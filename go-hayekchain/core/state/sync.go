@@ -27,6 +27,16 @@ import (
 
 // NewStateSync create a new state trie download scheduler.
 func NewStateSync(root common.Hash, database hykdb.KeyValueReader, bloom *trie.SyncBloom) *trie.Sync {
+	return newStateSync(root, database, bloom, trie.NewSync)
+}
+
+// NewBinaryStateSync create a new state trie download scheduler for a state
+// stored in binary tries.
+func NewBinaryStateSync(root common.Hash, database hykdb.KeyValueReader, bloom *trie.SyncBloom) *trie.Sync {
+	return newStateSync(root, database, bloom, trie.NewBinarySync)
+}
+
+func newStateSync(root common.Hash, database hykdb.KeyValueReader, bloom *trie.SyncBloom, newSync func(common.Hash, hykdb.KeyValueReader, trie.LeafCallback, *trie.SyncBloom) *trie.Sync) *trie.Sync {
 	var syncer *trie.Sync
 	callback := func(path []byte, leaf []byte, parent common.Hash) error {
 		var obj Account
@@ -37,6 +47,6 @@ func NewStateSync(root common.Hash, database hykdb.KeyValueReader, bloom *trie.S
 		syncer.AddCodeEntry(common.BytesToHash(obj.CodeHash), path, parent)
 		return nil
 	}
-	syncer = trie.NewSync(root, database, callback, bloom)
+	syncer = newSync(root, database, callback, bloom)
 	return syncer
 }
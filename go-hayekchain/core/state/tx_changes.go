@@ -0,0 +1,263 @@
+// Copyright 2021 The go-hayekchain Authors
+// This file is part of the go-hayekchain library.
+//
+// The go-hayekchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hayekchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hayekchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"math/big"
+
+	"github.com/hayekchain/go-hayekchain/common"
+	"github.com/hayekchain/go-hayekchain/core/types"
+)
+
+// accessTracker records the parts of the state a transaction depends on. Account
+// reads cover the account fields (balance, nonce, code and existence), whereas
+// storage accesses only depend on the accessed slots and the account not being
+// recreated or destroyed.
+//
+// Balance increases of fee recipients (the coinbase and the base fee collector)
+// are tracked as deltas instead of reads, as every transaction pays fees into
+// them, which would otherwise serialize them all.
+type accessTracker struct {
+	deltas map[common.Address]*big.Int // Balance increases of the fee recipients
+
+	accounts map[common.Address]struct{}
+	storage  map[common.Address]map[common.Hash]struct{}
+}
+
+// readAccount records a dependency on the fields of an account.
+func (t *accessTracker) readAccount(addr common.Address) {
+	t.accounts[addr] = struct{}{}
+}
+
+// readSlot records a dependency on a storage slot of an account.
+func (t *accessTracker) readSlot(addr common.Address, key common.Hash) {
+	slots, ok := t.storage[addr]
+	if !ok {
+		slots = make(map[common.Hash]struct{})
+		t.storage[addr] = slots
+	}
+	slots[key] = struct{}{}
+}
+
+// accountChange is the final state of an account modified by a transaction.
+type accountChange struct {
+	fields   bool // Whether the account fields were modified (or touched)
+	created  bool // Whether the account was (re)created, wiping its storage
+	suicided bool // Whether the account self destructed
+
+	balance *big.Int
+	nonce   uint64
+	code    []byte // Only set if the code was changed
+	storage map[common.Hash]common.Hash
+}
+
+// TxChanges is the footprint of a single transaction executed against a state:
+// the state it read and the state it wrote. It allows replaying the transaction
+// on top of a different state, as long as none of its reads were modified there.
+type TxChanges struct {
+	reads *accessTracker
+
+	accounts map[common.Address]*accountChange
+	deltas   map[common.Address]*big.Int // Balance increases of the fee recipients not read
+	logs     []*types.Log
+}
+
+// TrackAccesses starts recording the state accessed by subsequent operations,
+// which can be retrieved via TxChanges at the end of the transaction. Credits to
+// the given fee recipients are recorded as balance deltas.
+func (s *StateDB) TrackAccesses(recipients ...common.Address) {
+	deltas := make(map[common.Address]*big.Int, len(recipients))
+	for _, addr := range recipients {
+		deltas[addr] = new(big.Int)
+	}
+	s.tracker = &accessTracker{
+		deltas:   deltas,
+		accounts: make(map[common.Address]struct{}),
+		storage:  make(map[common.Address]map[common.Hash]struct{}),
+	}
+}
+
+// TxChanges stops tracking state accesses and returns the footprint of the
+// current transaction. It must be called before the state is finalised.
+func (s *StateDB) TxChanges() *TxChanges {
+	tracker := s.tracker
+	if tracker == nil {
+		return nil
+	}
+	s.tracker = nil
+
+	changes := &TxChanges{
+		reads:    tracker,
+		accounts: make(map[common.Address]*accountChange),
+		deltas:   make(map[common.Address]*big.Int),
+		logs:     s.logs[s.thash],
+	}
+	for addr, delta := range tracker.deltas {
+		if _, read := tracker.accounts[addr]; !read {
+			changes.deltas[addr] = delta
+		}
+	}
+	change := func(addr common.Address) *accountChange {
+		if c, ok := changes.accounts[addr]; ok {
+			return c
+		}
+		c := &accountChange{storage: make(map[common.Hash]common.Hash)}
+		changes.accounts[addr] = c
+		return c
+	}
+	// Only the non-reverted journal entries are left, collect the modified accounts
+	for _, entry := range s.journal.entries {
+		switch entry := entry.(type) {
+		case createObjectChange:
+			change(*entry.account).created = true
+		case resetObjectChange:
+			change(entry.prev.address).created = true
+		case suicideChange:
+			change(*entry.account).fields = true
+		case balanceChange:
+			change(*entry.account).fields = true
+		case nonceChange:
+			change(*entry.account).fields = true
+		case codeChange:
+			change(*entry.account).fields = true
+		case touchChange:
+			change(*entry.account).fields = true
+		case storageChange:
+			change(*entry.account).storage[entry.key] = common.Hash{}
+		}
+	}
+	for addr, c := range changes.accounts {
+		obj, exist := s.stateObjects[addr]
+		if !exist {
+			// Special case of the touched ripeMD, see Finalise
+			delete(changes.accounts, addr)
+			continue
+		}
+		if c.created {
+			c.fields = true
+		}
+		c.suicided = obj.suicided
+		c.balance = new(big.Int).Set(obj.Balance())
+		c.nonce = obj.Nonce()
+		if obj.dirtyCode {
+			c.code = obj.code
+		}
+		for key := range c.storage {
+			c.storage[key] = obj.GetState(s.db, key)
+		}
+	}
+	return changes
+}
+
+// ApplyTxChanges replays the state modifications of a transaction executed on
+// a different state. The caller is responsible for ensuring that none of the
+// state read by the transaction was modified in this state.
+func (s *StateDB) ApplyTxChanges(changes *TxChanges) {
+	for addr, c := range changes.accounts {
+		// Fee recipients might have been created by crediting them, which will
+		// also happen when applying the delta
+		_, blind := changes.deltas[addr]
+
+		if c.created && !blind {
+			s.createObject(addr)
+		}
+		if c.fields && !blind {
+			s.SetBalance(addr, c.balance)
+			s.SetNonce(addr, c.nonce)
+			if c.code != nil {
+				s.SetCode(addr, c.code)
+			}
+		}
+		for key, value := range c.storage {
+			s.SetState(addr, key, value)
+		}
+		if c.suicided {
+			s.Suicide(addr)
+		}
+	}
+	for addr, delta := range changes.deltas {
+		if _, ok := changes.accounts[addr]; ok {
+			s.AddBalance(addr, delta)
+		}
+	}
+	for _, log := range changes.logs {
+		s.AddLog(log)
+	}
+}
+
+// WriteSet accumulates the state written by a sequence of transactions, used to
+// detect whether a transaction read any of it.
+type WriteSet struct {
+	accounts map[common.Address]struct{}                 // Accounts with modified fields
+	resets   map[common.Address]struct{}                 // Accounts created, destroyed or possibly deleted as empty
+	storage  map[common.Address]map[common.Hash]struct{} // Modified storage slots
+}
+
+// NewWriteSet creates an empty write set.
+func NewWriteSet() *WriteSet {
+	return &WriteSet{
+		accounts: make(map[common.Address]struct{}),
+		resets:   make(map[common.Address]struct{}),
+		storage:  make(map[common.Address]map[common.Hash]struct{}),
+	}
+}
+
+// Add merges the writes of a transaction into the set.
+func (w *WriteSet) Add(changes *TxChanges) {
+	for addr, c := range changes.accounts {
+		if c.fields {
+			w.accounts[addr] = struct{}{}
+		}
+		if c.created || c.suicided || (c.fields && c.nonce == 0 && c.balance.Sign() == 0) {
+			w.resets[addr] = struct{}{}
+		}
+		if len(c.storage) > 0 {
+			slots, ok := w.storage[addr]
+			if !ok {
+				slots = make(map[common.Hash]struct{})
+				w.storage[addr] = slots
+			}
+			for key := range c.storage {
+				slots[key] = struct{}{}
+			}
+		}
+	}
+}
+
+// Conflicts reports whether the transaction read any state contained in the set.
+func (w *WriteSet) Conflicts(changes *TxChanges) bool {
+	for addr := range changes.reads.accounts {
+		if _, ok := w.accounts[addr]; ok {
+			return true
+		}
+	}
+	for addr, slots := range changes.reads.storage {
+		if _, ok := w.resets[addr]; ok {
+			return true
+		}
+		written, ok := w.storage[addr]
+		if !ok {
+			continue
+		}
+		for key := range slots {
+			if _, ok := written[key]; ok {
+				return true
+			}
+		}
+	}
+	return false
+}
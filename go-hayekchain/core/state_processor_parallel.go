@@ -0,0 +1,211 @@
+// Copyright 2021 The go-hayekchain Authors
+// This file is part of the go-hayekchain library.
+//
+// The go-hayekchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hayekchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hayekchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"fmt"
+	"runtime"
+	"sync/atomic"
+
+	"github.com/hayekchain/go-hayekchain/common"
+	"github.com/hayekchain/go-hayekchain/consensus"
+	"github.com/hayekchain/go-hayekchain/consensus/misc"
+	"github.com/hayekchain/go-hayekchain/core/state"
+	"github.com/hayekchain/go-hayekchain/core/types"
+	"github.com/hayekchain/go-hayekchain/core/vm"
+	"github.com/hayekchain/go-hayekchain/metrics"
+	"github.com/hayekchain/go-hayekchain/params"
+)
+
+var (
+	parallelAppliedMeter   = metrics.NewRegisteredMeter("chain/parallel/applied", nil)
+	parallelReexecuteMeter = metrics.NewRegisteredMeter("chain/parallel/reexecuted", nil)
+)
+
+// speculation is the outcome of a transaction executed on top of the parent
+// state of its block, ignoring the transactions before it.
+type speculation struct {
+	msg     types.Message
+	msgErr  error // Error converting the transaction into a message
+	result  *ExecutionResult
+	changes *state.TxChanges
+	err     error // Error executing the message
+}
+
+// ParallelStateProcessor is a Processor which optimistically executes the
+// transactions of a block concurrently, each on top of the block's parent state,
+// tracking the state every one of them reads and writes. The results are then
+// committed in order: transactions which read state modified by an earlier one
+// in the block are re-executed on top of the up-to-date state, all others have
+// their writes applied directly. The outcome is identical to sequential
+// processing.
+//
+// ParallelStateProcessor implements Processor.
+type ParallelStateProcessor struct {
+	config  *params.ChainConfig // Chain configuration options
+	bc      *BlockChain         // Canonical block chain
+	engine  consensus.Engine    // Consensus engine used for block rewards
+	workers int                 // Number of concurrent speculative executors
+
+	fallback *StateProcessor // Sequential processor for unsupported blocks
+}
+
+// NewParallelStateProcessor initialises a new ParallelStateProcessor.
+func NewParallelStateProcessor(config *params.ChainConfig, bc *BlockChain, engine consensus.Engine) *ParallelStateProcessor {
+	return &ParallelStateProcessor{
+		config:   config,
+		bc:       bc,
+		engine:   engine,
+		workers:  runtime.NumCPU(),
+		fallback: NewStateProcessor(config, bc, engine),
+	}
+}
+
+// Process processes the state changes according to the HayekChain rules by running
+// the transaction messages using the statedb and applying any rewards to both
+// the processor (coinbase) and any included uncles.
+//
+// Pre-Byzantium blocks, whose receipts contain intermediate state roots, as well
+// as debug and preimage recording runs are processed sequentially.
+func (p *ParallelStateProcessor) Process(block *types.Block, statedb *state.StateDB, cfg vm.Config) (types.Receipts, []*types.Log, uint64, error) {
+	txs := block.Transactions()
+	if len(txs) < 2 || p.workers < 2 || !p.config.IsByzantium(block.Number()) || cfg.Debug || cfg.EnablePreimageRecording {
+		return p.fallback.Process(block, statedb, cfg)
+	}
+	var (
+		receipts types.Receipts
+		usedGas  = new(uint64)
+		header   = block.Header()
+		allLogs  []*types.Log
+		gp       = new(GasPool).AddGas(block.GasLimit())
+	)
+	// Mutate the block and state according to any hard-fork specs
+	if p.config.DAOForkSupport && p.config.DAOForkBlock != nil && p.config.DAOForkBlock.Cmp(block.Number()) == 0 {
+		misc.ApplyDAOHardFork(statedb)
+	}
+	blockContext := NewEVMBlockContext(header, p.bc, nil)
+	vmenv := vm.NewEVM(blockContext, vm.TxContext{}, statedb, p.config, cfg)
+
+	// Fee payments into the coinbase and the base fee collector don't make the
+	// transactions of the block depend on each other
+	recipients := []common.Address{blockContext.Coinbase}
+	if collector := p.config.BaseFeeCollector; collector != nil && p.config.IsLondon(header.Number) {
+		recipients = append(recipients, *collector)
+	}
+
+	// Start speculatively executing all transactions on top of the parent state
+	var (
+		base    = statedb.Copy()
+		results = make([]chan *speculation, len(txs))
+		next    = int32(-1)
+		abort   = make(chan struct{})
+	)
+	defer close(abort)
+
+	for i := range results {
+		results[i] = make(chan *speculation, 1)
+	}
+	for i := 0; i < p.workers && i < len(txs); i++ {
+		go func() {
+			for {
+				index := int(atomic.AddInt32(&next, 1))
+				if index >= len(txs) {
+					return
+				}
+				select {
+				case <-abort:
+					return
+				default:
+				}
+				results[index] <- p.speculate(block, base, index, recipients, cfg)
+			}
+		}()
+	}
+	// Commit the transactions in order, re-executing any invalidated ones
+	written := state.NewWriteSet()
+	for i, tx := range txs {
+		spec := <-results[i]
+		if spec.msgErr != nil {
+			return nil, nil, 0, spec.msgErr
+		}
+		statedb.Prepare(tx.Hash(), block.Hash(), i)
+
+		var (
+			result  *ExecutionResult
+			changes *state.TxChanges
+		)
+		if spec.err == nil && gp.Gas() >= spec.msg.Gas() && !written.Conflicts(spec.changes) {
+			statedb.ApplyTxChanges(spec.changes)
+			if err := gp.SubGas(spec.result.UsedGas); err != nil {
+				return nil, nil, 0, fmt.Errorf("could not apply tx %d [%v]: %w", i, tx.Hash().Hex(), err)
+			}
+			result, changes = spec.result, spec.changes
+			parallelAppliedMeter.Mark(1)
+		} else {
+			statedb.TrackAccesses(recipients...)
+			vmenv.Reset(NewEVMTxContext(spec.msg), statedb)
+
+			var err error
+			result, err = ApplyMessage(vmenv, spec.msg, gp)
+			changes = statedb.TxChanges()
+			if err != nil {
+				return nil, nil, 0, fmt.Errorf("could not apply tx %d [%v]: %w", i, tx.Hash().Hex(), err)
+			}
+			parallelReexecuteMeter.Mark(1)
+		}
+		written.Add(changes)
+		statedb.Finalise(true)
+		*usedGas += result.UsedGas
+
+		receipt := newReceipt(spec.msg, result, nil, *usedGas, statedb, header, tx)
+		receipts = append(receipts, receipt)
+		allLogs = append(allLogs, receipt.Logs...)
+	}
+	// Finalize the block, applying any consensus engine specific extras (e.g. block rewards)
+	p.engine.Finalize(p.bc, header, statedb, txs, block.Uncles())
+
+	return receipts, allLogs, *usedGas, nil
+}
+
+// speculate executes a transaction on a copy of the base state, recording the
+// state it accessed and the fees paid to the given recipients.
+func (p *ParallelStateProcessor) speculate(block *types.Block, base *state.StateDB, index int, recipients []common.Address, cfg vm.Config) *speculation {
+	var (
+		header = block.Header()
+		tx     = block.Transactions()[index]
+	)
+	msg, err := tx.AsMessage(types.MakeSigner(p.config, header.Number), header.BaseFee)
+	if err != nil {
+		return &speculation{msgErr: err}
+	}
+	statedb := base.Copy()
+	statedb.Prepare(tx.Hash(), block.Hash(), index)
+	statedb.TrackAccesses(recipients...)
+
+	// Each execution needs its own block context, the block hash cache isn't thread safe
+	vmenv := vm.NewEVM(NewEVMBlockContext(header, p.bc, nil), NewEVMTxContext(msg), statedb, p.config, cfg)
+	result, err := ApplyMessage(vmenv, msg, new(GasPool).AddGas(block.GasLimit()))
+	if err == nil {
+		err = statedb.Error()
+	}
+	return &speculation{
+		msg:     msg,
+		result:  result,
+		changes: statedb.TxChanges(),
+		err:     err,
+	}
+}
@@ -0,0 +1,264 @@
+// Copyright 2021 The go-hayekchain Authors
+// This file is part of the go-hayekchain library.
+//
+// The go-hayekchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hayekchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hayekchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"crypto/ecdsa"
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/hayekchain/go-hayekchain/common"
+	"github.com/hayekchain/go-hayekchain/consensus/hykash"
+	"github.com/hayekchain/go-hayekchain/core/rawdb"
+	"github.com/hayekchain/go-hayekchain/core/state"
+	"github.com/hayekchain/go-hayekchain/core/types"
+	"github.com/hayekchain/go-hayekchain/core/vm"
+	"github.com/hayekchain/go-hayekchain/crypto"
+	"github.com/hayekchain/go-hayekchain/hykdb"
+	"github.com/hayekchain/go-hayekchain/params"
+)
+
+var (
+	// parallelCounterCode increments storage slot 0 and logs the new value with
+	// the caller as topic. All calls to it conflict with each other.
+	parallelCounterCode = common.FromHex("6000546001018060005560005233602060006000a100")
+
+	// parallelHasherCode hashes 1024 times in a loop and stores the result in
+	// the slot of the caller. Calls from different senders are independent.
+	parallelHasherCode = common.FromHex("6104005b602060002060005260019003806003575060005133550000")
+
+	// parallelSuicideCode self destructs, sending its balance to the caller.
+	parallelSuicideCode = common.FromHex("33ff")
+
+	parallelCounter = common.HexToAddress("0xc0c0")
+	parallelHasher  = common.HexToAddress("0xa5a5")
+	parallelSuicide = common.HexToAddress("0xdead")
+)
+
+// parallelTestChain generates a chain whose blocks exercise the parallel processor:
+// independent transactions, nonce and storage dependencies, coinbase transfers
+// and reads, contract creations, self destructs and touched empty accounts.
+func parallelTestChain(t testing.TB, config *params.ChainConfig, senders int, blocks int, gen func(i int, keys []*ecdsa.PrivateKey, b *BlockGen)) (*Genesis, hykdb.Database, []*types.Block) {
+	keys := make([]*ecdsa.PrivateKey, senders)
+	alloc := GenesisAlloc{
+		parallelCounter: {Code: parallelCounterCode, Balance: common.Big0},
+		parallelHasher:  {Code: parallelHasherCode, Balance: common.Big0},
+		parallelSuicide: {Code: parallelSuicideCode, Balance: big.NewInt(params.Hayeker)},
+	}
+	for i := range keys {
+		keys[i], _ = crypto.GenerateKey()
+		alloc[crypto.PubkeyToAddress(keys[i].PublicKey)] = GenesisAccount{Balance: big.NewInt(params.Hayeker)}
+	}
+	var (
+		db      = rawdb.NewMemoryDatabase()
+		gspec   = &Genesis{Config: config, Alloc: alloc, GasLimit: 30000000}
+		genesis = gspec.MustCommit(db)
+	)
+	chain, _ := GenerateChain(gspec.Config, genesis, hykash.NewFaker(), db, blocks, func(i int, b *BlockGen) {
+		gen(i, keys, b)
+	})
+	return gspec, db, chain
+}
+
+// Tests that the parallel state processor produces results identical to the
+// sequential one.
+func TestParallelStateProcessor(t *testing.T) {
+	signer := types.NewEIP155Signer(params.TestChainConfig.ChainID)
+	newTx := func(b *BlockGen, key *ecdsa.PrivateKey, to *common.Address, value int64, gas uint64, data []byte) {
+		from := crypto.PubkeyToAddress(key.PublicKey)
+		var tx *types.Transaction
+		if to == nil {
+			tx = types.NewContractCreation(b.TxNonce(from), big.NewInt(value), gas, big.NewInt(1), data)
+		} else {
+			tx = types.NewTransaction(b.TxNonce(from), *to, big.NewInt(value), gas, big.NewInt(1), data)
+		}
+		tx, err := types.SignTx(tx, signer, key)
+		if err != nil {
+			t.Fatalf("failed to sign tx: %v", err)
+		}
+		b.AddTx(tx)
+	}
+	gspec, db, blocks := parallelTestChain(t, params.TestChainConfig, 8, 4, func(i int, keys []*ecdsa.PrivateKey, b *BlockGen) {
+		// The last key mines the block, also transacting from the coinbase
+		coinbase := keys[len(keys)-1]
+		b.SetCoinbase(crypto.PubkeyToAddress(coinbase.PublicKey))
+
+		for j, key := range keys[:len(keys)-1] {
+			newTx(b, key, &parallelHasher, 0, 200000, nil)
+			switch j % 4 {
+			case 0:
+				newTx(b, key, &parallelCounter, 0, 100000, nil)
+			case 1:
+				newTx(b, key, &b.header.Coinbase, 1000, params.TxGas, nil)
+			case 2:
+				empty := common.BigToAddress(big.NewInt(int64(0xe000 + i*16 + j)))
+				newTx(b, key, &empty, 0, params.TxGas, nil)
+			case 3:
+				newTx(b, key, nil, 0, 100000, parallelSuicideCode)
+			}
+		}
+		newTx(b, coinbase, &parallelHasher, 0, 200000, nil)
+		if i == 1 {
+			newTx(b, keys[0], &parallelSuicide, 0, 100000, nil)
+			newTx(b, keys[1], &parallelSuicide, 0, 100000, nil)
+		}
+	})
+	chain, err := NewBlockChain(db, nil, gspec.Config, hykash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+
+	sequential := NewStateProcessor(gspec.Config, chain, chain.engine)
+	parallel := NewParallelStateProcessor(gspec.Config, chain, chain.engine)
+	parallel.workers = 4
+
+	for _, block := range blocks {
+		parent := chain.GetBlockByHash(block.ParentHash())
+		if parent == nil {
+			t.Fatalf("block %d: missing parent", block.NumberU64())
+		}
+		run := func(p Processor) (string, common.Hash) {
+			statedb, err := state.New(parent.Root(), chain.stateCache, nil)
+			if err != nil {
+				t.Fatalf("block %d: failed to open state: %v", block.NumberU64(), err)
+			}
+			receipts, logs, gas, err := p.Process(block, statedb, vm.Config{})
+			if err != nil {
+				t.Fatalf("block %d: failed to process: %v", block.NumberU64(), err)
+			}
+			blob, _ := json.Marshal([]interface{}{receipts, logs, gas})
+			return string(blob), statedb.IntermediateRoot(true)
+		}
+		seqResult, seqRoot := run(sequential)
+		parResult, parRoot := run(parallel)
+		if seqRoot != parRoot {
+			t.Fatalf("block %d: state root mismatch: sequential %x, parallel %x", block.NumberU64(), seqRoot, parRoot)
+		}
+		if seqResult != parResult {
+			t.Fatalf("block %d: result mismatch:\nsequential %s\nparallel   %s", block.NumberU64(), seqResult, parResult)
+		}
+		if n, err := chain.InsertChain(types.Blocks{block}); err != nil {
+			t.Fatalf("block %d: failed to insert: %v", n, err)
+		}
+	}
+	// Ensure a chain running with parallel execution accepts the same blocks
+	pardb := rawdb.NewMemoryDatabase()
+	gspec.MustCommit(pardb)
+
+	cacheConfig := *defaultCacheConfig
+	cacheConfig.ParallelExecution = true
+	parchain, err := NewBlockChain(pardb, &cacheConfig, gspec.Config, hykash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer parchain.Stop()
+
+	if n, err := parchain.InsertChain(blocks); err != nil {
+		t.Fatalf("block %d: failed to insert: %v", n, err)
+	}
+}
+
+// Tests that base fee payments into the collector don't make the transactions of
+// a London block depend on each other.
+func TestParallelStateProcessorBaseFeeCollector(t *testing.T) {
+	config := *params.TestChainConfig
+	config.YoloV2Block = common.Big0
+	config.LondonBlock = common.Big0
+	config.BaseFeeCollector = &common.Address{0xfe}
+
+	signer := types.LatestSigner(&config)
+	gspec, db, blocks := parallelTestChain(t, &config, 4, 1, func(i int, keys []*ecdsa.PrivateKey, b *BlockGen) {
+		for _, key := range keys {
+			tx, _ := types.SignTx(types.NewTransaction(0, parallelHasher, new(big.Int), 200000, big.NewInt(10*params.GWei), nil), signer, key)
+			b.AddTx(tx)
+		}
+	})
+	chain, err := NewBlockChain(db, nil, gspec.Config, hykash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+
+	var (
+		block      = blocks[0]
+		parallel   = NewParallelStateProcessor(gspec.Config, chain, chain.engine)
+		sequential = NewStateProcessor(gspec.Config, chain, chain.engine)
+		recipients = []common.Address{block.Coinbase(), *config.BaseFeeCollector}
+		written    = state.NewWriteSet()
+	)
+	base, _ := state.New(chain.Genesis().Root(), chain.stateCache, nil)
+	for i := range block.Transactions() {
+		spec := parallel.speculate(block, base, i, recipients, vm.Config{})
+		if spec.err != nil {
+			t.Fatalf("transaction %d: failed to execute: %v", i, spec.err)
+		}
+		if written.Conflicts(spec.changes) {
+			t.Fatalf("transaction %d: conflicts with the preceding ones", i)
+		}
+		written.Add(spec.changes)
+	}
+	// The deltas should add up to the sequential outcome
+	parallel.workers = 4
+	roots := make([]common.Hash, 2)
+	for i, p := range []Processor{sequential, parallel} {
+		statedb, _ := state.New(chain.Genesis().Root(), chain.stateCache, nil)
+		if _, _, _, err := p.Process(block, statedb, vm.Config{}); err != nil {
+			t.Fatalf("failed to process block: %v", err)
+		}
+		if statedb.GetBalance(*config.BaseFeeCollector).Sign() == 0 {
+			t.Fatalf("base fees not collected")
+		}
+		roots[i] = statedb.IntermediateRoot(true)
+	}
+	if roots[0] != roots[1] {
+		t.Fatalf("state root mismatch: sequential %x, parallel %x", roots[0], roots[1])
+	}
+}
+
+func benchmarkBlockProcessing(b *testing.B, parallel bool) {
+	signer := types.NewEIP155Signer(params.TestChainConfig.ChainID)
+	gspec, db, blocks := parallelTestChain(b, params.TestChainConfig, 64, 1, func(i int, keys []*ecdsa.PrivateKey, gen *BlockGen) {
+		for _, key := range keys {
+			tx, _ := types.SignTx(types.NewTransaction(0, parallelHasher, new(big.Int), 200000, big.NewInt(1), nil), signer, key)
+			gen.AddTx(tx)
+		}
+	})
+	chain, err := NewBlockChain(db, nil, gspec.Config, hykash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		b.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+
+	var processor Processor = NewStateProcessor(gspec.Config, chain, chain.engine)
+	if parallel {
+		processor = NewParallelStateProcessor(gspec.Config, chain, chain.engine)
+	}
+	parent := chain.Genesis()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		statedb, _ := state.New(parent.Root(), chain.stateCache, nil)
+		if _, _, _, err := processor.Process(blocks[0], statedb, vm.Config{}); err != nil {
+			b.Fatalf("failed to process block: %v", err)
+		}
+	}
+}
+
+func BenchmarkProcessSequential(b *testing.B) { benchmarkBlockProcessing(b, false) }
+func BenchmarkProcessParallel(b *testing.B)   { benchmarkBlockProcessing(b, true) }
@@ -24,6 +24,7 @@ import (
 	"github.com/hayekchain/go-hayekchain/consensus"
 	"github.com/hayekchain/go-hayekchain/consensus/hykash"
 	"github.com/hayekchain/go-hayekchain/core/rawdb"
+	"github.com/hayekchain/go-hayekchain/core/state"
 	"github.com/hayekchain/go-hayekchain/core/types"
 	"github.com/hayekchain/go-hayekchain/core/vm"
 	"github.com/hayekchain/go-hayekchain/crypto"
@@ -111,6 +112,40 @@ func TestStateProcessorErrors(t *testing.T) {
 	}
 }
 
+// Tests that before London the coinbase is touched by every transaction, even
+// if it pays no fee, as chains without EIP-158 keep the resulting empty account.
+func TestZeroFeeCoinbaseTouch(t *testing.T) {
+	var (
+		key, _   = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address  = crypto.PubkeyToAddress(key.PublicKey)
+		coinbase = common.Address{0xc0}
+		config   = &params.ChainConfig{ChainID: big.NewInt(1), HomesteadBlock: big.NewInt(0)}
+		db       = rawdb.NewMemoryDatabase()
+		gspec    = &Genesis{Config: config, Alloc: GenesisAlloc{address: {Balance: big.NewInt(params.Hayeker)}}}
+		genesis  = gspec.MustCommit(db)
+	)
+	statedb, _ := state.New(genesis.Root(), state.NewDatabase(db), nil)
+	header := &types.Header{
+		ParentHash: genesis.Hash(),
+		Number:     big.NewInt(1),
+		Coinbase:   coinbase,
+		Difficulty: big.NewInt(1),
+		GasLimit:   genesis.GasLimit(),
+	}
+	tx, _ := types.SignTx(types.NewTransaction(0, common.Address{0xaa}, big.NewInt(1), params.TxGas, new(big.Int), nil), types.HomesteadSigner{}, key)
+
+	var (
+		gp      = new(GasPool).AddGas(header.GasLimit)
+		usedGas uint64
+	)
+	if _, err := ApplyTransaction(config, nil, &coinbase, gp, statedb, header, tx, &usedGas, vm.Config{}); err != nil {
+		t.Fatalf("failed to apply transaction: %v", err)
+	}
+	if !statedb.Exist(coinbase) {
+		t.Fatalf("zero fee coinbase not touched")
+	}
+}
+
 // GenerateBadBlock constructs a "block" which contains the transactions. The transactions are not expected to be
 // valid, and no proper post-state can be made. But from the perspective of the blockchain, the block is sufficiently
 // valid to be considered for import:
@@ -22,6 +22,8 @@ import (
 	"math/big"
 
 	"github.com/hayekchain/go-hayekchain/common"
+	cmath "github.com/hayekchain/go-hayekchain/common/math"
+	"github.com/hayekchain/go-hayekchain/core/types"
 	"github.com/hayekchain/go-hayekchain/core/vm"
 	"github.com/hayekchain/go-hayekchain/params"
 )
@@ -48,6 +50,8 @@ type StateTransition struct {
 	msg        Message
 	gas        uint64
 	gasPrice   *big.Int
+	gasFeeCap  *big.Int
+	gasTipCap  *big.Int
 	initialGas uint64
 	value      *big.Int
 	data       []byte
@@ -61,12 +65,16 @@ type Message interface {
 	To() *common.Address
 
 	GasPrice() *big.Int
+	GasFeeCap() *big.Int
+	GasTipCap() *big.Int
 	Gas() uint64
 	Value() *big.Int
 
 	Nonce() uint64
 	CheckNonce() bool
 	Data() []byte
+	AccessList() types.AccessList
+	FeePayer() *common.Address // Account paying for the gas, nil if the sender
 }
 
 // ExecutionResult includes all output after executing given evm
@@ -104,8 +112,9 @@ func (result *ExecutionResult) Revert() []byte {
 	return common.CopyBytes(result.ReturnData)
 }
 
-// IntrinsicGas computes the 'intrinsic gas' for a message with the given data.
-func IntrinsicGas(data []byte, contractCreation, isHomestead bool, isEIP2028 bool) (uint64, error) {
+// IntrinsicGas computes the 'intrinsic gas' for a message with the given data
+// and access list.
+func IntrinsicGas(data []byte, accessList types.AccessList, contractCreation, isHomestead bool, isEIP2028 bool) (uint64, error) {
 	// Set the starting gas for the raw transaction
 	var gas uint64
 	if contractCreation && isHomestead {
@@ -138,19 +147,34 @@ func IntrinsicGas(data []byte, contractCreation, isHomestead bool, isEIP2028 boo
 		}
 		gas += z * params.TxDataZeroGas
 	}
+	if accessList != nil {
+		gas += uint64(len(accessList)) * params.TxAccessListAddressGas
+		gas += uint64(accessList.StorageKeys()) * params.TxAccessListStorageKeyGas
+	}
 	return gas, nil
 }
 
 // NewStateTransition initialises and returns a new state transition object.
+// Messages without fee caps, such as legacy calls, have both caps default to
+// their gas price.
 func NewStateTransition(evm *vm.EVM, msg Message, gp *GasPool) *StateTransition {
+	gasFeeCap, gasTipCap := msg.GasFeeCap(), msg.GasTipCap()
+	if gasFeeCap == nil {
+		gasFeeCap = msg.GasPrice()
+	}
+	if gasTipCap == nil {
+		gasTipCap = msg.GasPrice()
+	}
 	return &StateTransition{
-		gp:       gp,
-		evm:      evm,
-		msg:      msg,
-		gasPrice: msg.GasPrice(),
-		value:    msg.Value(),
-		data:     msg.Data(),
-		state:    evm.StateDB,
+		gp:        gp,
+		evm:       evm,
+		msg:       msg,
+		gasPrice:  msg.GasPrice(),
+		gasFeeCap: gasFeeCap,
+		gasTipCap: gasTipCap,
+		value:     msg.Value(),
+		data:      msg.Data(),
+		state:     evm.StateDB,
 	}
 }
 
@@ -173,9 +197,25 @@ func (st *StateTransition) to() common.Address {
 	return *st.msg.To()
 }
 
+// payer returns the account paying for the gas of the message, which is the fee
+// payer of sponsored transactions and the sender otherwise.
+func (st *StateTransition) payer() common.Address {
+	if payer := st.msg.FeePayer(); payer != nil {
+		return *payer
+	}
+	return st.msg.From()
+}
+
 func (st *StateTransition) buyGas() error {
 	mgval := new(big.Int).Mul(new(big.Int).SetUint64(st.msg.Gas()), st.gasPrice)
-	if have, want := st.state.GetBalance(st.msg.From()), mgval; have.Cmp(want) < 0 {
+	// The payer must be able to pay the fee cap in full, even if the base fee
+	// only charges part of it.
+	balanceCheck := new(big.Int).Mul(new(big.Int).SetUint64(st.msg.Gas()), st.gasFeeCap)
+	balanceCheck = cmath.BigMax(balanceCheck, mgval)
+	if have, want := st.state.GetBalance(st.payer()), balanceCheck; have.Cmp(want) < 0 {
+		if st.msg.FeePayer() != nil {
+			return fmt.Errorf("%w: address %v have %v want %v", ErrInsufficientFeePayerFunds, st.payer().Hex(), have, want)
+		}
 		return fmt.Errorf("%w: address %v have %v want %v", ErrInsufficientFunds, st.msg.From().Hex(), have, want)
 	}
 	if err := st.gp.SubGas(st.msg.Gas()); err != nil {
@@ -184,7 +224,7 @@ func (st *StateTransition) buyGas() error {
 	st.gas += st.msg.Gas()
 
 	st.initialGas = st.msg.Gas()
-	st.state.SubBalance(st.msg.From(), mgval)
+	st.state.SubBalance(st.payer(), mgval)
 	return nil
 }
 
@@ -200,6 +240,30 @@ func (st *StateTransition) preCheck() error {
 				st.msg.From().Hex(), msgNonce, stNonce)
 		}
 	}
+	// Make sure that transaction gasFeeCap is greater than the baseFee (post london)
+	if st.evm.ChainConfig().IsLondon(st.evm.Context.BlockNumber) {
+		// Skip the checks if gas fields are zero and baseFee was explicitly disabled (eth_call)
+		if !st.evm.Config().NoBaseFee || st.gasFeeCap.BitLen() > 0 || st.gasTipCap.BitLen() > 0 {
+			if l := st.gasFeeCap.BitLen(); l > 256 {
+				return fmt.Errorf("%w: address %v, maxFeePerGas bit length: %d", ErrFeeCapVeryHigh,
+					st.msg.From().Hex(), l)
+			}
+			if l := st.gasTipCap.BitLen(); l > 256 {
+				return fmt.Errorf("%w: address %v, maxPriorityFeePerGas bit length: %d", ErrTipVeryHigh,
+					st.msg.From().Hex(), l)
+			}
+			if st.gasFeeCap.Cmp(st.gasTipCap) < 0 {
+				return fmt.Errorf("%w: address %v, maxPriorityFeePerGas: %s, maxFeePerGas: %s", ErrTipAboveFeeCap,
+					st.msg.From().Hex(), st.gasTipCap, st.gasFeeCap)
+			}
+			// This will panic if baseFee is nil, but basefee presence is verified
+			// as part of header validation.
+			if st.gasFeeCap.Cmp(st.evm.Context.BaseFee) < 0 {
+				return fmt.Errorf("%w: address %v, maxFeePerGas: %s baseFee: %s", ErrFeeCapTooLow,
+					st.msg.From().Hex(), st.gasFeeCap, st.evm.Context.BaseFee)
+			}
+		}
+	}
 	return st.buyGas()
 }
 
@@ -238,7 +302,7 @@ func (st *StateTransition) TransitionDb() (*ExecutionResult, error) {
 	contractCreation := msg.To() == nil
 
 	// Check clauses 4-5, subtract intrinsic gas if everything is correct
-	gas, err := IntrinsicGas(st.data, contractCreation, homestead, istanbul)
+	gas, err := IntrinsicGas(st.data, msg.AccessList(), contractCreation, homestead, istanbul)
 	if err != nil {
 		return nil, err
 	}
@@ -251,6 +315,11 @@ func (st *StateTransition) TransitionDb() (*ExecutionResult, error) {
 	if msg.Value().Sign() > 0 && !st.evm.Context.CanTransfer(st.state, msg.From(), msg.Value()) {
 		return nil, fmt.Errorf("%w: address %v", ErrInsufficientFundsForTransfer, msg.From().Hex())
 	}
+	// Warm up the sender, the recipient, the precompiles and the declared
+	// access list before execution
+	if st.evm.ChainConfig().IsYoloV2(st.evm.Context.BlockNumber) {
+		st.state.PrepareAccessList(msg.From(), msg.To(), st.evm.ActivePrecompiles(), msg.AccessList())
+	}
 	var (
 		ret   []byte
 		vmerr error // vm errors do not effect consensus and are therefore not assigned to err
@@ -263,7 +332,23 @@ func (st *StateTransition) TransitionDb() (*ExecutionResult, error) {
 		ret, st.gas, vmerr = st.evm.Call(sender, st.to(), st.data, st.gas, st.value)
 	}
 	st.refundGas()
-	st.state.AddBalance(st.evm.Context.Coinbase, new(big.Int).Mul(new(big.Int).SetUint64(st.gasUsed()), st.gasPrice))
+
+	// After EIP-1559 the miner only earns the effective tip, while the base fee
+	// is burned or credited to the configured collector. Before London the
+	// coinbase is credited even if the fee is zero, as the touch is part of
+	// the state transition.
+	london := st.evm.ChainConfig().IsLondon(st.evm.Context.BlockNumber)
+
+	effectiveTip := st.gasPrice
+	if london {
+		effectiveTip = cmath.BigMin(st.gasTipCap, new(big.Int).Sub(st.gasFeeCap, st.evm.Context.BaseFee))
+		if collector := st.evm.ChainConfig().BaseFeeCollector; collector != nil && st.gasFeeCap.Cmp(st.evm.Context.BaseFee) >= 0 {
+			st.state.AddBalance(*collector, new(big.Int).Mul(new(big.Int).SetUint64(st.gasUsed()), st.evm.Context.BaseFee))
+		}
+	}
+	if !london || effectiveTip.Sign() > 0 {
+		st.state.AddBalance(st.evm.Context.Coinbase, new(big.Int).Mul(new(big.Int).SetUint64(st.gasUsed()), effectiveTip))
+	}
 
 	return &ExecutionResult{
 		UsedGas:    st.gasUsed(),
@@ -282,7 +367,7 @@ func (st *StateTransition) refundGas() {
 
 	// Return HYK for remaining gas, exchanged at the original rate.
 	remaining := new(big.Int).Mul(new(big.Int).SetUint64(st.gas), st.gasPrice)
-	st.state.AddBalance(st.msg.From(), remaining)
+	st.state.AddBalance(st.payer(), remaining)
 
 	// Also return remaining gas to the block gas counter so it is
 	// available for the next transaction.
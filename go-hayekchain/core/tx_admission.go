@@ -0,0 +1,279 @@
+// Copyright 2021 The go-hayekchain Authors
+// This file is part of the go-hayekchain library.
+//
+// The go-hayekchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hayekchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hayekchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+
+	"github.com/hayekchain/go-hayekchain/common"
+	"github.com/hayekchain/go-hayekchain/common/hexutil"
+	"github.com/hayekchain/go-hayekchain/common/math"
+	"github.com/hayekchain/go-hayekchain/core/types"
+	"github.com/hayekchain/go-hayekchain/log"
+	"github.com/hayekchain/go-hayekchain/metrics"
+)
+
+var (
+	// ErrSenderDenied is returned if the sender of a transaction is not admitted
+	// by the admission policy of the pool.
+	ErrSenderDenied = errors.New("sender denied by admission policy")
+
+	// ErrRecipientDenied is returned if the recipient of a transaction is not
+	// admitted by the admission policy of the pool.
+	ErrRecipientDenied = errors.New("recipient denied by admission policy")
+
+	// ErrMethodDenied is returned if the method selector of a contract call is
+	// not admitted by the admission policy of the pool.
+	ErrMethodDenied = errors.New("method denied by admission policy")
+
+	// ErrRecipientInFlightLimit is returned if the recipient of a transaction
+	// already has as many pooled inbound transactions as the policy permits.
+	ErrRecipientInFlightLimit = errors.New("too many in-flight transactions to recipient")
+
+	// ErrRecipientUnderpriced is returned if a transaction pays less than the
+	// minimum gas price the admission policy sets for its recipient.
+	ErrRecipientUnderpriced = errors.New("transaction underpriced for recipient")
+
+	// errNoAdmissionPolicy is returned if a policy reload is requested without
+	// a policy file being configured.
+	errNoAdmissionPolicy = errors.New("no admission policy configured")
+)
+
+var (
+	admissionDeniedMeter = metrics.NewRegisteredMeter("txpool/admission/denied", nil)
+	admissionPurgeMeter  = metrics.NewRegisteredMeter("txpool/admission/purge", nil) // Dropped due to a policy reload
+)
+
+// MethodSelector is the 4 byte identifier of a contract method, the first four
+// bytes of the call data.
+type MethodSelector [4]byte
+
+// UnmarshalText parses a method selector in hex syntax.
+func (s *MethodSelector) UnmarshalText(input []byte) error {
+	return hexutil.UnmarshalFixedText("MethodSelector", input, s[:])
+}
+
+// MarshalText returns the hex representation of the selector.
+func (s MethodSelector) MarshalText() ([]byte, error) {
+	return hexutil.Bytes(s[:]).MarshalText()
+}
+
+// AdmissionPolicy is a set of rules transactions need to satisfy on top of the
+// consensus and pool limits to be accepted into the pool. Deny rules take
+// precedence over allow rules, and an empty allow list permits everything.
+// Contract creations are only subject to the sender rules.
+type AdmissionPolicy struct {
+	AllowSenders    []common.Address `json:"allowSenders"`    // Only admit transactions from these senders
+	DenySenders     []common.Address `json:"denySenders"`     // Never admit transactions from these senders
+	AllowRecipients []common.Address `json:"allowRecipients"` // Only admit transactions to these recipients
+	DenyRecipients  []common.Address `json:"denyRecipients"`  // Never admit transactions to these recipients
+	AllowMethods    []MethodSelector `json:"allowMethods"`    // Only admit contract calls of these methods
+	DenyMethods     []MethodSelector `json:"denyMethods"`     // Never admit contract calls of these methods
+
+	MaxInFlight map[common.Address]uint64                `json:"maxInFlight"` // Maximum number of pooled transactions per recipient
+	MinGasPrice map[common.Address]*math.HexOrDecimal256 `json:"minGasPrice"` // Minimum effective gas price per recipient, local transactions exempt
+
+	allowSenders    map[common.Address]struct{}
+	denySenders     map[common.Address]struct{}
+	allowRecipients map[common.Address]struct{}
+	denyRecipients  map[common.Address]struct{}
+	allowMethods    map[MethodSelector]struct{}
+	denyMethods     map[MethodSelector]struct{}
+}
+
+// LoadAdmissionPolicy parses a JSON admission policy file.
+func LoadAdmissionPolicy(path string) (*AdmissionPolicy, error) {
+	blob, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	policy := new(AdmissionPolicy)
+	if err := json.Unmarshal(blob, policy); err != nil {
+		return nil, fmt.Errorf("invalid admission policy %s: %v", path, err)
+	}
+	for addr, price := range policy.MinGasPrice {
+		if price == nil || (*big.Int)(price).Sign() < 0 {
+			return nil, fmt.Errorf("invalid admission policy %s: invalid minimum gas price for %x", path, addr)
+		}
+	}
+	return policy, nil
+}
+
+// index builds the lookup sets of the allow and deny lists.
+func (p *AdmissionPolicy) index() {
+	addresses := func(list []common.Address) map[common.Address]struct{} {
+		set := make(map[common.Address]struct{}, len(list))
+		for _, addr := range list {
+			set[addr] = struct{}{}
+		}
+		return set
+	}
+	methods := func(list []MethodSelector) map[MethodSelector]struct{} {
+		set := make(map[MethodSelector]struct{}, len(list))
+		for _, method := range list {
+			set[method] = struct{}{}
+		}
+		return set
+	}
+	p.allowSenders, p.denySenders = addresses(p.AllowSenders), addresses(p.DenySenders)
+	p.allowRecipients, p.denyRecipients = addresses(p.AllowRecipients), addresses(p.DenyRecipients)
+	p.allowMethods, p.denyMethods = methods(p.AllowMethods), methods(p.DenyMethods)
+}
+
+// permits checks the allow and deny rules of the policy against a transaction
+// of the given sender.
+func (p *AdmissionPolicy) permits(tx *types.Transaction, from common.Address) error {
+	if !admitsAddress(p.allowSenders, p.denySenders, from) {
+		return ErrSenderDenied
+	}
+	to := tx.To()
+	if to == nil {
+		return nil
+	}
+	if !admitsAddress(p.allowRecipients, p.denyRecipients, *to) {
+		return ErrRecipientDenied
+	}
+	if data := tx.Data(); len(data) >= len(MethodSelector{}) {
+		var method MethodSelector
+		copy(method[:], data)
+		if _, ok := p.denyMethods[method]; ok {
+			return ErrMethodDenied
+		}
+		if _, ok := p.allowMethods[method]; !ok && len(p.allowMethods) > 0 {
+			return ErrMethodDenied
+		}
+	}
+	return nil
+}
+
+// admitsAddress reports whether an address passes an allow and a deny set, where
+// an empty allow set permits everything not denied.
+func admitsAddress(allow, deny map[common.Address]struct{}, addr common.Address) bool {
+	if _, ok := deny[addr]; ok {
+		return false
+	}
+	if len(allow) == 0 {
+		return true
+	}
+	_, ok := allow[addr]
+	return ok
+}
+
+// validateAdmission checks a transaction against the admission policy of the
+// pool, if any: the allow and deny rules, the minimum gas price and the number
+// of in-flight transactions of its recipient.
+func (pool *TxPool) validateAdmission(tx *types.Transaction, from common.Address, local bool) error {
+	if pool.admission == nil {
+		return nil
+	}
+	if err := pool.admission.permits(tx, from); err != nil {
+		return err
+	}
+	to := tx.To()
+	if to == nil {
+		return nil
+	}
+	if price, ok := pool.admission.MinGasPrice[*to]; ok && !local && effectiveGasPrice(tx, pool.priced.items.baseFee).Cmp((*big.Int)(price)) < 0 {
+		return ErrRecipientUnderpriced
+	}
+	if limit, ok := pool.admission.MaxInFlight[*to]; ok {
+		inflight := pool.all.InFlight(*to)
+		if old := pool.pooledTx(from, tx.Nonce()); old != nil && old.To() != nil && *old.To() == *to {
+			inflight-- // Replacements don't add a new in-flight transaction
+		}
+		if uint64(inflight) >= limit {
+			return ErrRecipientInFlightLimit
+		}
+	}
+	return nil
+}
+
+// effectiveGasPrice returns the gas price a transaction pays at the given base
+// fee, which is its plain gas price if no base fee is set.
+func effectiveGasPrice(tx *types.Transaction, baseFee *big.Int) *big.Int {
+	if baseFee == nil {
+		return tx.GasPrice()
+	}
+	return math.BigMin(tx.GasFeeCap(), new(big.Int).Add(baseFee, tx.GasTipCap()))
+}
+
+// ReloadAdmissionPolicy reloads the admission policy file of the pool and swaps
+// it in, dropping all pooled, private and bundled transactions which the new
+// policy doesn't permit any more. On failure the previous policy stays active.
+func (pool *TxPool) ReloadAdmissionPolicy() error {
+	if pool.config.AdmissionPolicy == "" {
+		return errNoAdmissionPolicy
+	}
+	policy, err := LoadAdmissionPolicy(pool.config.AdmissionPolicy)
+	if err != nil {
+		return err
+	}
+	pool.SetAdmissionPolicy(policy)
+	return nil
+}
+
+// SetAdmissionPolicy replaces the admission policy of the pool, dropping all
+// pooled, private and bundled transactions which the new policy doesn't permit.
+// A nil policy disables admission filtering. The in-flight and price limits
+// only apply to newly arriving transactions.
+func (pool *TxPool) SetAdmissionPolicy(policy *AdmissionPolicy) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	pool.admission = policy
+	if policy == nil {
+		log.Info("Transaction admission policy disabled")
+		return
+	}
+	policy.index()
+
+	var drop []common.Hash
+	pool.all.Range(func(hash common.Hash, tx *types.Transaction) bool {
+		from, _ := types.Sender(pool.signer, tx) // already validated
+		if policy.permits(tx, from) != nil {
+			drop = append(drop, hash)
+		}
+		return true
+	})
+	for _, hash := range drop {
+		pool.removeTx(hash, true)
+	}
+	purged := len(drop)
+	for hash, ptx := range pool.private.txs {
+		if policy.permits(ptx.tx, ptx.from) != nil {
+			pool.private.remove(hash)
+			purged++
+		}
+	}
+	for hash, bundle := range pool.bundles {
+		for _, tx := range bundle.Txs {
+			if from, _ := types.Sender(pool.signer, tx); policy.permits(tx, from) != nil {
+				delete(pool.bundles, hash)
+				purged++
+				break
+			}
+		}
+	}
+	bundleGauge.Update(int64(len(pool.bundles)))
+	admissionPurgeMeter.Mark(int64(purged))
+
+	log.Info("Transaction admission policy updated", "dropped", purged)
+}
@@ -0,0 +1,167 @@
+// Copyright 2021 The go-hayekchain Authors
+// This file is part of the go-hayekchain library.
+//
+// The go-hayekchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hayekchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hayekchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"errors"
+
+	"github.com/hayekchain/go-hayekchain/common"
+	"github.com/hayekchain/go-hayekchain/core/types"
+	"github.com/hayekchain/go-hayekchain/crypto"
+	"github.com/hayekchain/go-hayekchain/log"
+	"github.com/hayekchain/go-hayekchain/metrics"
+)
+
+var (
+	// ErrEmptyBundle is returned if a bundle without transactions is submitted.
+	ErrEmptyBundle = errors.New("empty bundle")
+
+	// ErrBundleExpired is returned if a bundle targets a block which was already
+	// imported by the chain.
+	ErrBundleExpired = errors.New("bundle target block passed")
+
+	// ErrBundleTimestamps is returned if the timestamp range of a bundle is empty.
+	ErrBundleTimestamps = errors.New("bundle max timestamp below min timestamp")
+
+	// ErrBundleLimit is returned if the bundle set of the pool is full.
+	ErrBundleLimit = errors.New("too many bundles")
+)
+
+var bundleGauge = metrics.NewRegisteredGauge("txpool/bundles", nil)
+
+// TxBundle is an ordered list of transactions which are only included in a
+// block together, in the given order, or not at all.
+type TxBundle struct {
+	Txs          types.Transactions // Transactions to include, in order
+	BlockNumber  uint64             // Number of the block the bundle targets
+	MinTimestamp uint64             // Minimum block timestamp to include the bundle at, 0 if unbounded
+	MaxTimestamp uint64             // Maximum block timestamp to include the bundle at, 0 if unbounded
+
+	RevertingTxHashes []common.Hash // Transactions which are allowed to revert
+}
+
+// Hash returns the identifier of the bundle, the hash of its transaction hashes.
+func (b *TxBundle) Hash() common.Hash {
+	hashes := make([]byte, 0, len(b.Txs)*common.HashLength)
+	for _, tx := range b.Txs {
+		hashes = append(hashes, tx.Hash().Bytes()...)
+	}
+	return crypto.Keccak256Hash(hashes)
+}
+
+// CanRevert reports whether the transaction with the given hash may revert
+// without invalidating the bundle.
+func (b *TxBundle) CanRevert(hash common.Hash) bool {
+	for _, allowed := range b.RevertingTxHashes {
+		if allowed == hash {
+			return true
+		}
+	}
+	return false
+}
+
+// includable reports whether the bundle may be included in a block with the
+// given number and timestamp.
+func (b *TxBundle) includable(number uint64, time uint64) bool {
+	if b.BlockNumber != number {
+		return false
+	}
+	if b.MinTimestamp != 0 && time < b.MinTimestamp {
+		return false
+	}
+	if b.MaxTimestamp != 0 && time > b.MaxTimestamp {
+		return false
+	}
+	return true
+}
+
+// AddBundle validates a bundle and inserts it into the pool. Bundles are never
+// broadcast to the network, they are only offered to the local miner when it
+// builds the block they target.
+//
+// Only the stateless validity of the transactions is checked here, whether the
+// bundle executes is decided when simulating it on top of the pending state.
+func (pool *TxPool) AddBundle(bundle *TxBundle) error {
+	if len(bundle.Txs) == 0 {
+		return ErrEmptyBundle
+	}
+	if bundle.MinTimestamp != 0 && bundle.MaxTimestamp != 0 && bundle.MaxTimestamp < bundle.MinTimestamp {
+		return ErrBundleTimestamps
+	}
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	if head := pool.chain.CurrentBlock().NumberU64(); bundle.BlockNumber <= head {
+		return ErrBundleExpired
+	}
+	hash := bundle.Hash()
+	if _, ok := pool.bundles[hash]; ok {
+		knownTxMeter.Mark(1)
+		return ErrAlreadyKnown
+	}
+	if uint64(len(pool.bundles)) >= pool.config.BundleSlots {
+		return ErrBundleLimit
+	}
+	for _, tx := range bundle.Txs {
+		if uint64(tx.Size()) > txMaxSize {
+			return ErrOversizedData
+		}
+		if pool.currentMaxGas < tx.Gas() {
+			return ErrGasLimit
+		}
+		from, err := types.Sender(pool.signer, tx)
+		if err != nil {
+			return ErrInvalidSender
+		}
+		if pool.admission != nil {
+			if err := pool.admission.permits(tx, from); err != nil {
+				admissionDeniedMeter.Mark(1)
+				return err
+			}
+		}
+	}
+	pool.bundles[hash] = bundle
+	bundleGauge.Update(int64(len(pool.bundles)))
+
+	log.Trace("Pooled new bundle", "hash", hash, "txs", len(bundle.Txs), "number", bundle.BlockNumber)
+	return nil
+}
+
+// Bundles retrieves the bundles which may be included in a block with the given
+// number and timestamp.
+func (pool *TxPool) Bundles(number uint64, time uint64) []*TxBundle {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	var bundles []*TxBundle
+	for _, bundle := range pool.bundles {
+		if bundle.includable(number, time) {
+			bundles = append(bundles, bundle)
+		}
+	}
+	return bundles
+}
+
+// dropStaleBundles removes all bundles targeting blocks up to the given head.
+func (pool *TxPool) dropStaleBundles(head uint64) {
+	for hash, bundle := range pool.bundles {
+		if bundle.BlockNumber <= head {
+			delete(pool.bundles, hash)
+		}
+	}
+	bundleGauge.Update(int64(len(pool.bundles)))
+}
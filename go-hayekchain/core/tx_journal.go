@@ -20,6 +20,7 @@ import (
 	"errors"
 	"io"
 	"os"
+	"time"
 
 	"github.com/hayekchain/go-hayekchain/common"
 	"github.com/hayekchain/go-hayekchain/core/types"
@@ -178,3 +179,58 @@ func (journal *txJournal) close() error {
 	}
 	return err
 }
+
+// remoteJournalEntry is a transaction persisted in the remote transaction
+// journal, along with the time it was first seen in unix nanoseconds.
+type remoteJournalEntry struct {
+	Tx   *types.Transaction
+	Time uint64
+}
+
+// loadRemoteJournal parses a remote transaction journal from disk, restoring the
+// arrival times of the transactions. A missing journal yields no transactions.
+func loadRemoteJournal(path string) (types.Transactions, error) {
+	input, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer input.Close()
+
+	var (
+		stream = rlp.NewStream(input, 0)
+		txs    types.Transactions
+	)
+	for {
+		entry := new(remoteJournalEntry)
+		if err := stream.Decode(entry); err != nil {
+			if err == io.EOF {
+				return txs, nil
+			}
+			return txs, err
+		}
+		entry.Tx.SetTime(time.Unix(0, int64(entry.Time)))
+		txs = append(txs, entry.Tx)
+	}
+}
+
+// writeRemoteJournal replaces the remote transaction journal on disk with the
+// given transactions.
+func writeRemoteJournal(path string, txs types.Transactions) error {
+	output, err := os.OpenFile(path+".new", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0755)
+	if err != nil {
+		return err
+	}
+	for _, tx := range txs {
+		if err := rlp.Encode(output, &remoteJournalEntry{Tx: tx, Time: uint64(tx.Time().UnixNano())}); err != nil {
+			output.Close()
+			return err
+		}
+	}
+	if err := output.Close(); err != nil {
+		return err
+	}
+	return os.Rename(path+".new", path)
+}
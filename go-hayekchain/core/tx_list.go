@@ -280,21 +280,30 @@ func (l *txList) Add(tx *types.Transaction, priceBump uint64) (bool, *types.Tran
 	// If there's an older better transaction, abort
 	old := l.txs.Get(tx.Nonce())
 	if old != nil {
-		// threshold = oldGP * (100 + priceBump) / 100
+		// Have to ensure that the new fee cap and tip are higher than the old
+		// ones as well as checking the percentage threshold to ensure that
+		// this is accurate for low (Wei-level) gas price replacements. For
+		// legacy transactions both equal the gas price.
+		if old.GasFeeCapCmp(tx) >= 0 || old.GasTipCapCmp(tx) >= 0 {
+			return false, nil
+		}
+		// thresholdFeeCap = oldFC  * (100 + priceBump) / 100
+		// thresholdTip    = oldTip * (100 + priceBump) / 100
 		a := big.NewInt(100 + int64(priceBump))
-		a = a.Mul(a, old.GasPrice())
+		aFeeCap := new(big.Int).Mul(a, old.GasFeeCap())
+		aTip := a.Mul(a, old.GasTipCap())
+
 		b := big.NewInt(100)
-		threshold := a.Div(a, b)
-		// Have to ensure that the new gas price is higher than the old gas
-		// price as well as checking the percentage threshold to ensure that
-		// this is accurate for low (Wei-level) gas price replacements
-		if old.GasPriceCmp(tx) >= 0 || tx.GasPriceIntCmp(threshold) < 0 {
+		thresholdFeeCap := aFeeCap.Div(aFeeCap, b)
+		thresholdTip := aTip.Div(aTip, b)
+
+		if tx.GasFeeCapIntCmp(thresholdFeeCap) < 0 || tx.GasTipCapIntCmp(thresholdTip) < 0 {
 			return false, nil
 		}
 	}
 	// Otherwise overwrite the old transaction with the current one
 	l.txs.Put(tx)
-	if cost := tx.Cost(); l.costcap.Cmp(cost) < 0 {
+	if cost := tx.SenderCost(); l.costcap.Cmp(cost) < 0 {
 		l.costcap = cost
 	}
 	if gas := tx.Gas(); l.gascap < gas {
@@ -329,27 +338,49 @@ func (l *txList) Filter(costLimit *big.Int, gasLimit uint64) (types.Transactions
 
 	// Filter out all the transactions above the account's funds
 	removed := l.txs.Filter(func(tx *types.Transaction) bool {
-		return tx.Gas() > gasLimit || tx.Cost().Cmp(costLimit) > 0
+		return tx.Gas() > gasLimit || tx.SenderCost().Cmp(costLimit) > 0
 	})
 
 	if len(removed) == 0 {
 		return nil, nil
 	}
-	var invalids types.Transactions
-	// If the list was strict, filter anything above the lowest nonce
-	if l.strict {
-		lowest := uint64(math.MaxUint64)
-		for _, tx := range removed {
-			if nonce := tx.Nonce(); lowest > nonce {
-				lowest = nonce
-			}
-		}
-		invalids = l.txs.filter(func(tx *types.Transaction) bool { return tx.Nonce() > lowest })
+	invalids := l.filterGapped(removed)
+	l.txs.reheap()
+	return removed, invalids
+}
+
+// FilterSponsored removes all sponsored transactions from the list whose fee
+// payer, as reported by balance, can't cover their gas cost any more. Every
+// removed transaction is returned for any post-removal maintenance. Strict-mode
+// invalidated transactions are also returned.
+func (l *txList) FilterSponsored(balance func(common.Address) *big.Int) (types.Transactions, types.Transactions) {
+	removed := l.txs.filter(func(tx *types.Transaction) bool {
+		payer := tx.FeePayer()
+		return payer != nil && balance(*payer).Cmp(tx.GasCost()) < 0
+	})
+	if len(removed) == 0 {
+		return nil, nil
 	}
+	invalids := l.filterGapped(removed)
 	l.txs.reheap()
 	return removed, invalids
 }
 
+// filterGapped removes all transactions above the lowest removed nonce if the
+// list is strict, returning them. The heap needs to be regenerated afterwards.
+func (l *txList) filterGapped(removed types.Transactions) types.Transactions {
+	if !l.strict {
+		return nil
+	}
+	lowest := uint64(math.MaxUint64)
+	for _, tx := range removed {
+		if nonce := tx.Nonce(); lowest > nonce {
+			lowest = nonce
+		}
+	}
+	return l.txs.filter(func(tx *types.Transaction) bool { return tx.Nonce() > lowest })
+}
+
 // Cap places a hard limit on the number of items, returning all transactions
 // exceeding that limit.
 func (l *txList) Cap(threshold int) types.Transactions {
@@ -407,34 +438,56 @@ func (l *txList) LastElement() *types.Transaction {
 }
 
 // priceHeap is a heap.Interface implementation over transactions for retrieving
-// price-sorted transactions to discard when the pool fills up.
-type priceHeap []*types.Transaction
+// price-sorted transactions to discard when the pool fills up. Transactions are
+// sorted by their effective miner tip at the base fee, which is the plain gas
+// price if no base fee is set.
+type priceHeap struct {
+	baseFee *big.Int // heap should always be re-sorted after baseFee is changed
+	list    []*types.Transaction
+}
 
-func (h priceHeap) Len() int      { return len(h) }
-func (h priceHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *priceHeap) Len() int      { return len(h.list) }
+func (h *priceHeap) Swap(i, j int) { h.list[i], h.list[j] = h.list[j], h.list[i] }
 
-func (h priceHeap) Less(i, j int) bool {
-	// Sort primarily by price, returning the cheaper one
-	switch h[i].GasPriceCmp(h[j]) {
+func (h *priceHeap) Less(i, j int) bool {
+	switch h.cmp(h.list[i], h.list[j]) {
 	case -1:
 		return true
 	case 1:
 		return false
+	default:
+		// If the prices match, stabilize via nonces (high nonce is worse)
+		return h.list[i].Nonce() > h.list[j].Nonce()
+	}
+}
+
+// cmp compares the prices of two transactions, primarily by their effective
+// tip and then by their fee caps.
+func (h *priceHeap) cmp(a, b *types.Transaction) int {
+	if h.baseFee != nil {
+		// Compare effective tips if baseFee is specified
+		if c := a.EffectiveGasTipCmp(b, h.baseFee); c != 0 {
+			return c
+		}
+	}
+	// Compare fee caps if baseFee is not specified or effective tips are equal
+	if c := a.GasFeeCapCmp(b); c != 0 {
+		return c
 	}
-	// If the prices match, stabilize via nonces (high nonce is worse)
-	return h[i].Nonce() > h[j].Nonce()
+	// Compare tips if effective tips and fee caps are equal
+	return a.GasTipCapCmp(b)
 }
 
 func (h *priceHeap) Push(x interface{}) {
-	*h = append(*h, x.(*types.Transaction))
+	h.list = append(h.list, x.(*types.Transaction))
 }
 
 func (h *priceHeap) Pop() interface{} {
-	old := *h
+	old := h.list
 	n := len(old)
 	x := old[n-1]
 	old[n-1] = nil
-	*h = old[0 : n-1]
+	h.list = old[0 : n-1]
 	return x
 }
 
@@ -465,27 +518,41 @@ func (l *txPricedList) Put(tx *types.Transaction) {
 func (l *txPricedList) Removed(count int) {
 	// Bump the stale counter, but exit if still too low (< 25%)
 	l.stales += count
-	if l.stales <= len(*l.items)/4 {
+	if l.stales <= len(l.items.list)/4 {
 		return
 	}
 	// Seems we've reached a critical number of stale transactions, reheap
-	reheap := make(priceHeap, 0, l.all.Count())
+	l.Reheap()
+}
 
-	l.stales, l.items = 0, &reheap
+// Reheap forcibly rebuilds the heap based on the current remote transaction set.
+func (l *txPricedList) Reheap() {
+	reheap := &priceHeap{
+		baseFee: l.items.baseFee,
+		list:    make([]*types.Transaction, 0, l.all.Count()),
+	}
+	l.stales, l.items = 0, reheap
 	l.all.Range(func(hash common.Hash, tx *types.Transaction) bool {
-		*l.items = append(*l.items, tx)
+		l.items.list = append(l.items.list, tx)
 		return true
 	})
 	heap.Init(l.items)
 }
 
-// Cap finds all the transactions below the given price threshold, drops them
+// SetBaseFee updates the base fee the transactions are sorted by and triggers
+// a re-heap. A nil base fee sorts the transactions by their plain gas price.
+func (l *txPricedList) SetBaseFee(baseFee *big.Int) {
+	l.items.baseFee = baseFee
+	l.Reheap()
+}
+
+// Cap finds all the transactions below the given tip threshold, drops them
 // from the priced list and returns them for further removal from the entire pool.
 func (l *txPricedList) Cap(threshold *big.Int, local *accountSet) types.Transactions {
 	drop := make(types.Transactions, 0, 128) // Remote underpriced transactions to drop
 	save := make(types.Transactions, 0, 64)  // Local underpriced transactions to keep
 
-	for len(*l.items) > 0 {
+	for len(l.items.list) > 0 {
 		// Discard stale transactions if found during cleanup
 		tx := heap.Pop(l.items).(*types.Transaction)
 		if l.all.Get(tx.Hash()) == nil {
@@ -493,7 +560,7 @@ func (l *txPricedList) Cap(threshold *big.Int, local *accountSet) types.Transact
 			continue
 		}
 		// Stop the discards if we've reached the threshold
-		if tx.GasPriceIntCmp(threshold) >= 0 {
+		if tx.GasTipCapIntCmp(threshold) >= 0 {
 			save = append(save, tx)
 			break
 		}
@@ -518,8 +585,8 @@ func (l *txPricedList) Underpriced(tx *types.Transaction, local *accountSet) boo
 		return false
 	}
 	// Discard stale price points if found at the heap start
-	for len(*l.items) > 0 {
-		head := []*types.Transaction(*l.items)[0]
+	for len(l.items.list) > 0 {
+		head := l.items.list[0]
 		if l.all.Get(head.Hash()) == nil {
 			l.stales--
 			heap.Pop(l.items)
@@ -528,12 +595,12 @@ func (l *txPricedList) Underpriced(tx *types.Transaction, local *accountSet) boo
 		break
 	}
 	// Check if the transaction is underpriced or not
-	if len(*l.items) == 0 {
+	if len(l.items.list) == 0 {
 		log.Error("Pricing query for empty pool") // This cannot happen, print to catch programming errors
 		return false
 	}
-	cheapest := []*types.Transaction(*l.items)[0]
-	return cheapest.GasPriceCmp(tx) >= 0
+	cheapest := l.items.list[0]
+	return l.items.cmp(cheapest, tx) >= 0
 }
 
 // Discard finds a number of most underpriced transactions, removes them from the
@@ -545,7 +612,7 @@ func (l *txPricedList) Discard(slots int, local *accountSet) types.Transactions
 		// little check to avoid unpacking / repacking the heap later on, which
 		// is very expensive
 		discardable := 0
-		for _, tx := range *l.items {
+		for _, tx := range l.items.list {
 			if !local.containsTx(tx) {
 				discardable++
 			}
@@ -560,10 +627,10 @@ func (l *txPricedList) Discard(slots int, local *accountSet) types.Transactions
 	if slots == 0 {
 		return nil
 	}
-	drop := make(types.Transactions, 0, slots)               // Remote underpriced transactions to drop
-	save := make(types.Transactions, 0, len(*l.items)-slots) // Local underpriced transactions to keep
+	drop := make(types.Transactions, 0, slots)                   // Remote underpriced transactions to drop
+	save := make(types.Transactions, 0, len(l.items.list)-slots) // Local underpriced transactions to keep
 
-	for len(*l.items) > 0 && slots > 0 {
+	for len(l.items.list) > 0 && slots > 0 {
 		// Discard stale transactions if found during cleanup
 		tx := heap.Pop(l.items).(*types.Transaction)
 		if l.all.Get(tx.Hash()) == nil {
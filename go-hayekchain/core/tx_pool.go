@@ -26,6 +26,7 @@ import (
 
 	"github.com/hayekchain/go-hayekchain/common"
 	"github.com/hayekchain/go-hayekchain/common/prque"
+	"github.com/hayekchain/go-hayekchain/consensus/misc"
 	"github.com/hayekchain/go-hayekchain/core/state"
 	"github.com/hayekchain/go-hayekchain/core/types"
 	"github.com/hayekchain/go-hayekchain/event"
@@ -59,6 +60,10 @@ var (
 	// ErrInvalidSender is returned if the transaction contains an invalid signature.
 	ErrInvalidSender = errors.New("invalid sender")
 
+	// ErrInvalidFeePayer is returned if a sponsored transaction contains an invalid
+	// fee payer signature.
+	ErrInvalidFeePayer = errors.New("invalid fee payer")
+
 	// ErrUnderpriced is returned if a transaction's gas price is below the minimum
 	// configured for the transaction pool.
 	ErrUnderpriced = errors.New("transaction underpriced")
@@ -139,6 +144,8 @@ type TxPoolConfig struct {
 	Journal   string           // Journal of local transactions to survive node restarts
 	Rejournal time.Duration    // Time interval to regenerate the local transaction journal
 
+	RemoteJournal string // Journal of remote transactions to survive node restarts (empty = disabled)
+
 	PriceLimit uint64 // Minimum gas price to enforce for acceptance into the pool
 	PriceBump  uint64 // Minimum price bump percentage to replace an already existing transaction (nonce)
 
@@ -148,6 +155,13 @@ type TxPoolConfig struct {
 	GlobalQueue  uint64 // Maximum number of non-executable transaction slots for all accounts
 
 	Lifetime time.Duration // Maximum amount of time non-executable transaction are queued
+
+	PrivateSlots    uint64 // Maximum number of transactions in the non-gossiped private lane
+	PrivateLifetime uint64 // Default number of blocks private transactions are offered to the miner
+
+	BundleSlots uint64 // Maximum number of transaction bundles waiting for their target block
+
+	AdmissionPolicy string // Policy file filtering transactions by sender, recipient and method (empty = disabled)
 }
 
 // DefaultTxPoolConfig contains the default configurations for the transaction
@@ -165,6 +179,11 @@ var DefaultTxPoolConfig = TxPoolConfig{
 	GlobalQueue:  1024,
 
 	Lifetime: 3 * time.Hour,
+
+	PrivateSlots:    1024,
+	PrivateLifetime: 25,
+
+	BundleSlots: 256,
 }
 
 // sanitize checks the provided user configurations and changes anything that's
@@ -203,6 +222,18 @@ func (config *TxPoolConfig) sanitize() TxPoolConfig {
 		log.Warn("Sanitizing invalid txpool lifetime", "provided", conf.Lifetime, "updated", DefaultTxPoolConfig.Lifetime)
 		conf.Lifetime = DefaultTxPoolConfig.Lifetime
 	}
+	if conf.PrivateSlots < 1 {
+		log.Warn("Sanitizing invalid txpool private slots", "provided", conf.PrivateSlots, "updated", DefaultTxPoolConfig.PrivateSlots)
+		conf.PrivateSlots = DefaultTxPoolConfig.PrivateSlots
+	}
+	if conf.PrivateLifetime < 1 {
+		log.Warn("Sanitizing invalid txpool private lifetime", "provided", conf.PrivateLifetime, "updated", DefaultTxPoolConfig.PrivateLifetime)
+		conf.PrivateLifetime = DefaultTxPoolConfig.PrivateLifetime
+	}
+	if conf.BundleSlots < 1 {
+		log.Warn("Sanitizing invalid txpool bundle slots", "provided", conf.BundleSlots, "updated", DefaultTxPoolConfig.BundleSlots)
+		conf.BundleSlots = DefaultTxPoolConfig.BundleSlots
+	}
 	return conf
 }
 
@@ -224,6 +255,9 @@ type TxPool struct {
 	mu          sync.RWMutex
 
 	istanbul bool // Fork indicator whether we are in the istanbul stage.
+	eip2718  bool // Fork indicator whether we are using EIP-2718 type transactions.
+	eip1559  bool // Fork indicator whether we are using EIP-1559 type transactions.
+	sponsor  bool // Fork indicator whether we are using sponsored transactions.
 
 	currentState  *state.StateDB // Current state in the blockchain head
 	pendingNonces *txNoncer      // Pending state tracking virtual nonces
@@ -237,6 +271,10 @@ type TxPool struct {
 	beats   map[common.Address]time.Time // Last heartbeat from each known account
 	all     *txLookup                    // All transactions to allow lookups
 	priced  *txPricedList                // All transactions sorted by price
+	private *privateTxSet                // Non-gossiped transactions only offered to the local miner
+	bundles map[common.Hash]*TxBundle    // Atomic transaction bundles waiting for their target block
+
+	admission *AdmissionPolicy // Operator rules transactions need to satisfy, nil if disabled
 
 	chainHeadCh     chan ChainHeadEvent
 	chainHeadSub    event.Subscription
@@ -263,11 +301,13 @@ func NewTxPool(config TxPoolConfig, chainconfig *params.ChainConfig, chain block
 		config:          config,
 		chainconfig:     chainconfig,
 		chain:           chain,
-		signer:          types.NewEIP155Signer(chainconfig.ChainID),
+		signer:          types.LatestSigner(chainconfig),
 		pending:         make(map[common.Address]*txList),
 		queue:           make(map[common.Address]*txList),
 		beats:           make(map[common.Address]time.Time),
 		all:             newTxLookup(),
+		private:         newPrivateTxSet(),
+		bundles:         make(map[common.Hash]*TxBundle),
 		chainHeadCh:     make(chan ChainHeadEvent, chainHeadChanSize),
 		reqResetCh:      make(chan *txpoolResetRequest),
 		reqPromoteCh:    make(chan *accountSet),
@@ -284,6 +324,13 @@ func NewTxPool(config TxPoolConfig, chainconfig *params.ChainConfig, chain block
 	pool.priced = newTxPricedList(pool.all)
 	pool.reset(nil, chain.CurrentBlock().Header())
 
+	// Load the admission policy before accepting any transactions
+	if config.AdmissionPolicy != "" {
+		if err := pool.ReloadAdmissionPolicy(); err != nil {
+			log.Crit("Failed to load transaction admission policy", "err", err)
+		}
+	}
+
 	// Start the reorg loop early so it can handle requests generated during journal loading.
 	pool.wg.Add(1)
 	go pool.scheduleReorgLoop()
@@ -299,6 +346,10 @@ func NewTxPool(config TxPoolConfig, chainconfig *params.ChainConfig, chain block
 			log.Warn("Failed to rotate transaction journal", "err", err)
 		}
 	}
+	// If remote journaling is enabled, reload the remotes revalidating them
+	if pool.config.RemoteJournal != "" {
+		pool.loadRemotes()
+	}
 
 	// Subscribe events from blockchain and start the main event loop.
 	pool.chainHeadSub = pool.chain.SubscribeChainHeadEvent(pool.chainHeadCh)
@@ -372,7 +423,7 @@ func (pool *TxPool) loop() {
 			}
 			pool.mu.Unlock()
 
-		// Handle local transaction journal rotation
+		// Handle local and remote transaction journal rotation
 		case <-journal.C:
 			if pool.journal != nil {
 				pool.mu.Lock()
@@ -381,6 +432,9 @@ func (pool *TxPool) loop() {
 				}
 				pool.mu.Unlock()
 			}
+			if pool.config.RemoteJournal != "" {
+				pool.journalRemotes()
+			}
 		}
 	}
 }
@@ -397,6 +451,9 @@ func (pool *TxPool) Stop() {
 	if pool.journal != nil {
 		pool.journal.close()
 	}
+	if pool.config.RemoteJournal != "" {
+		pool.journalRemotes()
+	}
 	log.Info("Transaction pool stopped")
 }
 
@@ -514,9 +571,85 @@ func (pool *TxPool) local() map[common.Address]types.Transactions {
 	return txs
 }
 
+// remotes retrieves the remote transactions worth persisting across restarts:
+// the executable ones first, then the queued ones, each account's in nonce order,
+// until the global executable slot limit is reached.
+func (pool *TxPool) remotes() types.Transactions {
+	var (
+		txs   types.Transactions
+		slots uint64
+	)
+	for _, lists := range []map[common.Address]*txList{pool.pending, pool.queue} {
+		for addr, list := range lists {
+			if pool.locals.contains(addr) {
+				continue
+			}
+			for _, tx := range list.Flatten() {
+				if slots += uint64(numSlots(tx)); slots > pool.config.GlobalSlots {
+					return txs
+				}
+				txs = append(txs, tx)
+			}
+		}
+	}
+	return txs
+}
+
+// journalRemotes persists the remote transactions of the pool into the remote
+// journal, replacing its previous contents.
+func (pool *TxPool) journalRemotes() {
+	pool.mu.RLock()
+	txs := pool.remotes()
+	pool.mu.RUnlock()
+
+	if err := writeRemoteJournal(pool.config.RemoteJournal, txs); err != nil {
+		log.Warn("Failed to write remote transaction journal", "err", err)
+		return
+	}
+	log.Debug("Regenerated remote transaction journal", "transactions", len(txs))
+}
+
+// loadRemotes injects the transactions of the remote journal into the pool.
+// They are revalidated against the current head like any other remote, keeping
+// the arrival times they had before the restart.
+func (pool *TxPool) loadRemotes() {
+	txs, err := loadRemoteJournal(pool.config.RemoteJournal)
+	if err != nil {
+		log.Warn("Failed to load remote transaction journal", "err", err)
+	}
+	total, dropped := len(txs), 0
+	for len(txs) > 0 {
+		batch := txs
+		if len(batch) > 1024 {
+			batch = batch[:1024]
+		}
+		txs = txs[len(batch):]
+
+		for _, err := range pool.AddRemotesSync(batch) {
+			if err != nil {
+				log.Debug("Failed to add journaled remote transaction", "err", err)
+				dropped++
+			}
+		}
+	}
+	log.Info("Loaded remote transaction journal", "transactions", total, "dropped", dropped)
+}
+
 // validateTx checks whether a transaction is valid according to the consensus
 // rules and adheres to some heuristic limits of the local node (price and size).
 func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
+	// Accept only legacy transactions until EIP-2718/2930 activates.
+	if !pool.eip2718 && tx.Type() != types.LegacyTxType {
+		return ErrTxTypeNotSupported
+	}
+	// Reject dynamic fee transactions until EIP-1559 activates.
+	if !pool.eip1559 && tx.Type() == types.DynamicFeeTxType {
+		return ErrTxTypeNotSupported
+	}
+	// Reject sponsored transactions until the sponsor fork activates.
+	if !pool.sponsor && tx.Type() == types.SponsoredTxType {
+		return ErrTxTypeNotSupported
+	}
 	// Reject transactions over defined size to prevent DOS attacks
 	if uint64(tx.Size()) > txMaxSize {
 		return ErrOversizedData
@@ -530,27 +663,62 @@ func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
 	if pool.currentMaxGas < tx.Gas() {
 		return ErrGasLimit
 	}
+	// Sanity check for extremely large numbers
+	if tx.GasFeeCap().BitLen() > 256 {
+		return ErrFeeCapVeryHigh
+	}
+	if tx.GasTipCap().BitLen() > 256 {
+		return ErrTipVeryHigh
+	}
+	// Ensure gasFeeCap is greater than or equal to gasTipCap.
+	if tx.GasFeeCapIntCmp(tx.GasTipCap()) < 0 {
+		return ErrTipAboveFeeCap
+	}
 	// Make sure the transaction is signed properly
 	from, err := types.Sender(pool.signer, tx)
 	if err != nil {
 		return ErrInvalidSender
 	}
-	// Drop non-local transactions under our own minimal accepted gas price
+	// Drop non-local transactions under our own minimal accepted gas price or tip
 	local = local || pool.locals.contains(from) // account may be local even if the transaction arrived from the network
-	if !local && tx.GasPriceIntCmp(pool.gasPrice) < 0 {
+	if !local && tx.GasTipCapIntCmp(pool.gasPrice) < 0 {
 		return ErrUnderpriced
 	}
+	// Enforce the admission policy of the node operator, if any
+	if err := pool.validateAdmission(tx, from, local); err != nil {
+		admissionDeniedMeter.Mark(1)
+		return err
+	}
 	// Ensure the transaction adheres to nonce ordering
 	if pool.currentState.GetNonce(from) > tx.Nonce() {
 		return ErrNonceTooLow
 	}
 	// Transactor should have enough funds to cover the costs
-	// cost == V + GP * GL
-	if pool.currentState.GetBalance(from).Cmp(tx.Cost()) < 0 {
+	// cost == V + GP * GL, with the fee cap standing in for the gas price and
+	// the gas of sponsored transactions paid by the fee payer instead
+	if pool.currentState.GetBalance(from).Cmp(tx.SenderCost()) < 0 {
 		return ErrInsufficientFunds
 	}
+	// Fee payers should have signed and have enough funds to cover the gas of
+	// all their pooled sponsored transactions
+	if tx.Type() == types.SponsoredTxType {
+		payer, err := types.FeePayer(pool.signer, tx)
+		if err != nil {
+			return ErrInvalidFeePayer
+		}
+		cost := new(big.Int).Add(pool.all.Sponsored(payer), tx.GasCost())
+		if payer == from {
+			cost.Add(cost, tx.Value())
+		}
+		if old := pool.pooledTx(from, tx.Nonce()); old != nil && old.FeePayer() != nil && *old.FeePayer() == payer {
+			cost.Sub(cost, old.GasCost()) // Replacements free up the old sponsorship
+		}
+		if pool.currentState.GetBalance(payer).Cmp(cost) < 0 {
+			return ErrInsufficientFeePayerFunds
+		}
+	}
 	// Ensure the transaction has more gas than the basic tx fee.
-	intrGas, err := IntrinsicGas(tx.Data(), tx.To() == nil, true, pool.istanbul)
+	intrGas, err := IntrinsicGas(tx.Data(), tx.AccessList(), tx.To() == nil, true, pool.istanbul)
 	if err != nil {
 		return err
 	}
@@ -560,6 +728,20 @@ func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
 	return nil
 }
 
+// pooledTx returns the pending or queued transaction of an account with the
+// given nonce, if any.
+func (pool *TxPool) pooledTx(addr common.Address, nonce uint64) *types.Transaction {
+	if list := pool.pending[addr]; list != nil {
+		if tx := list.txs.Get(nonce); tx != nil {
+			return tx
+		}
+	}
+	if list := pool.queue[addr]; list != nil {
+		return list.txs.Get(nonce)
+	}
+	return nil
+}
+
 // add validates a transaction and inserts it into the non-executable queue for later
 // pending promotion and execution. If the transaction is a replacement for an already
 // pending or queued one, it overwrites the previous transaction if its price is higher.
@@ -1172,6 +1354,10 @@ func (pool *TxPool) reset(oldHead, newHead *types.Header) {
 	pool.pendingNonces = newTxNoncer(statedb)
 	pool.currentMaxGas = newHead.GasLimit
 
+	// Drop the private transactions and bundles which were included or expired
+	pool.private.reset(newHead.Number.Uint64(), statedb)
+	pool.dropStaleBundles(newHead.Number.Uint64())
+
 	// Inject any transactions discarded due to reorgs
 	log.Debug("Reinjecting stale transactions", "count", len(reinject))
 	senderCacher.recover(pool.signer, reinject)
@@ -1180,6 +1366,14 @@ func (pool *TxPool) reset(oldHead, newHead *types.Header) {
 	// Update all fork indicator by next pending block number.
 	next := new(big.Int).Add(newHead.Number, big.NewInt(1))
 	pool.istanbul = pool.chainconfig.IsIstanbul(next)
+	pool.eip2718 = pool.chainconfig.IsYoloV2(next) || pool.chainconfig.IsLondon(next)
+	pool.eip1559 = pool.chainconfig.IsLondon(next)
+	pool.sponsor = pool.chainconfig.IsSponsor(next)
+
+	// Sort the pool by the effective tips at the upcoming base fee
+	if pool.eip1559 {
+		pool.priced.SetBaseFee(misc.CalcBaseFee(pool.chainconfig, newHead))
+	}
 }
 
 // promoteExecutables moves transactions that have become processable from the
@@ -1204,6 +1398,8 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) []*types.Trans
 		log.Trace("Removed old queued transactions", "count", len(forwards))
 		// Drop all transactions that are too costly (low balance or out of gas)
 		drops, _ := list.Filter(pool.currentState.GetBalance(addr), pool.currentMaxGas)
+		unsponsored, _ := list.FilterSponsored(pool.currentState.GetBalance)
+		drops = append(drops, unsponsored...)
 		for _, tx := range drops {
 			hash := tx.Hash()
 			pool.all.Remove(hash)
@@ -1397,6 +1593,10 @@ func (pool *TxPool) demoteUnexecutables() {
 		}
 		// Drop all transactions that are too costly (low balance or out of gas), and queue any invalids back for later
 		drops, invalids := list.Filter(pool.currentState.GetBalance(addr), pool.currentMaxGas)
+
+		// Drop all sponsored transactions whose fee payer can't pay any more
+		unsponsored, unsponsoredInvalids := list.FilterSponsored(pool.currentState.GetBalance)
+		drops, invalids = append(drops, unsponsored...), append(invalids, unsponsoredInvalids...)
 		for _, tx := range drops {
 			hash := tx.Hash()
 			log.Trace("Removed unpayable pending transaction", "hash", hash)
@@ -1529,18 +1729,43 @@ func (as *accountSet) merge(other *accountSet) {
 // peeking into the pool in TxPool.Get without having to acquire the widely scoped
 // TxPool.mu mutex.
 type txLookup struct {
-	all   map[common.Hash]*types.Transaction
-	slots int
-	lock  sync.RWMutex
+	all        map[common.Hash]*types.Transaction
+	slots      int
+	sponsored  map[common.Address]*big.Int // Gas cost of the pooled transactions of each fee payer
+	recipients map[common.Address]int      // Number of pooled transactions to each recipient
+	lock       sync.RWMutex
 }
 
 // newTxLookup returns a new txLookup structure.
 func newTxLookup() *txLookup {
 	return &txLookup{
-		all: make(map[common.Hash]*types.Transaction),
+		all:        make(map[common.Hash]*types.Transaction),
+		sponsored:  make(map[common.Address]*big.Int),
+		recipients: make(map[common.Address]int),
 	}
 }
 
+// Sponsored returns the total gas cost of the transactions in the lookup which
+// are sponsored by the given fee payer.
+func (t *txLookup) Sponsored(payer common.Address) *big.Int {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	if cost, ok := t.sponsored[payer]; ok {
+		return new(big.Int).Set(cost)
+	}
+	return new(big.Int)
+}
+
+// InFlight returns the number of transactions in the lookup which are sent to
+// the given recipient.
+func (t *txLookup) InFlight(to common.Address) int {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	return t.recipients[to]
+}
+
 // Range calls f on each key and value present in the map.
 func (t *txLookup) Range(f func(hash common.Hash, tx *types.Transaction) bool) {
 	t.lock.RLock()
@@ -1585,6 +1810,17 @@ func (t *txLookup) Add(tx *types.Transaction) {
 	t.slots += numSlots(tx)
 	slotsGauge.Update(int64(t.slots))
 
+	if payer := tx.FeePayer(); payer != nil {
+		cost, ok := t.sponsored[*payer]
+		if !ok {
+			cost = new(big.Int)
+			t.sponsored[*payer] = cost
+		}
+		cost.Add(cost, tx.GasCost())
+	}
+	if to := tx.To(); to != nil {
+		t.recipients[*to]++
+	}
 	t.all[tx.Hash()] = tx
 }
 
@@ -1593,9 +1829,20 @@ func (t *txLookup) Remove(hash common.Hash) {
 	t.lock.Lock()
 	defer t.lock.Unlock()
 
-	t.slots -= numSlots(t.all[hash])
+	tx := t.all[hash]
+	t.slots -= numSlots(tx)
 	slotsGauge.Update(int64(t.slots))
 
+	if payer := tx.FeePayer(); payer != nil {
+		if cost := t.sponsored[*payer].Sub(t.sponsored[*payer], tx.GasCost()); cost.Sign() <= 0 {
+			delete(t.sponsored, *payer)
+		}
+	}
+	if to := tx.To(); to != nil {
+		if t.recipients[*to]--; t.recipients[*to] <= 0 {
+			delete(t.recipients, *to)
+		}
+	}
 	delete(t.all, hash)
 }
 
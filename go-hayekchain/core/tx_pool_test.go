@@ -18,16 +18,19 @@ package core
 
 import (
 	"crypto/ecdsa"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"math/big"
 	"math/rand"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
 	"github.com/hayekchain/go-hayekchain/common"
+	"github.com/hayekchain/go-hayekchain/common/math"
 	"github.com/hayekchain/go-hayekchain/core/rawdb"
 	"github.com/hayekchain/go-hayekchain/core/state"
 	"github.com/hayekchain/go-hayekchain/core/types"
@@ -79,6 +82,19 @@ func pricedTransaction(nonce uint64, gaslimit uint64, gasprice *big.Int, key *ec
 	return tx
 }
 
+func dynamicFeeTransaction(nonce uint64, gaslimit uint64, gasFeeCap *big.Int, gasTipCap *big.Int, key *ecdsa.PrivateKey) *types.Transaction {
+	tx, _ := types.SignNewTx(key, types.LatestSignerForChainID(params.TestChainConfig.ChainID), &types.DynamicFeeTx{
+		ChainID:   params.TestChainConfig.ChainID,
+		Nonce:     nonce,
+		GasTipCap: gasTipCap,
+		GasFeeCap: gasFeeCap,
+		Gas:       gaslimit,
+		To:        &common.Address{},
+		Value:     big.NewInt(100),
+	})
+	return tx
+}
+
 func pricedDataTransaction(nonce uint64, gaslimit uint64, gasprice *big.Int, key *ecdsa.PrivateKey, bytes uint64) *types.Transaction {
 	data := make([]byte, bytes)
 	rand.Read(data)
@@ -269,6 +285,50 @@ func TestInvalidTransactions(t *testing.T) {
 	}
 }
 
+// Tests that typed transactions are only admitted into the pool once the fork
+// enabling them has been reached.
+func TestTypedTransactions(t *testing.T) {
+	t.Parallel()
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	blockchain := &testBlockChain{statedb, 10000000, new(event.Feed)}
+	key, _ := crypto.GenerateKey()
+
+	config := *params.TestChainConfig
+	signer := types.NewEIP2930Signer(config.ChainID)
+	tx, err := types.SignNewTx(key, signer, &types.AccessListTx{
+		ChainID:    config.ChainID,
+		Nonce:      0,
+		GasPrice:   big.NewInt(1),
+		Gas:        100000,
+		To:         &common.Address{0x01},
+		Value:      big.NewInt(0),
+		AccessList: types.AccessList{{Address: common.Address{0x02}, StorageKeys: []common.Hash{{}}}},
+	})
+	if err != nil {
+		t.Fatalf("failed to sign transaction: %v", err)
+	}
+	from, _ := types.Sender(signer, tx)
+	statedb.AddBalance(from, big.NewInt(1000000))
+
+	pool := NewTxPool(testTxPoolConfig, &config, blockchain)
+	if err := pool.addRemoteSync(tx); err == nil {
+		t.Errorf("pre-fork typed transaction accepted")
+	}
+	pool.Stop()
+
+	config.YoloV2Block = big.NewInt(0)
+	pool = NewTxPool(testTxPoolConfig, &config, blockchain)
+	defer pool.Stop()
+
+	if err := pool.addRemoteSync(tx); err != nil {
+		t.Fatalf("post-fork typed transaction rejected: %v", err)
+	}
+	if pending, _ := pool.Stats(); pending != 1 {
+		t.Fatalf("pending transactions mismatch: have %d, want %d", pending, 1)
+	}
+}
+
 func TestTransactionQueue(t *testing.T) {
 	t.Parallel()
 
@@ -1849,6 +1909,87 @@ func testTransactionJournaling(t *testing.T, nolocals bool) {
 	pool.Stop()
 }
 
+// Tests that remote transactions are persisted across pool restarts when the
+// remote journal is enabled, keeping their arrival times and being revalidated
+// against the head when loaded back.
+func TestTransactionJournalingRemotes(t *testing.T) {
+	t.Parallel()
+
+	// Create a temporary folder for the journal, the pool creates the file itself
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("failed to create temporary journal folder: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Create the original pool to inject transaction into the journal
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	blockchain := &testBlockChain{statedb, 1000000, new(event.Feed)}
+
+	config := testTxPoolConfig
+	config.RemoteJournal = filepath.Join(dir, "remotes.rlp")
+	config.GlobalSlots = 3
+
+	pool := NewTxPool(config, params.TestChainConfig, blockchain)
+
+	local, _ := crypto.GenerateKey()
+	remote, _ := crypto.GenerateKey()
+
+	pool.currentState.AddBalance(crypto.PubkeyToAddress(local.PublicKey), big.NewInt(1000000000))
+	pool.currentState.AddBalance(crypto.PubkeyToAddress(remote.PublicKey), big.NewInt(1000000000))
+
+	// Add a local, three executable and a queued remote transactions
+	if err := pool.AddLocal(pricedTransaction(0, 100000, big.NewInt(1), local)); err != nil {
+		t.Fatalf("failed to add local transaction: %v", err)
+	}
+	for _, nonce := range []uint64{0, 1, 2, 4} {
+		if err := pool.addRemoteSync(pricedTransaction(nonce, 100000, big.NewInt(1), remote)); err != nil {
+			t.Fatalf("failed to add remote transaction %d: %v", nonce, err)
+		}
+	}
+	pending, queued := pool.Stats()
+	if pending != 4 {
+		t.Fatalf("pending transactions mismatched: have %d, want %d", pending, 4)
+	}
+	if queued != 1 {
+		t.Fatalf("queued transactions mismatched: have %d, want %d", queued, 1)
+	}
+	times := make(map[common.Hash]time.Time)
+	txs, _ := pool.Pending()
+	for _, tx := range txs[crypto.PubkeyToAddress(remote.PublicKey)] {
+		times[tx.Hash()] = tx.Time()
+	}
+	// Terminate the old pool, bump the remote nonce, create a new pool and ensure
+	// only the still valid remotes survive. The local isn't journaled at all and
+	// the queued remote doesn't fit into the global slots.
+	pool.Stop()
+	statedb.SetNonce(crypto.PubkeyToAddress(remote.PublicKey), 1)
+	blockchain = &testBlockChain{statedb, 1000000, new(event.Feed)}
+
+	pool = NewTxPool(config, params.TestChainConfig, blockchain)
+	defer pool.Stop()
+
+	pending, queued = pool.Stats()
+	if pending != 2 {
+		t.Fatalf("pending transactions mismatched: have %d, want %d", pending, 2)
+	}
+	if queued != 0 {
+		t.Fatalf("queued transactions mismatched: have %d, want %d", queued, 0)
+	}
+	if pool.locals.contains(crypto.PubkeyToAddress(remote.PublicKey)) {
+		t.Fatalf("journaled remote transactions loaded as local")
+	}
+	txs, _ = pool.Pending()
+	for _, tx := range txs[crypto.PubkeyToAddress(remote.PublicKey)] {
+		if have, want := tx.Time(), times[tx.Hash()]; !have.Equal(want) {
+			t.Errorf("transaction %x arrival time mismatch: have %v, want %v", tx.Hash(), have, want)
+		}
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
 // TestTransactionStatusCheck tests that the pool can correctly retrieve the
 // pending status of individual transactions.
 func TestTransactionStatusCheck(t *testing.T) {
@@ -2007,3 +2148,443 @@ func benchmarkPoolBatchInsert(b *testing.B, size int, local bool) {
 		}
 	}
 }
+
+// Tests that dynamic fee transactions are only accepted after the London fork,
+// that their fee fields are sanity checked and that replacing them requires
+// bumping both the fee cap and the tip.
+func TestTransactionPoolDynamicFee(t *testing.T) {
+	t.Parallel()
+
+	// Dynamic fee transactions are rejected before the fork
+	pool, key := setupTxPool()
+	pool.currentState.AddBalance(crypto.PubkeyToAddress(key.PublicKey), big.NewInt(1000000000))
+	if err := pool.addRemoteSync(dynamicFeeTransaction(0, 100000, big.NewInt(10), big.NewInt(1), key)); err == nil {
+		t.Fatalf("pre-london dynamic fee transaction accepted")
+	}
+	pool.Stop()
+
+	// Activate the fork at the next block
+	config := *params.TestChainConfig
+	config.YoloV2Block = big.NewInt(0)
+	config.LondonBlock = big.NewInt(1)
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	blockchain := &testBlockChain{statedb, 1000000, new(event.Feed)}
+
+	pool = NewTxPool(testTxPoolConfig, &config, blockchain)
+	defer pool.Stop()
+
+	pool.currentState.AddBalance(crypto.PubkeyToAddress(key.PublicKey), big.NewInt(1000000000))
+
+	if err := pool.addRemoteSync(dynamicFeeTransaction(0, 100000, big.NewInt(1), big.NewInt(2), key)); err != ErrTipAboveFeeCap {
+		t.Fatalf("tip above fee cap error mismatch: have %v, want %v", err, ErrTipAboveFeeCap)
+	}
+	if err := pool.addRemoteSync(dynamicFeeTransaction(0, 100000, big.NewInt(10), big.NewInt(0), key)); err != ErrUnderpriced {
+		t.Fatalf("zero tip error mismatch: have %v, want %v", err, ErrUnderpriced)
+	}
+	if err := pool.addRemoteSync(dynamicFeeTransaction(0, 100000, big.NewInt(100), big.NewInt(10), key)); err != nil {
+		t.Fatalf("failed to add dynamic fee transaction: %v", err)
+	}
+	// Bumping only one of the fee cap and the tip is not enough for a replacement
+	if err := pool.addRemoteSync(dynamicFeeTransaction(0, 100000, big.NewInt(110), big.NewInt(10), key)); err != ErrReplaceUnderpriced {
+		t.Fatalf("fee cap only replacement error mismatch: have %v, want %v", err, ErrReplaceUnderpriced)
+	}
+	if err := pool.addRemoteSync(dynamicFeeTransaction(0, 100000, big.NewInt(100), big.NewInt(11), key)); err != ErrReplaceUnderpriced {
+		t.Fatalf("tip only replacement error mismatch: have %v, want %v", err, ErrReplaceUnderpriced)
+	}
+	if err := pool.addRemoteSync(dynamicFeeTransaction(0, 100000, big.NewInt(110), big.NewInt(11), key)); err != nil {
+		t.Fatalf("failed to replace dynamic fee transaction: %v", err)
+	}
+	if pending, _ := pool.Stats(); pending != 1 {
+		t.Fatalf("pending transactions mismatched: have %d, want %d", pending, 1)
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
+func sponsoredTransaction(nonce uint64, gaslimit uint64, gasFeeCap *big.Int, key *ecdsa.PrivateKey, payerKey *ecdsa.PrivateKey) *types.Transaction {
+	signer := types.NewSponsorSigner(params.TestChainConfig.ChainID)
+	tx, _ := types.SignNewTx(key, signer, &types.SponsoredTx{
+		ChainID:   params.TestChainConfig.ChainID,
+		Nonce:     nonce,
+		GasTipCap: big.NewInt(1),
+		GasFeeCap: gasFeeCap,
+		Gas:       gaslimit,
+		To:        &common.Address{},
+		Value:     big.NewInt(100),
+		FeePayer:  crypto.PubkeyToAddress(payerKey.PublicKey),
+	})
+	tx, _ = types.SignFeePayerTx(tx, signer, payerKey)
+	return tx
+}
+
+// Tests that sponsored transactions are only accepted after the sponsor fork,
+// and that the fee payer balance has to cover all the transactions it sponsors.
+func TestTransactionPoolSponsored(t *testing.T) {
+	t.Parallel()
+
+	payerKey, _ := crypto.GenerateKey()
+	payer := crypto.PubkeyToAddress(payerKey.PublicKey)
+
+	// Sponsored transactions are rejected before the fork
+	pool, key := setupTxPool()
+	pool.currentState.AddBalance(crypto.PubkeyToAddress(key.PublicKey), big.NewInt(100))
+	pool.currentState.AddBalance(payer, big.NewInt(1000000))
+	if err := pool.addRemoteSync(sponsoredTransaction(0, 100000, big.NewInt(10), key, payerKey)); err == nil {
+		t.Fatalf("pre-fork sponsored transaction accepted")
+	}
+	pool.Stop()
+
+	// Activate the fork at the next block
+	config := *params.TestChainConfig
+	config.YoloV2Block = big.NewInt(0)
+	config.LondonBlock = big.NewInt(1)
+	config.SponsorBlock = big.NewInt(1)
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	blockchain := &testBlockChain{statedb, 1000000, new(event.Feed)}
+
+	pool = NewTxPool(testTxPoolConfig, &config, blockchain)
+	defer pool.Stop()
+
+	// The senders can only afford the value, the payer can afford two transactions
+	keys := make([]*ecdsa.PrivateKey, 3)
+	for i := range keys {
+		keys[i], _ = crypto.GenerateKey()
+		pool.currentState.AddBalance(crypto.PubkeyToAddress(keys[i].PublicKey), big.NewInt(100))
+	}
+	pool.currentState.AddBalance(payer, big.NewInt(2000000))
+
+	// Transactions signed by a different fee payer are rejected
+	forged := sponsoredTransaction(0, 100000, big.NewInt(10), keys[0], keys[1])
+	forged, _ = types.SignFeePayerTx(forged, types.NewSponsorSigner(params.TestChainConfig.ChainID), keys[2])
+	if err := pool.addRemoteSync(forged); err != ErrInvalidFeePayer {
+		t.Fatalf("forged fee payer error mismatch: have %v, want %v", err, ErrInvalidFeePayer)
+	}
+	if err := pool.addRemoteSync(sponsoredTransaction(0, 100000, big.NewInt(10), keys[0], payerKey)); err != nil {
+		t.Fatalf("failed to add sponsored transaction: %v", err)
+	}
+	if err := pool.addRemoteSync(sponsoredTransaction(0, 100000, big.NewInt(10), keys[1], payerKey)); err != nil {
+		t.Fatalf("failed to add sponsored transaction: %v", err)
+	}
+	if err := pool.addRemoteSync(sponsoredTransaction(0, 100000, big.NewInt(10), keys[2], payerKey)); err != ErrInsufficientFeePayerFunds {
+		t.Fatalf("overdrawn fee payer error mismatch: have %v, want %v", err, ErrInsufficientFeePayerFunds)
+	}
+	// Replacements only need to cover the difference to the old sponsorship
+	if err := pool.addRemoteSync(sponsoredTransaction(0, 90000, big.NewInt(11), keys[1], payerKey)); err != ErrReplaceUnderpriced {
+		t.Fatalf("replacement error mismatch: have %v, want %v", err, ErrReplaceUnderpriced)
+	}
+	if err := pool.addRemoteSync(sponsoredTransaction(0, 90000, big.NewInt(20), keys[1], payerKey)); err != ErrInsufficientFeePayerFunds {
+		t.Fatalf("overdrawn replacement error mismatch: have %v, want %v", err, ErrInsufficientFeePayerFunds)
+	}
+	if pending, _ := pool.Stats(); pending != 2 {
+		t.Fatalf("pending transactions mismatched: have %d, want %d", pending, 2)
+	}
+	// Draining the fee payer should drop the transactions it can't pay for
+	pool.currentState.SubBalance(payer, big.NewInt(1500000))
+	<-pool.requestReset(nil, nil)
+
+	if pending, queued := pool.Stats(); pending+queued != 0 {
+		t.Fatalf("pooled transactions mismatched: have %d, want %d", pending+queued, 0)
+	}
+	if cost := pool.all.Sponsored(payer); cost.Sign() != 0 {
+		t.Fatalf("sponsored cost mismatch: have %v, want 0", cost)
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
+// Tests that private transactions are kept out of the pool content and the new
+// transaction events, and are dropped after inclusion or their deadline.
+func TestPrivateTransactions(t *testing.T) {
+	t.Parallel()
+
+	pool, key := setupTxPool()
+	defer pool.Stop()
+
+	events := make(chan NewTxsEvent, 32)
+	sub := pool.SubscribeNewTxsEvent(events)
+	defer sub.Unsubscribe()
+
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	pool.currentState.AddBalance(from, big.NewInt(1000000))
+
+	tx0, tx1 := transaction(0, 100000, key), transaction(1, 100000, key)
+	if err := pool.AddPrivate(tx0, 0); err != nil {
+		t.Fatalf("failed to add private transaction: %v", err)
+	}
+	if err := pool.AddPrivate(tx1, 5); err != nil {
+		t.Fatalf("failed to add private transaction: %v", err)
+	}
+	if err := pool.AddPrivate(tx0, 0); err != ErrAlreadyKnown {
+		t.Fatalf("duplicate error mismatch: have %v, want %v", err, ErrAlreadyKnown)
+	}
+	if err := pool.AddPrivate(transaction(2, 100000, key), 0); err != nil {
+		t.Fatalf("failed to add private transaction: %v", err)
+	}
+	if !pool.RemovePrivate(transaction(2, 100000, key).Hash()) {
+		t.Fatalf("failed to remove private transaction")
+	}
+	// Private transactions are priced like remote ones, unless from local accounts
+	if err := pool.AddPrivate(pricedTransaction(2, 100000, big.NewInt(0), key), 0); err != ErrUnderpriced {
+		t.Fatalf("underpriced error mismatch: have %v, want %v", err, ErrUnderpriced)
+	}
+	pool.mu.Lock()
+	pool.locals.add(from)
+	pool.mu.Unlock()
+
+	if err := pool.AddPrivate(pricedTransaction(2, 100000, big.NewInt(0), key), 0); err != nil {
+		t.Fatalf("failed to add underpriced local private transaction: %v", err)
+	}
+	if !pool.RemovePrivate(pricedTransaction(2, 100000, big.NewInt(0), key).Hash()) {
+		t.Fatalf("failed to remove private transaction")
+	}
+	// Private transactions should only be visible to the miner
+	if pending, queued := pool.Stats(); pending+queued != 0 {
+		t.Fatalf("pooled transactions mismatched: have %d, want %d", pending+queued, 0)
+	}
+	if pending, queued := pool.Content(); len(pending)+len(queued) != 0 {
+		t.Fatalf("private transactions leaked into the pool content")
+	}
+	if pool.Get(tx0.Hash()) != nil {
+		t.Fatalf("private transaction retrievable from the pool")
+	}
+	if err := validateEvents(events, 0); err != nil {
+		t.Fatalf("private transaction event firing failed: %v", err)
+	}
+	if txs := pool.Private()[from]; len(txs) != 2 || txs[0] != tx0 || txs[1] != tx1 {
+		t.Fatalf("private transactions mismatch: have %v, want %v", txs, types.Transactions{tx0, tx1})
+	}
+	// Including the first transaction should drop it from the private lane
+	pool.currentState.SetNonce(from, 1)
+	<-pool.requestReset(nil, nil)
+
+	if txs := pool.Private()[from]; len(txs) != 1 || txs[0] != tx1 {
+		t.Fatalf("private transactions mismatch after inclusion: have %v, want %v", txs, types.Transactions{tx1})
+	}
+	// Reaching the deadline should drop the rest
+	pool.mu.Lock()
+	pool.private.reset(5, pool.currentState)
+	pool.mu.Unlock()
+
+	if txs := pool.Private(); len(txs) != 0 {
+		t.Fatalf("expired private transactions retained: %v", txs)
+	}
+}
+
+// Tests that bundles are validated on submission and only handed out for the
+// block and time they target.
+func TestTransactionBundles(t *testing.T) {
+	t.Parallel()
+
+	pool, key := setupTxPool()
+	defer pool.Stop()
+
+	txs := types.Transactions{transaction(0, 100000, key), transaction(1, 100000, key)}
+	if err := pool.AddBundle(&TxBundle{BlockNumber: 1}); err != ErrEmptyBundle {
+		t.Fatalf("empty bundle error mismatch: have %v, want %v", err, ErrEmptyBundle)
+	}
+	if err := pool.AddBundle(&TxBundle{Txs: txs, BlockNumber: 0}); err != ErrBundleExpired {
+		t.Fatalf("expired bundle error mismatch: have %v, want %v", err, ErrBundleExpired)
+	}
+	if err := pool.AddBundle(&TxBundle{Txs: txs, BlockNumber: 1, MinTimestamp: 20, MaxTimestamp: 10}); err != ErrBundleTimestamps {
+		t.Fatalf("timestamp range error mismatch: have %v, want %v", err, ErrBundleTimestamps)
+	}
+	if err := pool.AddBundle(&TxBundle{Txs: types.Transactions{transaction(0, 100000000, key)}, BlockNumber: 1}); err != ErrGasLimit {
+		t.Fatalf("gas limit error mismatch: have %v, want %v", err, ErrGasLimit)
+	}
+	bundle := &TxBundle{Txs: txs, BlockNumber: 1, MinTimestamp: 10, MaxTimestamp: 20}
+	if err := pool.AddBundle(bundle); err != nil {
+		t.Fatalf("failed to add bundle: %v", err)
+	}
+	if err := pool.AddBundle(&TxBundle{Txs: txs, BlockNumber: 1}); err != ErrAlreadyKnown {
+		t.Fatalf("duplicate bundle error mismatch: have %v, want %v", err, ErrAlreadyKnown)
+	}
+	if err := pool.AddBundle(&TxBundle{Txs: txs[:1], BlockNumber: 2}); err != nil {
+		t.Fatalf("failed to add bundle: %v", err)
+	}
+	// Bundles shouldn't leak into the pool itself
+	if pending, queued := pool.Stats(); pending+queued != 0 {
+		t.Fatalf("pooled transactions mismatched: have %d, want %d", pending+queued, 0)
+	}
+	for i, tt := range []struct {
+		number, time uint64
+		want         int
+	}{{1, 9, 0}, {1, 10, 1}, {1, 20, 1}, {1, 21, 0}, {2, 100, 1}, {3, 10, 0}} {
+		if have := len(pool.Bundles(tt.number, tt.time)); have != tt.want {
+			t.Errorf("test %d: bundle count mismatch: have %d, want %d", i, have, tt.want)
+		}
+	}
+	if have := pool.Bundles(1, 15); len(have) != 1 || have[0] != bundle {
+		t.Fatalf("bundle mismatch: have %v, want %v", have, bundle)
+	}
+	// Importing the target block should drop the stale bundles
+	pool.mu.Lock()
+	pool.dropStaleBundles(1)
+	pool.mu.Unlock()
+
+	if have := len(pool.Bundles(1, 15)); have != 0 {
+		t.Fatalf("stale bundles retained: %d", have)
+	}
+	if have := len(pool.Bundles(2, 15)); have != 1 {
+		t.Fatalf("future bundle count mismatch: have %d, want 1", have)
+	}
+}
+
+// Tests that the admission policy filters transactions by sender, recipient and
+// method, enforces the per recipient limits, and purges the pool on reloads.
+func TestTransactionAdmissionPolicy(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("failed to create temporary policy folder: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	var (
+		denied, _ = crypto.GenerateKey()
+		sender, _ = crypto.GenerateKey()
+		other, _  = crypto.GenerateKey()
+
+		sanctioned = common.HexToAddress("0x1001")
+		capped     = common.HexToAddress("0x1002")
+		premium    = common.HexToAddress("0x1003")
+		open       = common.HexToAddress("0x1004")
+	)
+	writePolicy := func(denySenders ...common.Address) {
+		policy := fmt.Sprintf(`{
+			"denySenders": %s,
+			"denyRecipients": ["%s"],
+			"denyMethods": ["0xa9059cbb"],
+			"maxInFlight": {"%s": 2},
+			"minGasPrice": {"%s": "10"}
+		}`, jsonAddresses(denySenders), sanctioned.Hex(), capped.Hex(), premium.Hex())
+		if err := ioutil.WriteFile(filepath.Join(dir, "policy.json"), []byte(policy), 0644); err != nil {
+			t.Fatalf("failed to write admission policy: %v", err)
+		}
+	}
+	writePolicy(crypto.PubkeyToAddress(denied.PublicKey))
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	blockchain := &testBlockChain{statedb, 1000000, new(event.Feed)}
+
+	config := testTxPoolConfig
+	config.AdmissionPolicy = filepath.Join(dir, "policy.json")
+
+	pool := NewTxPool(config, params.TestChainConfig, blockchain)
+	defer pool.Stop()
+
+	for _, key := range []*ecdsa.PrivateKey{denied, sender, other} {
+		pool.currentState.AddBalance(crypto.PubkeyToAddress(key.PublicKey), big.NewInt(1000000000))
+	}
+	call := func(nonce uint64, to common.Address, price int64, data []byte, key *ecdsa.PrivateKey) *types.Transaction {
+		tx, _ := types.SignTx(types.NewTransaction(nonce, to, big.NewInt(0), 100000, big.NewInt(price), data), types.HomesteadSigner{}, key)
+		return tx
+	}
+	tests := []struct {
+		tx    *types.Transaction
+		local bool
+		want  error
+	}{
+		{call(0, open, 1, nil, denied), false, ErrSenderDenied},
+		{call(0, open, 1, nil, denied), true, ErrSenderDenied},
+		{call(0, sanctioned, 1, nil, sender), false, ErrRecipientDenied},
+		{call(0, open, 1, common.FromHex("0xa9059cbb0000"), sender), false, ErrMethodDenied},
+		{call(0, open, 1, common.FromHex("0x095ea7b30000"), sender), false, nil},
+		{call(1, capped, 1, nil, sender), false, nil},
+		{call(2, capped, 1, nil, sender), false, nil},
+		{call(3, capped, 1, nil, sender), false, ErrRecipientInFlightLimit},
+		{call(2, capped, 2, nil, sender), false, nil}, // Replacement, not a new in-flight transaction
+		{call(0, premium, 9, nil, other), false, ErrRecipientUnderpriced},
+		{call(0, premium, 10, nil, other), false, nil},
+		{call(1, premium, 1, nil, other), true, nil},
+	}
+	for i, tt := range tests {
+		var err error
+		if tt.local {
+			err = pool.AddLocal(tt.tx)
+		} else {
+			err = pool.addRemoteSync(tt.tx)
+		}
+		if err != tt.want {
+			t.Errorf("test %d: admission error mismatch: have %v, want %v", i, err, tt.want)
+		}
+	}
+	if pending, _ := pool.Stats(); pending != 5 {
+		t.Fatalf("pending transactions mismatched: have %d, want %d", pending, 5)
+	}
+	// Denied senders can't sneak in through bundles either
+	bundle := &TxBundle{Txs: types.Transactions{call(0, open, 1, nil, denied)}, BlockNumber: 1}
+	if err := pool.AddBundle(bundle); err != ErrSenderDenied {
+		t.Fatalf("denied bundle error mismatch: have %v, want %v", err, ErrSenderDenied)
+	}
+	// Deny a sender with pooled transactions and ensure a reload purges them
+	writePolicy(crypto.PubkeyToAddress(denied.PublicKey), crypto.PubkeyToAddress(other.PublicKey))
+	if err := pool.ReloadAdmissionPolicy(); err != nil {
+		t.Fatalf("failed to reload admission policy: %v", err)
+	}
+	if pending, _ := pool.Stats(); pending != 3 {
+		t.Fatalf("pending transactions mismatched: have %d, want %d", pending, 3)
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+	// A broken policy file must keep the previous policy in place
+	if err := ioutil.WriteFile(filepath.Join(dir, "policy.json"), []byte("{"), 0644); err != nil {
+		t.Fatalf("failed to write admission policy: %v", err)
+	}
+	if err := pool.ReloadAdmissionPolicy(); err == nil {
+		t.Fatalf("invalid admission policy accepted")
+	}
+	if err := pool.addRemoteSync(call(2, premium, 10, nil, other)); err != ErrSenderDenied {
+		t.Fatalf("admission error mismatch after failed reload: have %v, want %v", err, ErrSenderDenied)
+	}
+}
+
+// Tests that the minimum gas price of the admission policy is checked against
+// the effective gas price of dynamic fee transactions at the upcoming base fee,
+// not against their tip or fee cap alone.
+func TestAdmissionPolicyDynamicFee(t *testing.T) {
+	t.Parallel()
+
+	config := *params.TestChainConfig
+	config.YoloV2Block = big.NewInt(0)
+	config.LondonBlock = big.NewInt(1)
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	blockchain := &testBlockChain{statedb, 1000000, new(event.Feed)}
+
+	pool := NewTxPool(testTxPoolConfig, &config, blockchain)
+	defer pool.Stop()
+
+	key, _ := crypto.GenerateKey()
+	pool.currentState.AddBalance(crypto.PubkeyToAddress(key.PublicKey), big.NewInt(params.Hayeker))
+
+	// Require 2 gwei for the recipient, twice the base fee of the first London block
+	gwei := func(n float64) *big.Int { return big.NewInt(int64(n * params.GWei)) }
+	pool.SetAdmissionPolicy(&AdmissionPolicy{
+		MinGasPrice: map[common.Address]*math.HexOrDecimal256{{}: (*math.HexOrDecimal256)(gwei(2))},
+	})
+	tests := []struct {
+		feeCap, tip *big.Int
+		want        error
+	}{
+		{gwei(100), big.NewInt(1), ErrRecipientUnderpriced}, // High fee cap, but pays barely more than the base fee
+		{gwei(1.5), gwei(1.5), ErrRecipientUnderpriced},     // Tip covering the base fee, but capped below the minimum
+		{gwei(3), gwei(1.5), nil},                           // Tip below the minimum, but paying enough on top of the base fee
+	}
+	for i, tt := range tests {
+		if err := pool.addRemoteSync(dynamicFeeTransaction(0, 100000, tt.feeCap, tt.tip, key)); err != tt.want {
+			t.Errorf("test %d: admission error mismatch: have %v, want %v", i, err, tt.want)
+		}
+	}
+}
+
+// jsonAddresses formats a list of addresses as a JSON array.
+func jsonAddresses(addrs []common.Address) string {
+	blob, _ := json.Marshal(addrs)
+	return string(blob)
+}
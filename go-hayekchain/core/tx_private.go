@@ -0,0 +1,172 @@
+// Copyright 2021 The go-hayekchain Authors
+// This file is part of the go-hayekchain library.
+//
+// The go-hayekchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hayekchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hayekchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/hayekchain/go-hayekchain/common"
+	"github.com/hayekchain/go-hayekchain/core/state"
+	"github.com/hayekchain/go-hayekchain/core/types"
+	"github.com/hayekchain/go-hayekchain/log"
+	"github.com/hayekchain/go-hayekchain/metrics"
+)
+
+var (
+	// ErrPrivateTxExpired is returned if a private transaction is submitted with
+	// a deadline that was already reached by the chain.
+	ErrPrivateTxExpired = errors.New("private transaction deadline passed")
+
+	// ErrPrivateTxLimit is returned if the private transaction set is full.
+	ErrPrivateTxLimit = errors.New("too many private transactions")
+)
+
+var (
+	privateGauge       = metrics.NewRegisteredGauge("txpool/private", nil)
+	privateExpireMeter = metrics.NewRegisteredMeter("txpool/private/expire", nil) // Dropped due to the deadline
+)
+
+// privateTx is a transaction of the private lane along with the last block it
+// may be included in.
+type privateTx struct {
+	tx       *types.Transaction
+	from     common.Address
+	deadline uint64
+}
+
+// privateTxSet is the set of transactions submitted through the private lane.
+// They are never gossiped or announced, only handed to the local miner until
+// they are included or their deadline passes. The set is not thread safe, it
+// is guarded by the lock of the transaction pool.
+type privateTxSet struct {
+	txs map[common.Hash]*privateTx
+}
+
+// newPrivateTxSet creates an empty set of private transactions.
+func newPrivateTxSet() *privateTxSet {
+	return &privateTxSet{txs: make(map[common.Hash]*privateTx)}
+}
+
+// add inserts a transaction into the set, replacing any private transaction of
+// the same sender and nonce.
+func (s *privateTxSet) add(tx *types.Transaction, from common.Address, deadline uint64) {
+	for hash, ptx := range s.txs {
+		if ptx.from == from && ptx.tx.Nonce() == tx.Nonce() {
+			delete(s.txs, hash)
+		}
+	}
+	s.txs[tx.Hash()] = &privateTx{tx: tx, from: from, deadline: deadline}
+	privateGauge.Update(int64(len(s.txs)))
+}
+
+// remove drops a transaction from the set, returning whether it was present.
+func (s *privateTxSet) remove(hash common.Hash) bool {
+	if _, ok := s.txs[hash]; !ok {
+		return false
+	}
+	delete(s.txs, hash)
+	privateGauge.Update(int64(len(s.txs)))
+	return true
+}
+
+// reset drops all transactions that can't be included any more after the given
+// head: the ones past their deadline and the ones with stale nonces.
+func (s *privateTxSet) reset(head uint64, statedb *state.StateDB) {
+	for hash, ptx := range s.txs {
+		switch {
+		case ptx.deadline <= head:
+			log.Trace("Dropping expired private transaction", "hash", hash, "deadline", ptx.deadline)
+			privateExpireMeter.Mark(1)
+			delete(s.txs, hash)
+
+		case statedb.GetNonce(ptx.from) > ptx.tx.Nonce():
+			log.Trace("Dropping included private transaction", "hash", hash)
+			delete(s.txs, hash)
+		}
+	}
+	privateGauge.Update(int64(len(s.txs)))
+}
+
+// flatten returns the transactions of the set grouped by sender and sorted by
+// nonce.
+func (s *privateTxSet) flatten() map[common.Address]types.Transactions {
+	txs := make(map[common.Address]types.Transactions)
+	for _, ptx := range s.txs {
+		txs[ptx.from] = append(txs[ptx.from], ptx.tx)
+	}
+	for _, list := range txs {
+		sort.Sort(types.TxByNonce(list))
+	}
+	return txs
+}
+
+// AddPrivate validates a transaction and inserts it into the private lane. The
+// transaction is never broadcast to the network, nor does it show up in the
+// pool content or in new transaction events. It's only offered to the local
+// miner until it is included, or until the chain reaches the given deadline
+// block number. A zero deadline means the configured private lifetime.
+func (pool *TxPool) AddPrivate(tx *types.Transaction, deadline uint64) error {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	hash := tx.Hash()
+	if pool.all.Get(hash) != nil || pool.private.txs[hash] != nil {
+		knownTxMeter.Mark(1)
+		return ErrAlreadyKnown
+	}
+	head := pool.chain.CurrentBlock().NumberU64()
+	if deadline == 0 {
+		deadline = head + pool.config.PrivateLifetime
+	}
+	if deadline <= head {
+		return ErrPrivateTxExpired
+	}
+	// Private transactions may come from anyone, only senders of local accounts
+	// are exempt from the pricing rules
+	if err := pool.validateTx(tx, false); err != nil {
+		invalidTxMeter.Mark(1)
+		return err
+	}
+	if uint64(len(pool.private.txs)) >= pool.config.PrivateSlots {
+		return ErrPrivateTxLimit
+	}
+	from, _ := types.Sender(pool.signer, tx) // already validated
+	pool.private.add(tx, from, deadline)
+
+	log.Trace("Pooled new private transaction", "hash", hash, "from", from, "deadline", deadline)
+	return nil
+}
+
+// RemovePrivate drops a transaction from the private lane, returning whether
+// it was found.
+func (pool *TxPool) RemovePrivate(hash common.Hash) bool {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	return pool.private.remove(hash)
+}
+
+// Private retrieves all transactions of the private lane, grouped by origin
+// account and sorted by nonce. The returned transaction set is a copy and can
+// be freely modified by calling code.
+func (pool *TxPool) Private() map[common.Address]types.Transactions {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	return pool.private.flatten()
+}
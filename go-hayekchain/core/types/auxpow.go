@@ -0,0 +1,64 @@
+// Copyright 2021 The go-hayekchain Authors
+// This file is part of the go-hayekchain library.
+//
+// The go-hayekchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hayekchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hayekchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package types
+
+import (
+	"github.com/hayekchain/go-hayekchain/common"
+	"github.com/hayekchain/go-hayekchain/common/hexutil"
+)
+
+//go:generate gencodec -type AuxPow -field-override auxPowMarshaling -out gen_auxpow_json.go
+
+// AuxPow is an auxiliary proof-of-work, sealing a block with the work done on a
+// block of a double SHA256 parent chain. The coinbase transaction of the parent
+// block commits to the seal hash of the block, either directly or as a leaf of
+// a merged mining tree shared with other auxiliary chains.
+//
+// All hashes are in the internal (little endian) byte order of the parent chain.
+type AuxPow struct {
+	ParentHeader   []byte        `json:"parentHeader"   gencodec:"required"` // 80 byte header of the parent chain block
+	Coinbase       []byte        `json:"coinbase"       gencodec:"required"` // Coinbase transaction of the parent block, without witness
+	CoinbaseBranch []common.Hash `json:"coinbaseBranch" gencodec:"required"` // Merkle branch from the coinbase to the parent merkle root
+	ChainBranch    []common.Hash `json:"chainBranch"    gencodec:"required"` // Merkle branch from the seal hash to the merged mining root
+	ChainIndex     uint32        `json:"chainIndex"     gencodec:"required"` // Position of the seal hash in the merged mining tree
+}
+
+// field type overrides for gencodec
+type auxPowMarshaling struct {
+	ParentHeader hexutil.Bytes
+	Coinbase     hexutil.Bytes
+	ChainIndex   hexutil.Uint64
+}
+
+// Size returns the approximate memory used by the proof.
+func (a *AuxPow) Size() common.StorageSize {
+	return common.StorageSize(len(a.ParentHeader) + len(a.Coinbase) + (len(a.CoinbaseBranch)+len(a.ChainBranch))*common.HashLength + 4)
+}
+
+// Copy creates a deep copy of the proof.
+func (a *AuxPow) Copy() *AuxPow {
+	cpy := &AuxPow{
+		ParentHeader:   common.CopyBytes(a.ParentHeader),
+		Coinbase:       common.CopyBytes(a.Coinbase),
+		CoinbaseBranch: make([]common.Hash, len(a.CoinbaseBranch)),
+		ChainBranch:    make([]common.Hash, len(a.ChainBranch)),
+		ChainIndex:     a.ChainIndex,
+	}
+	copy(cpy.CoinbaseBranch, a.CoinbaseBranch)
+	copy(cpy.ChainBranch, a.ChainBranch)
+	return cpy
+}
@@ -18,6 +18,7 @@
 package types
 
 import (
+	"bytes"
 	"encoding/binary"
 	"fmt"
 	"io"
@@ -85,6 +86,13 @@ type Header struct {
 	Extra       []byte         `json:"extraData"        gencodec:"required"`
 	MixDigest   common.Hash    `json:"mixHash"`
 	Nonce       BlockNonce     `json:"nonce"`
+
+	// BaseFee was added by EIP-1559 and is ignored in legacy headers.
+	BaseFee *big.Int `json:"baseFeePerGas" rlp:"optional"`
+
+	// AuxPow seals the block with merged mined work of a parent chain instead
+	// of the nonce. It is absent from the RLP encoding of regular headers.
+	AuxPow *AuxPow `json:"auxpow,omitempty" rlp:"optional"`
 }
 
 // field type overrides for gencodec
@@ -95,6 +103,7 @@ type headerMarshaling struct {
 	GasUsed    hexutil.Uint64
 	Time       hexutil.Uint64
 	Extra      hexutil.Bytes
+	BaseFee    *hexutil.Big
 	Hash       common.Hash `json:"hash"` // adds call to Hash() in MarshalJSON
 }
 
@@ -106,10 +115,113 @@ func (h *Header) Hash() common.Hash {
 
 var headerSize = common.StorageSize(reflect.TypeOf(Header{}).Size())
 
+// noBaseFee takes the place of the base fee in the encoding of merge mined
+// headers without one. Being an empty list, it can't be mistaken for a zero
+// base fee.
+var noBaseFee = rlp.RawValue{0xc0}
+
+// headerRLP is the RLP layout of headers, with the base fee kept raw so that
+// merge mined headers can mark its absence explicitly.
+type headerRLP struct {
+	ParentHash  common.Hash
+	UncleHash   common.Hash
+	Coinbase    common.Address
+	Root        common.Hash
+	TxHash      common.Hash
+	ReceiptHash common.Hash
+	Bloom       Bloom
+	Difficulty  *big.Int
+	Number      *big.Int
+	GasLimit    uint64
+	GasUsed     uint64
+	Time        uint64
+	Extra       []byte
+	MixDigest   common.Hash
+	Nonce       BlockNonce
+	BaseFee     rlp.RawValue `rlp:"optional"`
+	AuxPow      *AuxPow      `rlp:"optional"`
+}
+
+// EncodeRLP implements rlp.Encoder. Merge mined headers without a base fee carry
+// an explicit marker in its place, regular headers use the plain encoding.
+func (h *Header) EncodeRLP(w io.Writer) error {
+	if h.AuxPow == nil {
+		type header Header // Without the encoder method, avoiding recursion
+		return rlp.Encode(w, (*header)(h))
+	}
+	enc := &headerRLP{
+		ParentHash:  h.ParentHash,
+		UncleHash:   h.UncleHash,
+		Coinbase:    h.Coinbase,
+		Root:        h.Root,
+		TxHash:      h.TxHash,
+		ReceiptHash: h.ReceiptHash,
+		Bloom:       h.Bloom,
+		Difficulty:  h.Difficulty,
+		Number:      h.Number,
+		GasLimit:    h.GasLimit,
+		GasUsed:     h.GasUsed,
+		Time:        h.Time,
+		Extra:       h.Extra,
+		MixDigest:   h.MixDigest,
+		Nonce:       h.Nonce,
+		BaseFee:     noBaseFee,
+		AuxPow:      h.AuxPow,
+	}
+	if h.BaseFee != nil {
+		blob, err := rlp.EncodeToBytes(h.BaseFee)
+		if err != nil {
+			return err
+		}
+		enc.BaseFee = blob
+	}
+	return rlp.Encode(w, enc)
+}
+
+// DecodeRLP implements rlp.Decoder, resolving the base fee marker of merge mined
+// headers without one.
+func (h *Header) DecodeRLP(s *rlp.Stream) error {
+	var dec headerRLP
+	if err := s.Decode(&dec); err != nil {
+		return err
+	}
+	var baseFee *big.Int
+	if len(dec.BaseFee) > 0 && !(dec.AuxPow != nil && bytes.Equal(dec.BaseFee, noBaseFee)) {
+		baseFee = new(big.Int)
+		if err := rlp.DecodeBytes(dec.BaseFee, baseFee); err != nil {
+			return fmt.Errorf("invalid base fee: %v", err)
+		}
+	}
+	*h = Header{
+		ParentHash:  dec.ParentHash,
+		UncleHash:   dec.UncleHash,
+		Coinbase:    dec.Coinbase,
+		Root:        dec.Root,
+		TxHash:      dec.TxHash,
+		ReceiptHash: dec.ReceiptHash,
+		Bloom:       dec.Bloom,
+		Difficulty:  dec.Difficulty,
+		Number:      dec.Number,
+		GasLimit:    dec.GasLimit,
+		GasUsed:     dec.GasUsed,
+		Time:        dec.Time,
+		Extra:       dec.Extra,
+		MixDigest:   dec.MixDigest,
+		Nonce:       dec.Nonce,
+		BaseFee:     baseFee,
+		AuxPow:      dec.AuxPow,
+	}
+	return nil
+}
+
 // Size returns the approximate memory used by all internal contents. It is used
 // to approximate and limit the memory consumption of various caches.
 func (h *Header) Size() common.StorageSize {
-	return headerSize + common.StorageSize(len(h.Extra)+(h.Difficulty.BitLen()+h.Number.BitLen())/8)
+	size := headerSize + common.StorageSize(len(h.Extra)+(h.Difficulty.BitLen()+h.Number.BitLen())/8)
+	if h.AuxPow != nil {
+		size += h.AuxPow.Size()
+	}
+	return size
 }
 
 // SanityCheck checks a few basic things -- these checks are way beyond what
@@ -128,6 +240,11 @@ func (h *Header) SanityCheck() error {
 	if eLen := len(h.Extra); eLen > 100*1024 {
 		return fmt.Errorf("too large block extradata: size %d", eLen)
 	}
+	if h.BaseFee != nil {
+		if bfLen := h.BaseFee.BitLen(); bfLen > 256 {
+			return fmt.Errorf("too large base fee: bitlen %d", bfLen)
+		}
+	}
 	return nil
 }
 
@@ -147,6 +264,18 @@ func rlpHash(x interface{}) (h common.Hash) {
 	return h
 }
 
+// prefixedRlpHash writes the prefix into the hasher before rlp-encoding the
+// given interface. It's used for typed transactions.
+func prefixedRlpHash(prefix byte, x interface{}) (h common.Hash) {
+	sha := hasherPool.Get().(crypto.KeccakState)
+	defer hasherPool.Put(sha)
+	sha.Reset()
+	sha.Write([]byte{prefix})
+	rlp.Encode(sha, x)
+	sha.Read(h[:])
+	return h
+}
+
 // EmptyBody returns true if there is no additional 'body' to complete the header
 // that is: no transactions and no uncles.
 func (h *Header) EmptyBody() bool {
@@ -274,6 +403,12 @@ func CopyHeader(h *Header) *Header {
 		cpy.Extra = make([]byte, len(h.Extra))
 		copy(cpy.Extra, h.Extra)
 	}
+	if h.BaseFee != nil {
+		cpy.BaseFee = new(big.Int).Set(h.BaseFee)
+	}
+	if h.AuxPow != nil {
+		cpy.AuxPow = h.AuxPow.Copy()
+	}
 	return &cpy
 }
 
@@ -340,6 +475,13 @@ func (b *Block) ReceiptHash() common.Hash { return b.header.ReceiptHash }
 func (b *Block) UncleHash() common.Hash   { return b.header.UncleHash }
 func (b *Block) Extra() []byte            { return common.CopyBytes(b.header.Extra) }
 
+func (b *Block) BaseFee() *big.Int {
+	if b.header.BaseFee == nil {
+		return nil
+	}
+	return new(big.Int).Set(b.header.BaseFee)
+}
+
 func (b *Block) Header() *Header { return CopyHeader(b.header) }
 
 // Body returns the non-header content of the block.
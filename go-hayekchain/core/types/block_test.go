@@ -69,6 +69,81 @@ func TestBlockEncoding(t *testing.T) {
 	}
 }
 
+// Tests that merge mined headers carry their proof through the RLP encoding,
+// while the encoding of regular headers is left untouched.
+func TestHeaderAuxPowEncoding(t *testing.T) {
+	header := &Header{Difficulty: big.NewInt(131072), Number: big.NewInt(1), GasLimit: 3141592, Time: 1426516743}
+	plain, err := rlp.EncodeToBytes(header)
+	if err != nil {
+		t.Fatal("encode error: ", err)
+	}
+	var elems []rlp.RawValue
+	if err := rlp.DecodeBytes(plain, &elems); err != nil || len(elems) != 15 {
+		t.Fatalf("regular header field count mismatch: have %d, want 15 (%v)", len(elems), err)
+	}
+	header.AuxPow = &AuxPow{
+		ParentHeader:   make([]byte, 80),
+		Coinbase:       []byte{0x01, 0x02, 0x03},
+		CoinbaseBranch: []common.Hash{{0x01}},
+		ChainBranch:    []common.Hash{{0x02}, {0x03}},
+		ChainIndex:     3,
+	}
+	blob, err := rlp.EncodeToBytes(header)
+	if err != nil {
+		t.Fatal("encode error: ", err)
+	}
+	decoded := new(Header)
+	if err := rlp.DecodeBytes(blob, decoded); err != nil {
+		t.Fatal("decode error: ", err)
+	}
+	if decoded.Hash() != header.Hash() || !reflect.DeepEqual(decoded.AuxPow, header.AuxPow) {
+		t.Errorf("decoded proof mismatch: have %+v, want %+v", decoded.AuxPow, header.AuxPow)
+	}
+	if decoded.BaseFee != nil {
+		t.Errorf("missing base fee decoded: have %v, want <nil>", decoded.BaseFee)
+	}
+	if cpy := CopyHeader(header); cpy.AuxPow == header.AuxPow || !reflect.DeepEqual(cpy.AuxPow, header.AuxPow) {
+		t.Errorf("copied proof not deep copied")
+	}
+	// Merge mined headers after EIP-1559 carry both the base fee and the proof
+	header.BaseFee = big.NewInt(1000000000)
+	if blob, err = rlp.EncodeToBytes(header); err != nil {
+		t.Fatal("encode error: ", err)
+	}
+	decoded = new(Header)
+	if err := rlp.DecodeBytes(blob, decoded); err != nil {
+		t.Fatal("decode error: ", err)
+	}
+	if decoded.Hash() != header.Hash() || decoded.BaseFee.Cmp(header.BaseFee) != 0 {
+		t.Errorf("decoded base fee mismatch: have %v, want %v", decoded.BaseFee, header.BaseFee)
+	}
+	// A zero base fee must survive next to the proof, distinct from a missing one
+	header.BaseFee = new(big.Int)
+	if blob, err = rlp.EncodeToBytes(header); err != nil {
+		t.Fatal("encode error: ", err)
+	}
+	decoded = new(Header)
+	if err := rlp.DecodeBytes(blob, decoded); err != nil {
+		t.Fatal("decode error: ", err)
+	}
+	if decoded.BaseFee == nil || decoded.BaseFee.Sign() != 0 {
+		t.Errorf("zero base fee lost: have %v, want 0", decoded.BaseFee)
+	}
+	legacy := CopyHeader(header)
+	legacy.BaseFee = nil
+	if legacy.Hash() == decoded.Hash() {
+		t.Errorf("zero and missing base fee hash the same")
+	}
+	// Regular headers must not carry the missing base fee marker
+	elems = append(elems[:15], noBaseFee)
+	if blob, err = rlp.EncodeToBytes(elems); err != nil {
+		t.Fatal("encode error: ", err)
+	}
+	if err := rlp.DecodeBytes(blob, new(Header)); err == nil {
+		t.Errorf("base fee marker accepted in regular header")
+	}
+}
+
 func TestUncleHash(t *testing.T) {
 	uncles := make([]*Header, 0)
 	h := CalcUncleHash(uncles)
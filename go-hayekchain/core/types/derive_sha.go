@@ -21,6 +21,10 @@ import (
 	"github.com/hayekchain/go-hayekchain/rlp"
 )
 
+// DerivableList is the input to DeriveSha. It is implemented by the 'Transactions'
+// and 'Receipts' types. GetRlp returns the canonical encoding of an element, so
+// EIP-2718 typed elements are hashed as their type byte followed by the RLP
+// encoding of their payload.
 type DerivableList interface {
 	Len() int
 	GetRlp(i int) []byte
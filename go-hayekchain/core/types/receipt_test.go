@@ -154,9 +154,18 @@ func encodeAsV3StoredReceiptRLP(want *Receipt) ([]byte, error) {
 // Tests that receipt data can be correctly derived from the contextual infos
 func TestDeriveFields(t *testing.T) {
 	// Create a few transactions to have receipts for
+	to3 := common.HexToAddress("0x3")
 	txs := Transactions{
 		NewContractCreation(1, big.NewInt(1), 1, big.NewInt(1), nil),
 		NewTransaction(2, common.HexToAddress("0x2"), big.NewInt(2), 2, big.NewInt(2), nil),
+		NewTx(&AccessListTx{
+			ChainID:  big.NewInt(1),
+			Nonce:    3,
+			To:       &to3,
+			Value:    big.NewInt(3),
+			Gas:      3,
+			GasPrice: big.NewInt(3),
+		}),
 	}
 	// Create the corresponding receipts
 	receipts := Receipts{
@@ -182,6 +191,18 @@ func TestDeriveFields(t *testing.T) {
 			ContractAddress: common.BytesToAddress([]byte{0x02, 0x22, 0x22}),
 			GasUsed:         2,
 		},
+		&Receipt{
+			Type:              AccessListTxType,
+			PostState:         common.Hash{3}.Bytes(),
+			CumulativeGasUsed: 6,
+			Logs: []*Log{
+				{Address: common.BytesToAddress([]byte{0x33})},
+				{Address: common.BytesToAddress([]byte{0x03, 0x33})},
+			},
+			TxHash:          txs[2].Hash(),
+			ContractAddress: common.BytesToAddress([]byte{0x03, 0x33, 0x33}),
+			GasUsed:         3,
+		},
 	}
 	// Clear all the computed fields and re-derive them
 	number := big.NewInt(1)
@@ -196,6 +217,9 @@ func TestDeriveFields(t *testing.T) {
 
 	logIndex := uint(0)
 	for i := range receipts {
+		if receipts[i].Type != txs[i].Type() {
+			t.Errorf("receipts[%d].Type = %d, want %d", i, receipts[i].Type, txs[i].Type())
+		}
 		if receipts[i].TxHash != txs[i].Hash() {
 			t.Errorf("receipts[%d].TxHash = %s, want %s", i, receipts[i].TxHash.String(), txs[i].Hash().String())
 		}
@@ -254,6 +278,7 @@ func clearComputedFieldsOnReceipts(t *testing.T, receipts Receipts) {
 func clearComputedFieldsOnReceipt(t *testing.T, receipt *Receipt) {
 	t.Helper()
 
+	receipt.Type = 0x7f
 	receipt.TxHash = common.Hash{}
 	receipt.BlockHash = common.Hash{}
 	receipt.BlockNumber = big.NewInt(math.MaxUint32)
@@ -281,3 +306,51 @@ func clearComputedFieldsOnLog(t *testing.T, log *Log) {
 	log.TxIndex = math.MaxUint32
 	log.Index = math.MaxUint32
 }
+
+// Tests that receipts of typed transactions are encoded with the type byte, both
+// in their canonical and network encodings.
+func TestTypedReceiptEncoding(t *testing.T) {
+	receipt := &Receipt{
+		Type:              AccessListTxType,
+		Status:            ReceiptStatusSuccessful,
+		CumulativeGasUsed: 21000,
+		Logs: []*Log{
+			{
+				Address: common.BytesToAddress([]byte{0x11}),
+				Topics:  []common.Hash{common.HexToHash("dead"), common.HexToHash("beef")},
+				Data:    []byte{0x01, 0x00, 0xff},
+			},
+		},
+	}
+	receipt.Bloom = CreateBloom(Receipts{receipt})
+
+	blob, err := receipt.MarshalBinary()
+	if err != nil {
+		t.Fatalf("could not encode receipt: %v", err)
+	}
+	if blob[0] != AccessListTxType {
+		t.Fatalf("receipt type prefix mismatch: have %#x, want %#x", blob[0], AccessListTxType)
+	}
+	if enc := (Receipts{receipt}).GetRlp(0); !bytes.Equal(enc, blob) {
+		t.Fatalf("derivable encoding mismatch: have %x, want %x", enc, blob)
+	}
+	network, err := rlp.EncodeToBytes(receipt)
+	if err != nil {
+		t.Fatalf("could not RLP encode receipt: %v", err)
+	}
+	if wrapped, _ := rlp.EncodeToBytes(blob); !bytes.Equal(network, wrapped) {
+		t.Fatalf("network encoding mismatch: have %x, want %x", network, wrapped)
+	}
+	for i, decode := range []func(*Receipt) error{
+		func(r *Receipt) error { return rlp.DecodeBytes(network, r) },
+		func(r *Receipt) error { return r.UnmarshalBinary(blob) },
+	} {
+		decoded := new(Receipt)
+		if err := decode(decoded); err != nil {
+			t.Fatalf("decoder %d: could not decode receipt: %v", i, err)
+		}
+		if !reflect.DeepEqual(decoded, receipt) {
+			t.Fatalf("decoder %d: receipt mismatch: have %+v, want %+v", i, decoded, receipt)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright 2021 The go-hayekchain Authors
+// This file is part of the go-hayekchain library.
+//
+// The go-hayekchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hayekchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hayekchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package types
+
+import (
+	"math/big"
+
+	"github.com/hayekchain/go-hayekchain/common"
+)
+
+// SponsoredTx is the data of sponsored transactions, dynamic fee transactions
+// whose gas is paid by a fee payer instead of the sender.
+//
+// The sender signs all fields up to and including the fee payer address, so it
+// decides who may sponsor it. The fee payer in turn signs the sender signed
+// transaction, consenting to pay for that exact transaction.
+type SponsoredTx struct {
+	ChainID    *big.Int        // destination chain ID
+	Nonce      uint64          // nonce of sender account
+	GasTipCap  *big.Int        // max priority fee per gas, paid to the miner
+	GasFeeCap  *big.Int        // max total fee per gas, base fee included
+	Gas        uint64          // gas limit
+	To         *common.Address `rlp:"nil"` // nil means contract creation
+	Value      *big.Int        // wei amount, paid by the sender
+	Data       []byte          // contract invocation input data
+	AccessList AccessList      // EIP-2930 access list
+	FeePayer   common.Address  // account paying for the gas
+	V, R, S    *big.Int        // sender signature values
+
+	PayerV, PayerR, PayerS *big.Int // fee payer signature values
+}
+
+// copy creates a deep copy of the transaction data and initializes all fields.
+func (tx *SponsoredTx) copy() TxData {
+	cpy := &SponsoredTx{
+		Nonce:    tx.Nonce,
+		To:       copyAddressPtr(tx.To),
+		Data:     common.CopyBytes(tx.Data),
+		Gas:      tx.Gas,
+		FeePayer: tx.FeePayer,
+		// These are copied below.
+		AccessList: make(AccessList, len(tx.AccessList)),
+		Value:      new(big.Int),
+		ChainID:    new(big.Int),
+		GasTipCap:  new(big.Int),
+		GasFeeCap:  new(big.Int),
+		V:          new(big.Int),
+		R:          new(big.Int),
+		S:          new(big.Int),
+		PayerV:     new(big.Int),
+		PayerR:     new(big.Int),
+		PayerS:     new(big.Int),
+	}
+	for i, tuple := range tx.AccessList {
+		cpy.AccessList[i] = AccessTuple{
+			Address:     tuple.Address,
+			StorageKeys: append([]common.Hash{}, tuple.StorageKeys...),
+		}
+	}
+	if tx.Value != nil {
+		cpy.Value.Set(tx.Value)
+	}
+	if tx.ChainID != nil {
+		cpy.ChainID.Set(tx.ChainID)
+	}
+	if tx.GasTipCap != nil {
+		cpy.GasTipCap.Set(tx.GasTipCap)
+	}
+	if tx.GasFeeCap != nil {
+		cpy.GasFeeCap.Set(tx.GasFeeCap)
+	}
+	if tx.V != nil {
+		cpy.V.Set(tx.V)
+	}
+	if tx.R != nil {
+		cpy.R.Set(tx.R)
+	}
+	if tx.S != nil {
+		cpy.S.Set(tx.S)
+	}
+	if tx.PayerV != nil {
+		cpy.PayerV.Set(tx.PayerV)
+	}
+	if tx.PayerR != nil {
+		cpy.PayerR.Set(tx.PayerR)
+	}
+	if tx.PayerS != nil {
+		cpy.PayerS.Set(tx.PayerS)
+	}
+	return cpy
+}
+
+// accessors for innerTx.
+
+func (tx *SponsoredTx) txType() byte           { return SponsoredTxType }
+func (tx *SponsoredTx) chainID() *big.Int      { return tx.ChainID }
+func (tx *SponsoredTx) accessList() AccessList { return tx.AccessList }
+func (tx *SponsoredTx) data() []byte           { return tx.Data }
+func (tx *SponsoredTx) gas() uint64            { return tx.Gas }
+func (tx *SponsoredTx) gasFeeCap() *big.Int    { return tx.GasFeeCap }
+func (tx *SponsoredTx) gasTipCap() *big.Int    { return tx.GasTipCap }
+func (tx *SponsoredTx) gasPrice() *big.Int     { return tx.GasFeeCap }
+func (tx *SponsoredTx) value() *big.Int        { return tx.Value }
+func (tx *SponsoredTx) nonce() uint64          { return tx.Nonce }
+func (tx *SponsoredTx) to() *common.Address    { return tx.To }
+
+func (tx *SponsoredTx) rawSignatureValues() (v, r, s *big.Int) {
+	return tx.V, tx.R, tx.S
+}
+
+func (tx *SponsoredTx) setSignatureValues(chainID, v, r, s *big.Int) {
+	tx.ChainID, tx.V, tx.R, tx.S = chainID, v, r, s
+}
+
+func (tx *SponsoredTx) setFeePayerSignatureValues(v, r, s *big.Int) {
+	tx.PayerV, tx.PayerR, tx.PayerS = v, r, s
+}
@@ -21,6 +21,7 @@ import (
 	"crypto/ecdsa"
 	"encoding/json"
 	"math/big"
+	"reflect"
 	"testing"
 	"time"
 
@@ -140,7 +141,7 @@ func TestTransactionPriceNonceSort(t *testing.T) {
 		}
 	}
 	// Sort the transactions and cross check the nonce ordering
-	txset := NewTransactionsByPriceAndNonce(signer, groups)
+	txset := NewTransactionsByPriceAndNonce(signer, groups, nil)
 
 	txs := Transactions{}
 	for tx := txset.Peek(); tx != nil; tx = txset.Peek() {
@@ -192,7 +193,7 @@ func TestTransactionTimeSort(t *testing.T) {
 		groups[addr] = append(groups[addr], tx)
 	}
 	// Sort the transactions and cross check the nonce ordering
-	txset := NewTransactionsByPriceAndNonce(signer, groups)
+	txset := NewTransactionsByPriceAndNonce(signer, groups, nil)
 
 	txs := Transactions{}
 	for tx := txset.Peek(); tx != nil; tx = txset.Peek() {
@@ -266,3 +267,314 @@ func TestTransactionJSON(t *testing.T) {
 		}
 	}
 }
+
+// Tests that access list transactions are signed, encoded and recovered using
+// the typed envelope, both in their canonical and network encodings.
+func TestAccessListTxEncoding(t *testing.T) {
+	key, addr := defaultTestKey()
+	signer := NewEIP2930Signer(big.NewInt(1))
+
+	to := common.HexToAddress("0x095e7baea6a6c7c4c2dfeb977efac326af552d87")
+	tx, err := SignNewTx(key, signer, &AccessListTx{
+		ChainID:  big.NewInt(1),
+		Nonce:    3,
+		To:       &to,
+		Value:    big.NewInt(10),
+		Gas:      25000,
+		GasPrice: big.NewInt(1),
+		Data:     common.FromHex("5544"),
+		AccessList: AccessList{
+			{Address: to, StorageKeys: []common.Hash{{0x01}, {0x02}}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("could not sign transaction: %v", err)
+	}
+	if tx.Type() != AccessListTxType || !tx.Protected() || tx.ChainId().Cmp(big.NewInt(1)) != 0 {
+		t.Fatalf("transaction fields mismatch: type %d, protected %v, chain %v", tx.Type(), tx.Protected(), tx.ChainId())
+	}
+	// The canonical encoding is the type byte followed by the RLP payload
+	blob, err := tx.MarshalBinary()
+	if err != nil {
+		t.Fatalf("could not encode transaction: %v", err)
+	}
+	if blob[0] != AccessListTxType {
+		t.Fatalf("transaction type prefix mismatch: have %#x, want %#x", blob[0], AccessListTxType)
+	}
+	if hash := crypto.Keccak256Hash(blob); tx.Hash() != hash {
+		t.Fatalf("transaction hash mismatch: have %x, want %x", tx.Hash(), hash)
+	}
+	if tx.Size() != common.StorageSize(len(blob)) {
+		t.Fatalf("transaction size mismatch: have %v, want %v", tx.Size(), len(blob))
+	}
+	if enc := (Transactions{tx}).GetRlp(0); !bytes.Equal(enc, blob) {
+		t.Fatalf("derivable encoding mismatch: have %x, want %x", enc, blob)
+	}
+	// The network encoding wraps the canonical one into an RLP string
+	network, err := rlp.EncodeToBytes(tx)
+	if err != nil {
+		t.Fatalf("could not RLP encode transaction: %v", err)
+	}
+	if wrapped, _ := rlp.EncodeToBytes(blob); !bytes.Equal(network, wrapped) {
+		t.Fatalf("network encoding mismatch: have %x, want %x", network, wrapped)
+	}
+	for i, decode := range []func() (*Transaction, error){
+		func() (*Transaction, error) { return decodeTx(network) },
+		func() (*Transaction, error) { tx := new(Transaction); return tx, tx.UnmarshalBinary(blob) },
+	} {
+		decoded, err := decode()
+		if err != nil {
+			t.Fatalf("decoder %d: could not decode transaction: %v", i, err)
+		}
+		if decoded.Hash() != tx.Hash() {
+			t.Fatalf("decoder %d: hash mismatch: have %x, want %x", i, decoded.Hash(), tx.Hash())
+		}
+		if !reflect.DeepEqual(decoded.AccessList(), tx.AccessList()) {
+			t.Fatalf("decoder %d: access list mismatch: have %v, want %v", i, decoded.AccessList(), tx.AccessList())
+		}
+		from, err := Sender(signer, decoded)
+		if err != nil || from != addr {
+			t.Fatalf("decoder %d: sender mismatch: have %x (%v), want %x", i, from, err, addr)
+		}
+	}
+	// Signers predating EIP-2930 or of other chains must reject the transaction
+	if _, err := Sender(NewEIP155Signer(big.NewInt(1)), tx); err != ErrTxTypeNotSupported {
+		t.Errorf("EIP-155 signer error mismatch: have %v, want %v", err, ErrTxTypeNotSupported)
+	}
+	if _, err := Sender(NewEIP2930Signer(big.NewInt(2)), tx); err != ErrInvalidChainId {
+		t.Errorf("foreign chain signer error mismatch: have %v, want %v", err, ErrInvalidChainId)
+	}
+	// Unknown transaction types must be rejected
+	if err := new(Transaction).UnmarshalBinary(append([]byte{0x7f}, blob[1:]...)); err != ErrTxTypeNotSupported {
+		t.Errorf("unknown type error mismatch: have %v, want %v", err, ErrTxTypeNotSupported)
+	}
+}
+
+// Tests that access list transactions survive the web3 RPC JSON format.
+func TestAccessListTxJSON(t *testing.T) {
+	key, _ := defaultTestKey()
+	signer := NewEIP2930Signer(big.NewInt(1))
+
+	tx, err := SignNewTx(key, signer, &AccessListTx{
+		ChainID:    big.NewInt(1),
+		Nonce:      1,
+		Gas:        50000,
+		GasPrice:   big.NewInt(2),
+		Data:       []byte("abcdef"),
+		AccessList: AccessList{{Address: common.Address{1}, StorageKeys: []common.Hash{{0xff}}}},
+	})
+	if err != nil {
+		t.Fatalf("could not sign transaction: %v", err)
+	}
+	data, err := json.Marshal(tx)
+	if err != nil {
+		t.Fatalf("json.Marshal failed: %v", err)
+	}
+	var parsed *Transaction
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		t.Fatalf("json.Unmarshal failed: %v", err)
+	}
+	if parsed.Hash() != tx.Hash() || parsed.Type() != AccessListTxType {
+		t.Errorf("parsed tx differs from original tx, want %v, got %v", tx, parsed)
+	}
+}
+
+// Tests that dynamic fee transactions are signed, encoded and recovered using
+// the typed envelope, and survive the web3 RPC JSON format.
+func TestDynamicFeeTxEncoding(t *testing.T) {
+	key, addr := defaultTestKey()
+	signer := NewLondonSigner(big.NewInt(1))
+
+	to := common.HexToAddress("0x095e7baea6a6c7c4c2dfeb977efac326af552d87")
+	tx, err := SignNewTx(key, signer, &DynamicFeeTx{
+		ChainID:    big.NewInt(1),
+		Nonce:      3,
+		To:         &to,
+		Value:      big.NewInt(10),
+		Gas:        25000,
+		GasTipCap:  big.NewInt(2),
+		GasFeeCap:  big.NewInt(10),
+		Data:       common.FromHex("5544"),
+		AccessList: AccessList{{Address: to, StorageKeys: []common.Hash{{0x01}}}},
+	})
+	if err != nil {
+		t.Fatalf("could not sign transaction: %v", err)
+	}
+	if tx.Type() != DynamicFeeTxType || tx.GasTipCap().Int64() != 2 || tx.GasFeeCap().Int64() != 10 {
+		t.Fatalf("transaction fields mismatch: type %d, tip cap %v, fee cap %v", tx.Type(), tx.GasTipCap(), tx.GasFeeCap())
+	}
+	if cost := tx.Cost(); cost.Int64() != 25000*10+10 {
+		t.Fatalf("transaction cost mismatch: have %v, want %v", cost, 25000*10+10)
+	}
+	blob, err := tx.MarshalBinary()
+	if err != nil {
+		t.Fatalf("could not encode transaction: %v", err)
+	}
+	if blob[0] != DynamicFeeTxType {
+		t.Fatalf("transaction type prefix mismatch: have %#x, want %#x", blob[0], DynamicFeeTxType)
+	}
+	decoded := new(Transaction)
+	if err := decoded.UnmarshalBinary(blob); err != nil {
+		t.Fatalf("could not decode transaction: %v", err)
+	}
+	if decoded.Hash() != tx.Hash() {
+		t.Fatalf("hash mismatch: have %x, want %x", decoded.Hash(), tx.Hash())
+	}
+	if from, err := Sender(signer, decoded); err != nil || from != addr {
+		t.Fatalf("sender mismatch: have %x (%v), want %x", from, err, addr)
+	}
+	data, err := json.Marshal(tx)
+	if err != nil {
+		t.Fatalf("json.Marshal failed: %v", err)
+	}
+	var parsed *Transaction
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		t.Fatalf("json.Unmarshal failed: %v", err)
+	}
+	if parsed.Hash() != tx.Hash() {
+		t.Errorf("parsed tx differs from original tx, want %v, got %v", tx, parsed)
+	}
+	// Signers predating EIP-1559 must reject the transaction
+	if _, err := Sender(NewEIP2930Signer(big.NewInt(1)), tx); err != ErrTxTypeNotSupported {
+		t.Errorf("EIP-2930 signer error mismatch: have %v, want %v", err, ErrTxTypeNotSupported)
+	}
+}
+
+// Tests that sponsored transactions carry both the sender and the fee payer
+// signatures through encoding, and that only the declared fee payer can sign.
+func TestSponsoredTransaction(t *testing.T) {
+	key, addr := defaultTestKey()
+	payerKey, _ := crypto.GenerateKey()
+	payer := crypto.PubkeyToAddress(payerKey.PublicKey)
+	signer := NewSponsorSigner(big.NewInt(1))
+
+	to := common.HexToAddress("0x095e7baea6a6c7c4c2dfeb977efac326af552d87")
+	tx, err := SignNewTx(key, signer, &SponsoredTx{
+		ChainID:   big.NewInt(1),
+		Nonce:     3,
+		To:        &to,
+		Value:     big.NewInt(10),
+		Gas:       25000,
+		GasTipCap: big.NewInt(2),
+		GasFeeCap: big.NewInt(10),
+		FeePayer:  payer,
+	})
+	if err != nil {
+		t.Fatalf("could not sign transaction: %v", err)
+	}
+	if *tx.FeePayer() != payer {
+		t.Fatalf("fee payer mismatch: have %x, want %x", *tx.FeePayer(), payer)
+	}
+	if cost := tx.SenderCost(); cost.Int64() != 10 {
+		t.Fatalf("sender cost mismatch: have %v, want %v", cost, 10)
+	}
+	// Without the fee payer signature, the transaction is invalid
+	if _, err := FeePayer(signer, tx); err != ErrInvalidFeePayer {
+		t.Fatalf("unsigned fee payer error mismatch: have %v, want %v", err, ErrInvalidFeePayer)
+	}
+	// Only the declared fee payer may sign for the fees
+	if forged, err := SignFeePayerTx(tx, signer, key); err != nil {
+		t.Fatalf("could not sign transaction as fee payer: %v", err)
+	} else if _, err := FeePayer(signer, forged); err != ErrInvalidFeePayer {
+		t.Fatalf("forged fee payer error mismatch: have %v, want %v", err, ErrInvalidFeePayer)
+	}
+	// The fee payer signature must not change the sender
+	tx, err = SignFeePayerTx(tx, signer, payerKey)
+	if err != nil {
+		t.Fatalf("could not sign transaction as fee payer: %v", err)
+	}
+	blob, err := tx.MarshalBinary()
+	if err != nil {
+		t.Fatalf("could not encode transaction: %v", err)
+	}
+	if blob[0] != SponsoredTxType {
+		t.Fatalf("transaction type prefix mismatch: have %#x, want %#x", blob[0], SponsoredTxType)
+	}
+	decoded := new(Transaction)
+	if err := decoded.UnmarshalBinary(blob); err != nil {
+		t.Fatalf("could not decode transaction: %v", err)
+	}
+	if decoded.Hash() != tx.Hash() {
+		t.Fatalf("hash mismatch: have %x, want %x", decoded.Hash(), tx.Hash())
+	}
+	if from, err := Sender(signer, decoded); err != nil || from != addr {
+		t.Fatalf("sender mismatch: have %x (%v), want %x", from, err, addr)
+	}
+	if have, err := FeePayer(signer, decoded); err != nil || have != payer {
+		t.Fatalf("fee payer mismatch: have %x (%v), want %x", have, err, payer)
+	}
+	data, err := json.Marshal(tx)
+	if err != nil {
+		t.Fatalf("json.Marshal failed: %v", err)
+	}
+	var parsed *Transaction
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		t.Fatalf("json.Unmarshal failed: %v", err)
+	}
+	if parsed.Hash() != tx.Hash() {
+		t.Errorf("parsed tx differs from original tx, want %v, got %v", tx, parsed)
+	}
+	if have, err := FeePayer(signer, parsed); err != nil || have != payer {
+		t.Fatalf("parsed fee payer mismatch: have %x (%v), want %x", have, err, payer)
+	}
+	// Signers predating the sponsor fork must reject the transaction
+	if _, err := Sender(NewLondonSigner(big.NewInt(1)), tx); err != ErrTxTypeNotSupported {
+		t.Errorf("london signer error mismatch: have %v, want %v", err, ErrTxTypeNotSupported)
+	}
+}
+
+// Tests that the effective miner tip is capped by both the tip cap and the fee
+// cap left above the base fee.
+func TestEffectiveGasTip(t *testing.T) {
+	tx := NewTx(&DynamicFeeTx{GasTipCap: big.NewInt(2), GasFeeCap: big.NewInt(10)})
+	legacy := NewTransaction(0, common.Address{}, nil, 0, big.NewInt(5), nil)
+
+	tests := []struct {
+		tx      *Transaction
+		baseFee *big.Int
+		tip     int64
+		err     error
+	}{
+		{tx, nil, 2, nil},
+		{tx, big.NewInt(5), 2, nil},
+		{tx, big.NewInt(9), 1, nil},
+		{tx, big.NewInt(11), -1, ErrGasFeeCapTooLow},
+		{legacy, nil, 5, nil},
+		{legacy, big.NewInt(3), 2, nil},
+	}
+	for i, tt := range tests {
+		tip, err := tt.tx.EffectiveGasTip(tt.baseFee)
+		if err != tt.err || tip.Int64() != tt.tip {
+			t.Errorf("test %d: tip mismatch: have %v (%v), want %v (%v)", i, tip, err, tt.tip, tt.err)
+		}
+	}
+}
+
+// Tests that transactions are sorted by their effective miner tip if a base fee
+// is given, not by their fee caps.
+func TestTransactionTipSort(t *testing.T) {
+	signer := NewLondonSigner(big.NewInt(1))
+
+	caps := [][2]int64{{1, 100}, {5, 6}, {3, 50}}
+	groups := map[common.Address]Transactions{}
+	for _, c := range caps {
+		key, _ := crypto.GenerateKey()
+		tx, _ := SignNewTx(key, signer, &DynamicFeeTx{
+			ChainID:   big.NewInt(1),
+			GasTipCap: big.NewInt(c[0]),
+			GasFeeCap: big.NewInt(c[1]),
+			Gas:       21000,
+		})
+		groups[crypto.PubkeyToAddress(key.PublicKey)] = Transactions{tx}
+	}
+	txset := NewTransactionsByPriceAndNonce(signer, groups, big.NewInt(4))
+
+	var tips []int64
+	for tx := txset.Peek(); tx != nil; tx = txset.Peek() {
+		tips = append(tips, tx.EffectiveGasTipValue(big.NewInt(4)).Int64())
+		txset.Shift()
+	}
+	if want := []int64{3, 2, 1}; !reflect.DeepEqual(tips, want) {
+		t.Errorf("tip ordering mismatch: have %v, want %v", tips, want)
+	}
+}
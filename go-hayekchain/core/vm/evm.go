@@ -108,6 +108,7 @@ type BlockContext struct {
 	BlockNumber *big.Int       // Provides information for NUMBER
 	Time        *big.Int       // Provides information for TIME
 	Difficulty  *big.Int       // Provides information for DIFFICULTY
+	BaseFee     *big.Int       // Base fee of the block after EIP-1559, nil before
 }
 
 // TxContext provides the EVM with information about a transaction.
@@ -531,3 +532,6 @@ func (evm *EVM) Create2(caller ContractRef, code []byte, gas uint64, endowment *
 
 // ChainConfig returns the environment's chain configuration
 func (evm *EVM) ChainConfig() *params.ChainConfig { return evm.chainConfig }
+
+// Config returns the environment's virtual machine configuration
+func (evm *EVM) Config() Config { return evm.vmConfig }
@@ -264,6 +264,15 @@ func (api *PrivateAdminAPI) ImportChain(file string) (bool, error) {
 	return true, nil
 }
 
+// ReloadTxPoolPolicy reloads the transaction admission policy file, dropping
+// the pooled transactions the new policy doesn't permit.
+func (api *PrivateAdminAPI) ReloadTxPoolPolicy() (bool, error) {
+	if err := api.hyk.TxPool().ReloadAdmissionPolicy(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
 // PublicDebugAPI is the collection of HayekChain full node APIs exposed
 // over the public debugging endpoint.
 type PublicDebugAPI struct {
@@ -104,11 +104,30 @@ func (b *HykAPIBackend) BlockByNumber(ctx context.Context, number rpc.BlockNumbe
 	if number == rpc.LatestBlockNumber {
 		return b.hyk.blockchain.CurrentBlock(), nil
 	}
-	return b.hyk.blockchain.GetBlockByNumber(uint64(number)), nil
+	block := b.hyk.blockchain.GetBlockByNumber(uint64(number))
+	if block == nil && b.hyk.blockchain.GetHeaderByNumber(uint64(number)) != nil {
+		return nil, b.historyPruned(uint64(number))
+	}
+	return block, nil
 }
 
 func (b *HykAPIBackend) BlockByHash(ctx context.Context, hash common.Hash) (*types.Block, error) {
-	return b.hyk.blockchain.GetBlockByHash(hash), nil
+	block := b.hyk.blockchain.GetBlockByHash(hash)
+	if block == nil {
+		if header := b.hyk.blockchain.GetHeaderByHash(hash); header != nil {
+			return nil, b.historyPruned(header.Number.Uint64())
+		}
+	}
+	return block, nil
+}
+
+// historyPruned returns core.ErrHistoryPruned if the body and receipts of the
+// block with the given number were discarded by history pruning.
+func (b *HykAPIBackend) historyPruned(number uint64) error {
+	if number < b.hyk.blockchain.HistoryTail() {
+		return core.ErrHistoryPruned
+	}
+	return nil
 }
 
 func (b *HykAPIBackend) BlockByNumberOrHash(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (*types.Block, error) {
@@ -125,6 +144,9 @@ func (b *HykAPIBackend) BlockByNumberOrHash(ctx context.Context, blockNrOrHash r
 		}
 		block := b.hyk.blockchain.GetBlock(hash, header.Number.Uint64())
 		if block == nil {
+			if err := b.historyPruned(header.Number.Uint64()); err != nil {
+				return nil, err
+			}
 			return nil, errors.New("header found, but block body is missing")
 		}
 		return block, nil
@@ -172,13 +194,19 @@ func (b *HykAPIBackend) StateAndHeaderByNumberOrHash(ctx context.Context, blockN
 }
 
 func (b *HykAPIBackend) GetReceipts(ctx context.Context, hash common.Hash) (types.Receipts, error) {
-	return b.hyk.blockchain.GetReceiptsByHash(hash), nil
+	receipts := b.hyk.blockchain.GetReceiptsByHash(hash)
+	if receipts == nil {
+		if header := b.hyk.blockchain.GetHeaderByHash(hash); header != nil {
+			return nil, b.historyPruned(header.Number.Uint64())
+		}
+	}
+	return receipts, nil
 }
 
 func (b *HykAPIBackend) GetLogs(ctx context.Context, hash common.Hash) ([][]*types.Log, error) {
-	receipts := b.hyk.blockchain.GetReceiptsByHash(hash)
+	receipts, err := b.GetReceipts(ctx, hash)
 	if receipts == nil {
-		return nil, nil
+		return nil, err
 	}
 	logs := make([][]*types.Log, len(receipts))
 	for i, receipt := range receipts {
@@ -191,12 +219,14 @@ func (b *HykAPIBackend) GetTd(ctx context.Context, hash common.Hash) *big.Int {
 	return b.hyk.blockchain.GetTdByHash(hash)
 }
 
-func (b *HykAPIBackend) GetEVM(ctx context.Context, msg core.Message, state *state.StateDB, header *types.Header) (*vm.EVM, func() error, error) {
+func (b *HykAPIBackend) GetEVM(ctx context.Context, msg core.Message, state *state.StateDB, header *types.Header, vmConfig *vm.Config) (*vm.EVM, func() error, error) {
 	vmError := func() error { return nil }
-
+	if vmConfig == nil {
+		vmConfig = b.hyk.blockchain.GetVMConfig()
+	}
 	txContext := core.NewEVMTxContext(msg)
 	context := core.NewEVMBlockContext(header, b.hyk.BlockChain(), nil)
-	return vm.NewEVM(context, txContext, state, b.hyk.blockchain.Config(), *b.hyk.blockchain.GetVMConfig()), vmError, nil
+	return vm.NewEVM(context, txContext, state, b.hyk.blockchain.Config(), *vmConfig), vmError, nil
 }
 
 func (b *HykAPIBackend) SubscribeRemovedLogsEvent(ch chan<- core.RemovedLogsEvent) event.Subscription {
@@ -227,6 +257,18 @@ func (b *HykAPIBackend) SendTx(ctx context.Context, signedTx *types.Transaction)
 	return b.hyk.txPool.AddLocal(signedTx)
 }
 
+func (b *HykAPIBackend) SendPrivateTx(ctx context.Context, signedTx *types.Transaction, deadline uint64) error {
+	return b.hyk.txPool.AddPrivate(signedTx, deadline)
+}
+
+func (b *HykAPIBackend) CancelPrivateTx(ctx context.Context, txHash common.Hash) (bool, error) {
+	return b.hyk.txPool.RemovePrivate(txHash), nil
+}
+
+func (b *HykAPIBackend) SendBundle(ctx context.Context, bundle *core.TxBundle) error {
+	return b.hyk.txPool.AddBundle(bundle)
+}
+
 func (b *HykAPIBackend) GetPoolTransactions() (types.Transactions, error) {
 	pending, err := b.hyk.txPool.Pending()
 	if err != nil {
@@ -280,6 +322,14 @@ func (b *HykAPIBackend) SuggestPrice(ctx context.Context) (*big.Int, error) {
 	return b.gpo.SuggestPrice(ctx)
 }
 
+func (b *HykAPIBackend) SuggestGasTipCap(ctx context.Context) (*big.Int, error) {
+	return b.gpo.SuggestTipCap(ctx)
+}
+
+func (b *HykAPIBackend) FeeHistory(ctx context.Context, blockCount int, lastBlock rpc.BlockNumber, rewardPercentiles []float64) (*big.Int, [][]*big.Int, []*big.Int, []float64, error) {
+	return b.gpo.FeeHistory(ctx, blockCount, lastBlock, rewardPercentiles)
+}
+
 func (b *HykAPIBackend) ChainDb() hykdb.Database {
 	return b.hyk.ChainDb()
 }
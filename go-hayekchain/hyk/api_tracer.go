@@ -149,7 +149,7 @@ func (api *PrivateDebugAPI) traceChain(ctx context.Context, start, end *types.Bl
 
 	// Ensure we have a valid starting state before doing any work
 	origin := start.NumberU64()
-	database := state.NewDatabaseWithConfig(api.hyk.ChainDb(), &trie.Config{Cache: 16, Preimages: true})
+	database := state.NewDatabaseWithConfig(api.hyk.ChainDb(), &trie.Config{Cache: 16, Preimages: true, Binary: api.hyk.blockchain.Config().BinaryTrie})
 
 	if number := start.NumberU64(); number > 0 {
 		start = api.hyk.blockchain.GetBlock(start.ParentHash(), start.NumberU64()-1)
@@ -207,7 +207,7 @@ func (api *PrivateDebugAPI) traceChain(ctx context.Context, start, end *types.Bl
 				blockCtx := core.NewEVMBlockContext(task.block.Header(), api.hyk.blockchain, nil)
 				// Trace all the transactions contained within
 				for i, tx := range task.block.Transactions() {
-					msg, _ := tx.AsMessage(signer)
+					msg, _ := tx.AsMessage(signer, task.block.BaseFee())
 					res, err := api.traceTx(ctx, msg, blockCtx, task.statedb, config)
 					if err != nil {
 						task.results[i] = &txTraceResult{Error: err.Error()}
@@ -479,7 +479,7 @@ func (api *PrivateDebugAPI) traceBlock(ctx context.Context, block *types.Block,
 			defer pend.Done()
 			// Fetch and execute the next transaction trace tasks
 			for task := range jobs {
-				msg, _ := txs[task.index].AsMessage(signer)
+				msg, _ := txs[task.index].AsMessage(signer, block.BaseFee())
 				res, err := api.traceTx(ctx, msg, blockCtx, task.statedb, config)
 				if err != nil {
 					results[task.index] = &txTraceResult{Error: err.Error()}
@@ -496,7 +496,7 @@ func (api *PrivateDebugAPI) traceBlock(ctx context.Context, block *types.Block,
 		jobs <- &txTraceTask{statedb: statedb.Copy(), index: i}
 
 		// Generate the next state snapshot fast without tracing
-		msg, _ := tx.AsMessage(signer)
+		msg, _ := tx.AsMessage(signer, block.BaseFee())
 		txContext := core.NewEVMTxContext(msg)
 
 		vmenv := vm.NewEVM(blockCtx, txContext, statedb, api.hyk.blockchain.Config(), vm.Config{})
@@ -583,7 +583,7 @@ func (api *PrivateDebugAPI) standardTraceBlockToFile(ctx context.Context, block
 	for i, tx := range block.Transactions() {
 		// Prepare the trasaction for un-traced execution
 		var (
-			msg, _    = tx.AsMessage(signer)
+			msg, _    = tx.AsMessage(signer, block.BaseFee())
 			txContext = core.NewEVMTxContext(msg)
 			vmConf    vm.Config
 			dump      *os.File
@@ -658,7 +658,7 @@ func (api *PrivateDebugAPI) computeStateDB(block *types.Block, reexec uint64) (*
 	}
 	// Otherwise try to reexec blocks until we find a state or reach our limit
 	origin := block.NumberU64()
-	database := state.NewDatabaseWithConfig(api.hyk.ChainDb(), &trie.Config{Cache: 16, Preimages: true})
+	database := state.NewDatabaseWithConfig(api.hyk.ChainDb(), &trie.Config{Cache: 16, Preimages: true, Binary: api.hyk.blockchain.Config().BinaryTrie})
 
 	for i := uint64(0); i < reexec; i++ {
 		block = api.hyk.blockchain.GetBlock(block.ParentHash(), block.NumberU64()-1)
@@ -770,7 +770,10 @@ func (api *PrivateDebugAPI) TraceCall(ctx context.Context, args hykapi.CallArgs,
 	}
 
 	// Execute the trace
-	msg := args.ToMessage(api.hyk.APIBackend.RPCGasCap())
+	msg, err := args.ToMessage(api.hyk.APIBackend.RPCGasCap(), header.BaseFee)
+	if err != nil {
+		return nil, err
+	}
 	vmctx := core.NewEVMBlockContext(header, api.hyk.blockchain, nil)
 	return api.traceTx(ctx, msg, vmctx, statedb, config)
 }
@@ -813,7 +816,7 @@ func (api *PrivateDebugAPI) traceTx(ctx context.Context, message core.Message, v
 		tracer = vm.NewStructLogger(config.LogConfig)
 	}
 	// Run the transaction with tracing enabled.
-	vmenv := vm.NewEVM(vmctx, txContext, statedb, api.hyk.blockchain.Config(), vm.Config{Debug: true, Tracer: tracer})
+	vmenv := vm.NewEVM(vmctx, txContext, statedb, api.hyk.blockchain.Config(), vm.Config{Debug: true, Tracer: tracer, NoBaseFee: true})
 
 	result, err := core.ApplyMessage(vmenv, message, new(core.GasPool).AddGas(message.Gas()))
 	if err != nil {
@@ -863,7 +866,7 @@ func (api *PrivateDebugAPI) computeTxEnv(block *types.Block, txIndex int, reexec
 
 	for idx, tx := range block.Transactions() {
 		// Assemble the transaction call message and return if the requested offset
-		msg, _ := tx.AsMessage(signer)
+		msg, _ := tx.AsMessage(signer, block.BaseFee())
 		txContext := core.NewEVMTxContext(msg)
 		context := core.NewEVMBlockContext(block.Header(), api.hyk.blockchain, nil)
 		if idx == txIndex {
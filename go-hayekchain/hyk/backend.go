@@ -34,12 +34,14 @@ import (
 	"github.com/hayekchain/go-hayekchain/core"
 	"github.com/hayekchain/go-hayekchain/core/bloombits"
 	"github.com/hayekchain/go-hayekchain/core/rawdb"
+	"github.com/hayekchain/go-hayekchain/core/state/pruner"
 	"github.com/hayekchain/go-hayekchain/core/types"
 	"github.com/hayekchain/go-hayekchain/core/vm"
 	"github.com/hayekchain/go-hayekchain/event"
 	"github.com/hayekchain/go-hayekchain/hyk/downloader"
 	"github.com/hayekchain/go-hayekchain/hyk/filters"
 	"github.com/hayekchain/go-hayekchain/hyk/gasprice"
+	"github.com/hayekchain/go-hayekchain/hyk/protocols/snap"
 	"github.com/hayekchain/go-hayekchain/hykdb"
 	"github.com/hayekchain/go-hayekchain/internal/hykapi"
 	"github.com/hayekchain/go-hayekchain/log"
@@ -98,10 +100,19 @@ func New(stack *node.Node, config *Config) (*HayekChain, error) {
 	if !config.SyncMode.IsValid() {
 		return nil, fmt.Errorf("invalid sync mode %d", config.SyncMode)
 	}
+	if config.SyncMode == downloader.SnapSync && config.SnapshotCache == 0 {
+		return nil, errors.New("snap sync requires snapshots, set a snapshot cache")
+	}
 	if config.Miner.GasPrice == nil || config.Miner.GasPrice.Cmp(common.Big0) <= 0 {
 		log.Warn("Sanitizing invalid miner gas price", "provided", config.Miner.GasPrice, "updated", DefaultConfig.Miner.GasPrice)
 		config.Miner.GasPrice = new(big.Int).Set(DefaultConfig.Miner.GasPrice)
 	}
+	// History can only be pruned from the freezer, which holds data older than the
+	// immutability threshold
+	if config.HistoryRetention > 0 && config.HistoryRetention < params.FullImmutabilityThreshold {
+		log.Warn("Sanitizing history retention below immutability threshold", "provided", config.HistoryRetention, "updated", params.FullImmutabilityThreshold)
+		config.HistoryRetention = params.FullImmutabilityThreshold
+	}
 	if config.NoPruning && config.TrieDirtyCache > 0 {
 		if config.SnapshotCache > 0 {
 			config.TrieCleanCache += config.TrieDirtyCache * 3 / 5
@@ -124,6 +135,10 @@ func New(stack *node.Node, config *Config) (*HayekChain, error) {
 	}
 	log.Info("Initialised chain configuration", "config", chainConfig)
 
+	// Resume an interrupted offline state pruning before touching the state
+	if err := pruner.RecoverPruning(stack.ResolvePath(""), chainDb, stack.ResolvePath(config.TrieCleanCacheJournal)); err != nil {
+		log.Error("Failed to recover state", "error", err)
+	}
 	hyk := &HayekChain{
 		config:            config,
 		chainDb:           chainDb,
@@ -170,6 +185,9 @@ func New(stack *node.Node, config *Config) (*HayekChain, error) {
 			TrieTimeLimit:       config.TrieTimeout,
 			SnapshotLimit:       config.SnapshotCache,
 			Preimages:           config.Preimages,
+			HistoryRetention:    config.HistoryRetention,
+			ParallelExecution:   config.ParallelExecution,
+			StateDiffs:          config.StateDiffs,
 		}
 	)
 	hyk.blockchain, err = core.NewBlockChain(chainDb, cacheConfig, chainConfig, hyk.engine, vmConfig, hyk.shouldPreserve, &config.TxLookupLimit)
@@ -187,6 +205,9 @@ func New(stack *node.Node, config *Config) (*HayekChain, error) {
 	if config.TxPool.Journal != "" {
 		config.TxPool.Journal = stack.ResolvePath(config.TxPool.Journal)
 	}
+	if config.TxPool.RemoteJournal != "" {
+		config.TxPool.RemoteJournal = stack.ResolvePath(config.TxPool.RemoteJournal)
+	}
 	hyk.txPool = core.NewTxPool(config.TxPool, chainConfig, hyk.blockchain)
 
 	// Permit the downloader to use the trie cache allowance during fast sync
@@ -259,14 +280,17 @@ func CreateConsensusEngine(stack *node.Node, chainConfig *params.ChainConfig, co
 		return hykash.NewShared()
 	default:
 		engine := hykash.New(hykash.Config{
-			CacheDir:         stack.ResolvePath(config.CacheDir),
-			CachesInMem:      config.CachesInMem,
-			CachesOnDisk:     config.CachesOnDisk,
-			CachesLockMmap:   config.CachesLockMmap,
-			DatasetDir:       config.DatasetDir,
-			DatasetsInMem:    config.DatasetsInMem,
-			DatasetsOnDisk:   config.DatasetsOnDisk,
-			DatasetsLockMmap: config.DatasetsLockMmap,
+			CacheDir:          stack.ResolvePath(config.CacheDir),
+			CachesInMem:       config.CachesInMem,
+			CachesOnDisk:      config.CachesOnDisk,
+			CachesLockMmap:    config.CachesLockMmap,
+			DatasetDir:        config.DatasetDir,
+			DatasetsInMem:     config.DatasetsInMem,
+			DatasetsOnDisk:    config.DatasetsOnDisk,
+			DatasetsLockMmap:  config.DatasetsLockMmap,
+			PowSwitches:       chainConfig.Hayekash,
+			StratumAddr:       config.StratumAddr,
+			StratumDifficulty: config.StratumDifficulty,
 		}, notify, noverify)
 		engine.SetThreads(-1) // Disable CPU mining
 		return engine
@@ -506,6 +530,9 @@ func (s *HayekChain) Protocols() []p2p.Protocol {
 		protos[i].Attributes = []enr.Entry{s.currentHykEntry()}
 		protos[i].DialCandidates = s.dialCandidates
 	}
+	if s.config.SnapshotCache > 0 {
+		protos = append(protos, snap.MakeProtocols((*snapHandler)(s.protocolManager))...)
+	}
 	return protos
 }
 
@@ -51,13 +51,14 @@ var DefaultLightGPOConfig = gasprice.Config{
 var DefaultConfig = Config{
 	SyncMode: downloader.FastSync,
 	Hayekash: hykash.Config{
-		CacheDir:         "hykash",
-		CachesInMem:      2,
-		CachesOnDisk:     3,
-		CachesLockMmap:   false,
-		DatasetsInMem:    1,
-		DatasetsOnDisk:   2,
-		DatasetsLockMmap: false,
+		CacheDir:          "hykash",
+		CachesInMem:       2,
+		CachesOnDisk:      3,
+		CachesLockMmap:    false,
+		DatasetsInMem:     1,
+		DatasetsOnDisk:    2,
+		DatasetsLockMmap:  false,
+		StratumDifficulty: 1,
 	},
 	NetworkId:               1,
 	LightPeers:              100,
@@ -120,8 +121,20 @@ type Config struct {
 	NoPruning  bool // Whhyker to disable pruning and flush everything to disk
 	NoPrefetch bool // Whhyker to disable prefetching and only load state on demand
 
+	// ParallelExecution enables optimistic parallel execution of the transactions
+	// in imported blocks.
+	ParallelExecution bool `toml:",omitempty"`
+
+	// StateDiffs enables recording reverse state diffs of every block, used to
+	// serve historic state without keeping the archive tries.
+	StateDiffs bool `toml:",omitempty"`
+
 	TxLookupLimit uint64 `toml:",omitempty"` // The maximum number of blocks from head whose tx indices are reserved.
 
+	// HistoryRetention is the number of recent blocks whose bodies and receipts
+	// are retained, older ones are pruned from the freezer (0 = keep all).
+	HistoryRetention uint64 `toml:",omitempty"`
+
 	// Whitelist of required block number -> hash values to accept
 	Whitelist map[uint64]common.Hash `toml:"-"`
 
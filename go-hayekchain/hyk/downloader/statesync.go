@@ -17,6 +17,7 @@
 package downloader
 
 import (
+	"crypto/sha256"
 	"fmt"
 	"hash"
 	"sync"
@@ -254,6 +255,7 @@ type stateSync struct {
 
 	sched  *trie.Sync // State trie sync scheduler defining the tasks
 	keccak hash.Hash  // Keccak256 hasher to verify deliveries with
+	binary bool       // Whether the state is stored in binary tries, hashed with SHA256
 
 	trieTasks map[common.Hash]*trieTask // Set of trie node tasks currently queued for retrieval
 	codeTasks map[common.Hash]*codeTask // Set of byte code tasks currently queued for retrieval
@@ -288,10 +290,19 @@ type codeTask struct {
 // newStateSync creates a new state trie download scheduler. This method does not
 // yet start the sync. The user needs to call run to initiate.
 func newStateSync(d *Downloader, root common.Hash) *stateSync {
+	var binary bool
+	if config := rawdb.ReadChainConfig(d.stateDB, rawdb.ReadCanonicalHash(d.stateDB, 0)); config != nil {
+		binary = config.BinaryTrie
+	}
+	sched := state.NewStateSync(root, d.stateDB, d.stateBloom)
+	if binary {
+		sched = state.NewBinaryStateSync(root, d.stateDB, d.stateBloom)
+	}
 	return &stateSync{
 		d:         d,
-		sched:     state.NewStateSync(root, d.stateDB, d.stateBloom),
+		sched:     sched,
 		keccak:    sha3.NewLegacyKeccak256(),
+		binary:    binary,
 		trieTasks: make(map[common.Hash]*trieTask),
 		codeTasks: make(map[common.Hash]*codeTask),
 		deliver:   make(chan *stateReq),
@@ -306,7 +317,12 @@ func newStateSync(d *Downloader, root common.Hash) *stateSync {
 // it finishes, and finally notifying any goroutines waiting for the loop to
 // finish.
 func (s *stateSync) run() {
-	s.err = s.loop()
+	close(s.started)
+	if s.d.snapSync {
+		s.err = s.d.SnapSyncer.Sync(s.root, s.cancel)
+	} else {
+		s.err = s.loop()
+	}
 	close(s.done)
 }
 
@@ -329,7 +345,6 @@ func (s *stateSync) Cancel() error {
 // pushed here async. The reason is to decouple processing from data receipt
 // and timeouts.
 func (s *stateSync) loop() (err error) {
-	close(s.started)
 	// Listen for new peer events to assign tasks to them
 	newPeer := make(chan *peerConnection, 1024)
 	peerSub := s.d.peers.SubscribeNewPeers(newPeer)
@@ -579,6 +594,11 @@ func (s *stateSync) processNodeData(blob []byte) (common.Hash, error) {
 	s.keccak.Write(blob)
 	s.keccak.Sum(res.Hash[:0])
 	err := s.sched.Process(res)
+	if err == trie.ErrNotRequested && s.binary {
+		// Binary trie nodes are keyed by their SHA256 hash instead
+		res.Hash = sha256.Sum256(blob)
+		err = s.sched.Process(res)
+	}
 	return res.Hash, err
 }
 
@@ -0,0 +1,174 @@
+// Copyright 2021 The go-hayekchain Authors
+// This file is part of the go-hayekchain library.
+//
+// The go-hayekchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hayekchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hayekchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package gasprice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/hayekchain/go-hayekchain/consensus/misc"
+	"github.com/hayekchain/go-hayekchain/core/types"
+	"github.com/hayekchain/go-hayekchain/rpc"
+)
+
+// maxFeeHistory is the maximum number of blocks that can be retrieved for a
+// fee history request.
+const maxFeeHistory = 1024
+
+var (
+	errInvalidPercentile = errors.New("invalid reward percentile")
+	errRequestBeyondHead = errors.New("request beyond head block")
+)
+
+// txGasAndReward is a sortable pair of the gas used and the effective tip
+// paid by a single transaction.
+type txGasAndReward struct {
+	gasUsed uint64
+	reward  *big.Int
+}
+
+type sortGasAndReward []txGasAndReward
+
+func (s sortGasAndReward) Len() int           { return len(s) }
+func (s sortGasAndReward) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s sortGasAndReward) Less(i, j int) bool { return s[i].reward.Cmp(s[j].reward) < 0 }
+
+// FeeHistory returns data relevant for fee estimation based on the specified
+// range of blocks ending at lastBlock. The range can be shorter than requested
+// if the start of the chain is reached. The returned values are:
+//   - oldest: the number of the first block in the returned range
+//   - reward: the requested percentiles of effective tips paid in each block,
+//     weighted by the gas used by each transaction
+//   - baseFee: the base fee of each block, plus the base fee of the block
+//     following the range (zero before the London fork)
+//   - gasUsedRatio: gasUsed/gasLimit of each block
+func (gpo *Oracle) FeeHistory(ctx context.Context, blocks int, lastBlock rpc.BlockNumber, rewardPercentiles []float64) (*big.Int, [][]*big.Int, []*big.Int, []float64, error) {
+	if blocks < 1 {
+		return nil, nil, nil, nil, nil
+	}
+	if blocks > maxFeeHistory {
+		blocks = maxFeeHistory
+	}
+	for i, p := range rewardPercentiles {
+		if p < 0 || p > 100 {
+			return nil, nil, nil, nil, fmt.Errorf("%w: %f", errInvalidPercentile, p)
+		}
+		if i > 0 && p < rewardPercentiles[i-1] {
+			return nil, nil, nil, nil, fmt.Errorf("%w: #%d:%f > #%d:%f", errInvalidPercentile, i-1, rewardPercentiles[i-1], i, p)
+		}
+	}
+	head, err := gpo.backend.HeaderByNumber(ctx, rpc.LatestBlockNumber)
+	if err != nil || head == nil {
+		return nil, nil, nil, nil, err
+	}
+	last := head.Number.Uint64()
+	if lastBlock >= 0 {
+		if uint64(lastBlock) > last {
+			return nil, nil, nil, nil, fmt.Errorf("%w: requested %d, head %d", errRequestBeyondHead, lastBlock, last)
+		}
+		last = uint64(lastBlock)
+	}
+	if uint64(blocks) > last+1 {
+		blocks = int(last + 1)
+	}
+	oldest := last + 1 - uint64(blocks)
+
+	var (
+		reward       = make([][]*big.Int, 0, blocks)
+		baseFee      = make([]*big.Int, 0, blocks+1)
+		gasUsedRatio = make([]float64, 0, blocks)
+		config       = gpo.backend.ChainConfig()
+		lastHeader   *types.Header
+	)
+	for number := oldest; number <= last; number++ {
+		header, err := gpo.backend.HeaderByNumber(ctx, rpc.BlockNumber(number))
+		if err != nil {
+			return nil, nil, nil, nil, err
+		}
+		if header == nil {
+			return nil, nil, nil, nil, fmt.Errorf("missing header #%d", number)
+		}
+		if header.BaseFee != nil {
+			baseFee = append(baseFee, new(big.Int).Set(header.BaseFee))
+		} else {
+			baseFee = append(baseFee, new(big.Int))
+		}
+		gasUsedRatio = append(gasUsedRatio, float64(header.GasUsed)/float64(header.GasLimit))
+
+		if len(rewardPercentiles) > 0 {
+			rewards, err := gpo.blockRewards(ctx, header, rewardPercentiles)
+			if err != nil {
+				return nil, nil, nil, nil, err
+			}
+			reward = append(reward, rewards)
+		}
+		lastHeader = header
+	}
+	// Append the base fee of the block following the range
+	if config.IsLondon(new(big.Int).Add(lastHeader.Number, big.NewInt(1))) {
+		baseFee = append(baseFee, misc.CalcBaseFee(config, lastHeader))
+	} else {
+		baseFee = append(baseFee, new(big.Int))
+	}
+	if len(rewardPercentiles) == 0 {
+		reward = nil
+	}
+	return new(big.Int).SetUint64(oldest), reward, baseFee, gasUsedRatio, nil
+}
+
+// blockRewards calculates the requested percentiles of the effective tips paid
+// by the transactions of a block, weighted by the gas they used.
+func (gpo *Oracle) blockRewards(ctx context.Context, header *types.Header, percentiles []float64) ([]*big.Int, error) {
+	rewards := make([]*big.Int, len(percentiles))
+	block, err := gpo.backend.BlockByNumber(ctx, rpc.BlockNumber(header.Number.Uint64()))
+	if err != nil {
+		return nil, err
+	}
+	if block == nil || len(block.Transactions()) == 0 {
+		for i := range rewards {
+			rewards[i] = new(big.Int)
+		}
+		return rewards, nil
+	}
+	receipts, err := gpo.backend.GetReceipts(ctx, block.Hash())
+	if err != nil {
+		return nil, err
+	}
+	if len(receipts) != len(block.Transactions()) {
+		return nil, fmt.Errorf("receipt count mismatch for block #%d: have %d, want %d", header.Number, len(receipts), len(block.Transactions()))
+	}
+	sorter := make(sortGasAndReward, len(block.Transactions()))
+	for i, tx := range block.Transactions() {
+		sorter[i] = txGasAndReward{gasUsed: receipts[i].GasUsed, reward: tx.EffectiveGasTipValue(block.BaseFee())}
+	}
+	sort.Sort(sorter)
+
+	var txIndex int
+	sumGasUsed := sorter[0].gasUsed
+	for i, p := range percentiles {
+		thresholdGasUsed := uint64(float64(block.GasUsed()) * p / 100)
+		for sumGasUsed < thresholdGasUsed && txIndex < len(sorter)-1 {
+			txIndex++
+			sumGasUsed += sorter[txIndex].gasUsed
+		}
+		rewards[i] = sorter[txIndex].reward
+	}
+	return rewards, nil
+}
@@ -0,0 +1,95 @@
+// Copyright 2021 The go-hayekchain Authors
+// This file is part of the go-hayekchain library.
+//
+// The go-hayekchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hayekchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hayekchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package gasprice
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/hayekchain/go-hayekchain/params"
+	"github.com/hayekchain/go-hayekchain/rpc"
+)
+
+func TestFeeHistory(t *testing.T) {
+	var cases = []struct {
+		fork        *big.Int
+		count       int
+		last        rpc.BlockNumber
+		percent     []float64
+		expFirst    uint64
+		expCount    int
+		expBaseFees bool
+		expErr      error
+	}{
+		{nil, 10, 30, nil, 21, 10, false, nil},
+		{nil, 10, 30, []float64{0, 10}, 21, 10, false, nil},
+		{nil, 10, 30, []float64{20, 10}, 0, 0, false, errInvalidPercentile},
+		{nil, 40, rpc.LatestBlockNumber, nil, 0, 33, false, nil},
+		{nil, 1, 40, nil, 0, 0, false, errRequestBeyondHead},
+		{big.NewInt(0), 10, rpc.LatestBlockNumber, []float64{50}, 23, 10, true, nil},
+		{big.NewInt(16), 4, 15, []float64{50}, 12, 4, false, nil},
+	}
+	for i, c := range cases {
+		backend := newTestBackend(t, c.fork)
+		oracle := NewOracle(backend, Config{Blocks: 3, Percentile: 60, Default: big.NewInt(params.GWei)})
+
+		first, reward, baseFee, ratio, err := oracle.FeeHistory(context.Background(), c.count, c.last, c.percent)
+		if c.expErr != nil {
+			if !errors.Is(err, c.expErr) {
+				t.Errorf("test %d: error mismatch, want %v, have %v", i, c.expErr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("test %d: unexpected error: %v", i, err)
+		}
+		if first.Uint64() != c.expFirst {
+			t.Errorf("test %d: first block mismatch, want %d, have %d", i, c.expFirst, first)
+		}
+		if len(ratio) != c.expCount {
+			t.Errorf("test %d: gasUsedRatio length mismatch, want %d, have %d", i, c.expCount, len(ratio))
+		}
+		if len(baseFee) != c.expCount+1 {
+			t.Errorf("test %d: baseFee length mismatch, want %d, have %d", i, c.expCount+1, len(baseFee))
+		}
+		if c.expBaseFees != (baseFee[0].Sign() > 0) {
+			t.Errorf("test %d: base fee presence mismatch, want %v, have %v", i, c.expBaseFees, baseFee[0])
+		}
+		if len(c.percent) == 0 {
+			if reward != nil {
+				t.Errorf("test %d: unexpected rewards", i)
+			}
+			continue
+		}
+		if len(reward) != c.expCount {
+			t.Fatalf("test %d: reward length mismatch, want %d, have %d", i, c.expCount, len(reward))
+		}
+		// Every block holds a single transaction tipping (number) gwei, the
+		// genesis block is empty.
+		for j, r := range reward {
+			number := c.expFirst + uint64(j)
+			want := new(big.Int).Mul(new(big.Int).SetUint64(number), big.NewInt(params.GWei))
+			for k := range r {
+				if r[k].Cmp(want) != 0 {
+					t.Errorf("test %d: block %d reward %d mismatch, want %d, have %d", i, number, k, want, r[k])
+				}
+			}
+		}
+	}
+}
@@ -44,6 +44,7 @@ type Config struct {
 type OracleBackend interface {
 	HeaderByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Header, error)
 	BlockByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Block, error)
+	GetReceipts(ctx context.Context, hash common.Hash) (types.Receipts, error)
 	ChainConfig() *params.ChainConfig
 }
 
@@ -93,8 +94,24 @@ func NewOracle(backend OracleBackend, params Config) *Oracle {
 }
 
 // SuggestPrice returns a gasprice so that newly created transaction can
-// have a very high chance to be included in the following blocks.
+// have a very high chance to be included in the following blocks. After the
+// London fork the suggestion is the tip cap on top of the latest base fee.
 func (gpo *Oracle) SuggestPrice(ctx context.Context) (*big.Int, error) {
+	tip, err := gpo.SuggestTipCap(ctx)
+	if err != nil {
+		return tip, err
+	}
+	head, _ := gpo.backend.HeaderByNumber(ctx, rpc.LatestBlockNumber)
+	if head != nil && head.BaseFee != nil {
+		tip = new(big.Int).Add(tip, head.BaseFee)
+	}
+	return tip, nil
+}
+
+// SuggestTipCap returns a tip cap (priority fee) so that newly created
+// transaction can have a very high chance to be included in the following
+// blocks. Before the London fork the whole gas price is treated as the tip.
+func (gpo *Oracle) SuggestTipCap(ctx context.Context) (*big.Int, error) {
 	head, _ := gpo.backend.HeaderByNumber(ctx, rpc.LatestBlockNumber)
 	headHash := head.Hash()
 
@@ -173,13 +190,18 @@ type getBlockPricesResult struct {
 	err    error
 }
 
-type transactionsByGasPrice []*types.Transaction
+type transactionsByGasPrice struct {
+	txs     []*types.Transaction
+	baseFee *big.Int
+}
 
-func (t transactionsByGasPrice) Len() int           { return len(t) }
-func (t transactionsByGasPrice) Swap(i, j int)      { t[i], t[j] = t[j], t[i] }
-func (t transactionsByGasPrice) Less(i, j int) bool { return t[i].GasPriceCmp(t[j]) < 0 }
+func (t transactionsByGasPrice) Len() int      { return len(t.txs) }
+func (t transactionsByGasPrice) Swap(i, j int) { t.txs[i], t.txs[j] = t.txs[j], t.txs[i] }
+func (t transactionsByGasPrice) Less(i, j int) bool {
+	return t.txs[i].EffectiveGasTipCmp(t.txs[j], t.baseFee) < 0
+}
 
-// getBlockPrices calculates the lowest transaction gas price in a given block
+// getBlockPrices calculates the lowest transaction effective tip in a given block
 // and sends it to the result channel. If the block is empty or all transactions
 // are sent by the miner itself(it doesn't make any sense to include this kind of
 // transaction prices for sampling), nil gasprice is returned.
@@ -195,13 +217,17 @@ func (gpo *Oracle) getBlockPrices(ctx context.Context, signer types.Signer, bloc
 	blockTxs := block.Transactions()
 	txs := make([]*types.Transaction, len(blockTxs))
 	copy(txs, blockTxs)
-	sort.Sort(transactionsByGasPrice(txs))
+	sort.Sort(transactionsByGasPrice{txs, block.BaseFee()})
 
 	var prices []*big.Int
 	for _, tx := range txs {
+		tip, err := tx.EffectiveGasTip(block.BaseFee())
+		if err != nil {
+			continue
+		}
 		sender, err := types.Sender(signer, tx)
 		if err == nil && sender != block.Coinbase() {
-			prices = append(prices, tx.GasPrice())
+			prices = append(prices, tip)
 			if len(prices) >= limit {
 				break
 			}
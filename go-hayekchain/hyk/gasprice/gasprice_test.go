@@ -51,28 +51,60 @@ func (b *testBackend) BlockByNumber(ctx context.Context, number rpc.BlockNumber)
 	return b.chain.GetBlockByNumber(uint64(number)), nil
 }
 
+func (b *testBackend) GetReceipts(ctx context.Context, hash common.Hash) (types.Receipts, error) {
+	return b.chain.GetReceiptsByHash(hash), nil
+}
+
 func (b *testBackend) ChainConfig() *params.ChainConfig {
 	return b.chain.Config()
 }
 
-func newTestBackend(t *testing.T) *testBackend {
+// newTestBackend creates a chain of 32 blocks, each containing a single
+// transaction paying a tip of (number) gwei. If londonBlock is non-nil, the
+// London fork is activated at that block and dynamic fee transactions are
+// used from then on.
+func newTestBackend(t *testing.T, londonBlock *big.Int) *testBackend {
 	var (
 		key, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
 		addr   = crypto.PubkeyToAddress(key.PublicKey)
+		config = *params.TestChainConfig
 		gspec  = &core.Genesis{
-			Config: params.TestChainConfig,
+			Config: &config,
 			Alloc:  core.GenesisAlloc{addr: {Balance: big.NewInt(math.MaxInt64)}},
 		}
-		signer = types.NewEIP155Signer(gspec.Config.ChainID)
 	)
+	config.YoloV2Block = londonBlock
+	config.LondonBlock = londonBlock
+	signer := types.LatestSigner(gspec.Config)
+
 	engine := hykash.NewFaker()
 	db := rawdb.NewMemoryDatabase()
 	genesis, _ := gspec.Commit(db)
 
 	// Generate testing blocks
-	blocks, _ := core.GenerateChain(params.TestChainConfig, genesis, engine, db, 32, func(i int, b *core.BlockGen) {
+	blocks, _ := core.GenerateChain(gspec.Config, genesis, engine, db, 32, func(i int, b *core.BlockGen) {
 		b.SetCoinbase(common.Address{1})
-		tx, err := types.SignTx(types.NewTransaction(b.TxNonce(addr), common.HexToAddress("deadbeef"), big.NewInt(100), 21000, big.NewInt(int64(i+1)*params.GWei), nil), signer, key)
+
+		var txdata types.TxData
+		if baseFee := b.BaseFee(); baseFee != nil {
+			txdata = &types.DynamicFeeTx{
+				ChainID:   gspec.Config.ChainID,
+				Nonce:     b.TxNonce(addr),
+				To:        &common.Address{},
+				Gas:       21000,
+				GasFeeCap: new(big.Int).Add(baseFee, big.NewInt(int64(i+1)*params.GWei)),
+				GasTipCap: big.NewInt(int64(i+1) * params.GWei),
+			}
+		} else {
+			txdata = &types.LegacyTx{
+				Nonce:    b.TxNonce(addr),
+				To:       &common.Address{},
+				Gas:      21000,
+				GasPrice: big.NewInt(int64(i+1) * params.GWei),
+				Value:    big.NewInt(100),
+			}
+		}
+		tx, err := types.SignNewTx(key, signer, txdata)
 		if err != nil {
 			t.Fatalf("failed to create tx: %v", err)
 		}
@@ -81,7 +113,7 @@ func newTestBackend(t *testing.T) *testBackend {
 	// Construct testing chain
 	diskdb := rawdb.NewMemoryDatabase()
 	gspec.Commit(diskdb)
-	chain, err := core.NewBlockChain(diskdb, nil, params.TestChainConfig, engine, vm.Config{}, nil, nil)
+	chain, err := core.NewBlockChain(diskdb, nil, gspec.Config, engine, vm.Config{}, nil, nil)
 	if err != nil {
 		t.Fatalf("Failed to create local chain, %v", err)
 	}
@@ -103,7 +135,7 @@ func TestSuggestPrice(t *testing.T) {
 		Percentile: 60,
 		Default:    big.NewInt(params.GWei),
 	}
-	backend := newTestBackend(t)
+	backend := newTestBackend(t, nil)
 	oracle := NewOracle(backend, config)
 
 	// The gas price sampled is: 32G, 31G, 30G, 29G, 28G, 27G
@@ -116,3 +148,46 @@ func TestSuggestPrice(t *testing.T) {
 		t.Fatalf("Gas price mismatch, want %d, got %d", expect, got)
 	}
 }
+
+func TestSuggestTipCap(t *testing.T) {
+	config := Config{
+		Blocks:     3,
+		Percentile: 60,
+		Default:    big.NewInt(params.GWei),
+	}
+	var cases = []struct {
+		fork   *big.Int // London fork number
+		expect *big.Int // Expected gasprice suggestion
+	}{
+		{nil, big.NewInt(params.GWei * int64(30))},
+		{big.NewInt(0), big.NewInt(params.GWei * int64(30))},  // Fork point in genesis
+		{big.NewInt(1), big.NewInt(params.GWei * int64(30))},  // Fork point in first block
+		{big.NewInt(32), big.NewInt(params.GWei * int64(30))}, // Fork point in last block
+		{big.NewInt(33), big.NewInt(params.GWei * int64(30))}, // Fork point in the future
+	}
+	for _, c := range cases {
+		backend := newTestBackend(t, c.fork)
+		oracle := NewOracle(backend, config)
+
+		// The gas price sampled is: 32G, 31G, 30G, 29G, 28G, 27G
+		got, err := oracle.SuggestTipCap(context.Background())
+		if err != nil {
+			t.Fatalf("Failed to retrieve recommended gas price: %v", err)
+		}
+		if got.Cmp(c.expect) != 0 {
+			t.Fatalf("Gas price mismatch, want %d, got %d", c.expect, got)
+		}
+		// The legacy suggestion should add the head base fee on top
+		price, err := oracle.SuggestPrice(context.Background())
+		if err != nil {
+			t.Fatalf("Failed to retrieve recommended gas price: %v", err)
+		}
+		want := new(big.Int).Set(c.expect)
+		if baseFee := backend.chain.CurrentBlock().BaseFee(); baseFee != nil {
+			want.Add(want, baseFee)
+		}
+		if price.Cmp(want) != 0 {
+			t.Fatalf("Legacy gas price mismatch, want %d, got %d", want, price)
+		}
+	}
+}
@@ -23,7 +23,10 @@ func (c Config) MarshalTOML() (interface{}, error) {
 		DiscoveryURLs           []string
 		NoPruning               bool
 		NoPrefetch              bool
+		ParallelExecution       bool                   `toml:",omitempty"`
+		StateDiffs              bool                   `toml:",omitempty"`
 		TxLookupLimit           uint64                 `toml:",omitempty"`
+		HistoryRetention        uint64                 `toml:",omitempty"`
 		Whitelist               map[uint64]common.Hash `toml:"-"`
 		LightServ               int                    `toml:",omitempty"`
 		LightIngress            int                    `toml:",omitempty"`
@@ -64,7 +67,10 @@ func (c Config) MarshalTOML() (interface{}, error) {
 	enc.DiscoveryURLs = c.DiscoveryURLs
 	enc.NoPruning = c.NoPruning
 	enc.NoPrefetch = c.NoPrefetch
+	enc.ParallelExecution = c.ParallelExecution
+	enc.StateDiffs = c.StateDiffs
 	enc.TxLookupLimit = c.TxLookupLimit
+	enc.HistoryRetention = c.HistoryRetention
 	enc.Whitelist = c.Whitelist
 	enc.LightServ = c.LightServ
 	enc.LightIngress = c.LightIngress
@@ -109,7 +115,10 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 		DiscoveryURLs           []string
 		NoPruning               *bool
 		NoPrefetch              *bool
+		ParallelExecution       *bool                  `toml:",omitempty"`
+		StateDiffs              *bool                  `toml:",omitempty"`
 		TxLookupLimit           *uint64                `toml:",omitempty"`
+		HistoryRetention        *uint64                `toml:",omitempty"`
 		Whitelist               map[uint64]common.Hash `toml:"-"`
 		LightServ               *int                   `toml:",omitempty"`
 		LightIngress            *int                   `toml:",omitempty"`
@@ -165,9 +174,18 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.NoPrefetch != nil {
 		c.NoPrefetch = *dec.NoPrefetch
 	}
+	if dec.ParallelExecution != nil {
+		c.ParallelExecution = *dec.ParallelExecution
+	}
+	if dec.StateDiffs != nil {
+		c.StateDiffs = *dec.StateDiffs
+	}
 	if dec.TxLookupLimit != nil {
 		c.TxLookupLimit = *dec.TxLookupLimit
 	}
+	if dec.HistoryRetention != nil {
+		c.HistoryRetention = *dec.HistoryRetention
+	}
 	if dec.Whitelist != nil {
 		c.Whitelist = dec.Whitelist
 	}
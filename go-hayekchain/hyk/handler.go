@@ -33,6 +33,7 @@ import (
 	"github.com/hayekchain/go-hayekchain/core/types"
 	"github.com/hayekchain/go-hayekchain/hyk/downloader"
 	"github.com/hayekchain/go-hayekchain/hyk/fetcher"
+	"github.com/hayekchain/go-hayekchain/hyk/protocols/snap"
 	"github.com/hayekchain/go-hayekchain/hykdb"
 	"github.com/hayekchain/go-hayekchain/event"
 	"github.com/hayekchain/go-hayekchain/log"
@@ -65,6 +66,7 @@ type ProtocolManager struct {
 	forkFilter forkid.Filter // Fork ID filter, constant across the lifetime of the node
 
 	fastSync  uint32 // Flag whether fast sync is enabled (gets disabled if we already have blocks)
+	snapSync  uint32 // Flag whether snap sync is enabled (gets disabled if we already have blocks)
 	acceptTxs uint32 // Flag whether we're considered synchronised (enables transaction processing)
 
 	checkpointNumber uint64      // Block number for the sync progress validator to cross reference
@@ -80,6 +82,9 @@ type ProtocolManager struct {
 	txFetcher    *fetcher.TxFetcher
 	peers        *peerSet
 
+	snapPeers map[string]*snap.Peer // Peers connected on the `snap` protocol
+	snapLock  sync.RWMutex          // Lock protecting the snap peer set
+
 	eventMux      *event.TypeMux
 	txsCh         chan core.NewTxsEvent
 	txsSub        event.Subscription
@@ -111,6 +116,7 @@ func NewProtocolManager(config *params.ChainConfig, checkpoint *params.TrustedCh
 		blockchain: blockchain,
 		chaindb:    chaindb,
 		peers:      newPeerSet(),
+		snapPeers:  make(map[string]*snap.Peer),
 		whitelist:  whitelist,
 		txsyncCh:   make(chan *txsync),
 		quitSync:   make(chan struct{}),
@@ -137,6 +143,13 @@ func NewProtocolManager(config *params.ChainConfig, checkpoint *params.TrustedCh
 		} else {
 			// If fast sync was requested and our database is empty, grant it
 			manager.fastSync = uint32(1)
+			if mode == downloader.SnapSync {
+				if blockchain.Config().BinaryTrie {
+					log.Warn("Snap sync is not supported with binary state tries, using fast sync")
+				} else {
+					manager.snapSync = uint32(1)
+				}
+			}
 		}
 	}
 
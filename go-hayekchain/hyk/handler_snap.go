@@ -0,0 +1,99 @@
+// Copyright 2021 The go-hayekchain Authors
+// This file is part of the go-hayekchain library.
+//
+// The go-hayekchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hayekchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hayekchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package hyk
+
+import (
+	"fmt"
+
+	"github.com/hayekchain/go-hayekchain/core"
+	"github.com/hayekchain/go-hayekchain/hyk/protocols/snap"
+	"github.com/hayekchain/go-hayekchain/p2p"
+	"github.com/hayekchain/go-hayekchain/p2p/enode"
+)
+
+// snapHandler implements the snap.Backend interface to handle the various network
+// packets that are sent as replies or broadcasts.
+type snapHandler ProtocolManager
+
+// snapPeerInfo represents a short summary of the `snap` sub-protocol metadata known
+// about a connected peer.
+type snapPeerInfo struct {
+	Version uint `json:"version"` // Snapshot protocol version negotiated
+}
+
+// Chain retrieves the blockchain object to serve data.
+func (h *snapHandler) Chain() *core.BlockChain { return h.blockchain }
+
+// RunPeer is invoked when a peer joins on the `snap` protocol.
+func (h *snapHandler) RunPeer(peer *snap.Peer, handler snap.Handler) error {
+	if err := h.registerSnapPeer(peer); err != nil {
+		return err
+	}
+	defer h.unregisterSnapPeer(peer.ID())
+
+	return handler(peer)
+}
+
+// PeerInfo retrieves all known `snap` information about a peer.
+func (h *snapHandler) PeerInfo(id enode.ID) interface{} {
+	h.snapLock.RLock()
+	defer h.snapLock.RUnlock()
+
+	if p := h.snapPeers[fmt.Sprintf("%x", id[:8])]; p != nil {
+		return &snapPeerInfo{Version: p.Version()}
+	}
+	return nil
+}
+
+// Handle is invoked from a peer's message handler when it receives a new remote
+// message that the handler couldn't consume and serve itself.
+func (h *snapHandler) Handle(peer *snap.Peer, packet snap.Packet) error {
+	return h.downloader.DeliverSnapPacket(peer, packet)
+}
+
+// registerSnapPeer tracks a new `snap` peer and injects it into the snapshot
+// syncer as a data source.
+func (h *snapHandler) registerSnapPeer(peer *snap.Peer) error {
+	h.snapLock.Lock()
+	if _, ok := h.snapPeers[peer.ID()]; ok {
+		h.snapLock.Unlock()
+		return p2p.DiscAlreadyConnected
+	}
+	h.snapPeers[peer.ID()] = peer
+	h.snapLock.Unlock()
+
+	if err := h.downloader.SnapSyncer.Register(peer); err != nil {
+		h.snapLock.Lock()
+		delete(h.snapPeers, peer.ID())
+		h.snapLock.Unlock()
+		return err
+	}
+	return nil
+}
+
+// unregisterSnapPeer removes a `snap` peer from the local tracker and from the
+// snapshot syncer's data sources.
+func (h *snapHandler) unregisterSnapPeer(id string) {
+	h.snapLock.Lock()
+	_, ok := h.snapPeers[id]
+	delete(h.snapPeers, id)
+	h.snapLock.Unlock()
+
+	if ok {
+		h.downloader.SnapSyncer.Unregister(id)
+	}
+}
@@ -0,0 +1,2131 @@
+// Copyright 2021 The go-hayekchain Authors
+// This file is part of the go-hayekchain library.
+//
+// The go-hayekchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hayekchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hayekchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package snap
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hayekchain/go-hayekchain/common"
+	"github.com/hayekchain/go-hayekchain/core/rawdb"
+	"github.com/hayekchain/go-hayekchain/core/state"
+	"github.com/hayekchain/go-hayekchain/crypto"
+	"github.com/hayekchain/go-hayekchain/hykdb"
+	"github.com/hayekchain/go-hayekchain/hykdb/memorydb"
+	"github.com/hayekchain/go-hayekchain/log"
+	"github.com/hayekchain/go-hayekchain/rlp"
+	"github.com/hayekchain/go-hayekchain/trie"
+	"golang.org/x/crypto/sha3"
+)
+
+var (
+	// emptyRoot is the known root hash of an empty trie.
+	emptyRoot = common.HexToHash("56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421")
+
+	// emptyCode is the known hash of the empty EVM bytecode.
+	emptyCode = crypto.Keccak256Hash(nil)
+)
+
+const (
+	// maxRequestSize is the maximum number of bytes to request from a remote peer.
+	maxRequestSize = 512 * 1024
+
+	// maxStorageSetRequestCount is the maximum number of contracts to request the
+	// storage of in a single query. If this number is too low, we're not filling
+	// responses fully and waste round trip times. If it's too high, we're capping
+	// responses and waste bandwidth.
+	maxStorageSetRequestCount = maxRequestSize / 1024
+
+	// maxCodeRequestCount is the maximum number of bytecode blobs to request in a
+	// single query. If this number is too low, we're not filling responses fully
+	// and waste round trip times. If it's too high, we're capping responses and
+	// waste bandwidth.
+	//
+	// Deployed bytecodes are currently capped at 24KB, so the minimum request
+	// size should be maxRequestSize / 24K. Assuming that most contracts do not
+	// come close to that, requesting 4x should be a good approximation.
+	maxCodeRequestCount = maxRequestSize / (24 * 1024) * 4
+
+	// maxTrieRequestCount is the maximum number of trie node blobs to request in
+	// a single query. If this number is too low, we're not filling responses fully
+	// and waste round trip times. If it's too high, we're capping responses and
+	// waste bandwidth.
+	maxTrieRequestCount = 256
+
+	// requestTimeout is the maximum time a peer is allowed to spend on serving
+	// a single network request.
+	requestTimeout = 10 * time.Second
+
+	// accountConcurrency is the number of chunks to split the account trie into
+	// to allow concurrent retrievals.
+	accountConcurrency = 16
+)
+
+// errCancelled is returned from the syncer if the sync was aborted before it
+// could complete.
+var errCancelled = errors.New("sync cancelled")
+
+// accountRequest tracks a pending account range request to ensure responses are
+// to actual requests and to validate any security constraints.
+//
+// Concurrency note: account requests and responses are handled concurrently from
+// the main runloop to allow Merkle proof verifications on the peer's thread and
+// to drop on invalid response. The request struct must contain all the data to
+// construct the response without accessing runloop internals (i.e. task). That
+// is only included to allow the runloop to match a response to the task being
+// synced without having yet another set of maps.
+type accountRequest struct {
+	peer string // Peer to which this request is assigned
+	id   uint64 // Request ID of this request
+
+	term    chan struct{} // Channel to track sync termination
+	timeout *time.Timer   // Timer to track delivery timeout
+
+	root   common.Hash // State root of the account trie to retrieve from
+	origin common.Hash // First account requested to allow continuation checks
+	limit  common.Hash // Last account requested to allow non-overlapping chunking
+
+	task *accountTask // Task which this request is filling (only access fields through the runloop!!)
+}
+
+// accountResponse is an already Merkle-verified remote response to an account
+// range request. It contains the subtrie for the requested account range and
+// the database that's going to be filled with the internal nodes on commit.
+type accountResponse struct {
+	task *accountTask // Task which this request is filling
+
+	hashes   []common.Hash    // Account hashes in the returned range
+	accounts []*state.Account // Expanded accounts in the returned range
+	blobs    [][]byte         // Consensus RLP encoded accounts in the returned range
+
+	cont bool // Whether the account range has a continuation
+}
+
+// bytecodeRequest tracks a pending bytecode request to ensure responses are to
+// actual requests and to validate any security constraints.
+//
+// Concurrency note: bytecode requests and responses are handled concurrently from
+// the main runloop to allow Keccak256 hash verifications on the peer's thread and
+// to drop on invalid response. The request struct must contain all the data to
+// construct the response without accessing runloop internals (i.e. task). That
+// is only included to allow the runloop to match a response to the task being
+// synced without having yet another set of maps.
+type bytecodeRequest struct {
+	peer string // Peer to which this request is assigned
+	id   uint64 // Request ID of this request
+
+	term    chan struct{} // Channel to track sync termination
+	timeout *time.Timer   // Timer to track delivery timeout
+
+	hashes []common.Hash // Bytecode hashes to validate responses
+	task   *accountTask  // Task which this request is filling (nil if healing)
+}
+
+// bytecodeResponse is an already verified remote response to a bytecode request.
+type bytecodeResponse struct {
+	task *accountTask // Task which this request is filling (nil if healing)
+
+	hashes []common.Hash // Hashes of the bytecode to avoid double hashing
+	codes  [][]byte      // Actual bytecodes to store into the database (nil = missing)
+}
+
+// storageRequest tracks a pending storage ranges request to ensure responses are
+// to actual requests and to validate any security constraints.
+//
+// Concurrency note: storage requests and responses are handled concurrently from
+// the main runloop to allow Merkle proof verifications on the peer's thread and
+// to drop on invalid response. The request struct must contain all the data to
+// construct the response without accessing runloop internals (i.e. tasks). That
+// is only included to allow the runloop to match a response to the task being
+// synced without having yet another set of maps.
+type storageRequest struct {
+	peer string // Peer to which this request is assigned
+	id   uint64 // Request ID of this request
+
+	term    chan struct{} // Channel to track sync termination
+	timeout *time.Timer   // Timer to track delivery timeout
+
+	root     common.Hash   // State root of the account trie to retrieve from
+	accounts []common.Hash // Account hashes to validate responses
+	roots    []common.Hash // Storage roots to validate responses
+
+	origin common.Hash // First storage slot requested to allow continuation checks
+
+	mainTask *accountTask // Task which this response belongs to (only access fields through the runloop!!)
+	subTask  *storageTask // Task which this response is filling (only access fields through the runloop!!)
+}
+
+// storageResponse is an already Merkle-verified remote response to a storage
+// range request. It contains the subtries for the requested storage ranges and
+// the databases that's going to be filled with the internal nodes on commit.
+type storageResponse struct {
+	mainTask *accountTask // Task which this response belongs to
+	subTask  *storageTask // Task which this response is filling
+
+	accounts []common.Hash // Account hashes requested, may be only partially filled
+	roots    []common.Hash // Storage roots requested, may be only partially filled
+
+	hashes [][]common.Hash // Storage slot hashes in the returned range
+	slots  [][][]byte      // Storage slot values in the returned range
+
+	cont bool // Whether the last storage range has a continuation
+}
+
+// trienodeHealRequest tracks a pending state trie request to ensure responses
+// are to actual requests and to validate any security constraints.
+//
+// Concurrency note: trie node requests and responses are handled concurrently from
+// the main runloop to allow Keccak256 hash verifications on the peer's thread and
+// to drop on invalid response. The request struct must contain all the data to
+// construct the response without accessing runloop internals (i.e. task). That
+// is only included to allow the runloop to match a response to the task being
+// synced without having yet another set of maps.
+type trienodeHealRequest struct {
+	peer string // Peer to which this request is assigned
+	id   uint64 // Request ID of this request
+
+	term    chan struct{} // Channel to track sync termination
+	timeout *time.Timer   // Timer to track delivery timeout
+
+	hashes []common.Hash   // Trie node hashes to validate responses
+	paths  []trie.SyncPath // Trie node paths requested for rescheduling
+}
+
+// trienodeHealResponse is an already verified remote response to a trie node request.
+type trienodeHealResponse struct {
+	hashes []common.Hash   // Hashes of the trie nodes to avoid double hashing
+	paths  []trie.SyncPath // Trie node paths requested for rescheduling missing ones
+	nodes  [][]byte        // Actual trie nodes to store into the database (nil = missing)
+}
+
+// accountTask represents the sync task for a chunk of the account snapshot.
+type accountTask struct {
+	// These fields are retained across sync cycles
+	Next     common.Hash                  // Next account to sync in this interval
+	Last     common.Hash                  // Last account to sync in this interval
+	SubTasks map[common.Hash]*storageTask // Storage intervals needing fetching for large contracts
+
+	// These fields are internals used during runtime
+	req  *accountRequest  // Pending request to fill this task
+	res  *accountResponse // Validate response filling this task
+	pend int              // Number of pending subtasks for this round
+
+	needCode  []bool // Flags whether the filling accounts need code retrieval
+	needState []bool // Flags whether the filling accounts need storage retrieval
+
+	codeTasks  map[common.Hash]struct{}    // Code hashes that need retrieval
+	stateTasks map[common.Hash]common.Hash // Account hashes->roots that need full state retrieval
+
+	genBatch hykdb.Batch     // Batch used by the node generator
+	genTrie  *trie.StackTrie // Node generator from storage slots
+
+	done bool // Flag whether the task can be removed
+}
+
+// storageTask represents the sync task for a chunk of the storage snapshot.
+type storageTask struct {
+	Next common.Hash // Next account to sync in this interval
+	root common.Hash // Storage root hash for this instance
+
+	req *storageRequest // Pending request to fill this task
+
+	genBatch hykdb.Batch     // Batch used by the node generator
+	genTrie  *trie.StackTrie // Node generator from storage slots
+}
+
+// healTask represents the sync task for healing the snap-synced chunk boundaries.
+type healTask struct {
+	scheduler *trie.Sync // State trie sync scheduler defining the tasks
+
+	trieTasks map[common.Hash]trie.SyncPath // Set of trie node tasks currently queued for retrieval
+	codeTasks map[common.Hash]struct{}      // Set of byte code tasks currently queued for retrieval
+}
+
+// SyncPeer abstracts out the methods required for a peer to be synced against
+// with the goal of allowing the construction of mock peers without the full
+// blown networking.
+type SyncPeer interface {
+	// ID retrieves the peer's unique identifier.
+	ID() string
+
+	// RequestAccountRange fetches a batch of accounts rooted in a specific account
+	// trie, starting with the origin.
+	RequestAccountRange(id uint64, root, origin, limit common.Hash, bytes uint64) error
+
+	// RequestStorageRanges fetches a batch of storage slots belonging to one or
+	// more accounts. If slots from only one account is requested, an origin marker
+	// may also be used to retrieve from there.
+	RequestStorageRanges(id uint64, root common.Hash, accounts []common.Hash, origin, limit []byte, bytes uint64) error
+
+	// RequestByteCodes fetches a batch of bytecodes by hash.
+	RequestByteCodes(id uint64, hashes []common.Hash, bytes uint64) error
+
+	// RequestTrieNodes fetches a batch of account or storage trie nodes rooted in
+	// a specific state trie.
+	RequestTrieNodes(id uint64, root common.Hash, paths []TrieNodePathSet, bytes uint64) error
+
+	// Log retrieves the peer's own contextual logger.
+	Log() log.Logger
+}
+
+// Syncer is a hayekchain account and storage trie syncer based on snapshots and
+// the snap protocol. It's purpose is to download all the accounts and storage
+// slots from remote peers and reassemble chunks of the state trie, on top of
+// which a state sync can be run to fix any gaps / overlaps.
+//
+// Every network request has a variety of failure events:
+//   - The peer disconnects after task assignment, failing to send the request
+//   - The peer disconnects after sending the request, before delivering on it
+//   - The peer remains connected, but does not deliver a response in time
+//   - The peer delivers a stale response after a previous timeout
+//   - The peer delivers a refusal to serve the requested state
+type Syncer struct {
+	db    hykdb.KeyValueStore // Database to store the trie nodes into (and dedup)
+	bloom *trie.SyncBloom     // Bloom filter to deduplicate nodes for state fixup
+
+	root    common.Hash    // Current state trie root being synced
+	tasks   []*accountTask // Current account task set being synced
+	snapped bool           // Flag to signal that snap phase is done
+	healer  *healTask      // Current state healing task being executed
+	update  chan struct{}  // Notification channel for possible sync progression
+
+	peers map[string]SyncPeer // Currently active peers to download from
+
+	// Request tracking during syncing phase
+	statelessPeers map[string]struct{} // Peers that failed to deliver state data
+	idlers         map[string]struct{} // Peers that aren't serving requests
+	reqid          uint64              // Last allocated request identifier
+
+	accountReqs  map[uint64]*accountRequest  // Account requests currently running
+	bytecodeReqs map[uint64]*bytecodeRequest // Bytecode requests currently running
+	storageReqs  map[uint64]*storageRequest  // Storage requests currently running
+
+	accountReqFails  chan *accountRequest  // Failed account range requests to revert
+	storageReqFails  chan *storageRequest  // Failed storage requests to revert
+	bytecodeReqFails chan *bytecodeRequest // Failed bytecode requests to revert
+
+	accountResps  chan *accountResponse  // Account sub-tries to integrate into the database
+	storageResps  chan *storageResponse  // Storage sub-tries to integrate into the database
+	bytecodeResps chan *bytecodeResponse // Bytecodes to integrate into the database
+
+	accountSynced  uint64             // Number of accounts downloaded
+	accountBytes   common.StorageSize // Number of account trie bytes persisted to disk
+	bytecodeSynced uint64             // Number of bytecodes downloaded
+	bytecodeBytes  common.StorageSize // Number of bytecode bytes downloaded
+	storageSynced  uint64             // Number of storage slots downloaded
+	storageBytes   common.StorageSize // Number of storage trie bytes persisted to disk
+
+	// Request tracking during healing phase
+	trienodeHealReqs map[uint64]*trienodeHealRequest // Trie node requests currently running
+	bytecodeHealReqs map[uint64]*bytecodeRequest     // Bytecode requests currently running
+
+	trienodeHealReqFails chan *trienodeHealRequest // Failed trienode requests to revert
+	bytecodeHealReqFails chan *bytecodeRequest     // Failed bytecode requests to revert
+
+	trienodeHealResps chan *trienodeHealResponse // Trie nodes to integrate into the database
+	bytecodeHealResps chan *bytecodeResponse     // Bytecodes to integrate into the database
+
+	trienodeHealSynced uint64             // Number of state trie nodes downloaded
+	trienodeHealBytes  common.StorageSize // Number of state trie bytes persisted to disk
+	trienodeHealDups   uint64             // Number of state trie nodes already processed
+	trienodeHealNops   uint64             // Number of state trie nodes not requested
+	bytecodeHealSynced uint64             // Number of bytecodes downloaded
+	bytecodeHealBytes  common.StorageSize // Number of bytecodes persisted to disk
+	bytecodeHealDups   uint64             // Number of bytecodes already processed
+	bytecodeHealNops   uint64             // Number of bytecodes not requested
+
+	startTime time.Time // Time instance when snapshot sync started
+	logTime   time.Time // Time instance when status was last reported
+
+	lock sync.RWMutex // Protects fields that can change outside of sync (peers, reqs, root)
+}
+
+// NewSyncer creates a new snapshot syncer to download the hayekchain state over
+// the snap protocol.
+func NewSyncer(db hykdb.KeyValueStore, bloom *trie.SyncBloom) *Syncer {
+	return &Syncer{
+		db:    db,
+		bloom: bloom,
+
+		peers:  make(map[string]SyncPeer),
+		update: make(chan struct{}, 1),
+
+		idlers:       make(map[string]struct{}),
+		accountReqs:  make(map[uint64]*accountRequest),
+		storageReqs:  make(map[uint64]*storageRequest),
+		bytecodeReqs: make(map[uint64]*bytecodeRequest),
+
+		accountReqFails:  make(chan *accountRequest),
+		storageReqFails:  make(chan *storageRequest),
+		bytecodeReqFails: make(chan *bytecodeRequest),
+		accountResps:     make(chan *accountResponse),
+		storageResps:     make(chan *storageResponse),
+		bytecodeResps:    make(chan *bytecodeResponse),
+
+		trienodeHealReqs: make(map[uint64]*trienodeHealRequest),
+		bytecodeHealReqs: make(map[uint64]*bytecodeRequest),
+
+		trienodeHealReqFails: make(chan *trienodeHealRequest),
+		bytecodeHealReqFails: make(chan *bytecodeRequest),
+		trienodeHealResps:    make(chan *trienodeHealResponse),
+		bytecodeHealResps:    make(chan *bytecodeResponse),
+	}
+}
+
+// Register injects a new data source into the syncer's peerset.
+func (s *Syncer) Register(peer SyncPeer) error {
+	// Make sure the peer is not registered yet
+	id := peer.ID()
+
+	s.lock.Lock()
+	if _, ok := s.peers[id]; ok {
+		log.Error("Snap peer already registered", "id", id)
+
+		s.lock.Unlock()
+		return errors.New("already registered")
+	}
+	s.peers[id] = peer
+
+	// Mark the peer as idle, even if no sync is running
+	s.idlers[id] = struct{}{}
+	s.lock.Unlock()
+
+	// Notify any active syncs that a new peer can be assigned data
+	s.notify()
+	return nil
+}
+
+// Unregister removes a data source from the syncer's peerset.
+func (s *Syncer) Unregister(id string) error {
+	// Remove all traces of the peer from the registry
+	s.lock.Lock()
+	if _, ok := s.peers[id]; !ok {
+		log.Error("Snap peer not registered", "id", id)
+
+		s.lock.Unlock()
+		return errors.New("not registered")
+	}
+	delete(s.peers, id)
+
+	// Remove status markers, even if no sync is running
+	delete(s.statelessPeers, id)
+	delete(s.idlers, id)
+
+	// Collect all the requests assigned to the dropped peer, to revert them
+	// once the lock is released
+	var (
+		accountReqs      []*accountRequest
+		bytecodeReqs     []*bytecodeRequest
+		storageReqs      []*storageRequest
+		trienodeHealReqs []*trienodeHealRequest
+		bytecodeHealReqs []*bytecodeRequest
+	)
+	for reqid, req := range s.accountReqs {
+		if req.peer == id {
+			req.timeout.Stop()
+			delete(s.accountReqs, reqid)
+			accountReqs = append(accountReqs, req)
+		}
+	}
+	for reqid, req := range s.bytecodeReqs {
+		if req.peer == id {
+			req.timeout.Stop()
+			delete(s.bytecodeReqs, reqid)
+			bytecodeReqs = append(bytecodeReqs, req)
+		}
+	}
+	for reqid, req := range s.storageReqs {
+		if req.peer == id {
+			req.timeout.Stop()
+			delete(s.storageReqs, reqid)
+			storageReqs = append(storageReqs, req)
+		}
+	}
+	for reqid, req := range s.trienodeHealReqs {
+		if req.peer == id {
+			req.timeout.Stop()
+			delete(s.trienodeHealReqs, reqid)
+			trienodeHealReqs = append(trienodeHealReqs, req)
+		}
+	}
+	for reqid, req := range s.bytecodeHealReqs {
+		if req.peer == id {
+			req.timeout.Stop()
+			delete(s.bytecodeHealReqs, reqid)
+			bytecodeHealReqs = append(bytecodeHealReqs, req)
+		}
+	}
+	s.lock.Unlock()
+
+	// Revert all the requests of the dropped peer, to reassign them
+	for _, req := range accountReqs {
+		s.scheduleRevertAccountRequest(req)
+	}
+	for _, req := range bytecodeReqs {
+		s.scheduleRevertBytecodeRequest(req)
+	}
+	for _, req := range storageReqs {
+		s.scheduleRevertStorageRequest(req)
+	}
+	for _, req := range trienodeHealReqs {
+		s.scheduleRevertTrienodeHealRequest(req)
+	}
+	for _, req := range bytecodeHealReqs {
+		s.scheduleRevertBytecodeHealRequest(req)
+	}
+	// Notify any active syncs that pending requests need to be reverted
+	s.notify()
+	return nil
+}
+
+// Sync starts (or resumes a previous) sync cycle to iterate over an state trie
+// with the given root and reconstruct the nodes based on the snapshot leaves.
+// Previously downloaded segments will not be redownloaded or fixed, rather any
+// errors will be healed after the leaves are fully accumulated.
+func (s *Syncer) Sync(root common.Hash, cancel chan struct{}) error {
+	// Move the trie root from any previous value, revert stateless markers for
+	// any peers and initialize the syncer if it was not yet run
+	term := make(chan struct{})
+
+	s.lock.Lock()
+	s.root = root
+	s.healer = &healTask{
+		scheduler: state.NewStateSync(root, s.db, s.bloom),
+		trieTasks: make(map[common.Hash]trie.SyncPath),
+		codeTasks: make(map[common.Hash]struct{}),
+	}
+	s.statelessPeers = make(map[string]struct{})
+	s.lock.Unlock()
+
+	if s.startTime == (time.Time{}) {
+		s.startTime = time.Now()
+	}
+	// Retrieve the previous sync status and start a fresh sync if none is
+	// available
+	s.loadSyncStatus()
+	if len(s.tasks) == 0 && s.healer.scheduler.Pending() == 0 {
+		log.Debug("Snapshot sync already completed")
+		return nil
+	}
+	defer func() {
+		// Drop all pending requests and wake up any goroutines still trying to
+		// hand over responses or failures of the terminating cycle
+		s.dropRequests()
+		close(term)
+		s.report(true)
+	}()
+	log.Debug("Starting snapshot sync cycle", "root", root)
+
+	for {
+		// Remove all completed tasks and terminate sync if everything's done
+		s.cleanAccountTasks()
+		if len(s.tasks) == 0 && s.healer.scheduler.Pending() == 0 {
+			return nil
+		}
+		// Assign all the data retrieval tasks to any free peers
+		s.assignAccountTasks(term)
+		s.assignBytecodeTasks(term)
+		s.assignStorageTasks(term)
+
+		if len(s.tasks) == 0 {
+			// Sync phase done, run heal phase
+			s.assignTrienodeHealTasks(term)
+			s.assignBytecodeHealTasks(term)
+		}
+		// Wait for something to happen
+		select {
+		case <-s.update:
+			// Something happened (new peer, delivery, timeout), recheck tasks
+		case <-cancel:
+			return errCancelled
+
+		case req := <-s.accountReqFails:
+			s.revertAccountRequest(req)
+		case req := <-s.bytecodeReqFails:
+			s.revertBytecodeRequest(req)
+		case req := <-s.storageReqFails:
+			s.revertStorageRequest(req)
+		case req := <-s.trienodeHealReqFails:
+			s.revertTrienodeHealRequest(req)
+		case req := <-s.bytecodeHealReqFails:
+			s.revertBytecodeHealRequest(req)
+
+		case res := <-s.accountResps:
+			s.processAccountResponse(res)
+		case res := <-s.bytecodeResps:
+			s.processBytecodeResponse(res)
+		case res := <-s.storageResps:
+			s.processStorageResponse(res)
+		case res := <-s.trienodeHealResps:
+			s.processTrienodeHealResponse(res)
+		case res := <-s.bytecodeHealResps:
+			s.processBytecodeHealResponse(res)
+		}
+		// Report stats if something meaningful happened
+		s.report(false)
+	}
+}
+
+// loadSyncStatus prepares the account tasks for a new sync cycle. Any progress
+// made by previous cycles is retained, but data that was downloaded and not yet
+// forwarded into the trie generators is discarded since it might not be valid
+// for the new state root.
+func (s *Syncer) loadSyncStatus() {
+	if s.tasks != nil || s.snapped {
+		for _, task := range s.tasks {
+			task.req, task.res, task.pend = nil, nil, 0
+			task.needCode, task.needState = nil, nil
+
+			task.codeTasks = make(map[common.Hash]struct{})
+			task.stateTasks = make(map[common.Hash]common.Hash)
+
+			// Large contracts chunked mid-way might have a different storage
+			// root in the new state, drop them and let the healer fix them up
+			task.SubTasks = make(map[common.Hash]*storageTask)
+		}
+		return
+	}
+	// No previous sync cycle found, start a fresh one. Split the account hash
+	// space into equal chunks to allow concurrent retrievals.
+	var next common.Hash
+	step := new(big.Int).Sub(
+		new(big.Int).Div(
+			new(big.Int).Exp(common.Big2, common.Big256, nil),
+			big.NewInt(accountConcurrency),
+		), common.Big1,
+	)
+	for i := 0; i < accountConcurrency; i++ {
+		last := common.BigToHash(new(big.Int).Add(next.Big(), step))
+		if i == accountConcurrency-1 {
+			// Make sure we don't overflow if the step is not a proper divisor
+			last = common.HexToHash("0xffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff")
+		}
+		batch := s.newSyncBatch()
+		s.tasks = append(s.tasks, &accountTask{
+			Next:       next,
+			Last:       last,
+			SubTasks:   make(map[common.Hash]*storageTask),
+			codeTasks:  make(map[common.Hash]struct{}),
+			stateTasks: make(map[common.Hash]common.Hash),
+			genBatch:   batch,
+			genTrie:    trie.NewStackTrie(batch),
+		})
+		log.Debug("Created account sync task", "from", next, "last", last)
+		next = common.BigToHash(new(big.Int).Add(last.Big(), common.Big1))
+	}
+}
+
+// cleanAccountTasks removes account range retrieval tasks that have already been
+// completed.
+func (s *Syncer) cleanAccountTasks() {
+	if len(s.tasks) == 0 {
+		return
+	}
+	for i := 0; i < len(s.tasks); i++ {
+		if s.tasks[i].done {
+			s.tasks = append(s.tasks[:i], s.tasks[i+1:]...)
+			i--
+		}
+	}
+	if len(s.tasks) == 0 {
+		s.lock.Lock()
+		s.snapped = true
+		s.lock.Unlock()
+
+		log.Debug("Snapshot sync phase completed, starting healing")
+	}
+}
+
+// dropRequests cancels all the requests still in flight when a sync cycle is
+// terminated. Their eventual responses will be discarded as unsolicited.
+func (s *Syncer) dropRequests() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for id, req := range s.accountReqs {
+		req.timeout.Stop()
+		delete(s.accountReqs, id)
+		s.markIdle(req.peer)
+	}
+	for id, req := range s.bytecodeReqs {
+		req.timeout.Stop()
+		delete(s.bytecodeReqs, id)
+		s.markIdle(req.peer)
+	}
+	for id, req := range s.storageReqs {
+		req.timeout.Stop()
+		delete(s.storageReqs, id)
+		s.markIdle(req.peer)
+	}
+	for id, req := range s.trienodeHealReqs {
+		req.timeout.Stop()
+		delete(s.trienodeHealReqs, id)
+		s.markIdle(req.peer)
+	}
+	for id, req := range s.bytecodeHealReqs {
+		req.timeout.Stop()
+		delete(s.bytecodeHealReqs, id)
+		s.markIdle(req.peer)
+	}
+}
+
+// markIdle flags a peer as available for new requests, if it's still connected.
+// The method assumes the lock is held.
+func (s *Syncer) markIdle(id string) {
+	if _, ok := s.peers[id]; ok {
+		s.idlers[id] = struct{}{}
+	}
+}
+
+// idlePeer picks an idle peer that did not previously refuse to serve state
+// data. The method assumes the lock is held.
+func (s *Syncer) idlePeer() (string, SyncPeer) {
+	for id := range s.idlers {
+		if _, ok := s.statelessPeers[id]; ok {
+			continue
+		}
+		return id, s.peers[id]
+	}
+	return "", nil
+}
+
+// nextRequestID allocates a new unique request identifier. The method assumes
+// the lock is held.
+func (s *Syncer) nextRequestID() uint64 {
+	s.reqid++
+	return s.reqid
+}
+
+// notify signals the sync loop that something happened which might allow the
+// sync to progress.
+func (s *Syncer) notify() {
+	select {
+	case s.update <- struct{}{}:
+	default:
+	}
+}
+
+// assignAccountTasks attempts to match idle peers to pending account range
+// retrievals.
+func (s *Syncer) assignAccountTasks(term chan struct{}) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	// Iterate over all the tasks and try to find a pending one
+	for _, task := range s.tasks {
+		// Skip any tasks already filling
+		if task.req != nil || task.res != nil {
+			continue
+		}
+		// Task pending retrieval, try to find an idle peer. If no such peer
+		// exists, we probably assigned tasks for all (or they are stateless).
+		// Abort the entire assignment mechanism.
+		idle, peer := s.idlePeer()
+		if peer == nil {
+			return
+		}
+		// Matched a pending task to an idle peer, allocate a unique request id
+		req := &accountRequest{
+			peer:   idle,
+			id:     s.nextRequestID(),
+			term:   term,
+			root:   s.root,
+			origin: task.Next,
+			limit:  task.Last,
+			task:   task,
+		}
+		req.timeout = time.AfterFunc(requestTimeout, func() {
+			peer.Log().Debug("Account range request timed out", "reqid", req.id)
+			s.expireAccountRequest(req)
+		})
+		s.accountReqs[req.id] = req
+		delete(s.idlers, idle)
+
+		// Inject the request into the task to block further assignments
+		task.req = req
+
+		go func() {
+			if err := peer.RequestAccountRange(req.id, req.root, req.origin, req.limit, maxRequestSize); err != nil {
+				peer.Log().Debug("Failed to request account range", "err", err)
+				s.expireAccountRequest(req)
+			}
+		}()
+	}
+}
+
+// assignBytecodeTasks attempts to match idle peers to pending code retrievals.
+func (s *Syncer) assignBytecodeTasks(term chan struct{}) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	// Iterate over all the tasks and try to find a pending one
+	for _, task := range s.tasks {
+		// Skip any tasks not in the bytecode retrieval phase
+		if task.res == nil {
+			continue
+		}
+		// Skip tasks that are already retrieving (or done with) all codes
+		for len(task.codeTasks) > 0 {
+			idle, peer := s.idlePeer()
+			if peer == nil {
+				return
+			}
+			hashes := make([]common.Hash, 0, maxCodeRequestCount)
+			for hash := range task.codeTasks {
+				delete(task.codeTasks, hash)
+				hashes = append(hashes, hash)
+				if len(hashes) >= maxCodeRequestCount {
+					break
+				}
+			}
+			req := &bytecodeRequest{
+				peer:   idle,
+				id:     s.nextRequestID(),
+				term:   term,
+				hashes: hashes,
+				task:   task,
+			}
+			req.timeout = time.AfterFunc(requestTimeout, func() {
+				peer.Log().Debug("Bytecode request timed out", "reqid", req.id)
+				s.expireBytecodeRequest(req)
+			})
+			s.bytecodeReqs[req.id] = req
+			delete(s.idlers, idle)
+
+			go func() {
+				if err := peer.RequestByteCodes(req.id, req.hashes, maxRequestSize); err != nil {
+					peer.Log().Debug("Failed to request bytecodes", "err", err)
+					s.expireBytecodeRequest(req)
+				}
+			}()
+		}
+	}
+}
+
+// assignStorageTasks attempts to match idle peers to pending storage range
+// retrievals.
+func (s *Syncer) assignStorageTasks(term chan struct{}) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	// Iterate over all the tasks and try to find a pending one
+	for _, task := range s.tasks {
+		// Skip any tasks not in the storage retrieval phase
+		if task.res == nil {
+			continue
+		}
+		for len(task.SubTasks) > 0 || len(task.stateTasks) > 0 {
+			// Gather the storage ranges to retrieve: a large contract chunk
+			// if any is pending, otherwise a batch of small contracts
+			var (
+				accounts = make([]common.Hash, 0, maxStorageSetRequestCount)
+				roots    = make([]common.Hash, 0, maxStorageSetRequestCount)
+				subtask  *storageTask
+			)
+			for account, st := range task.SubTasks {
+				if st.req != nil {
+					continue
+				}
+				accounts = append(accounts, account)
+				roots = append(roots, st.root)
+				subtask = st
+				break
+			}
+			if subtask == nil {
+				for account, root := range task.stateTasks {
+					accounts = append(accounts, account)
+					roots = append(roots, root)
+					if len(accounts) >= maxStorageSetRequestCount {
+						break
+					}
+				}
+			}
+			// If nothing was found, it means this task is actually already
+			// fully retrieving, but large contracts are hard to detect. Skip
+			// to the next task as it's useless to try again.
+			if len(accounts) == 0 {
+				break
+			}
+			idle, peer := s.idlePeer()
+			if peer == nil {
+				return
+			}
+			if subtask == nil {
+				for _, account := range accounts {
+					delete(task.stateTasks, account)
+				}
+			}
+			req := &storageRequest{
+				peer:     idle,
+				id:       s.nextRequestID(),
+				term:     term,
+				root:     s.root,
+				accounts: accounts,
+				roots:    roots,
+				mainTask: task,
+				subTask:  subtask,
+			}
+			if subtask != nil {
+				req.origin = subtask.Next
+				subtask.req = req
+			}
+			req.timeout = time.AfterFunc(requestTimeout, func() {
+				peer.Log().Debug("Storage request timed out", "reqid", req.id)
+				s.expireStorageRequest(req)
+			})
+			s.storageReqs[req.id] = req
+			delete(s.idlers, idle)
+
+			go func() {
+				var origin, limit []byte
+				if req.subTask != nil {
+					origin, limit = req.origin[:], common.HexToHash("0xffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff").Bytes()
+				}
+				if err := peer.RequestStorageRanges(req.id, req.root, req.accounts, origin, limit, maxRequestSize); err != nil {
+					peer.Log().Debug("Failed to request storage", "err", err)
+					s.expireStorageRequest(req)
+				}
+			}()
+		}
+	}
+}
+
+// assignTrienodeHealTasks attempts to match idle peers to trie node requests to
+// heal any trie errors caused by the snap sync's chunked retrieval model.
+func (s *Syncer) assignTrienodeHealTasks(term chan struct{}) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for {
+		// Make sure there are tasks to assign, refilling from the scheduler
+		// if we've run dry
+		if len(s.healer.trieTasks) == 0 {
+			nodes, paths, codes := s.healer.scheduler.Missing(maxTrieRequestCount)
+			for i, hash := range nodes {
+				s.healer.trieTasks[hash] = paths[i]
+			}
+			for _, hash := range codes {
+				s.healer.codeTasks[hash] = struct{}{}
+			}
+		}
+		if len(s.healer.trieTasks) == 0 {
+			return
+		}
+		idle, peer := s.idlePeer()
+		if peer == nil {
+			return
+		}
+		var (
+			hashes   = make([]common.Hash, 0, maxTrieRequestCount)
+			paths    = make([]trie.SyncPath, 0, maxTrieRequestCount)
+			pathsets = make([]TrieNodePathSet, 0, maxTrieRequestCount)
+		)
+		for hash, path := range s.healer.trieTasks {
+			delete(s.healer.trieTasks, hash)
+
+			hashes = append(hashes, hash)
+			paths = append(paths, path)
+			pathsets = append(pathsets, TrieNodePathSet(path))
+
+			if len(hashes) >= maxTrieRequestCount {
+				break
+			}
+		}
+		req := &trienodeHealRequest{
+			peer:   idle,
+			id:     s.nextRequestID(),
+			term:   term,
+			hashes: hashes,
+			paths:  paths,
+		}
+		req.timeout = time.AfterFunc(requestTimeout, func() {
+			peer.Log().Debug("Trienode heal request timed out", "reqid", req.id)
+			s.expireTrienodeHealRequest(req)
+		})
+		s.trienodeHealReqs[req.id] = req
+		delete(s.idlers, idle)
+
+		go func(root common.Hash) {
+			if err := peer.RequestTrieNodes(req.id, root, pathsets, maxRequestSize); err != nil {
+				peer.Log().Debug("Failed to request trienode healers", "err", err)
+				s.expireTrienodeHealRequest(req)
+			}
+		}(s.root)
+	}
+}
+
+// assignBytecodeHealTasks attempts to match idle peers to bytecode requests to
+// heal any trie errors caused by the snap sync's chunked retrieval model.
+func (s *Syncer) assignBytecodeHealTasks(term chan struct{}) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for len(s.healer.codeTasks) > 0 {
+		idle, peer := s.idlePeer()
+		if peer == nil {
+			return
+		}
+		hashes := make([]common.Hash, 0, maxCodeRequestCount)
+		for hash := range s.healer.codeTasks {
+			delete(s.healer.codeTasks, hash)
+
+			hashes = append(hashes, hash)
+			if len(hashes) >= maxCodeRequestCount {
+				break
+			}
+		}
+		req := &bytecodeRequest{
+			peer:   idle,
+			id:     s.nextRequestID(),
+			term:   term,
+			hashes: hashes,
+		}
+		req.timeout = time.AfterFunc(requestTimeout, func() {
+			peer.Log().Debug("Bytecode heal request timed out", "reqid", req.id)
+			s.expireBytecodeHealRequest(req)
+		})
+		s.bytecodeHealReqs[req.id] = req
+		delete(s.idlers, idle)
+
+		go func() {
+			if err := peer.RequestByteCodes(req.id, req.hashes, maxRequestSize); err != nil {
+				peer.Log().Debug("Failed to request bytecode healers", "err", err)
+				s.expireBytecodeHealRequest(req)
+			}
+		}()
+	}
+}
+
+// expireAccountRequest takes ownership of a timed out or undeliverable account
+// range request and schedules it for reverting.
+func (s *Syncer) expireAccountRequest(req *accountRequest) {
+	s.lock.Lock()
+	if _, ok := s.accountReqs[req.id]; !ok {
+		s.lock.Unlock()
+		return
+	}
+	req.timeout.Stop()
+	delete(s.accountReqs, req.id)
+	s.markIdle(req.peer)
+	s.lock.Unlock()
+
+	s.scheduleRevertAccountRequest(req)
+}
+
+// expireBytecodeRequest takes ownership of a timed out or undeliverable bytecode
+// request and schedules it for reverting.
+func (s *Syncer) expireBytecodeRequest(req *bytecodeRequest) {
+	s.lock.Lock()
+	if _, ok := s.bytecodeReqs[req.id]; !ok {
+		s.lock.Unlock()
+		return
+	}
+	req.timeout.Stop()
+	delete(s.bytecodeReqs, req.id)
+	s.markIdle(req.peer)
+	s.lock.Unlock()
+
+	s.scheduleRevertBytecodeRequest(req)
+}
+
+// expireStorageRequest takes ownership of a timed out or undeliverable storage
+// request and schedules it for reverting.
+func (s *Syncer) expireStorageRequest(req *storageRequest) {
+	s.lock.Lock()
+	if _, ok := s.storageReqs[req.id]; !ok {
+		s.lock.Unlock()
+		return
+	}
+	req.timeout.Stop()
+	delete(s.storageReqs, req.id)
+	s.markIdle(req.peer)
+	s.lock.Unlock()
+
+	s.scheduleRevertStorageRequest(req)
+}
+
+// expireTrienodeHealRequest takes ownership of a timed out or undeliverable trie
+// node request and schedules it for reverting.
+func (s *Syncer) expireTrienodeHealRequest(req *trienodeHealRequest) {
+	s.lock.Lock()
+	if _, ok := s.trienodeHealReqs[req.id]; !ok {
+		s.lock.Unlock()
+		return
+	}
+	req.timeout.Stop()
+	delete(s.trienodeHealReqs, req.id)
+	s.markIdle(req.peer)
+	s.lock.Unlock()
+
+	s.scheduleRevertTrienodeHealRequest(req)
+}
+
+// expireBytecodeHealRequest takes ownership of a timed out or undeliverable
+// bytecode heal request and schedules it for reverting.
+func (s *Syncer) expireBytecodeHealRequest(req *bytecodeRequest) {
+	s.lock.Lock()
+	if _, ok := s.bytecodeHealReqs[req.id]; !ok {
+		s.lock.Unlock()
+		return
+	}
+	req.timeout.Stop()
+	delete(s.bytecodeHealReqs, req.id)
+	s.markIdle(req.peer)
+	s.lock.Unlock()
+
+	s.scheduleRevertBytecodeHealRequest(req)
+}
+
+// scheduleRevertAccountRequest asks the event loop to clean up an account range
+// request and return all failed retrieval tasks to the scheduler for reassignment.
+func (s *Syncer) scheduleRevertAccountRequest(req *accountRequest) {
+	select {
+	case s.accountReqFails <- req:
+		// Sync loop notified
+	case <-req.term:
+		// Sync cycle got cancelled
+	}
+}
+
+// scheduleRevertBytecodeRequest asks the event loop to clean up a bytecode request
+// and return all failed retrieval tasks to the scheduler for reassignment.
+func (s *Syncer) scheduleRevertBytecodeRequest(req *bytecodeRequest) {
+	select {
+	case s.bytecodeReqFails <- req:
+		// Sync loop notified
+	case <-req.term:
+		// Sync cycle got cancelled
+	}
+}
+
+// scheduleRevertStorageRequest asks the event loop to clean up a storage range
+// request and return all failed retrieval tasks to the scheduler for reassignment.
+func (s *Syncer) scheduleRevertStorageRequest(req *storageRequest) {
+	select {
+	case s.storageReqFails <- req:
+		// Sync loop notified
+	case <-req.term:
+		// Sync cycle got cancelled
+	}
+}
+
+// scheduleRevertTrienodeHealRequest asks the event loop to clean up a trienode
+// heal request and return all failed retrieval tasks to the scheduler for
+// reassignment.
+func (s *Syncer) scheduleRevertTrienodeHealRequest(req *trienodeHealRequest) {
+	select {
+	case s.trienodeHealReqFails <- req:
+		// Sync loop notified
+	case <-req.term:
+		// Sync cycle got cancelled
+	}
+}
+
+// scheduleRevertBytecodeHealRequest asks the event loop to clean up a bytecode
+// heal request and return all failed retrieval tasks to the scheduler for
+// reassignment.
+func (s *Syncer) scheduleRevertBytecodeHealRequest(req *bytecodeRequest) {
+	select {
+	case s.bytecodeHealReqFails <- req:
+		// Sync loop notified
+	case <-req.term:
+		// Sync cycle got cancelled
+	}
+}
+
+// revertAccountRequest cleans up an account range request and returns all failed
+// retrieval tasks to the scheduler for reassignment.
+func (s *Syncer) revertAccountRequest(req *accountRequest) {
+	log.Debug("Reverting account request", "peer", req.peer, "reqid", req.id)
+
+	// If there's a timeout timer still running, abort it and mark the account
+	// task as not-pending, ready for rescheduling
+	req.timeout.Stop()
+	if req.task.req == req {
+		req.task.req = nil
+	}
+}
+
+// revertBytecodeRequest cleans up a bytecode request and returns all failed
+// retrieval tasks to the scheduler for reassignment.
+func (s *Syncer) revertBytecodeRequest(req *bytecodeRequest) {
+	log.Debug("Reverting bytecode request", "peer", req.peer, "reqid", req.id)
+
+	// If the task was reset by a new sync cycle, the codes will be refetched
+	// from scratch, otherwise requeue them
+	if req.task.res == nil {
+		return
+	}
+	for _, hash := range req.hashes {
+		req.task.codeTasks[hash] = struct{}{}
+	}
+}
+
+// revertStorageRequest cleans up a storage range request and returns all failed
+// retrieval tasks to the scheduler for reassignment.
+func (s *Syncer) revertStorageRequest(req *storageRequest) {
+	log.Debug("Reverting storage request", "peer", req.peer, "reqid", req.id)
+
+	// Mark a large contract chunk as not-pending, or requeue the small contracts
+	// for rescheduling
+	if req.subTask != nil {
+		if req.subTask.req == req {
+			req.subTask.req = nil
+		}
+		return
+	}
+	if req.mainTask.res == nil {
+		return
+	}
+	for i, account := range req.accounts {
+		req.mainTask.stateTasks[account] = req.roots[i]
+	}
+}
+
+// revertTrienodeHealRequest cleans up a trienode heal request and returns all
+// failed retrieval tasks to the scheduler for reassignment.
+func (s *Syncer) revertTrienodeHealRequest(req *trienodeHealRequest) {
+	log.Debug("Reverting trienode heal request", "peer", req.peer, "reqid", req.id)
+
+	for i, hash := range req.hashes {
+		s.healer.trieTasks[hash] = req.paths[i]
+	}
+}
+
+// revertBytecodeHealRequest cleans up a bytecode heal request and returns all
+// failed retrieval tasks to the scheduler for reassignment.
+func (s *Syncer) revertBytecodeHealRequest(req *bytecodeRequest) {
+	log.Debug("Reverting bytecode heal request", "peer", req.peer, "reqid", req.id)
+
+	for _, hash := range req.hashes {
+		s.healer.codeTasks[hash] = struct{}{}
+	}
+}
+
+// processAccountResponse integrates an already validated account range response
+// into the account tasks.
+func (s *Syncer) processAccountResponse(res *accountResponse) {
+	// Switch the task from pending to filling
+	res.task.req = nil
+	res.task.res = res
+
+	// Ensure that the response doesn't overflow into the subsequent task
+	for i, hash := range res.hashes {
+		if bytes.Compare(hash[:], res.task.Last[:]) > 0 {
+			// Chop off the trailing accounts
+			res.hashes = res.hashes[:i]
+			res.accounts = res.accounts[:i]
+			res.blobs = res.blobs[:i]
+			res.cont = false
+			break
+		}
+		if hash == res.task.Last {
+			res.cont = false
+		}
+	}
+	// Iterate over all the accounts and assemble which ones need further sub-
+	// filling before the entire account range can be persisted.
+	res.task.needCode = make([]bool, len(res.accounts))
+	res.task.needState = make([]bool, len(res.accounts))
+
+	res.task.codeTasks = make(map[common.Hash]struct{})
+	res.task.stateTasks = make(map[common.Hash]common.Hash)
+
+	res.task.pend = 0
+	for i, account := range res.accounts {
+		// Check if the account is a contract with an unknown code
+		if !bytes.Equal(account.CodeHash, emptyCode[:]) {
+			if code := rawdb.ReadCode(s.db, common.BytesToHash(account.CodeHash)); len(code) == 0 {
+				res.task.codeTasks[common.BytesToHash(account.CodeHash)] = struct{}{}
+				res.task.needCode[i] = true
+				res.task.pend++
+			}
+		}
+		// Check if the account is a contract with an unknown storage trie
+		if account.Root != emptyRoot {
+			if node := rawdb.ReadTrieNode(s.db, account.Root); len(node) == 0 {
+				res.task.stateTasks[res.hashes[i]] = account.Root
+				res.task.needState[i] = true
+				res.task.pend++
+			}
+		}
+	}
+	// If the account range contained no contracts, or all have been fully filled
+	// beforehand, short circuit storage filling and forward to the next task
+	if res.task.pend == 0 {
+		s.forwardAccountTask(res.task)
+	}
+}
+
+// processBytecodeResponse integrates an already validated bytecode response
+// into the account tasks.
+func (s *Syncer) processBytecodeResponse(res *bytecodeResponse) {
+	// If the task was reset by a new sync cycle, discard the delivery
+	if res.task.res == nil {
+		return
+	}
+	batch := s.db.NewBatch()
+
+	var codes uint64
+	for i, hash := range res.hashes {
+		code := res.codes[i]
+
+		// If the bytecode was not delivered, reschedule it
+		if code == nil {
+			res.task.codeTasks[hash] = struct{}{}
+			continue
+		}
+		// Code was delivered, mark it not needed any more
+		for j, account := range res.task.res.accounts {
+			if res.task.needCode[j] && hash == common.BytesToHash(account.CodeHash) {
+				res.task.needCode[j] = false
+				res.task.pend--
+			}
+		}
+		// Push the bytecode into a database batch
+		s.bytecodeSynced++
+		s.bytecodeBytes += common.StorageSize(len(code))
+
+		codes++
+		rawdb.WriteCode(batch, hash, code)
+		s.bloomAdd(hash[:])
+	}
+	if err := batch.Write(); err != nil {
+		log.Crit("Failed to persist bytecodes", "err", err)
+	}
+	log.Debug("Persisted set of bytecodes", "count", codes, "bytes", common.StorageSize(batch.ValueSize()))
+
+	// If this delivery completed the last pending task, forward the account task
+	// to the next chunk
+	if res.task.pend == 0 {
+		s.forwardAccountTask(res.task)
+	}
+}
+
+// processStorageResponse integrates an already validated storage response
+// into the account tasks.
+func (s *Syncer) processStorageResponse(res *storageResponse) {
+	// Switch the subtask from pending to idle
+	if res.subTask != nil {
+		res.subTask.req = nil
+	}
+	// If the task was reset by a new sync cycle, discard the delivery
+	if res.mainTask.res == nil {
+		return
+	}
+	var slots int
+	for i, account := range res.accounts {
+		// If the account was not delivered, reschedule it
+		if i >= len(res.hashes) {
+			if res.subTask == nil {
+				res.mainTask.stateTasks[account] = res.roots[i]
+			}
+			continue
+		}
+		slots += len(res.hashes[i])
+		s.storageSynced += uint64(len(res.hashes[i]))
+
+		// If the storage range was partial and continues in a subtask, feed
+		// the chunk into the subtask's generator and return
+		var (
+			subtask = res.subTask
+			partial = i == len(res.hashes)-1 && res.cont
+		)
+		if subtask == nil && partial {
+			batch := s.newSyncBatch()
+			subtask = &storageTask{
+				root:     res.roots[i],
+				genBatch: batch,
+				genTrie:  trie.NewStackTrie(batch),
+			}
+			res.mainTask.SubTasks[account] = subtask
+		}
+		if subtask != nil {
+			for j, hash := range res.hashes[i] {
+				subtask.genTrie.Update(hash[:], res.slots[i][j])
+			}
+			if partial {
+				subtask.Next = incHash(res.hashes[i][len(res.hashes[i])-1])
+				if subtask.genBatch.ValueSize() > hykdb.IdealBatchSize {
+					s.storageBytes += common.StorageSize(subtask.genBatch.ValueSize())
+					if err := subtask.genBatch.Write(); err != nil {
+						log.Crit("Failed to persist storage slots", "err", err)
+					}
+					subtask.genBatch.Reset()
+				}
+				continue
+			}
+			// Large contract fully retrieved, flush its trie and drop the subtask
+			if _, err := subtask.genTrie.Commit(); err != nil {
+				log.Crit("Failed to commit storage trie", "err", err)
+			}
+			s.storageBytes += common.StorageSize(subtask.genBatch.ValueSize())
+			if err := subtask.genBatch.Write(); err != nil {
+				log.Crit("Failed to persist storage slots", "err", err)
+			}
+			delete(res.mainTask.SubTasks, account)
+		} else {
+			// Small contract fully retrieved, generate its trie in one go
+			batch := s.newSyncBatch()
+			tr := trie.NewStackTrie(batch)
+			for j, hash := range res.hashes[i] {
+				tr.Update(hash[:], res.slots[i][j])
+			}
+			if _, err := tr.Commit(); err != nil {
+				log.Crit("Failed to commit storage trie", "err", err)
+			}
+			s.storageBytes += common.StorageSize(batch.ValueSize())
+			if err := batch.Write(); err != nil {
+				log.Crit("Failed to persist storage slots", "err", err)
+			}
+		}
+		// The storage of the account is complete, mark it not needed any more
+		if j := res.mainTask.index(account); j >= 0 && res.mainTask.needState[j] {
+			res.mainTask.needState[j] = false
+			res.mainTask.pend--
+		}
+	}
+	log.Debug("Persisted set of storage slots", "accounts", len(res.hashes), "slots", slots)
+
+	// If this delivery completed the last pending task, forward the account task
+	// to the next chunk
+	if res.mainTask.pend == 0 {
+		s.forwardAccountTask(res.mainTask)
+	}
+}
+
+// processTrienodeHealResponse integrates an already validated trienode response
+// into the healer tasks.
+func (s *Syncer) processTrienodeHealResponse(res *trienodeHealResponse) {
+	for i, hash := range res.hashes {
+		node := res.nodes[i]
+
+		// If the trie node was not delivered, reschedule it
+		if node == nil {
+			s.healer.trieTasks[hash] = res.paths[i]
+			continue
+		}
+		// Push the trie node into the state syncer
+		s.trienodeHealSynced++
+		s.trienodeHealBytes += common.StorageSize(len(node))
+
+		err := s.healer.scheduler.Process(trie.SyncResult{Hash: hash, Data: node})
+		switch err {
+		case nil:
+		case trie.ErrAlreadyProcessed:
+			s.trienodeHealDups++
+		case trie.ErrNotRequested:
+			s.trienodeHealNops++
+		default:
+			log.Error("Invalid trienode processed", "hash", hash, "err", err)
+		}
+	}
+	s.commitHealer()
+}
+
+// processBytecodeHealResponse integrates an already validated bytecode response
+// into the healer tasks.
+func (s *Syncer) processBytecodeHealResponse(res *bytecodeResponse) {
+	for i, hash := range res.hashes {
+		node := res.codes[i]
+
+		// If the bytecode was not delivered, reschedule it
+		if node == nil {
+			s.healer.codeTasks[hash] = struct{}{}
+			continue
+		}
+		// Push the bytecode into the state syncer
+		s.bytecodeHealSynced++
+		s.bytecodeHealBytes += common.StorageSize(len(node))
+
+		err := s.healer.scheduler.Process(trie.SyncResult{Hash: hash, Data: node})
+		switch err {
+		case nil:
+		case trie.ErrAlreadyProcessed:
+			s.bytecodeHealDups++
+		case trie.ErrNotRequested:
+			s.bytecodeHealNops++
+		default:
+			log.Error("Invalid bytecode processed", "hash", hash, "err", err)
+		}
+	}
+	s.commitHealer()
+}
+
+// commitHealer flushes the healed state data accumulated by the trie scheduler
+// into the database.
+func (s *Syncer) commitHealer() {
+	batch := s.db.NewBatch()
+	if err := s.healer.scheduler.Commit(batch); err != nil {
+		log.Error("Failed to commit healing data", "err", err)
+	}
+	if err := batch.Write(); err != nil {
+		log.Crit("Failed to persist healing data", "err", err)
+	}
+	log.Debug("Persisted set of healing data", "bytes", common.StorageSize(batch.ValueSize()))
+}
+
+// forwardAccountTask takes a filled account task and persists anything available
+// into the database, after which it forwards the next account marker so that the
+// task's next chunk may be filled.
+func (s *Syncer) forwardAccountTask(task *accountTask) {
+	// Remove any pending delivery
+	res := task.res
+	if res == nil {
+		return // nothing to forward
+	}
+	task.res = nil
+
+	// Iterate over all the accounts and feed them into the trie generator
+	for i, hash := range res.hashes {
+		if task.needCode[i] || task.needState[i] {
+			break
+		}
+		task.genTrie.Update(hash[:], res.blobs[i])
+		task.Next = incHash(hash)
+		s.accountSynced++
+	}
+	// All accounts marked as complete, track if the entire task is done
+	task.done = !res.cont
+
+	// Flush the generated trie nodes into the database if the batch grew large
+	// enough or the chunk was finished
+	if task.done {
+		if _, err := task.genTrie.Commit(); err != nil {
+			log.Error("Failed to commit stack account", "err", err)
+		}
+	}
+	if task.genBatch.ValueSize() > hykdb.IdealBatchSize || task.done {
+		s.accountBytes += common.StorageSize(task.genBatch.ValueSize())
+		if err := task.genBatch.Write(); err != nil {
+			log.Error("Failed to persist stack account", "err", err)
+		}
+		task.genBatch.Reset()
+	}
+	log.Debug("Persisted range of accounts", "accounts", len(res.accounts))
+}
+
+// index returns the position of the given account in the task's currently filling
+// response, or -1 if it's not part of it.
+func (task *accountTask) index(account common.Hash) int {
+	hashes := task.res.hashes
+	i := sort.Search(len(hashes), func(i int) bool {
+		return bytes.Compare(hashes[i][:], account[:]) >= 0
+	})
+	if i < len(hashes) && hashes[i] == account {
+		return i
+	}
+	return -1
+}
+
+// OnAccounts is a callback method to invoke when a range of accounts are
+// received from a remote peer.
+func (s *Syncer) OnAccounts(peer SyncPeer, id uint64, hashes []common.Hash, accounts [][]byte, proof [][]byte) error {
+	size := common.StorageSize(len(hashes) * common.HashLength)
+	for _, account := range accounts {
+		size += common.StorageSize(len(account))
+	}
+	for _, node := range proof {
+		size += common.StorageSize(len(node))
+	}
+	logger := peer.Log().New("reqid", id)
+	logger.Trace("Delivering range of accounts", "hashes", len(hashes), "accounts", len(accounts), "proofs", len(proof), "bytes", size)
+
+	// Whether or not the response is valid, we can mark the peer as idle and
+	// notify the scheduler to assign a new task. If the response is invalid,
+	// we'll drop the peer in a bit.
+	s.lock.Lock()
+	if _, ok := s.peers[peer.ID()]; ok {
+		s.idlers[peer.ID()] = struct{}{}
+	}
+	s.notify()
+
+	// Ensure the response is for a valid request
+	req, ok := s.accountReqs[id]
+	if !ok {
+		// Request stale, perhaps the peer timed out but came through in the end
+		logger.Warn("Unexpected account range packet")
+		s.lock.Unlock()
+		return nil
+	}
+	delete(s.accountReqs, id)
+
+	// Clean up the request timeout timer, we'll see how to proceed further based
+	// on the actual delivered content
+	req.timeout.Stop()
+
+	// Response is valid, but check if peer is signalling that it does not have
+	// the requested data. For account range queries that means the state being
+	// retrieved was either already pruned remotely, or the peer is not yet
+	// synced to our head.
+	if len(hashes) == 0 && len(accounts) == 0 && len(proof) == 0 {
+		logger.Debug("Peer rejected account range request", "root", req.root)
+		s.statelessPeers[peer.ID()] = struct{}{}
+		s.lock.Unlock()
+
+		// Signal this request as failed, and ready for rescheduling
+		s.scheduleRevertAccountRequest(req)
+		return nil
+	}
+	s.lock.Unlock()
+
+	// Reconstruct a partial trie from the response and verify it
+	keys := make([][]byte, len(hashes))
+	for i, key := range hashes {
+		keys[i] = common.CopyBytes(key[:])
+	}
+	var end []byte
+	if len(keys) > 0 {
+		end = keys[len(keys)-1]
+	}
+	err, cont := trie.VerifyRangeProof(req.root, req.origin[:], end, keys, accounts, proofDatabase(proof))
+	if err != nil {
+		logger.Warn("Account range failed proof", "err", err)
+		// Signal this request as failed, and ready for rescheduling
+		s.scheduleRevertAccountRequest(req)
+		return err
+	}
+	accs := make([]*state.Account, len(accounts))
+	for i, account := range accounts {
+		acc := new(state.Account)
+		if err := rlp.DecodeBytes(account, acc); err != nil {
+			panic(err) // We created these blobs, we must be able to decode them
+		}
+		accs[i] = acc
+	}
+	response := &accountResponse{
+		task:     req.task,
+		hashes:   hashes,
+		accounts: accs,
+		blobs:    accounts,
+		cont:     cont,
+	}
+	select {
+	case s.accountResps <- response:
+	case <-req.term:
+	}
+	return nil
+}
+
+// OnByteCodes is a callback method to invoke when a batch of contract
+// bytes codes are received from a remote peer.
+func (s *Syncer) OnByteCodes(peer SyncPeer, id uint64, bytecodes [][]byte) error {
+	s.lock.RLock()
+	syncing := !s.snapped
+	s.lock.RUnlock()
+
+	if syncing {
+		return s.onByteCodes(peer, id, bytecodes)
+	}
+	return s.onHealByteCodes(peer, id, bytecodes)
+}
+
+// onByteCodes is a callback method to invoke when a batch of contract
+// bytes codes are received from a remote peer in the syncing phase.
+func (s *Syncer) onByteCodes(peer SyncPeer, id uint64, bytecodes [][]byte) error {
+	var size common.StorageSize
+	for _, code := range bytecodes {
+		size += common.StorageSize(len(code))
+	}
+	logger := peer.Log().New("reqid", id)
+	logger.Trace("Delivering set of bytecodes", "bytecodes", len(bytecodes), "bytes", size)
+
+	// Whether or not the response is valid, we can mark the peer as idle and
+	// notify the scheduler to assign a new task. If the response is invalid,
+	// we'll drop the peer in a bit.
+	s.lock.Lock()
+	if _, ok := s.peers[peer.ID()]; ok {
+		s.idlers[peer.ID()] = struct{}{}
+	}
+	s.notify()
+
+	// Ensure the response is for a valid request
+	req, ok := s.bytecodeReqs[id]
+	if !ok {
+		// Request stale, perhaps the peer timed out but came through in the end
+		logger.Warn("Unexpected bytecode packet")
+		s.lock.Unlock()
+		return nil
+	}
+	delete(s.bytecodeReqs, id)
+
+	// Clean up the request timeout timer, we'll see how to proceed further based
+	// on the actual delivered content
+	req.timeout.Stop()
+
+	// Response is valid, but check if peer is signalling that it does not have
+	// the requested data. For bytecode range queries that means the peer is not
+	// yet synced.
+	if len(bytecodes) == 0 {
+		logger.Debug("Peer rejected bytecode request")
+		s.statelessPeers[peer.ID()] = struct{}{}
+		s.lock.Unlock()
+
+		// Signal this request as failed, and ready for rescheduling
+		s.scheduleRevertBytecodeRequest(req)
+		return nil
+	}
+	s.lock.Unlock()
+
+	// Cross reference the requested bytecodes with the response to find gaps
+	// that the serving node is missing
+	codes, err := matchByteCodes(req.hashes, bytecodes)
+	if err != nil {
+		logger.Warn("Unexpected bytecodes", "count", len(bytecodes))
+		s.scheduleRevertBytecodeRequest(req)
+		return err
+	}
+	// Response validated, send it to the scheduler for filling
+	response := &bytecodeResponse{
+		task:   req.task,
+		hashes: req.hashes,
+		codes:  codes,
+	}
+	select {
+	case s.bytecodeResps <- response:
+	case <-req.term:
+	}
+	return nil
+}
+
+// OnStorage is a callback method to invoke when ranges of storage slots
+// are received from a remote peer.
+func (s *Syncer) OnStorage(peer SyncPeer, id uint64, hashes [][]common.Hash, slots [][][]byte, proof [][]byte) error {
+	// Gather some trace stats to aid in debugging issues
+	var (
+		hashCount int
+		slotCount int
+		size      common.StorageSize
+	)
+	for _, hashset := range hashes {
+		size += common.StorageSize(common.HashLength * len(hashset))
+		hashCount += len(hashset)
+	}
+	for _, slotset := range slots {
+		for _, slot := range slotset {
+			size += common.StorageSize(len(slot))
+		}
+		slotCount += len(slotset)
+	}
+	for _, node := range proof {
+		size += common.StorageSize(len(node))
+	}
+	logger := peer.Log().New("reqid", id)
+	logger.Trace("Delivering ranges of storage slots", "accounts", len(hashes), "hashes", hashCount, "slots", slotCount, "proofs", len(proof), "size", size)
+
+	// Whether or not the response is valid, we can mark the peer as idle and
+	// notify the scheduler to assign a new task. If the response is invalid,
+	// we'll drop the peer in a bit.
+	s.lock.Lock()
+	if _, ok := s.peers[peer.ID()]; ok {
+		s.idlers[peer.ID()] = struct{}{}
+	}
+	s.notify()
+
+	// Ensure the response is for a valid request
+	req, ok := s.storageReqs[id]
+	if !ok {
+		// Request stale, perhaps the peer timed out but came through in the end
+		logger.Warn("Unexpected storage ranges packet")
+		s.lock.Unlock()
+		return nil
+	}
+	delete(s.storageReqs, id)
+
+	// Clean up the request timeout timer, we'll see how to proceed further based
+	// on the actual delivered content
+	req.timeout.Stop()
+
+	// Reject the response if the hash sets and slot sets don't match, or if the
+	// peer sent more data than requested.
+	if len(hashes) != len(slots) {
+		s.lock.Unlock()
+		s.scheduleRevertStorageRequest(req) // reschedule request
+		logger.Warn("Hash and slot set size mismatch", "hashset", len(hashes), "slotset", len(slots))
+		return errors.New("hash and slot set size mismatch")
+	}
+	if len(hashes) > len(req.accounts) {
+		s.lock.Unlock()
+		s.scheduleRevertStorageRequest(req) // reschedule request
+		logger.Warn("Hash set larger than requested", "hashset", len(hashes), "requested", len(req.accounts))
+		return errors.New("hash set larger than requested")
+	}
+	// Response is valid, but check if peer is signalling that it does not have
+	// the requested data. For storage range queries that means the state being
+	// retrieved was either already pruned remotely, or the peer is not yet
+	// synced to our head.
+	if len(hashes) == 0 {
+		logger.Debug("Peer rejected storage request")
+		s.statelessPeers[peer.ID()] = struct{}{}
+		s.lock.Unlock()
+		s.scheduleRevertStorageRequest(req) // reschedule request
+		return nil
+	}
+	s.lock.Unlock()
+
+	// Reconstruct the partial tries from the response and verify them
+	var cont bool
+
+	for i := 0; i < len(hashes); i++ {
+		// Convert the keys and proofs into an internal format
+		keys := make([][]byte, len(hashes[i]))
+		for j, key := range hashes[i] {
+			keys[j] = common.CopyBytes(key[:])
+		}
+		// If the range was not the last one or no proofs were attached, the
+		// storage trie must be complete and we can verify the entire range
+		if i < len(hashes)-1 || len(proof) == 0 {
+			if err, _ := trie.VerifyRangeProof(req.roots[i], nil, nil, keys, slots[i], nil); err != nil {
+				logger.Warn("Storage slots failed proof", "err", err)
+				s.scheduleRevertStorageRequest(req) // reschedule request
+				return err
+			}
+			continue
+		}
+		// The last range was chunked, validate the edge proofs
+		var end []byte
+		if len(keys) > 0 {
+			end = keys[len(keys)-1]
+		}
+		var err error
+		err, cont = trie.VerifyRangeProof(req.roots[i], req.origin[:], end, keys, slots[i], proofDatabase(proof))
+		if err != nil {
+			logger.Warn("Storage range failed proof", "err", err)
+			s.scheduleRevertStorageRequest(req) // reschedule request
+			return err
+		}
+	}
+	// Partial tries reconstructed, send them to the scheduler for storage filling
+	response := &storageResponse{
+		mainTask: req.mainTask,
+		subTask:  req.subTask,
+		accounts: req.accounts,
+		roots:    req.roots,
+		hashes:   hashes,
+		slots:    slots,
+		cont:     cont,
+	}
+	select {
+	case s.storageResps <- response:
+	case <-req.term:
+	}
+	return nil
+}
+
+// OnTrieNodes is a callback method to invoke when a batch of trie nodes
+// are received from a remote peer.
+func (s *Syncer) OnTrieNodes(peer SyncPeer, id uint64, trienodes [][]byte) error {
+	var size common.StorageSize
+	for _, node := range trienodes {
+		size += common.StorageSize(len(node))
+	}
+	logger := peer.Log().New("reqid", id)
+	logger.Trace("Delivering set of healing trienodes", "trienodes", len(trienodes), "bytes", size)
+
+	// Whether or not the response is valid, we can mark the peer as idle and
+	// notify the scheduler to assign a new task. If the response is invalid,
+	// we'll drop the peer in a bit.
+	s.lock.Lock()
+	if _, ok := s.peers[peer.ID()]; ok {
+		s.idlers[peer.ID()] = struct{}{}
+	}
+	s.notify()
+
+	// Ensure the response is for a valid request
+	req, ok := s.trienodeHealReqs[id]
+	if !ok {
+		// Request stale, perhaps the peer timed out but came through in the end
+		logger.Warn("Unexpected trienode heal packet")
+		s.lock.Unlock()
+		return nil
+	}
+	delete(s.trienodeHealReqs, id)
+
+	// Clean up the request timeout timer, we'll see how to proceed further based
+	// on the actual delivered content
+	req.timeout.Stop()
+
+	// Response is valid, but check if peer is signalling that it does not have
+	// the requested data. For bytecode range queries that means the peer is not
+	// yet synced.
+	if len(trienodes) == 0 {
+		logger.Debug("Peer rejected trienode heal request")
+		s.statelessPeers[peer.ID()] = struct{}{}
+		s.lock.Unlock()
+
+		// Signal this request as failed, and ready for rescheduling
+		s.scheduleRevertTrienodeHealRequest(req)
+		return nil
+	}
+	s.lock.Unlock()
+
+	// Cross reference the requested trienodes with the response to find gaps
+	// that the serving node is missing
+	hasher := sha3.NewLegacyKeccak256().(crypto.KeccakState)
+	hash := make([]byte, 32)
+
+	nodes := make([][]byte, len(req.hashes))
+	for i, j := 0, 0; i < len(trienodes); i++ {
+		// Find the next hash that we've been served, leaving misses with nils
+		hasher.Reset()
+		hasher.Write(trienodes[i])
+		hasher.Read(hash)
+
+		for j < len(req.hashes) && !bytes.Equal(hash, req.hashes[j][:]) {
+			j++
+		}
+		if j < len(req.hashes) {
+			nodes[j] = trienodes[i]
+			j++
+			continue
+		}
+		// We've either ran out of hashes, or got unrequested data
+		logger.Warn("Unexpected healing trienodes", "count", len(trienodes)-i)
+		// Signal this request as failed, and ready for rescheduling
+		s.scheduleRevertTrienodeHealRequest(req)
+		return errors.New("unexpected healing trienode")
+	}
+	// Response validated, send it to the scheduler for filling
+	response := &trienodeHealResponse{
+		hashes: req.hashes,
+		paths:  req.paths,
+		nodes:  nodes,
+	}
+	select {
+	case s.trienodeHealResps <- response:
+	case <-req.term:
+	}
+	return nil
+}
+
+// onHealByteCodes is a callback method to invoke when a batch of contract
+// bytes codes are received from a remote peer in the healing phase.
+func (s *Syncer) onHealByteCodes(peer SyncPeer, id uint64, bytecodes [][]byte) error {
+	var size common.StorageSize
+	for _, code := range bytecodes {
+		size += common.StorageSize(len(code))
+	}
+	logger := peer.Log().New("reqid", id)
+	logger.Trace("Delivering set of healing bytecodes", "bytecodes", len(bytecodes), "bytes", size)
+
+	// Whether or not the response is valid, we can mark the peer as idle and
+	// notify the scheduler to assign a new task. If the response is invalid,
+	// we'll drop the peer in a bit.
+	s.lock.Lock()
+	if _, ok := s.peers[peer.ID()]; ok {
+		s.idlers[peer.ID()] = struct{}{}
+	}
+	s.notify()
+
+	// Ensure the response is for a valid request
+	req, ok := s.bytecodeHealReqs[id]
+	if !ok {
+		// Request stale, perhaps the peer timed out but came through in the end
+		logger.Warn("Unexpected bytecode heal packet")
+		s.lock.Unlock()
+		return nil
+	}
+	delete(s.bytecodeHealReqs, id)
+
+	// Clean up the request timeout timer, we'll see how to proceed further based
+	// on the actual delivered content
+	req.timeout.Stop()
+
+	// Response is valid, but check if peer is signalling that it does not have
+	// the requested data. For bytecode range queries that means the peer is not
+	// yet synced.
+	if len(bytecodes) == 0 {
+		logger.Debug("Peer rejected bytecode heal request")
+		s.statelessPeers[peer.ID()] = struct{}{}
+		s.lock.Unlock()
+
+		// Signal this request as failed, and ready for rescheduling
+		s.scheduleRevertBytecodeHealRequest(req)
+		return nil
+	}
+	s.lock.Unlock()
+
+	// Cross reference the requested bytecodes with the response to find gaps
+	// that the serving node is missing
+	codes, err := matchByteCodes(req.hashes, bytecodes)
+	if err != nil {
+		logger.Warn("Unexpected healing bytecodes", "count", len(bytecodes))
+		s.scheduleRevertBytecodeHealRequest(req)
+		return err
+	}
+	// Response validated, send it to the scheduler for filling
+	response := &bytecodeResponse{
+		hashes: req.hashes,
+		codes:  codes,
+	}
+	select {
+	case s.bytecodeHealResps <- response:
+	case <-req.term:
+	}
+	return nil
+}
+
+// report calculates various status reports and provides it to the user.
+func (s *Syncer) report(force bool) {
+	if len(s.tasks) > 0 {
+		s.reportSyncProgress(force)
+		return
+	}
+	s.reportHealProgress(force)
+}
+
+// reportSyncProgress calculates various status reports and provides it to the user.
+func (s *Syncer) reportSyncProgress(force bool) {
+	// Don't report all the events, just occasionally
+	if !force && time.Since(s.logTime) < 3*time.Second {
+		return
+	}
+	// Don't report anything until we have a meaningful progress
+	synced := s.accountBytes + s.bytecodeBytes + s.storageBytes
+	if synced == 0 {
+		return
+	}
+	accountGaps := new(big.Int)
+	for _, task := range s.tasks {
+		accountGaps.Add(accountGaps, new(big.Int).Sub(task.Last.Big(), task.Next.Big()))
+	}
+	accountFills := new(big.Int).Sub(hashSpace, accountGaps)
+	if accountFills.BitLen() == 0 {
+		return
+	}
+	s.logTime = time.Now()
+	estBytes := float64(new(big.Int).Div(
+		new(big.Int).Mul(new(big.Int).SetUint64(uint64(synced)), hashSpace),
+		accountFills,
+	).Uint64())
+
+	elapsed := time.Since(s.startTime)
+	estTime := elapsed / time.Duration(synced) * time.Duration(estBytes)
+
+	// Create a mega progress report
+	var (
+		progress = fmt.Sprintf("%.2f%%", float64(synced)*100/estBytes)
+		accounts = fmt.Sprintf("%d@%v", s.accountSynced, s.accountBytes.TerminalString())
+		storage  = fmt.Sprintf("%d@%v", s.storageSynced, s.storageBytes.TerminalString())
+		bytecode = fmt.Sprintf("%d@%v", s.bytecodeSynced, s.bytecodeBytes.TerminalString())
+	)
+	log.Info("State sync in progress", "synced", progress, "state", synced,
+		"accounts", accounts, "slots", storage, "codes", bytecode, "eta", common.PrettyDuration(estTime-elapsed))
+}
+
+// reportHealProgress calculates various status reports and provides it to the user.
+func (s *Syncer) reportHealProgress(force bool) {
+	// Don't report all the events, just occasionally
+	if !force && time.Since(s.logTime) < 3*time.Second {
+		return
+	}
+	s.logTime = time.Now()
+
+	// Create a mega progress report
+	var (
+		trienode = fmt.Sprintf("%d@%v", s.trienodeHealSynced, s.trienodeHealBytes.TerminalString())
+		bytecode = fmt.Sprintf("%d@%v", s.bytecodeHealSynced, s.bytecodeHealBytes.TerminalString())
+	)
+	log.Info("State heal in progress", "nodes", trienode, "codes", bytecode,
+		"pending", s.healer.scheduler.Pending())
+}
+
+// syncBatch is a database batch that additionally tracks all the written keys
+// in the state sync bloom filter, allowing the healer to skip them.
+type syncBatch struct {
+	hykdb.Batch
+	bloom *trie.SyncBloom
+}
+
+// newSyncBatch creates a database batch that tracks written keys in the bloom
+// filter of the syncer.
+func (s *Syncer) newSyncBatch() hykdb.Batch {
+	return &syncBatch{Batch: s.db.NewBatch(), bloom: s.bloom}
+}
+
+// Put inserts the given value into the batch, and marks the key as present in
+// the bloom filter.
+func (b *syncBatch) Put(key, value []byte) error {
+	if b.bloom != nil {
+		b.bloom.Add(key)
+	}
+	return b.Batch.Put(key, value)
+}
+
+// bloomAdd marks a hash as present in the sync bloom filter, if any.
+func (s *Syncer) bloomAdd(hash []byte) {
+	if s.bloom != nil {
+		s.bloom.Add(hash)
+	}
+}
+
+// hashSpace is the total size of the 256 bit hash space for accounts.
+var hashSpace = new(big.Int).Exp(common.Big2, common.Big256, nil)
+
+// incHash returns the next hash, in lexicographical order (a.k.a plus one).
+func incHash(h common.Hash) common.Hash {
+	a := new(big.Int).SetBytes(h[:])
+	a.Add(a, common.Big1)
+	return common.BigToHash(a)
+}
+
+// proofDatabase converts a list of Merkle proof nodes into a key-value store
+// keyed by their hashes. A nil reader is returned if no proofs were supplied,
+// signalling that the delivered range must be the entire trie.
+func proofDatabase(proof [][]byte) hykdb.KeyValueReader {
+	if len(proof) == 0 {
+		return nil
+	}
+	db := memorydb.New()
+	for _, node := range proof {
+		db.Put(crypto.Keccak256(node), node)
+	}
+	return db
+}
+
+// matchByteCodes cross references the requested bytecode hashes with the ones
+// delivered, returning the codes in request order with nils for the missing
+// ones. Any delivered code not requested (or out of order) is an error.
+func matchByteCodes(hashes []common.Hash, bytecodes [][]byte) ([][]byte, error) {
+	hasher := sha3.NewLegacyKeccak256().(crypto.KeccakState)
+	hash := make([]byte, 32)
+
+	codes := make([][]byte, len(hashes))
+	for i, j := 0, 0; i < len(bytecodes); i++ {
+		// Find the next hash that we've been served, leaving misses with nils
+		hasher.Reset()
+		hasher.Write(bytecodes[i])
+		hasher.Read(hash)
+
+		for j < len(hashes) && !bytes.Equal(hash, hashes[j][:]) {
+			j++
+		}
+		if j < len(hashes) {
+			codes[j] = bytecodes[i]
+			j++
+			continue
+		}
+		// We've either ran out of hashes, or got unrequested data
+		return nil, errors.New("unexpected bytecode")
+	}
+	return codes, nil
+}
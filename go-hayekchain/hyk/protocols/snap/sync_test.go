@@ -0,0 +1,378 @@
+// Copyright 2021 The go-hayekchain Authors
+// This file is part of the go-hayekchain library.
+//
+// The go-hayekchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hayekchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hayekchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package snap
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/hayekchain/go-hayekchain/common"
+	"github.com/hayekchain/go-hayekchain/consensus/hykash"
+	"github.com/hayekchain/go-hayekchain/core"
+	"github.com/hayekchain/go-hayekchain/core/rawdb"
+	"github.com/hayekchain/go-hayekchain/core/state"
+	"github.com/hayekchain/go-hayekchain/core/types"
+	"github.com/hayekchain/go-hayekchain/core/vm"
+	"github.com/hayekchain/go-hayekchain/crypto"
+	"github.com/hayekchain/go-hayekchain/hykdb"
+	"github.com/hayekchain/go-hayekchain/log"
+	"github.com/hayekchain/go-hayekchain/p2p"
+	"github.com/hayekchain/go-hayekchain/p2p/enode"
+	"github.com/hayekchain/go-hayekchain/params"
+	"github.com/hayekchain/go-hayekchain/trie"
+)
+
+var (
+	testKey, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	testAddress = crypto.PubkeyToAddress(testKey.PublicKey)
+)
+
+// newTestChain creates a short snapshotted chain on top of a genesis state
+// containing a mix of plain accounts, small contracts and a few contracts with
+// a storage too large to be served in a single response.
+func newTestChain(t *testing.T, accounts, contracts, slots int) *core.BlockChain {
+	alloc := core.GenesisAlloc{testAddress: {Balance: big.NewInt(1000000000)}}
+	for i := 0; i < accounts; i++ {
+		alloc[common.BigToAddress(big.NewInt(int64(i+1)))] = core.GenesisAccount{Balance: big.NewInt(int64(i + 1))}
+	}
+	for i := 0; i < contracts; i++ {
+		storage := make(map[common.Hash]common.Hash)
+		for j := 0; j < slots*(i+1)/contracts; j++ {
+			storage[common.BigToHash(big.NewInt(int64(j)))] = crypto.Keccak256Hash(big.NewInt(int64(i*slots + j)).Bytes())
+		}
+		alloc[common.BigToAddress(big.NewInt(int64(1000000+i)))] = core.GenesisAccount{
+			Balance: big.NewInt(1),
+			Code:    []byte{byte(vm.PUSH1), byte(i), byte(vm.PUSH1), byte(i >> 8), byte(vm.SSTORE)},
+			Storage: storage,
+		}
+	}
+	var (
+		db    = rawdb.NewMemoryDatabase()
+		gspec = &core.Genesis{Config: params.TestChainConfig, Alloc: alloc}
+	)
+	genesis := gspec.MustCommit(db)
+
+	cacheConfig := &core.CacheConfig{
+		TrieCleanLimit:    256,
+		TrieDirtyDisabled: true,
+		SnapshotLimit:     256,
+		SnapshotWait:      true,
+	}
+	chain, err := core.NewBlockChain(db, cacheConfig, gspec.Config, hykash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create blockchain: %v", err)
+	}
+	// Extend the chain with a few blocks each creating a new account
+	signer := types.LatestSigner(gspec.Config)
+	blocks, _ := core.GenerateChain(gspec.Config, genesis, hykash.NewFaker(), db, 2, func(i int, block *core.BlockGen) {
+		tx, err := types.SignTx(types.NewTransaction(block.TxNonce(testAddress), common.Address{byte(i + 1)}, big.NewInt(1000), params.TxGas, big.NewInt(1), nil), signer, testKey)
+		if err != nil {
+			t.Fatalf("failed to sign transaction: %v", err)
+		}
+		block.AddTx(tx)
+	})
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to import chain: %v", err)
+	}
+	return chain
+}
+
+// testPeer is a snap sync data source serving requests straight from a local
+// chain, bypassing the networking layer.
+type testPeer struct {
+	id     string
+	chain  *core.BlockChain
+	syncer *Syncer
+	logger log.Logger
+
+	stateless bool // Whether to refuse serving any state data
+}
+
+func newTestPeer(id string, chain *core.BlockChain, syncer *Syncer) *testPeer {
+	return &testPeer{
+		id:     id,
+		chain:  chain,
+		syncer: syncer,
+		logger: log.New("id", id),
+	}
+}
+
+func (p *testPeer) ID() string      { return p.id }
+func (p *testPeer) Log() log.Logger { return p.logger }
+
+func (p *testPeer) RequestAccountRange(id uint64, root, origin, limit common.Hash, bytes uint64) error {
+	packet := &AccountRangePacket{ID: id}
+	if !p.stateless {
+		packet.Accounts, packet.Proof = ServiceGetAccountRangeQuery(p.chain, &GetAccountRangePacket{
+			ID:     id,
+			Root:   root,
+			Origin: origin,
+			Limit:  limit,
+			Bytes:  bytes,
+		})
+	}
+	go func() {
+		hashes, accounts, err := packet.Unpack()
+		if err != nil {
+			panic(err)
+		}
+		p.syncer.OnAccounts(p, id, hashes, accounts, packet.Proof)
+	}()
+	return nil
+}
+
+func (p *testPeer) RequestStorageRanges(id uint64, root common.Hash, accounts []common.Hash, origin, limit []byte, bytes uint64) error {
+	packet := &StorageRangesPacket{ID: id}
+	if !p.stateless {
+		packet.Slots, packet.Proof = ServiceGetStorageRangesQuery(p.chain, &GetStorageRangesPacket{
+			ID:       id,
+			Root:     root,
+			Accounts: accounts,
+			Origin:   origin,
+			Limit:    limit,
+			Bytes:    bytes,
+		})
+	}
+	go func() {
+		hashes, slots := packet.Unpack()
+		p.syncer.OnStorage(p, id, hashes, slots, packet.Proof)
+	}()
+	return nil
+}
+
+func (p *testPeer) RequestByteCodes(id uint64, hashes []common.Hash, bytes uint64) error {
+	var codes [][]byte
+	if !p.stateless {
+		codes = ServiceGetByteCodesQuery(p.chain, &GetByteCodesPacket{ID: id, Hashes: hashes, Bytes: bytes})
+	}
+	go p.syncer.OnByteCodes(p, id, codes)
+	return nil
+}
+
+func (p *testPeer) RequestTrieNodes(id uint64, root common.Hash, paths []TrieNodePathSet, bytes uint64) error {
+	var nodes [][]byte
+	if !p.stateless {
+		var err error
+		if nodes, err = ServiceGetTrieNodesQuery(p.chain, &GetTrieNodesPacket{ID: id, Root: root, Paths: paths, Bytes: bytes}); err != nil {
+			return err
+		}
+	}
+	go p.syncer.OnTrieNodes(p, id, nodes)
+	return nil
+}
+
+// runSync runs a snap sync cycle against the given root, failing the test if
+// it does not finish in a reasonable time.
+func runSync(t *testing.T, syncer *Syncer, root common.Hash) {
+	done := make(chan error, 1)
+	cancel := make(chan struct{})
+	go func() { done <- syncer.Sync(root, cancel) }()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("sync failed: %v", err)
+		}
+	case <-time.After(30 * time.Second):
+		close(cancel)
+		t.Fatalf("sync timed out, err: %v", <-done)
+	}
+}
+
+// checkSyncedState verifies that the state rooted at the given hash is fully
+// available in the database, including all storage tries and contract codes.
+func checkSyncedState(t *testing.T, db hykdb.Database, root common.Hash) {
+	statedb, err := state.New(root, state.NewDatabase(db), nil)
+	if err != nil {
+		t.Fatalf("failed to open synced state %x: %v", root, err)
+	}
+	it := state.NewNodeIterator(statedb)
+	for it.Next() {
+	}
+	if it.Error != nil {
+		t.Fatalf("synced state %x incomplete: %v", root, it.Error)
+	}
+}
+
+// Tests that the state can be snap synced from a set of peers, including large
+// contracts whose storage needs to be chunked across multiple requests.
+func TestSync(t *testing.T) {
+	source := newTestChain(t, 2000, 10, 10000)
+	defer source.Stop()
+
+	var (
+		db     = rawdb.NewMemoryDatabase()
+		syncer = NewSyncer(db, trie.NewSyncBloom(1, db))
+	)
+	for i := 0; i < 3; i++ {
+		syncer.Register(newTestPeer(fmt.Sprintf("peer-%d", i), source, syncer))
+	}
+	root := source.CurrentBlock().Root()
+	runSync(t, syncer, root)
+	checkSyncedState(t, db, root)
+
+	// Syncing the same root again should be a noop
+	runSync(t, syncer, root)
+}
+
+// Tests that after syncing a state, a subsequent sync to a newer root heals
+// the differences between the two.
+func TestSyncNewRoot(t *testing.T) {
+	source := newTestChain(t, 1000, 4, 2000)
+	defer source.Stop()
+
+	var (
+		db     = rawdb.NewMemoryDatabase()
+		syncer = NewSyncer(db, nil)
+	)
+	syncer.Register(newTestPeer("peer", source, syncer))
+
+	head := source.CurrentBlock()
+	parent := source.GetBlockByHash(head.ParentHash())
+
+	runSync(t, syncer, parent.Root())
+	checkSyncedState(t, db, parent.Root())
+
+	runSync(t, syncer, head.Root())
+	checkSyncedState(t, db, head.Root())
+}
+
+// Tests that peers refusing to serve state data are skipped and the sync
+// completes using the remaining ones.
+func TestSyncWithStatelessPeer(t *testing.T) {
+	source := newTestChain(t, 500, 4, 1000)
+	defer source.Stop()
+
+	var (
+		db     = rawdb.NewMemoryDatabase()
+		syncer = NewSyncer(db, nil)
+	)
+	stateless := newTestPeer("stateless", source, syncer)
+	stateless.stateless = true
+
+	syncer.Register(stateless)
+	syncer.Register(newTestPeer("full", source, syncer))
+
+	root := source.CurrentBlock().Root()
+	runSync(t, syncer, root)
+	checkSyncedState(t, db, root)
+}
+
+// Tests that a sync with no capable peers can be cancelled.
+func TestSyncCancel(t *testing.T) {
+	source := newTestChain(t, 10, 1, 10)
+	defer source.Stop()
+
+	var (
+		db     = rawdb.NewMemoryDatabase()
+		syncer = NewSyncer(db, nil)
+	)
+	peer := newTestPeer("stateless", source, syncer)
+	peer.stateless = true
+	syncer.Register(peer)
+
+	done := make(chan error, 1)
+	cancel := make(chan struct{})
+	go func() { done <- syncer.Sync(source.CurrentBlock().Root(), cancel) }()
+
+	time.Sleep(50 * time.Millisecond)
+	close(cancel)
+
+	select {
+	case err := <-done:
+		if err != errCancelled {
+			t.Fatalf("sync error mismatch: have %v, want %v", err, errCancelled)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("sync not cancelled")
+	}
+}
+
+// testBackend is a snap protocol backend serving data from a local chain and
+// delivering any received data packets to a syncer.
+type testBackend struct {
+	chain  *core.BlockChain
+	syncer *Syncer
+}
+
+func (b *testBackend) Chain() *core.BlockChain { return b.chain }
+
+func (b *testBackend) RunPeer(peer *Peer, handler Handler) error {
+	if b.syncer != nil {
+		if err := b.syncer.Register(peer); err != nil {
+			return err
+		}
+		defer b.syncer.Unregister(peer.ID())
+	}
+	return handler(peer)
+}
+
+func (b *testBackend) PeerInfo(id enode.ID) interface{} { return nil }
+
+func (b *testBackend) Handle(peer *Peer, packet Packet) error {
+	switch packet := packet.(type) {
+	case *AccountRangePacket:
+		hashes, accounts, err := packet.Unpack()
+		if err != nil {
+			return err
+		}
+		return b.syncer.OnAccounts(peer, packet.ID, hashes, accounts, packet.Proof)
+
+	case *StorageRangesPacket:
+		hashset, slotset := packet.Unpack()
+		return b.syncer.OnStorage(peer, packet.ID, hashset, slotset, packet.Proof)
+
+	case *ByteCodesPacket:
+		return b.syncer.OnByteCodes(peer, packet.ID, packet.Codes)
+
+	case *TrieNodesPacket:
+		return b.syncer.OnTrieNodes(peer, packet.ID, packet.Nodes)
+
+	default:
+		return fmt.Errorf("unexpected snap packet type: %T", packet)
+	}
+}
+
+// Tests that the state can be snap synced over the wire protocol between two
+// in-process peers.
+func TestSyncOverProtocol(t *testing.T) {
+	source := newTestChain(t, 1000, 4, 2000)
+	defer source.Stop()
+
+	var (
+		db     = rawdb.NewMemoryDatabase()
+		syncer = NewSyncer(db, nil)
+
+		server = &testBackend{chain: source}
+		client = &testBackend{chain: source, syncer: syncer}
+
+		serverRW, clientRW = p2p.MsgPipe()
+		serverPeer         = NewPeer(snap1, p2p.NewPeer(enode.ID{1}, "client", nil), serverRW)
+		clientPeer         = NewPeer(snap1, p2p.NewPeer(enode.ID{2}, "server", nil), clientRW)
+	)
+	defer serverRW.Close()
+	defer clientRW.Close()
+
+	go server.RunPeer(serverPeer, func(peer *Peer) error { return handle(server, peer) })
+	go client.RunPeer(clientPeer, func(peer *Peer) error { return handle(client, peer) })
+
+	root := source.CurrentBlock().Root()
+	runSync(t, syncer, root)
+	checkSyncedState(t, db, root)
+}
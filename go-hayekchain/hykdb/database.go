@@ -81,6 +81,10 @@ type AncientReader interface {
 
 	// AncientSize returns the ancient size of the specified category.
 	AncientSize(kind string) (uint64, error)
+
+	// AncientTail returns the number of the first item whose prunable ancient
+	// data (block bodies and receipts) is still retained in the ancient store.
+	AncientTail() (uint64, error)
 }
 
 // AncientWriter contains the methods required to write to immutable ancient data.
@@ -92,6 +96,10 @@ type AncientWriter interface {
 	// TruncateAncients discards all but the first n ancient data from the ancient store.
 	TruncateAncients(n uint64) error
 
+	// TruncateAncientTail discards the prunable ancient data (block bodies and
+	// receipts) of all items below tail from the ancient store.
+	TruncateAncientTail(tail uint64) error
+
 	// Sync flushes all in-memory ancient store data to disk.
 	Sync() error
 }
@@ -0,0 +1,430 @@
+// Copyright 2021 The go-hayekchain Authors
+// This file is part of the go-hayekchain library.
+//
+// The go-hayekchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hayekchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hayekchain library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package era implements a portable, checksummed archive format for chain history.
+//
+// An era file holds a fixed-size epoch of consecutive blocks, each stored as its
+// header, body, receipts and total difficulty. The file is a flat sequence of
+// type-length-value entries, each prefixed by an 8 byte header made up of a
+// little endian uint16 type, a little endian uint32 payload length and two
+// reserved zero bytes:
+//
+//	era := Version | block-tuple* | Checksum | BlockIndex
+//	block-tuple := CompressedHeader | CompressedBody | CompressedReceipts | TotalDifficulty
+//
+// Headers, bodies and receipts are stored in their database RLP encoding and are
+// snappy compressed. The total difficulty is a 32 byte little endian integer. The
+// checksum is the keccak256 hash of all block-tuple entries, and the block index
+// records the number of the first block, the offsets of every block-tuple relative
+// to the start of the index entry, and the number of blocks in the file.
+package era
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/golang/snappy"
+	"github.com/hayekchain/go-hayekchain/common"
+	"github.com/hayekchain/go-hayekchain/crypto"
+	"golang.org/x/crypto/sha3"
+)
+
+const (
+	TypeVersion            uint16 = 0x3265
+	TypeCompressedHeader   uint16 = 0x03
+	TypeCompressedBody     uint16 = 0x04
+	TypeCompressedReceipts uint16 = 0x05
+	TypeTotalDifficulty    uint16 = 0x06
+	TypeChecksum           uint16 = 0x07
+	TypeBlockIndex         uint16 = 0x3266
+
+	// MaxEraBatchSize is the number of blocks in a full epoch.
+	MaxEraBatchSize = 8192
+
+	headerSize = 8 // Size of an entry header (type, length, reserved)
+)
+
+var (
+	errNoBlocks       = errors.New("era has no blocks")
+	errTooManyBlocks  = errors.New("era batch size exceeded")
+	errFinalized      = errors.New("era already finalized")
+	errNotConsecutive = errors.New("era blocks not consecutive")
+	errBadChecksum    = errors.New("era checksum mismatch")
+	errOutOfRange     = errors.New("block out of era range")
+)
+
+// Filename returns the canonical name of the era file containing the given
+// epoch of the named network. The checksum prefix makes archives of different
+// chains or forks distinguishable at a glance.
+func Filename(network string, epoch int, checksum common.Hash) string {
+	return fmt.Sprintf("%s-%05d-%x.era", network, epoch, checksum[:4])
+}
+
+// ReadDir returns the era files of the given network in the directory, ordered
+// by epoch. An error is returned if the epochs are not contiguous.
+func ReadDir(dir, network string) ([]string, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		pattern = regexp.MustCompile("^" + regexp.QuoteMeta(network) + `-(\d{5})-[0-9a-f]{8}\.era$`)
+		epochs  = make(map[int]string)
+		sorted  []int
+	)
+	for _, entry := range entries {
+		match := pattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+		epoch, _ := strconv.Atoi(match[1])
+		if prev, ok := epochs[epoch]; ok {
+			return nil, fmt.Errorf("duplicate era files for epoch %d: %s, %s", epoch, prev, entry.Name())
+		}
+		epochs[epoch] = entry.Name()
+		sorted = append(sorted, epoch)
+	}
+	sort.Ints(sorted)
+
+	files := make([]string, 0, len(sorted))
+	for i, epoch := range sorted {
+		if i > 0 && sorted[i-1]+1 != epoch {
+			return nil, fmt.Errorf("missing era file for epoch %d", sorted[i-1]+1)
+		}
+		files = append(files, filepath.Join(dir, epochs[epoch]))
+	}
+	return files, nil
+}
+
+// Builder writes a single era file, one block at a time.
+type Builder struct {
+	w       io.Writer
+	start   *uint64
+	offsets []int64
+	written int64
+	hasher  crypto.KeccakState
+	final   bool
+}
+
+// NewBuilder creates an era builder writing into w.
+func NewBuilder(w io.Writer) *Builder {
+	return &Builder{
+		w:      w,
+		hasher: sha3.NewLegacyKeccak256().(crypto.KeccakState),
+	}
+}
+
+// Add appends a block to the era. The header, body and receipts are expected in
+// their database RLP encoding. Blocks must be added in ascending order.
+func (b *Builder) Add(number uint64, header, body, receipts []byte, td *big.Int) error {
+	if b.final {
+		return errFinalized
+	}
+	if b.start == nil {
+		if err := b.write(TypeVersion, nil, false); err != nil {
+			return err
+		}
+		b.start = &number
+	}
+	if len(b.offsets) >= MaxEraBatchSize {
+		return errTooManyBlocks
+	}
+	if want := *b.start + uint64(len(b.offsets)); number != want {
+		return fmt.Errorf("%w: have %d, want %d", errNotConsecutive, number, want)
+	}
+	b.offsets = append(b.offsets, b.written)
+
+	if err := b.write(TypeCompressedHeader, snappy.Encode(nil, header), true); err != nil {
+		return err
+	}
+	if err := b.write(TypeCompressedBody, snappy.Encode(nil, body), true); err != nil {
+		return err
+	}
+	if err := b.write(TypeCompressedReceipts, snappy.Encode(nil, receipts), true); err != nil {
+		return err
+	}
+	return b.write(TypeTotalDifficulty, encodeTd(td), true)
+}
+
+// Finalize writes the checksum and the block index, closing the era. The returned
+// checksum covers all the block data in the file.
+func (b *Builder) Finalize() (common.Hash, error) {
+	if b.final {
+		return common.Hash{}, errFinalized
+	}
+	if b.start == nil {
+		return common.Hash{}, errNoBlocks
+	}
+	var checksum common.Hash
+	b.hasher.Read(checksum[:])
+	if err := b.write(TypeChecksum, checksum[:], false); err != nil {
+		return common.Hash{}, err
+	}
+	// Offsets are relative to the start of the index entry
+	index := make([]byte, 16+8*len(b.offsets))
+	binary.LittleEndian.PutUint64(index, *b.start)
+	for i, offset := range b.offsets {
+		binary.LittleEndian.PutUint64(index[8+8*i:], uint64(offset-b.written))
+	}
+	binary.LittleEndian.PutUint64(index[8+8*len(b.offsets):], uint64(len(b.offsets)))
+	if err := b.write(TypeBlockIndex, index, false); err != nil {
+		return common.Hash{}, err
+	}
+	b.final = true
+	return checksum, nil
+}
+
+// write emits a single entry, optionally feeding it into the checksum.
+func (b *Builder) write(typ uint16, data []byte, hashed bool) error {
+	var header [headerSize]byte
+	binary.LittleEndian.PutUint16(header[0:], typ)
+	binary.LittleEndian.PutUint32(header[2:], uint32(len(data)))
+
+	for _, blob := range [][]byte{header[:], data} {
+		if _, err := b.w.Write(blob); err != nil {
+			return err
+		}
+		if hashed {
+			b.hasher.Write(blob)
+		}
+		b.written += int64(len(blob))
+	}
+	return nil
+}
+
+// ReadAtCloser is the file interface needed to access an era.
+type ReadAtCloser interface {
+	io.ReaderAt
+	io.Closer
+}
+
+// Era is a read-only handle to an era file.
+type Era struct {
+	f        ReadAtCloser
+	start    uint64
+	offsets  []int64 // Absolute file offsets of each block-tuple
+	end      int64   // Offset of the checksum entry, end of the block data
+	checksum common.Hash
+}
+
+// Open opens the era file at the given path.
+func Open(path string) (*Era, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	stat, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	e, err := From(f, stat.Size())
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return e, nil
+}
+
+// From wraps an era from a file handle of the given size.
+func From(f ReadAtCloser, size int64) (*Era, error) {
+	// Validate the leading version entry
+	typ, length, err := readHeader(f, 0)
+	if err != nil {
+		return nil, err
+	}
+	if typ != TypeVersion || length != 0 {
+		return nil, errors.New("invalid era version")
+	}
+	// Read the block count from the trailing end of the index
+	if size < 2*headerSize+40+16 {
+		return nil, errors.New("era file too short")
+	}
+	var buf [8]byte
+	if _, err := f.ReadAt(buf[:], size-8); err != nil {
+		return nil, err
+	}
+	count := binary.LittleEndian.Uint64(buf[:])
+	if count == 0 || count > MaxEraBatchSize {
+		return nil, fmt.Errorf("invalid era block count %d", count)
+	}
+	indexOffset := size - headerSize - int64(16+8*count)
+	checksumOffset := indexOffset - headerSize - common.HashLength
+	if checksumOffset < headerSize {
+		return nil, errors.New("era file too short")
+	}
+	if typ, length, err := readHeader(f, indexOffset); err != nil {
+		return nil, err
+	} else if typ != TypeBlockIndex || length != uint32(16+8*count) {
+		return nil, errors.New("invalid era block index")
+	}
+	index := make([]byte, 16+8*count)
+	if _, err := f.ReadAt(index, indexOffset+headerSize); err != nil {
+		return nil, err
+	}
+	e := &Era{
+		f:       f,
+		start:   binary.LittleEndian.Uint64(index),
+		offsets: make([]int64, count),
+		end:     checksumOffset,
+	}
+	for i := range e.offsets {
+		offset := indexOffset + int64(binary.LittleEndian.Uint64(index[8+8*i:]))
+		if offset < headerSize || offset >= checksumOffset {
+			return nil, fmt.Errorf("invalid era block offset %d", offset)
+		}
+		e.offsets[i] = offset
+	}
+	// Retrieve the stored checksum
+	if typ, length, err := readHeader(f, checksumOffset); err != nil {
+		return nil, err
+	} else if typ != TypeChecksum || length != common.HashLength {
+		return nil, errors.New("invalid era checksum entry")
+	}
+	if _, err := f.ReadAt(e.checksum[:], checksumOffset+headerSize); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Start returns the number of the first block in the era.
+func (e *Era) Start() uint64 {
+	return e.start
+}
+
+// Count returns the number of blocks in the era.
+func (e *Era) Count() uint64 {
+	return uint64(len(e.offsets))
+}
+
+// Checksum returns the checksum stored in the era.
+func (e *Era) Checksum() common.Hash {
+	return e.checksum
+}
+
+// Verify recomputes the checksum over the block data and compares it against
+// the stored one.
+func (e *Era) Verify() error {
+	hasher := sha3.NewLegacyKeccak256().(crypto.KeccakState)
+	if _, err := io.Copy(hasher, io.NewSectionReader(e.f, headerSize, e.end-headerSize)); err != nil {
+		return err
+	}
+	var checksum common.Hash
+	hasher.Read(checksum[:])
+	if checksum != e.checksum {
+		return fmt.Errorf("%w: have %x, want %x", errBadChecksum, checksum, e.checksum)
+	}
+	return nil
+}
+
+// GetRawBlock retrieves the header, body and receipts RLP and the total difficulty
+// of the given block.
+func (e *Era) GetRawBlock(number uint64) (header, body, receipts []byte, td *big.Int, err error) {
+	if number < e.start || number-e.start >= uint64(len(e.offsets)) {
+		return nil, nil, nil, nil, fmt.Errorf("%w: %d not in [%d, %d)", errOutOfRange, number, e.start, e.start+uint64(len(e.offsets)))
+	}
+	offset := e.offsets[number-e.start]
+	if header, offset, err = e.readEntry(offset, TypeCompressedHeader); err != nil {
+		return nil, nil, nil, nil, err
+	}
+	if body, offset, err = e.readEntry(offset, TypeCompressedBody); err != nil {
+		return nil, nil, nil, nil, err
+	}
+	if receipts, offset, err = e.readEntry(offset, TypeCompressedReceipts); err != nil {
+		return nil, nil, nil, nil, err
+	}
+	blob, _, err := e.readEntry(offset, TypeTotalDifficulty)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	if header, err = snappy.Decode(nil, header); err != nil {
+		return nil, nil, nil, nil, err
+	}
+	if body, err = snappy.Decode(nil, body); err != nil {
+		return nil, nil, nil, nil, err
+	}
+	if receipts, err = snappy.Decode(nil, receipts); err != nil {
+		return nil, nil, nil, nil, err
+	}
+	return header, body, receipts, decodeTd(blob), nil
+}
+
+// Close releases the underlying file handle.
+func (e *Era) Close() error {
+	return e.f.Close()
+}
+
+// readEntry reads the entry at the given offset, ensuring it's of the expected
+// type, and returns its payload along with the offset of the next entry.
+func (e *Era) readEntry(offset int64, want uint16) ([]byte, int64, error) {
+	typ, length, err := readHeader(e.f, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	if typ != want {
+		return nil, 0, fmt.Errorf("unexpected era entry type %#x at %d, want %#x", typ, offset, want)
+	}
+	if offset+headerSize+int64(length) > e.end {
+		return nil, 0, fmt.Errorf("era entry at %d overflows block data", offset)
+	}
+	data := make([]byte, length)
+	if _, err := e.f.ReadAt(data, offset+headerSize); err != nil {
+		return nil, 0, err
+	}
+	return data, offset + headerSize + int64(length), nil
+}
+
+// readHeader reads the entry header at the given offset.
+func readHeader(r io.ReaderAt, offset int64) (uint16, uint32, error) {
+	var header [headerSize]byte
+	if _, err := r.ReadAt(header[:], offset); err != nil {
+		return 0, 0, err
+	}
+	if header[6] != 0 || header[7] != 0 {
+		return 0, 0, fmt.Errorf("reserved bytes set in era entry at %d", offset)
+	}
+	return binary.LittleEndian.Uint16(header[0:]), binary.LittleEndian.Uint32(header[2:]), nil
+}
+
+// encodeTd converts a total difficulty into its 32 byte little endian form.
+func encodeTd(td *big.Int) []byte {
+	blob := common.LeftPadBytes(td.Bytes(), 32)
+	reverse(blob)
+	return blob
+}
+
+// decodeTd converts a 32 byte little endian total difficulty into a big integer.
+func decodeTd(blob []byte) *big.Int {
+	blob = common.CopyBytes(blob)
+	reverse(blob)
+	return new(big.Int).SetBytes(blob)
+}
+
+// reverse flips the byte order of the slice in place.
+func reverse(blob []byte) {
+	for i, j := 0, len(blob)-1; i < j; i, j = i+1, j-1 {
+		blob[i], blob[j] = blob[j], blob[i]
+	}
+}
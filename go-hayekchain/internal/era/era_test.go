@@ -0,0 +1,167 @@
+// Copyright 2021 The go-hayekchain Authors
+// This file is part of the go-hayekchain library.
+//
+// The go-hayekchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hayekchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hayekchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package era
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hayekchain/go-hayekchain/common"
+)
+
+type testBlock struct {
+	header, body, receipts []byte
+	td                     *big.Int
+}
+
+func makeTestBlocks(start, count uint64) []testBlock {
+	blocks := make([]testBlock, count)
+	for i := range blocks {
+		n := start + uint64(i)
+		blocks[i] = testBlock{
+			header:   []byte(fmt.Sprintf("header-%d", n)),
+			body:     bytes.Repeat([]byte{byte(n)}, int(n%7)+1),
+			receipts: []byte(fmt.Sprintf("receipts-%d", n)),
+			td:       new(big.Int).Lsh(big.NewInt(int64(n)+1), 100),
+		}
+	}
+	return blocks
+}
+
+// bytesFile wraps a byte slice to satisfy ReadAtCloser.
+type bytesFile struct {
+	*bytes.Reader
+}
+
+func (bytesFile) Close() error { return nil }
+
+func buildEra(t *testing.T, start uint64, blocks []testBlock) ([]byte, common.Hash) {
+	buf := new(bytes.Buffer)
+	builder := NewBuilder(buf)
+	for i, b := range blocks {
+		if err := builder.Add(start+uint64(i), b.header, b.body, b.receipts, b.td); err != nil {
+			t.Fatalf("failed to add block %d: %v", start+uint64(i), err)
+		}
+	}
+	checksum, err := builder.Finalize()
+	if err != nil {
+		t.Fatalf("failed to finalize era: %v", err)
+	}
+	return buf.Bytes(), checksum
+}
+
+// Tests that blocks written into an era can be read back intact.
+func TestEraRoundtrip(t *testing.T) {
+	start := uint64(128)
+	blocks := makeTestBlocks(start, 64)
+	blob, checksum := buildEra(t, start, blocks)
+
+	e, err := From(bytesFile{bytes.NewReader(blob)}, int64(len(blob)))
+	if err != nil {
+		t.Fatalf("failed to open era: %v", err)
+	}
+	defer e.Close()
+
+	if e.Start() != start || e.Count() != uint64(len(blocks)) {
+		t.Fatalf("range mismatch: have [%d, +%d), want [%d, +%d)", e.Start(), e.Count(), start, len(blocks))
+	}
+	if e.Checksum() != checksum {
+		t.Fatalf("checksum mismatch: have %x, want %x", e.Checksum(), checksum)
+	}
+	if err := e.Verify(); err != nil {
+		t.Fatalf("failed to verify era: %v", err)
+	}
+	for i, want := range blocks {
+		header, body, receipts, td, err := e.GetRawBlock(start + uint64(i))
+		if err != nil {
+			t.Fatalf("block %d: failed to read: %v", start+uint64(i), err)
+		}
+		if !bytes.Equal(header, want.header) || !bytes.Equal(body, want.body) || !bytes.Equal(receipts, want.receipts) {
+			t.Fatalf("block %d: data mismatch", start+uint64(i))
+		}
+		if td.Cmp(want.td) != 0 {
+			t.Fatalf("block %d: td mismatch: have %v, want %v", start+uint64(i), td, want.td)
+		}
+	}
+	if _, _, _, _, err := e.GetRawBlock(start - 1); !errors.Is(err, errOutOfRange) {
+		t.Fatalf("read before start: have %v, want %v", err, errOutOfRange)
+	}
+	if _, _, _, _, err := e.GetRawBlock(start + uint64(len(blocks))); !errors.Is(err, errOutOfRange) {
+		t.Fatalf("read after end: have %v, want %v", err, errOutOfRange)
+	}
+}
+
+// Tests that the builder rejects gaps and corrupted data fails verification.
+func TestEraCorruption(t *testing.T) {
+	builder := NewBuilder(new(bytes.Buffer))
+	if err := builder.Add(5, nil, nil, nil, common.Big0); err != nil {
+		t.Fatalf("failed to add block: %v", err)
+	}
+	if err := builder.Add(7, nil, nil, nil, common.Big0); !errors.Is(err, errNotConsecutive) {
+		t.Fatalf("gapped insert: have %v, want %v", err, errNotConsecutive)
+	}
+	blob, _ := buildEra(t, 0, makeTestBlocks(0, 16))
+	blob[headerSize+headerSize+1] ^= 0xff // Flip a byte in the first header payload
+
+	e, err := From(bytesFile{bytes.NewReader(blob)}, int64(len(blob)))
+	if err != nil {
+		t.Fatalf("failed to open era: %v", err)
+	}
+	if err := e.Verify(); !errors.Is(err, errBadChecksum) {
+		t.Fatalf("corrupted era: have %v, want %v", err, errBadChecksum)
+	}
+}
+
+// Tests that era files are listed in epoch order and gaps are detected.
+func TestEraReadDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "era-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, epoch := range []int{2, 0, 1} {
+		name := Filename("test", epoch, common.Hash{byte(epoch)})
+		if err := ioutil.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ioutil.WriteFile(filepath.Join(dir, Filename("other", 5, common.Hash{})), nil, 0644)
+
+	files, err := ReadDir(dir, "test")
+	if err != nil {
+		t.Fatalf("failed to read dir: %v", err)
+	}
+	if len(files) != 3 {
+		t.Fatalf("file count mismatch: have %d, want 3", len(files))
+	}
+	for i, file := range files {
+		if want := filepath.Join(dir, Filename("test", i, common.Hash{byte(i)})); file != want {
+			t.Fatalf("file %d: have %s, want %s", i, file, want)
+		}
+	}
+	os.Remove(files[1])
+	if _, err := ReadDir(dir, "test"); err == nil {
+		t.Fatalf("gapped epochs accepted")
+	}
+}
@@ -142,6 +142,21 @@ web3._extend({
 			call: 'hykash_submitHashRate',
 			params: 2,
 		}),
+		new web3._extend.Method({
+			name: 'getStratumWorkers',
+			call: 'hykash_getStratumWorkers',
+			params: 0,
+		}),
+		new web3._extend.Method({
+			name: 'getAuxWork',
+			call: 'hykash_getAuxWork',
+			params: 0,
+		}),
+		new web3._extend.Method({
+			name: 'submitAuxWork',
+			call: 'hykash_submitAuxWork',
+			params: 2,
+		}),
 	]
 });
 `
@@ -181,6 +196,10 @@ web3._extend({
 			call: 'admin_importChain',
 			params: 1
 		}),
+		new web3._extend.Method({
+			name: 'reloadTxPoolPolicy',
+			call: 'admin_reloadTxPoolPolicy'
+		}),
 		new web3._extend.Method({
 			name: 'sleepBlocks',
 			call: 'admin_sleepBlocks',
@@ -261,6 +280,12 @@ web3._extend({
 			call: 'debug_seedHash',
 			params: 1
 		}),
+		new web3._extend.Method({
+			name: 'hykashPreimage',
+			call: 'debug_hykashPreimage',
+			params: 2,
+			inputFormatter: [null, null]
+		}),
 		new web3._extend.Method({
 			name: 'dumpBlock',
 			call: 'debug_dumpBlock',
@@ -521,6 +546,37 @@ web3._extend({
 			params: 1,
 			inputFormatter: [web3._extend.formatters.inputTransactionFormatter]
 		}),
+		new web3._extend.Method({
+			name: 'signTransactionAsFeePayer',
+			call: 'hyk_signTransactionAsFeePayer',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'sendTransactionAsFeePayer',
+			call: 'hyk_sendTransactionAsFeePayer',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'sendPrivateTransaction',
+			call: 'hyk_sendPrivateTransaction',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'cancelPrivateTransaction',
+			call: 'hyk_cancelPrivateTransaction',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'sendBundle',
+			call: 'hyk_sendBundle',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'feeHistory',
+			call: 'hyk_feeHistory',
+			params: 3,
+			inputFormatter: [null, web3._extend.formatters.inputBlockNumberFormatter, null]
+		}),
 		new web3._extend.Method({
 			name: 'getHeaderByNumber',
 			call: 'hyk_getHeaderByNumber',
@@ -565,6 +621,11 @@ web3._extend({
 		}),
 	],
 	properties: [
+		new web3._extend.Property({
+			name: 'maxPriorityFeePerGas',
+			getter: 'hyk_maxPriorityFeePerGas',
+			outputFormatter: web3._extend.utils.toBigNumber
+		}),
 		new web3._extend.Property({
 			name: 'pendingTransactions',
 			getter: 'hyk_pendingTransactions',
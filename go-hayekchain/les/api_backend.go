@@ -170,16 +170,31 @@ func (b *LesApiBackend) GetTd(ctx context.Context, hash common.Hash) *big.Int {
 	return nil
 }
 
-func (b *LesApiBackend) GetEVM(ctx context.Context, msg core.Message, state *state.StateDB, header *types.Header) (*vm.EVM, func() error, error) {
+func (b *LesApiBackend) GetEVM(ctx context.Context, msg core.Message, state *state.StateDB, header *types.Header, vmConfig *vm.Config) (*vm.EVM, func() error, error) {
+	if vmConfig == nil {
+		vmConfig = new(vm.Config)
+	}
 	txContext := core.NewEVMTxContext(msg)
 	context := core.NewEVMBlockContext(header, b.hyk.blockchain, nil)
-	return vm.NewEVM(context, txContext, state, b.hyk.chainConfig, vm.Config{}), state.Error, nil
+	return vm.NewEVM(context, txContext, state, b.hyk.chainConfig, *vmConfig), state.Error, nil
 }
 
 func (b *LesApiBackend) SendTx(ctx context.Context, signedTx *types.Transaction) error {
 	return b.hyk.txPool.Add(ctx, signedTx)
 }
 
+func (b *LesApiBackend) SendPrivateTx(ctx context.Context, signedTx *types.Transaction, deadline uint64) error {
+	return errors.New("private transactions are not supported by light clients")
+}
+
+func (b *LesApiBackend) CancelPrivateTx(ctx context.Context, txHash common.Hash) (bool, error) {
+	return false, errors.New("private transactions are not supported by light clients")
+}
+
+func (b *LesApiBackend) SendBundle(ctx context.Context, bundle *core.TxBundle) error {
+	return errors.New("bundles are not supported by light clients")
+}
+
 func (b *LesApiBackend) RemoveTx(txHash common.Hash) {
 	b.hyk.txPool.RemoveTx(txHash)
 }
@@ -251,6 +266,14 @@ func (b *LesApiBackend) SuggestPrice(ctx context.Context) (*big.Int, error) {
 	return b.gpo.SuggestPrice(ctx)
 }
 
+func (b *LesApiBackend) SuggestGasTipCap(ctx context.Context) (*big.Int, error) {
+	return b.gpo.SuggestTipCap(ctx)
+}
+
+func (b *LesApiBackend) FeeHistory(ctx context.Context, blockCount int, lastBlock rpc.BlockNumber, rewardPercentiles []float64) (*big.Int, [][]*big.Int, []*big.Int, []float64, error) {
+	return b.gpo.FeeHistory(ctx, blockCount, lastBlock, rewardPercentiles)
+}
+
 func (b *LesApiBackend) ChainDb() hykdb.Database {
 	return b.hyk.chainDb
 }
@@ -0,0 +1,157 @@
+// Copyright 2021 The go-hayekchain Authors
+// This file is part of the go-hayekchain library.
+//
+// The go-hayekchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hayekchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hayekchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync/atomic"
+
+	"github.com/hayekchain/go-hayekchain/common"
+	"github.com/hayekchain/go-hayekchain/core"
+	"github.com/hayekchain/go-hayekchain/core/state"
+	"github.com/hayekchain/go-hayekchain/core/types"
+	"github.com/hayekchain/go-hayekchain/log"
+)
+
+// errBundleReverted is returned if a transaction of a bundle reverted without
+// the bundle allowing it.
+var errBundleReverted = errors.New("bundle transaction reverted")
+
+// simulatedBundle is a transaction bundle along with the results of executing
+// it on top of the pending state.
+type simulatedBundle struct {
+	bundle  *core.TxBundle
+	payment *big.Int // Increase of the coinbase balance caused by the bundle
+	gasUsed uint64   // Gas consumed by all transactions of the bundle
+	score   *big.Int // Coinbase payment per gas, bundles are ranked by it
+}
+
+// applyBundle executes all transactions of a bundle on top of the given state,
+// failing if any of them is invalid or reverts without being allowed to. The
+// state is left modified on failure, it's up to the caller to discard it.
+func (w *worker) applyBundle(bundle *core.TxBundle, statedb *state.StateDB, gasPool *core.GasPool, gasUsed *uint64, tcount int, coinbase common.Address) ([]*types.Receipt, *big.Int, error) {
+	var (
+		balance  = statedb.GetBalance(coinbase)
+		receipts = make([]*types.Receipt, 0, len(bundle.Txs))
+	)
+	for i, tx := range bundle.Txs {
+		if tx.Protected() && !w.chainConfig.IsEIP155(w.current.header.Number) {
+			return nil, nil, fmt.Errorf("transaction %d: replay protected before EIP155", i)
+		}
+		statedb.Prepare(tx.Hash(), common.Hash{}, tcount+i)
+
+		receipt, err := core.ApplyTransaction(w.chainConfig, w.chain, &coinbase, gasPool, statedb, w.current.header, tx, gasUsed, *w.chain.GetVMConfig())
+		if err != nil {
+			return nil, nil, fmt.Errorf("transaction %d: %w", i, err)
+		}
+		if receipt.Status == types.ReceiptStatusFailed && !bundle.CanRevert(tx.Hash()) {
+			return nil, nil, fmt.Errorf("transaction %d: %w", i, errBundleReverted)
+		}
+		receipts = append(receipts, receipt)
+	}
+	return receipts, new(big.Int).Sub(statedb.GetBalance(coinbase), balance), nil
+}
+
+// simulateBundles executes each bundle on its own on top of the current pending
+// state, dropping the failing and unprofitable ones and ranking the rest by the
+// coinbase payment per gas they offer.
+func (w *worker) simulateBundles(bundles []*core.TxBundle, coinbase common.Address) []*simulatedBundle {
+	var simulated []*simulatedBundle
+	for _, bundle := range bundles {
+		var (
+			gasPool = new(core.GasPool).AddGas(w.current.gasPool.Gas())
+			gasUsed = w.current.header.GasUsed
+		)
+		_, payment, err := w.applyBundle(bundle, w.current.state.Copy(), gasPool, &gasUsed, w.current.tcount, coinbase)
+		if err != nil {
+			log.Trace("Discarding failing bundle", "hash", bundle.Hash(), "err", err)
+			continue
+		}
+		if payment.Sign() <= 0 {
+			log.Trace("Discarding unprofitable bundle", "hash", bundle.Hash(), "payment", payment)
+			continue
+		}
+		used := gasUsed - w.current.header.GasUsed
+		simulated = append(simulated, &simulatedBundle{
+			bundle:  bundle,
+			payment: payment,
+			gasUsed: used,
+			score:   new(big.Int).Div(payment, new(big.Int).SetUint64(used)),
+		})
+	}
+	sort.SliceStable(simulated, func(i, j int) bool {
+		return simulated[i].score.Cmp(simulated[j].score) > 0
+	})
+	return simulated
+}
+
+// commitBundles simulates the given bundles and commits the successful ones into
+// the current block, best paying first. A bundle is committed atomically: if any
+// of its transactions fails on top of the previously committed bundles, the
+// whole bundle is skipped. The return value reports whether the work should be
+// discarded due to a new head.
+func (w *worker) commitBundles(bundles []*core.TxBundle, coinbase common.Address, interrupt *int32) bool {
+	// Short circuit if current is nil
+	if w.current == nil {
+		return true
+	}
+	if w.current.gasPool == nil {
+		w.current.gasPool = new(core.GasPool).AddGas(w.current.header.GasLimit)
+	}
+	var coalescedLogs []*types.Log
+
+	for _, sim := range w.simulateBundles(bundles, coinbase) {
+		if interrupt != nil && atomic.LoadInt32(interrupt) == commitInterruptNewHead {
+			return true
+		}
+		var (
+			snap    = w.current.state.Snapshot()
+			gasPool = *w.current.gasPool
+			gasUsed = w.current.header.GasUsed
+		)
+		receipts, _, err := w.applyBundle(sim.bundle, w.current.state, w.current.gasPool, &w.current.header.GasUsed, w.current.tcount, coinbase)
+		if err != nil {
+			log.Trace("Skipping conflicting bundle", "hash", sim.bundle.Hash(), "err", err)
+			w.current.state.RevertToSnapshot(snap)
+			*w.current.gasPool = gasPool
+			w.current.header.GasUsed = gasUsed
+			continue
+		}
+		w.current.txs = append(w.current.txs, sim.bundle.Txs...)
+		w.current.receipts = append(w.current.receipts, receipts...)
+		w.current.tcount += len(sim.bundle.Txs)
+
+		for _, receipt := range receipts {
+			coalescedLogs = append(coalescedLogs, receipt.Logs...)
+		}
+		log.Debug("Committed bundle", "hash", sim.bundle.Hash(), "txs", len(sim.bundle.Txs), "gas", sim.gasUsed, "payment", sim.payment)
+	}
+	if !w.isRunning() && len(coalescedLogs) > 0 {
+		// Same as for transactions, copy the logs to avoid racing with their upgrade
+		// to mined logs.
+		cpy := make([]*types.Log, len(coalescedLogs))
+		for i, l := range coalescedLogs {
+			cpy[i] = new(types.Log)
+			*cpy[i] = *l
+		}
+		w.pendingLogsFeed.Send(cpy)
+	}
+	return false
+}
@@ -50,6 +50,7 @@ type Config struct {
 	GasPrice  *big.Int       // Minimum gas price for mining a transaction
 	Recommit  time.Duration  // The time interval for miner to re-create mining work.
 	Noverify  bool           // Disable remote mining solution verification(only useful in hykash).
+	Ordering  string         `toml:",omitempty"` // Transaction ordering policy for block building (price, fifo, fairshare)
 }
 
 // Miner creates blocks and searches for proof-of-work values.
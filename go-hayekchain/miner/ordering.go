@@ -0,0 +1,248 @@
+// Copyright 2021 The go-hayekchain Authors
+// This file is part of the go-hayekchain library.
+//
+// The go-hayekchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hayekchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hayekchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"bytes"
+	"container/heap"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/hayekchain/go-hayekchain/common"
+	"github.com/hayekchain/go-hayekchain/core/types"
+)
+
+// Names of the built-in transaction ordering policies.
+const (
+	OrderingPrice     = "price"     // Highest tip first, local transactions before remote ones
+	OrderingFIFO      = "fifo"      // First seen first, regardless of price or origin
+	OrderingFairShare = "fairshare" // One transaction per sender in turns
+)
+
+// OrderedTransactions is a set of transactions returned one by one in the order
+// they should be included in a block, honouring the nonce order of the senders.
+// It is implemented by types.TransactionsByPriceAndNonce.
+type OrderedTransactions interface {
+	// Peek returns the next transaction to include, nil if the set is exhausted.
+	Peek() *types.Transaction
+
+	// Shift replaces the current transaction with the next one of its sender.
+	Shift()
+
+	// Pop removes the current transaction along with all later ones of its
+	// sender. It is used when a transaction can't be executed, making the rest
+	// of the sender's transactions unexecutable too.
+	Pop()
+}
+
+// OrderingPolicy decides the order in which the worker tries to include pending
+// transactions into the blocks it builds.
+type OrderingPolicy interface {
+	// Order splits the pending transactions, grouped by sender and sorted by
+	// nonce, into batches which the worker commits one after the other. The
+	// policy takes ownership of the pending map.
+	Order(pending map[common.Address]types.Transactions, locals []common.Address, signer types.Signer, baseFee *big.Int) []OrderedTransactions
+}
+
+// NewOrderingPolicy returns the built-in ordering policy with the given name. An
+// empty name selects the default price ordering.
+func NewOrderingPolicy(name string) (OrderingPolicy, error) {
+	switch name {
+	case "", OrderingPrice:
+		return priceOrdering{}, nil
+	case OrderingFIFO:
+		return fifoOrdering{}, nil
+	case OrderingFairShare:
+		return fairShareOrdering{}, nil
+	default:
+		return nil, fmt.Errorf("unknown transaction ordering policy %q", name)
+	}
+}
+
+// priceOrdering commits the transactions of local accounts first, then the
+// remote ones, each batch sorted by the effective miner tip.
+type priceOrdering struct{}
+
+func (priceOrdering) Order(pending map[common.Address]types.Transactions, locals []common.Address, signer types.Signer, baseFee *big.Int) []OrderedTransactions {
+	var batches []OrderedTransactions
+
+	localTxs, remoteTxs := make(map[common.Address]types.Transactions), pending
+	for _, account := range locals {
+		if txs := remoteTxs[account]; len(txs) > 0 {
+			delete(remoteTxs, account)
+			localTxs[account] = txs
+		}
+	}
+	if len(localTxs) > 0 {
+		batches = append(batches, types.NewTransactionsByPriceAndNonce(signer, localTxs, baseFee))
+	}
+	if len(remoteTxs) > 0 {
+		batches = append(batches, types.NewTransactionsByPriceAndNonce(signer, remoteTxs, baseFee))
+	}
+	return batches
+}
+
+// fifoOrdering commits the transactions in the order they were first seen by
+// the node, without any preference for prices or local accounts.
+type fifoOrdering struct{}
+
+func (fifoOrdering) Order(pending map[common.Address]types.Transactions, locals []common.Address, signer types.Signer, baseFee *big.Int) []OrderedTransactions {
+	if len(pending) == 0 {
+		return nil
+	}
+	return []OrderedTransactions{newTransactionsByTime(pending)}
+}
+
+// fairShareOrdering commits one transaction of each sender in turns, so that no
+// sender can crowd the others out of a block. The turns are initially ordered
+// by the time the first transaction of each sender was seen.
+type fairShareOrdering struct{}
+
+func (fairShareOrdering) Order(pending map[common.Address]types.Transactions, locals []common.Address, signer types.Signer, baseFee *big.Int) []OrderedTransactions {
+	if len(pending) == 0 {
+		return nil
+	}
+	return []OrderedTransactions{newTransactionsRoundRobin(pending)}
+}
+
+// txHead is the next transaction of a sender.
+type txHead struct {
+	from common.Address
+	tx   *types.Transaction
+}
+
+// txHeadsByTime implements the heap interface, ordering sender heads by the time
+// their transactions were first seen, falling back to the hashes for ties.
+type txHeadsByTime []txHead
+
+func (s txHeadsByTime) Len() int { return len(s) }
+func (s txHeadsByTime) Less(i, j int) bool {
+	ti, tj := s[i].tx.Time(), s[j].tx.Time()
+	if ti.Equal(tj) {
+		hi, hj := s[i].tx.Hash(), s[j].tx.Hash()
+		return bytes.Compare(hi[:], hj[:]) < 0
+	}
+	return ti.Before(tj)
+}
+func (s txHeadsByTime) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+
+func (s *txHeadsByTime) Push(x interface{}) {
+	*s = append(*s, x.(txHead))
+}
+
+func (s *txHeadsByTime) Pop() interface{} {
+	old := *s
+	n := len(old)
+	x := old[n-1]
+	*s = old[0 : n-1]
+	return x
+}
+
+// transactionsByTime is a set of transactions returned in the order they were
+// first seen, with each sender's transactions in nonce order.
+type transactionsByTime struct {
+	txs   map[common.Address]types.Transactions // Per sender nonce-sorted transactions after the heads
+	heads txHeadsByTime                         // Next transaction of each sender
+}
+
+// newTransactionsByTime creates an arrival time ordered transaction set. The
+// input map is reowned by the set.
+func newTransactionsByTime(txs map[common.Address]types.Transactions) *transactionsByTime {
+	heads := make(txHeadsByTime, 0, len(txs))
+	for from, accTxs := range txs {
+		heads = append(heads, txHead{from: from, tx: accTxs[0]})
+		txs[from] = accTxs[1:]
+	}
+	heap.Init(&heads)
+	return &transactionsByTime{txs: txs, heads: heads}
+}
+
+// Peek returns the earliest seen transaction which can be included next.
+func (t *transactionsByTime) Peek() *types.Transaction {
+	if len(t.heads) == 0 {
+		return nil
+	}
+	return t.heads[0].tx
+}
+
+// Shift replaces the current head with the next transaction of its sender.
+func (t *transactionsByTime) Shift() {
+	from := t.heads[0].from
+	if txs := t.txs[from]; len(txs) > 0 {
+		t.heads[0].tx, t.txs[from] = txs[0], txs[1:]
+		heap.Fix(&t.heads, 0)
+	} else {
+		heap.Pop(&t.heads)
+	}
+}
+
+// Pop removes the current head, not replacing it with the next transaction of
+// its sender.
+func (t *transactionsByTime) Pop() {
+	heap.Pop(&t.heads)
+}
+
+// transactionsRoundRobin is a set of transactions returned one per sender in
+// turns, with each sender's transactions in nonce order.
+type transactionsRoundRobin struct {
+	txs   map[common.Address]types.Transactions // Per sender nonce-sorted transactions
+	turns []common.Address                      // Senders in the order of their next turn
+}
+
+// newTransactionsRoundRobin creates a transaction set giving each sender a turn
+// after the other. The input map is reowned by the set.
+func newTransactionsRoundRobin(txs map[common.Address]types.Transactions) *transactionsRoundRobin {
+	heads := make(txHeadsByTime, 0, len(txs))
+	for from, accTxs := range txs {
+		heads = append(heads, txHead{from: from, tx: accTxs[0]})
+	}
+	sort.Sort(heads)
+
+	turns := make([]common.Address, len(heads))
+	for i, head := range heads {
+		turns[i] = head.from
+	}
+	return &transactionsRoundRobin{txs: txs, turns: turns}
+}
+
+// Peek returns the next transaction of the sender whose turn it is.
+func (t *transactionsRoundRobin) Peek() *types.Transaction {
+	if len(t.turns) == 0 {
+		return nil
+	}
+	return t.txs[t.turns[0]][0]
+}
+
+// Shift consumes the current transaction and moves its sender to the end of the
+// turns if it has further transactions.
+func (t *transactionsRoundRobin) Shift() {
+	from := t.turns[0]
+	t.turns = t.turns[1:]
+	if txs := t.txs[from][1:]; len(txs) > 0 {
+		t.txs[from] = txs
+		t.turns = append(t.turns, from)
+	} else {
+		delete(t.txs, from)
+	}
+}
+
+// Pop removes the sender whose turn it is along with all its transactions.
+func (t *transactionsRoundRobin) Pop() {
+	delete(t.txs, t.turns[0])
+	t.turns = t.turns[1:]
+}
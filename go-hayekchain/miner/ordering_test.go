@@ -0,0 +1,133 @@
+// Copyright 2021 The go-hayekchain Authors
+// This file is part of the go-hayekchain library.
+//
+// The go-hayekchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hayekchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hayekchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/hayekchain/go-hayekchain/common"
+	"github.com/hayekchain/go-hayekchain/core/types"
+	"github.com/hayekchain/go-hayekchain/crypto"
+)
+
+// orderingTestTxs creates transactions of three senders arriving interleaved,
+// returning them grouped by sender along with the senders.
+//
+//	sender 0: nonce 0 (price 1, arrival 0), nonce 1 (price 1, arrival 3), nonce 2 (price 1, arrival 4)
+//	sender 1: nonce 0 (price 3, arrival 1)
+//	sender 2: nonce 0 (price 2, arrival 2), nonce 1 (price 2, arrival 5)
+func orderingTestTxs(t *testing.T) (map[common.Address]types.Transactions, []common.Address) {
+	keys := make([]*ecdsa.PrivateKey, 3)
+	addrs := make([]common.Address, 3)
+	for i := range keys {
+		keys[i], _ = crypto.GenerateKey()
+		addrs[i] = crypto.PubkeyToAddress(keys[i].PublicKey)
+	}
+	arrivals := []struct {
+		sender int
+		nonce  uint64
+		price  int64
+	}{{0, 0, 1}, {1, 0, 3}, {2, 0, 2}, {0, 1, 1}, {0, 2, 1}, {2, 1, 2}}
+
+	pending := make(map[common.Address]types.Transactions)
+	for _, arrival := range arrivals {
+		tx := types.NewTransaction(arrival.nonce, common.Address{}, new(big.Int), 21000, big.NewInt(arrival.price), nil)
+		tx, err := types.SignTx(tx, types.HomesteadSigner{}, keys[arrival.sender])
+		if err != nil {
+			t.Fatalf("failed to sign transaction: %v", err)
+		}
+		pending[addrs[arrival.sender]] = append(pending[addrs[arrival.sender]], tx)
+		time.Sleep(time.Millisecond) // Ensure distinct arrival times
+	}
+	return pending, addrs
+}
+
+// drainOrdering collects the sender indexes and nonces of the transactions yielded
+// by the given batches, popping the sender listed in pop on its first occurrence.
+func drainOrdering(batches []OrderedTransactions, senders []common.Address, pop common.Address) [][2]uint64 {
+	signer := types.HomesteadSigner{}
+
+	var order [][2]uint64
+	for _, txs := range batches {
+		for tx := txs.Peek(); tx != nil; tx = txs.Peek() {
+			from, _ := types.Sender(signer, tx)
+			if from == pop {
+				txs.Pop()
+				continue
+			}
+			for i, sender := range senders {
+				if sender == from {
+					order = append(order, [2]uint64{uint64(i), tx.Nonce()})
+				}
+			}
+			txs.Shift()
+		}
+	}
+	return order
+}
+
+func TestOrderingPolicies(t *testing.T) {
+	pending, addrs := orderingTestTxs(t)
+	id := func(sender int, nonce uint64) [2]uint64 { return [2]uint64{uint64(sender), nonce} }
+
+	tests := []struct {
+		policy string
+		locals []common.Address
+		pop    common.Address
+		want   [][2]uint64
+	}{
+		// Price ordering is nonce-honouring by tip, with locals first
+		{OrderingPrice, nil, common.Address{}, [][2]uint64{id(1, 0), id(2, 0), id(2, 1), id(0, 0), id(0, 1), id(0, 2)}},
+		{OrderingPrice, []common.Address{addrs[0]}, common.Address{}, [][2]uint64{id(0, 0), id(0, 1), id(0, 2), id(1, 0), id(2, 0), id(2, 1)}},
+
+		// FIFO ordering only cares about arrival times, and ignores locals
+		{OrderingFIFO, nil, common.Address{}, [][2]uint64{id(0, 0), id(1, 0), id(2, 0), id(0, 1), id(0, 2), id(2, 1)}},
+		{OrderingFIFO, []common.Address{addrs[2]}, common.Address{}, [][2]uint64{id(0, 0), id(1, 0), id(2, 0), id(0, 1), id(0, 2), id(2, 1)}},
+		{OrderingFIFO, nil, addrs[2], [][2]uint64{id(0, 0), id(1, 0), id(0, 1), id(0, 2)}},
+
+		// Fair share ordering gives each sender a turn, in the order of their first arrival
+		{OrderingFairShare, nil, common.Address{}, [][2]uint64{id(0, 0), id(1, 0), id(2, 0), id(0, 1), id(2, 1), id(0, 2)}},
+		{OrderingFairShare, nil, addrs[1], [][2]uint64{id(0, 0), id(2, 0), id(0, 1), id(2, 1), id(0, 2)}},
+	}
+	for i, tt := range tests {
+		policy, err := NewOrderingPolicy(tt.policy)
+		if err != nil {
+			t.Fatalf("test %d: failed to create policy %q: %v", i, tt.policy, err)
+		}
+		txs := make(map[common.Address]types.Transactions)
+		for addr, list := range pending {
+			txs[addr] = list
+		}
+		have := drainOrdering(policy.Order(txs, tt.locals, types.HomesteadSigner{}, nil), addrs, tt.pop)
+		if len(have) != len(tt.want) {
+			t.Errorf("test %d (%s): ordering length mismatch: have %v, want %v", i, tt.policy, have, tt.want)
+			continue
+		}
+		for j := range have {
+			if have[j] != tt.want[j] {
+				t.Errorf("test %d (%s): ordering mismatch: have %v, want %v", i, tt.policy, have, tt.want)
+				break
+			}
+		}
+	}
+	if _, err := NewOrderingPolicy("random"); err == nil {
+		t.Fatalf("unknown ordering policy accepted")
+	}
+}
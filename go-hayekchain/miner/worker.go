@@ -88,6 +88,9 @@ type environment struct {
 	tcount    int            // tx count in cycle
 	gasPool   *core.GasPool  // available gas used to pack transactions
 
+	maxUncles     uint64 // maximum number of uncles allowed in the block
+	maxUncleDepth uint64 // maximum distance of an uncle from the block
+
 	header   *types.Header
 	txs      []*types.Transaction
 	receipts []*types.Receipt
@@ -128,6 +131,7 @@ type worker struct {
 	engine      consensus.Engine
 	hyk         Backend
 	chain       *core.BlockChain
+	ordering    OrderingPolicy // Policy deciding the order of pending transactions in blocks
 
 	// Feeds
 	pendingLogsFeed event.Feed
@@ -211,6 +215,14 @@ func newWorker(config *Config, chainConfig *params.ChainConfig, engine consensus
 		resubmitIntervalCh: make(chan time.Duration),
 		resubmitAdjustCh:   make(chan *intervalAdjust, resubmitAdjustChanSize),
 	}
+	// Pick the transaction ordering policy, falling back to the default one
+	ordering, err := NewOrderingPolicy(config.Ordering)
+	if err != nil {
+		log.Warn("Sanitizing miner ordering policy", "provided", config.Ordering, "updated", OrderingPrice, "err", err)
+		ordering, _ = NewOrderingPolicy(OrderingPrice)
+	}
+	worker.ordering = ordering
+
 	// Subscribe NewTxsEvent for tx pool
 	worker.txsSub = hyk.TxPool().SubscribeNewTxsEvent(worker.txsCh)
 	// Subscribe events for blockchain
@@ -447,9 +459,9 @@ func (w *worker) mainLoop() {
 			} else {
 				w.remoteUncles[ev.Block.Hash()] = ev.Block
 			}
-			// If our mining block contains less than 2 uncle blocks,
+			// If our mining block contains less than the allowed uncle blocks,
 			// add the new uncle block if valid and regenerate a mining block.
-			if w.isRunning() && w.current != nil && w.current.uncles.Cardinality() < 2 {
+			if w.isRunning() && w.current != nil && uint64(w.current.uncles.Cardinality()) < w.current.maxUncles {
 				start := time.Now()
 				if err := w.commitUncle(w.current, ev.Block.Header()); err == nil {
 					var uncles []*types.Header
@@ -492,9 +504,10 @@ func (w *worker) mainLoop() {
 					acc, _ := types.Sender(w.current.signer, tx)
 					txs[acc] = append(txs[acc], tx)
 				}
-				txset := types.NewTransactionsByPriceAndNonce(w.current.signer, txs)
 				tcount := w.current.tcount
-				w.commitTransactions(txset, coinbase, nil)
+				for _, txset := range w.ordering.Order(txs, w.hyk.TxPool().Locals(), w.current.signer, w.current.header.BaseFee) {
+					w.commitTransactions(txset, coinbase, nil)
+				}
 				// Only update the snapshot if any new transactons were added
 				// to the pending block
 				if tcount != w.current.tcount {
@@ -643,7 +656,7 @@ func (w *worker) makeCurrent(parent *types.Block, header *types.Header) error {
 		return err
 	}
 	env := &environment{
-		signer:    types.NewEIP155Signer(w.chainConfig.ChainID),
+		signer:    types.LatestSigner(w.chainConfig),
 		state:     state,
 		ancestors: mapset.NewSet(),
 		family:    mapset.NewSet(),
@@ -651,8 +664,10 @@ func (w *worker) makeCurrent(parent *types.Block, header *types.Header) error {
 		header:    header,
 	}
 
+	env.maxUncles, env.maxUncleDepth = w.chainConfig.Hayekash.UncleLimits(header.Number)
+
 	// when 08 is processed ancestors contain 07 (quick block)
-	for _, ancestor := range w.chain.GetBlocksFromHash(parent.Hash(), 7) {
+	for _, ancestor := range w.chain.GetBlocksFromHash(parent.Hash(), int(env.maxUncleDepth)+1) {
 		for _, uncle := range ancestor.Uncles() {
 			env.family.Add(uncle.Hash())
 		}
@@ -733,7 +748,7 @@ func (w *worker) commitTransaction(tx *types.Transaction, coinbase common.Addres
 	return receipt.Logs, nil
 }
 
-func (w *worker) commitTransactions(txs *types.TransactionsByPriceAndNonce, coinbase common.Address, interrupt *int32) bool {
+func (w *worker) commitTransactions(txs OrderedTransactions, coinbase common.Address, interrupt *int32) bool {
 	// Short circuit if current is nil
 	if w.current == nil {
 		return true
@@ -779,7 +794,7 @@ func (w *worker) commitTransactions(txs *types.TransactionsByPriceAndNonce, coin
 		// Error may be ignored here. The error has already been checked
 		// during transaction acceptance is the transaction pool.
 		//
-		// We use the eip155 signer regardless of the current hf.
+		// We use the latest signer regardless of the current hf.
 		from, _ := types.Sender(w.current.signer, tx)
 		// Check whether the tx is replay protected. If we're not in the EIP155 hf
 		// phase, start ignoring the sender until we do.
@@ -809,6 +824,16 @@ func (w *worker) commitTransactions(txs *types.TransactionsByPriceAndNonce, coin
 			log.Trace("Skipping account with hight nonce", "sender", from, "nonce", tx.Nonce())
 			txs.Pop()
 
+		case errors.Is(err, core.ErrTxTypeNotSupported):
+			// Pop the unsupported transaction without shifting in the next from the account
+			log.Trace("Skipping unsupported transaction type", "sender", from, "type", tx.Type())
+			txs.Pop()
+
+		case errors.Is(err, core.ErrFeeCapTooLow):
+			// Pop the transaction priced below the base fee, the account's later ones can't run either
+			log.Trace("Skipping transaction below base fee", "sender", from, "feecap", tx.GasFeeCap(), "basefee", w.current.header.BaseFee)
+			txs.Pop()
+
 		case errors.Is(err, nil):
 			// Everything ok, collect the logs and shift in the next transaction from the same account
 			coalescedLogs = append(coalescedLogs, logs...)
@@ -872,6 +897,13 @@ func (w *worker) commitNewWork(interrupt *int32, noempty bool, timestamp int64)
 		Extra:      w.extra,
 		Time:       uint64(timestamp),
 	}
+	// Set baseFee and GasLimit if we are on an EIP-1559 chain
+	if w.chainConfig.IsLondon(header.Number) {
+		header.BaseFee = misc.CalcBaseFee(w.chainConfig, parent.Header())
+		if !w.chainConfig.IsLondon(parent.Number()) {
+			header.GasLimit = parent.GasLimit() * params.ElasticityMultiplier
+		}
+	}
 	// Only set the coinbase if our consensus engine is running (avoid spurious block rewards)
 	if w.isRunning() {
 		if w.coinbase == (common.Address{}) {
@@ -918,7 +950,7 @@ func (w *worker) commitNewWork(interrupt *int32, noempty bool, timestamp int64)
 			}
 		}
 		for hash, uncle := range blocks {
-			if len(uncles) == 2 {
+			if uint64(len(uncles)) >= env.maxUncles {
 				break
 			}
 			if err := w.commitUncle(env, uncle.Header()); err != nil {
@@ -945,29 +977,31 @@ func (w *worker) commitNewWork(interrupt *int32, noempty bool, timestamp int64)
 		log.Error("Failed to fetch pending transactions", "err", err)
 		return
 	}
+	private := w.hyk.TxPool().Private()
+	bundles := w.hyk.TxPool().Bundles(header.Number.Uint64(), header.Time)
+
 	// Short circuit if there is no available pending transactions.
 	// But if we disable empty precommit already, ignore it. Since
 	// empty block is necessary to keep the liveness of the network.
-	if len(pending) == 0 && atomic.LoadUint32(&w.noempty) == 0 {
+	if len(pending) == 0 && len(private) == 0 && len(bundles) == 0 && atomic.LoadUint32(&w.noempty) == 0 {
 		w.updateSnapshot()
 		return
 	}
-	// Split the pending transactions into locals and remotes
-	localTxs, remoteTxs := make(map[common.Address]types.Transactions), pending
-	for _, account := range w.hyk.TxPool().Locals() {
-		if txs := remoteTxs[account]; len(txs) > 0 {
-			delete(remoteTxs, account)
-			localTxs[account] = txs
+	// Bundles go first as they need to execute atomically in their given order
+	if len(bundles) > 0 {
+		if w.commitBundles(bundles, w.coinbase, interrupt) {
+			return
 		}
 	}
-	if len(localTxs) > 0 {
-		txs := types.NewTransactionsByPriceAndNonce(w.current.signer, localTxs)
+	// Private transactions go first, the pending ones they supersede will be
+	// skipped as nonce too low
+	for _, txs := range w.ordering.Order(private, nil, w.current.signer, header.BaseFee) {
 		if w.commitTransactions(txs, w.coinbase, interrupt) {
 			return
 		}
 	}
-	if len(remoteTxs) > 0 {
-		txs := types.NewTransactionsByPriceAndNonce(w.current.signer, remoteTxs)
+	// Fill the rest of the block in the order of the configured policy
+	for _, txs := range w.ordering.Order(pending, w.hyk.TxPool().Locals(), w.current.signer, header.BaseFee) {
 		if w.commitTransactions(txs, w.coinbase, interrupt) {
 			return
 		}
@@ -980,7 +1014,7 @@ func (w *worker) commitNewWork(interrupt *int32, noempty bool, timestamp int64)
 func (w *worker) commit(uncles []*types.Header, interval func(), update bool, start time.Time) error {
 	// Deep copy receipts here to avoid interaction between different tasks.
 	receipts := copyReceipts(w.current.receipts)
-	s := w.current.state.Copy()
+	s := w.current.state.CopyForCommit()
 	block, err := w.engine.FinalizeAndAssemble(w.chain, w.current.header, s, w.current.txs, uncles, receipts)
 	if err != nil {
 		return err
@@ -1025,11 +1059,13 @@ func (w *worker) postSideBlock(event core.ChainSideEvent) {
 	}
 }
 
-// totalFees computes total consumed fees in HYK. Block transactions and receipts have to have the same order.
+// totalFees computes total consumed miner fees in HYK, leaving out the base fee
+// after EIP-1559. Block transactions and receipts have to have the same order.
 func totalFees(block *types.Block, receipts []*types.Receipt) *big.Float {
 	feesWei := new(big.Int)
 	for i, tx := range block.Transactions() {
-		feesWei.Add(feesWei, new(big.Int).Mul(new(big.Int).SetUint64(receipts[i].GasUsed), tx.GasPrice()))
+		minerFee, _ := tx.EffectiveGasTip(block.BaseFee())
+		feesWei.Add(feesWei, new(big.Int).Mul(new(big.Int).SetUint64(receipts[i].GasUsed), minerFee))
 	}
 	return new(big.Float).Quo(new(big.Float).SetInt(feesWei), new(big.Float).SetInt(big.NewInt(params.Hayeker)))
 }
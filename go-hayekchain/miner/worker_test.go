@@ -61,6 +61,9 @@ var (
 	testUserKey, _  = crypto.GenerateKey()
 	testUserAddress = crypto.PubkeyToAddress(testUserKey.PublicKey)
 
+	testSearcherKey, _  = crypto.GenerateKey()
+	testSearcherAddress = crypto.PubkeyToAddress(testSearcherKey.PublicKey)
+
 	// Test transactions
 	pendingTxs []*types.Transaction
 	newTxs     []*types.Transaction
@@ -101,7 +104,7 @@ type testWorkerBackend struct {
 func newTestWorkerBackend(t *testing.T, chainConfig *params.ChainConfig, engine consensus.Engine, db hykdb.Database, n int) *testWorkerBackend {
 	var gspec = core.Genesis{
 		Config: chainConfig,
-		Alloc:  core.GenesisAlloc{testBankAddress: {Balance: testBankFunds}},
+		Alloc:  core.GenesisAlloc{testBankAddress: {Balance: testBankFunds}, testSearcherAddress: {Balance: testBankFunds}},
 	}
 
 	switch e := engine.(type) {
@@ -297,6 +300,152 @@ func testEmptyWork(t *testing.T, chainConfig *params.ChainConfig, engine consens
 	}
 }
 
+// Tests that private transactions are included ahead of the pending ones.
+func TestPrivateTransactionsMined(t *testing.T) {
+	engine := hykash.NewFaker()
+	defer engine.Close()
+
+	w, b := newTestWorker(t, hykashChainConfig, engine, rawdb.NewMemoryDatabase(), 0)
+	defer w.close()
+
+	// Supersede the pending transaction of the bank with a private one
+	tx, _ := types.SignTx(types.NewTransaction(0, testUserAddress, big.NewInt(5000), params.TxGas, nil, nil), types.HomesteadSigner{}, testBankKey)
+	if err := b.txPool.AddPrivate(tx, 0); err != nil {
+		t.Fatalf("failed to add private transaction: %v", err)
+	}
+	taskCh := make(chan *task, 2)
+	w.newTaskHook = func(task *task) {
+		if task.block.NumberU64() == 1 && len(task.receipts) > 0 {
+			taskCh <- task
+		}
+	}
+	w.skipSealHook = func(task *task) bool { return true }
+	w.start()
+
+	select {
+	case task := <-taskCh:
+		if txs := task.block.Transactions(); len(txs) != 1 || txs[0].Hash() != tx.Hash() {
+			t.Fatalf("included transactions mismatch: have %v, want %v", txs, types.Transactions{tx})
+		}
+		if balance := task.state.GetBalance(testUserAddress); balance.Cmp(big.NewInt(5000)) != 0 {
+			t.Fatalf("account balance mismatch: have %d, want %d", balance, 5000)
+		}
+	case <-time.NewTimer(3 * time.Second).C:
+		t.Fatalf("new task timeout")
+	}
+}
+
+// Tests that bundles are included atomically, ranked by their coinbase payment
+// per gas, and rejected if they revert without being allowed to.
+func TestBundlesMined(t *testing.T) {
+	engine := hykash.NewFaker()
+	defer engine.Close()
+
+	w, b := newTestWorker(t, hykashChainConfig, engine, rawdb.NewMemoryDatabase(), 0)
+	defer w.close()
+
+	var (
+		revertCode = common.FromHex("60006000fd") // REVERT(0, 0)
+		signer     = types.HomesteadSigner{}
+	)
+	transfer := func(nonce uint64, value int64, price int64) *types.Transaction {
+		tx, _ := types.SignTx(types.NewTransaction(nonce, testUserAddress, big.NewInt(value), params.TxGas, big.NewInt(price), nil), signer, testSearcherKey)
+		return tx
+	}
+	revert := func(nonce uint64, price int64) *types.Transaction {
+		tx, _ := types.SignTx(types.NewContractCreation(nonce, new(big.Int), 100000, big.NewInt(price), revertCode), signer, testSearcherKey)
+		return tx
+	}
+	var (
+		reverting = &core.TxBundle{Txs: types.Transactions{transfer(0, 1000, 3), revert(1, 3)}, BlockNumber: 1}
+		allowed   = &core.TxBundle{Txs: types.Transactions{transfer(0, 2000, 2), revert(1, 2)}, BlockNumber: 1}
+		cheap     = &core.TxBundle{Txs: types.Transactions{transfer(0, 3000, 1)}, BlockNumber: 1}
+		future    = &core.TxBundle{Txs: types.Transactions{transfer(0, 4000, 5)}, BlockNumber: 2}
+	)
+	allowed.RevertingTxHashes = []common.Hash{allowed.Txs[1].Hash()}
+
+	// A bundle of the coinbase pays nothing, it must not be preferred for its tip
+	selfPaid, _ := types.SignTx(types.NewTransaction(0, testUserAddress, new(big.Int), params.TxGas, big.NewInt(5), nil), signer, testBankKey)
+	unprofitable := &core.TxBundle{Txs: types.Transactions{selfPaid}, BlockNumber: 1}
+
+	for _, bundle := range []*core.TxBundle{reverting, allowed, cheap, future, unprofitable} {
+		if err := b.txPool.AddBundle(bundle); err != nil {
+			t.Fatalf("failed to add bundle: %v", err)
+		}
+	}
+	taskCh := make(chan *task, 2)
+	w.newTaskHook = func(task *task) {
+		if task.block.NumberU64() == 1 && len(task.receipts) > 0 {
+			taskCh <- task
+		}
+	}
+	w.skipSealHook = func(task *task) bool { return true }
+	w.start()
+
+	select {
+	case task := <-taskCh:
+		// The best paying bundle goes first, followed by the pooled transaction
+		txs := task.block.Transactions()
+		if len(txs) != 3 || txs[0].Hash() != allowed.Txs[0].Hash() || txs[1].Hash() != allowed.Txs[1].Hash() || txs[2].Hash() != pendingTxs[0].Hash() {
+			t.Fatalf("included transactions mismatch: have %v, want %v", txs, append(allowed.Txs, pendingTxs[0]))
+		}
+		if status := task.receipts[1].Status; status != types.ReceiptStatusFailed {
+			t.Fatalf("allowed revert status mismatch: have %d, want %d", status, types.ReceiptStatusFailed)
+		}
+		if balance := task.state.GetBalance(testUserAddress); balance.Cmp(big.NewInt(3000)) != 0 {
+			t.Fatalf("account balance mismatch: have %d, want %d", balance, 3000)
+		}
+	case <-time.NewTimer(3 * time.Second).C:
+		t.Fatalf("new task timeout")
+	}
+}
+
+// Tests that the worker builds blocks with the configured ordering policy, and
+// falls back to the default one for unknown policies.
+func TestWorkerOrderingPolicy(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		want OrderingPolicy
+	}{
+		{"", priceOrdering{}},
+		{OrderingFIFO, fifoOrdering{}},
+		{OrderingFairShare, fairShareOrdering{}},
+		{"unknown", priceOrdering{}},
+	} {
+		engine := hykash.NewFaker()
+		backend := newTestWorkerBackend(t, hykashChainConfig, engine, rawdb.NewMemoryDatabase(), 0)
+		backend.txPool.AddLocals(pendingTxs)
+
+		config := *testConfig
+		config.Ordering = tt.name
+		w := newWorker(&config, hykashChainConfig, engine, backend, new(event.TypeMux), nil, false)
+		w.setHayekerbase(testBankAddress)
+
+		if w.ordering != tt.want {
+			t.Errorf("policy %q: ordering mismatch: have %T, want %T", tt.name, w.ordering, tt.want)
+		}
+		taskCh := make(chan *task, 2)
+		w.newTaskHook = func(task *task) {
+			if task.block.NumberU64() == 1 && len(task.receipts) > 0 {
+				taskCh <- task
+			}
+		}
+		w.skipSealHook = func(task *task) bool { return true }
+		w.start()
+
+		select {
+		case task := <-taskCh:
+			if txs := task.block.Transactions(); len(txs) != 1 || txs[0].Hash() != pendingTxs[0].Hash() {
+				t.Errorf("policy %q: included transactions mismatch: have %v, want %v", tt.name, txs, pendingTxs)
+			}
+		case <-time.NewTimer(3 * time.Second).C:
+			t.Errorf("policy %q: new task timeout", tt.name)
+		}
+		w.close()
+		engine.Close()
+	}
+}
+
 func TestStreamUncleBlock(t *testing.T) {
 	hykash := hykash.NewFaker()
 	defer hykash.Close()
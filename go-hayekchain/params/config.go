@@ -240,16 +240,16 @@ var (
 	//
 	// This configuration is intentionally not using keyed fields to force anyone
 	// adding flags to the config to also have to set these fields.
-	AllHayekashProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, nil, new(HayekashConfig), nil}
+	AllHayekashProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, nil, nil, nil, nil, false, new(HayekashConfig), nil}
 
 	// AllCliqueProtocolChanges contains every protocol change (EIPs) introduced
 	// and accepted by the HayekChain core developers into the Clique consensus.
 	//
 	// This configuration is intentionally not using keyed fields to force anyone
 	// adding flags to the config to also have to set these fields.
-	AllCliqueProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, nil, nil, &CliqueConfig{Period: 0, Epoch: 30000}}
+	AllCliqueProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, nil, nil, nil, nil, false, nil, &CliqueConfig{Period: 0, Epoch: 30000}}
 
-	TestChainConfig = &ChainConfig{big.NewInt(1), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, nil, new(HayekashConfig), nil}
+	TestChainConfig = &ChainConfig{big.NewInt(1), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, nil, nil, nil, nil, false, new(HayekashConfig), nil}
 	TestRules       = TestChainConfig.Rules(new(big.Int))
 )
 
@@ -321,8 +321,18 @@ type ChainConfig struct {
 	IstanbulBlock       *big.Int `json:"istanbulBlock,omitempty"`       // Istanbul switch block (nil = no fork, 0 = already on istanbul)
 	MuirGlacierBlock    *big.Int `json:"muirGlacierBlock,omitempty"`    // Eip-2384 (bomb delay) switch block (nil = no fork, 0 = already activated)
 
-	YoloV2Block *big.Int `json:"yoloV2Block,omitempty"` // YOLO v2: Gas repricings TODO @holiman add EIP references
-	EWASMBlock  *big.Int `json:"ewasmBlock,omitempty"`  // EWASM switch block (nil = no fork, 0 = already activated)
+	YoloV2Block  *big.Int `json:"yoloV2Block,omitempty"`  // YOLO v2: Gas repricings TODO @holiman add EIP references
+	LondonBlock  *big.Int `json:"londonBlock,omitempty"`  // EIP-1559 base fee market switch block (nil = no fork, 0 = already activated)
+	SponsorBlock *big.Int `json:"sponsorBlock,omitempty"` // Sponsored (fee payer) transactions switch block (nil = no fork, 0 = already activated)
+	EWASMBlock   *big.Int `json:"ewasmBlock,omitempty"`   // EWASM switch block (nil = no fork, 0 = already activated)
+
+	// BaseFeeCollector is the treasury credited with the base fee of every
+	// transaction after the London fork. If nil, the base fee is burned.
+	BaseFeeCollector *common.Address `json:"baseFeeCollector,omitempty"`
+
+	// BinaryTrie stores the state of the chain in experimental binary SHA256
+	// tries instead of hexary Merkle-Patricia tries, starting from genesis.
+	BinaryTrie bool `json:"binaryTrie,omitempty"`
 
 	// Various consensus engines
 	Hayekash *HayekashConfig `json:"hykash,omitempty"`
@@ -330,7 +340,227 @@ type ChainConfig struct {
 }
 
 // HayekashConfig is the consensus engine configs for proof-of-work based sealing.
-type HayekashConfig struct{}
+type HayekashConfig struct {
+	Rewards    []HayekashReward    `json:"rewards,omitempty"`    // Block reward schedule, in activation order
+	Uncles     []HayekashUncleRule `json:"uncles,omitempty"`     // Uncle inclusion rules, in activation order
+	Retargets  []HayekashRetarget  `json:"retargets,omitempty"`  // Compact target retarget rules, in activation order
+	Algorithms []HayekashAlgorithm `json:"algorithms,omitempty"` // Proof-of-work algorithm switches, in activation order
+
+	AuxPowBlock   *big.Int `json:"auxPowBlock,omitempty"`   // Block number merge mined seals are accepted from (nil = never)
+	AuxPowChainID uint32   `json:"auxPowChainId,omitempty"` // Chain ID selecting the merged mining tree slot of the chain
+}
+
+// Stock uncle inclusion limits, in effect until the first uncle rule activates.
+const (
+	HayekashMaxUncles     = 2 // Maximum number of uncles allowed in a single block
+	HayekashMaxUncleDepth = 6 // Maximum distance of an uncle from the block including it
+)
+
+// HayekashReward is a block reward schedule step, replacing the stock fork based
+// block rewards from its activation block onwards. A non-zero halving interval
+// halves the reward every that many blocks after activation.
+type HayekashReward struct {
+	Block           *big.Int `json:"block"`                     // Block number the step activates at
+	Reward          *big.Int `json:"reward"`                    // Block reward in wei at activation
+	HalvingInterval uint64   `json:"halvingInterval,omitempty"` // Number of blocks between halvings (0 = no halving)
+}
+
+// HayekashUncleRule is an uncle inclusion rule, replacing the stock uncle limits
+// from its activation block onwards. Zero max uncles disables uncles entirely.
+type HayekashUncleRule struct {
+	Block     *big.Int `json:"block"`     // Block number the rule activates at
+	MaxUncles uint64   `json:"maxUncles"` // Maximum number of uncles allowed in a single block
+	MaxDepth  uint64   `json:"maxDepth"`  // Maximum distance of an uncle from the block including it
+}
+
+// Retarget algorithms supported by the hykash compact target retargeting.
+const (
+	RetargetLWMA       = "lwma"       // Linearly weighted moving average of solve times
+	RetargetDigiShield = "digishield" // Dampened windowed average of the window timespan
+)
+
+// HayekashRetarget is a compact target difficulty retargeting rule, replacing
+// the stock difficulty adjustment from its activation block onwards.
+type HayekashRetarget struct {
+	Block     *big.Int `json:"block"`     // Block number the rule activates at
+	Algorithm string   `json:"algorithm"` // Retarget algorithm, "lwma" or "digishield"
+	BlockTime uint64   `json:"blockTime"` // Target block time in seconds
+	Window    uint64   `json:"window"`    // Number of past blocks the retarget averages over
+}
+
+// Proof-of-work algorithms selectable by the hykash algorithm switches.
+const (
+	PowHashimoto = "hashimoto" // Memory hard hashimoto over an epoch DAG
+	PowSHA256d   = "sha256d"   // Bitcoin style double-SHA256 over an 80 byte header
+)
+
+// HayekashAlgorithm is a proof-of-work algorithm switch, sealing and verifying
+// blocks with the given algorithm from its activation block onwards.
+type HayekashAlgorithm struct {
+	Block     *big.Int `json:"block"`     // Block number the switch activates at
+	Algorithm string   `json:"algorithm"` // Proof-of-work algorithm, "hashimoto" or "sha256d"
+}
+
+// Reward returns the block reward schedule step active at the given block
+// number, or nil if the stock block rewards are still in effect.
+func (c *HayekashConfig) Reward(num *big.Int) *HayekashReward {
+	if c == nil {
+		return nil
+	}
+	var active *HayekashReward
+	for i := range c.Rewards {
+		if isForked(c.Rewards[i].Block, num) {
+			active = &c.Rewards[i]
+		}
+	}
+	return active
+}
+
+// UncleLimits returns the maximum number of uncles and their maximum depth
+// allowed in the block with the given number.
+func (c *HayekashConfig) UncleLimits(num *big.Int) (uint64, uint64) {
+	maxUncles, maxDepth := uint64(HayekashMaxUncles), uint64(HayekashMaxUncleDepth)
+	if c == nil {
+		return maxUncles, maxDepth
+	}
+	for _, rule := range c.Uncles {
+		if isForked(rule.Block, num) {
+			maxUncles, maxDepth = rule.MaxUncles, rule.MaxDepth
+		}
+	}
+	return maxUncles, maxDepth
+}
+
+// Retarget returns the retargeting rule active at the given block number, or
+// nil if the stock difficulty adjustment is still in effect.
+func (c *HayekashConfig) Retarget(num *big.Int) *HayekashRetarget {
+	if c == nil {
+		return nil
+	}
+	var active *HayekashRetarget
+	for i := range c.Retargets {
+		if isForked(c.Retargets[i].Block, num) {
+			active = &c.Retargets[i]
+		}
+	}
+	return active
+}
+
+// Algorithm returns the proof-of-work algorithm switched to at the given block
+// number, or an empty string if the node's own algorithm is still in effect.
+func (c *HayekashConfig) Algorithm(num *big.Int) string {
+	if c == nil {
+		return ""
+	}
+	var active string
+	for _, rule := range c.Algorithms {
+		if isForked(rule.Block, num) {
+			active = rule.Algorithm
+		}
+	}
+	return active
+}
+
+// IsAuxPow returns whether num is either equal to the merged mining activation
+// block or greater.
+func (c *HayekashConfig) IsAuxPow(num *big.Int) bool {
+	return c != nil && isForked(c.AuxPowBlock, num)
+}
+
+// CheckRules verifies that all the reward, uncle, retargeting and algorithm
+// rules are well formed and listed in activation order.
+func (c *HayekashConfig) CheckRules() error {
+	if err := c.CheckRewards(); err != nil {
+		return err
+	}
+	if err := c.CheckUncles(); err != nil {
+		return err
+	}
+	if err := c.CheckRetargets(); err != nil {
+		return err
+	}
+	return c.CheckAlgorithms()
+}
+
+// CheckRewards verifies that the block reward schedule is well formed and listed
+// in activation order.
+func (c *HayekashConfig) CheckRewards() error {
+	var last *big.Int
+	for i, step := range c.Rewards {
+		if step.Block == nil {
+			return fmt.Errorf("hykash reward #%d has no activation block", i)
+		}
+		if last != nil && step.Block.Cmp(last) <= 0 {
+			return fmt.Errorf("hykash reward #%d at block %v not after block %v", i, step.Block, last)
+		}
+		if step.Reward == nil || step.Reward.Sign() < 0 {
+			return fmt.Errorf("hykash reward #%d has no valid reward", i)
+		}
+		last = step.Block
+	}
+	return nil
+}
+
+// CheckUncles verifies that the uncle inclusion rules are well formed and listed
+// in activation order. Uncles deeper than the stock limit are rejected as the
+// uncle reward curve would not pay them.
+func (c *HayekashConfig) CheckUncles() error {
+	var last *big.Int
+	for i, rule := range c.Uncles {
+		if rule.Block == nil {
+			return fmt.Errorf("hykash uncle rule #%d has no activation block", i)
+		}
+		if last != nil && rule.Block.Cmp(last) <= 0 {
+			return fmt.Errorf("hykash uncle rule #%d at block %v not after block %v", i, rule.Block, last)
+		}
+		if rule.MaxUncles > 0 && (rule.MaxDepth == 0 || rule.MaxDepth > HayekashMaxUncleDepth) {
+			return fmt.Errorf("hykash uncle rule #%d has invalid depth %d (1-%d)", i, rule.MaxDepth, HayekashMaxUncleDepth)
+		}
+		last = rule.Block
+	}
+	return nil
+}
+
+// CheckRetargets verifies that the retargeting rules are well formed and listed
+// in activation order.
+func (c *HayekashConfig) CheckRetargets() error {
+	var last *big.Int
+	for i, rule := range c.Retargets {
+		if rule.Block == nil {
+			return fmt.Errorf("hykash retarget #%d has no activation block", i)
+		}
+		if last != nil && rule.Block.Cmp(last) <= 0 {
+			return fmt.Errorf("hykash retarget #%d at block %v not after block %v", i, rule.Block, last)
+		}
+		if rule.Algorithm != RetargetLWMA && rule.Algorithm != RetargetDigiShield {
+			return fmt.Errorf("hykash retarget #%d has unknown algorithm %q", i, rule.Algorithm)
+		}
+		if rule.BlockTime == 0 || rule.Window == 0 {
+			return fmt.Errorf("hykash retarget #%d needs a non-zero block time and window", i)
+		}
+		last = rule.Block
+	}
+	return nil
+}
+
+// CheckAlgorithms verifies that the proof-of-work algorithm switches are well
+// formed and listed in activation order.
+func (c *HayekashConfig) CheckAlgorithms() error {
+	var last *big.Int
+	for i, rule := range c.Algorithms {
+		if rule.Block == nil {
+			return fmt.Errorf("hykash algorithm switch #%d has no activation block", i)
+		}
+		if last != nil && rule.Block.Cmp(last) <= 0 {
+			return fmt.Errorf("hykash algorithm switch #%d at block %v not after block %v", i, rule.Block, last)
+		}
+		if rule.Algorithm != PowHashimoto && rule.Algorithm != PowSHA256d {
+			return fmt.Errorf("hykash algorithm switch #%d has unknown algorithm %q", i, rule.Algorithm)
+		}
+		last = rule.Block
+	}
+	return nil
+}
 
 // String implements the stringer interface, returning the consensus engine details.
 func (c *HayekashConfig) String() string {
@@ -359,7 +589,7 @@ func (c *ChainConfig) String() string {
 	default:
 		engine = "unknown"
 	}
-	return fmt.Sprintf("{ChainID: %v Homestead: %v DAO: %v DAOSupport: %v EIP150: %v EIP155: %v EIP158: %v Byzantium: %v Constantinople: %v Petersburg: %v Istanbul: %v, Muir Glacier: %v, YOLO v2: %v, Engine: %v}",
+	return fmt.Sprintf("{ChainID: %v Homestead: %v DAO: %v DAOSupport: %v EIP150: %v EIP155: %v EIP158: %v Byzantium: %v Constantinople: %v Petersburg: %v Istanbul: %v, Muir Glacier: %v, YOLO v2: %v, London: %v, Sponsor: %v, Engine: %v}",
 		c.ChainID,
 		c.HomesteadBlock,
 		c.DAOForkBlock,
@@ -373,6 +603,8 @@ func (c *ChainConfig) String() string {
 		c.IstanbulBlock,
 		c.MuirGlacierBlock,
 		c.YoloV2Block,
+		c.LondonBlock,
+		c.SponsorBlock,
 		engine,
 	)
 	/*
@@ -452,6 +684,17 @@ func (c *ChainConfig) IsYoloV2(num *big.Int) bool {
 	return isForked(c.YoloV2Block, num)
 }
 
+// IsLondon returns whether num is either equal to the London fork block or greater.
+func (c *ChainConfig) IsLondon(num *big.Int) bool {
+	return isForked(c.LondonBlock, num)
+}
+
+// IsSponsor returns whether num is either equal to the sponsored transactions
+// fork block or greater.
+func (c *ChainConfig) IsSponsor(num *big.Int) bool {
+	return isForked(c.SponsorBlock, num)
+}
+
 // IsEWASM returns whether num represents a block number after the EWASM fork
 func (c *ChainConfig) IsEWASM(num *big.Int) bool {
 	return isForked(c.EWASMBlock, num)
@@ -495,7 +738,9 @@ func (c *ChainConfig) CheckConfigForkOrder() error {
 		{name: "petersburgBlock", block: c.PetersburgBlock},
 		{name: "istanbulBlock", block: c.IstanbulBlock},
 		{name: "muirGlacierBlock", block: c.MuirGlacierBlock, optional: true},
-		{name: "yoloV2Block", block: c.YoloV2Block},
+		{name: "yoloV2Block", block: c.YoloV2Block}, // London txs carry access lists, which need EIP-2929 warming
+		{name: "londonBlock", block: c.LondonBlock},
+		{name: "sponsorBlock", block: c.SponsorBlock, optional: true},
 	} {
 		if lastFork.name != "" {
 			// Next one must be higher number
@@ -515,6 +760,9 @@ func (c *ChainConfig) CheckConfigForkOrder() error {
 			lastFork = cur
 		}
 	}
+	if c.Hayekash != nil {
+		return c.Hayekash.CheckRules()
+	}
 	return nil
 }
 
@@ -562,9 +810,92 @@ func (c *ChainConfig) checkCompatible(newcfg *ChainConfig, head *big.Int) *Confi
 	if isForkIncompatible(c.YoloV2Block, newcfg.YoloV2Block, head) {
 		return newCompatError("YOLOv2 fork block", c.YoloV2Block, newcfg.YoloV2Block)
 	}
+	if isForkIncompatible(c.LondonBlock, newcfg.LondonBlock, head) {
+		return newCompatError("London fork block", c.LondonBlock, newcfg.LondonBlock)
+	}
+	if c.IsLondon(head) && !addressEqual(c.BaseFeeCollector, newcfg.BaseFeeCollector) {
+		return newCompatError("London base fee collector", c.LondonBlock, newcfg.LondonBlock)
+	}
+	if isForkIncompatible(c.SponsorBlock, newcfg.SponsorBlock, head) {
+		return newCompatError("Sponsor fork block", c.SponsorBlock, newcfg.SponsorBlock)
+	}
 	if isForkIncompatible(c.EWASMBlock, newcfg.EWASMBlock, head) {
 		return newCompatError("ewasm fork block", c.EWASMBlock, newcfg.EWASMBlock)
 	}
+	if err := c.Hayekash.checkCompatible(newcfg.Hayekash, head); err != nil {
+		return err
+	}
+	return nil
+}
+
+// checkCompatible returns an error if a reward, uncle, retargeting or algorithm
+// rule that is already in effect at head was changed, inserted or removed, or
+// if merged mining was rescheduled or retargeted to another chain ID once active.
+func (c *HayekashConfig) checkCompatible(newcfg *HayekashConfig, head *big.Int) *ConfigCompatError {
+	if c == nil {
+		c = new(HayekashConfig)
+	}
+	if newcfg == nil {
+		newcfg = new(HayekashConfig)
+	}
+	for i := 0; i < len(c.Rewards) || i < len(newcfg.Rewards); i++ {
+		var stored, next HayekashReward
+		if i < len(c.Rewards) {
+			stored = c.Rewards[i]
+		}
+		if i < len(newcfg.Rewards) {
+			next = newcfg.Rewards[i]
+		}
+		equal := configNumEqual(stored.Block, next.Block) && configNumEqual(stored.Reward, next.Reward) && stored.HalvingInterval == next.HalvingInterval
+		if !equal && (isForked(stored.Block, head) || isForked(next.Block, head)) {
+			return newCompatError("hykash reward schedule", stored.Block, next.Block)
+		}
+	}
+	for i := 0; i < len(c.Uncles) || i < len(newcfg.Uncles); i++ {
+		var stored, next HayekashUncleRule
+		if i < len(c.Uncles) {
+			stored = c.Uncles[i]
+		}
+		if i < len(newcfg.Uncles) {
+			next = newcfg.Uncles[i]
+		}
+		equal := configNumEqual(stored.Block, next.Block) && stored.MaxUncles == next.MaxUncles && stored.MaxDepth == next.MaxDepth
+		if !equal && (isForked(stored.Block, head) || isForked(next.Block, head)) {
+			return newCompatError("hykash uncle rules", stored.Block, next.Block)
+		}
+	}
+	for i := 0; i < len(c.Retargets) || i < len(newcfg.Retargets); i++ {
+		var stored, next HayekashRetarget
+		if i < len(c.Retargets) {
+			stored = c.Retargets[i]
+		}
+		if i < len(newcfg.Retargets) {
+			next = newcfg.Retargets[i]
+		}
+		equal := configNumEqual(stored.Block, next.Block) && stored.Algorithm == next.Algorithm && stored.BlockTime == next.BlockTime && stored.Window == next.Window
+		if !equal && (isForked(stored.Block, head) || isForked(next.Block, head)) {
+			return newCompatError("hykash retarget rules", stored.Block, next.Block)
+		}
+	}
+	for i := 0; i < len(c.Algorithms) || i < len(newcfg.Algorithms); i++ {
+		var stored, next HayekashAlgorithm
+		if i < len(c.Algorithms) {
+			stored = c.Algorithms[i]
+		}
+		if i < len(newcfg.Algorithms) {
+			next = newcfg.Algorithms[i]
+		}
+		equal := configNumEqual(stored.Block, next.Block) && stored.Algorithm == next.Algorithm
+		if !equal && (isForked(stored.Block, head) || isForked(next.Block, head)) {
+			return newCompatError("hykash algorithm switches", stored.Block, next.Block)
+		}
+	}
+	if isForkIncompatible(c.AuxPowBlock, newcfg.AuxPowBlock, head) {
+		return newCompatError("hykash auxpow fork block", c.AuxPowBlock, newcfg.AuxPowBlock)
+	}
+	if c.AuxPowChainID != newcfg.AuxPowChainID && isForked(c.AuxPowBlock, head) {
+		return newCompatError("hykash auxpow chain id", c.AuxPowBlock, newcfg.AuxPowBlock)
+	}
 	return nil
 }
 
@@ -592,6 +923,13 @@ func configNumEqual(x, y *big.Int) bool {
 	return x.Cmp(y) == 0
 }
 
+func addressEqual(x, y *common.Address) bool {
+	if x == nil || y == nil {
+		return x == y
+	}
+	return *x == *y
+}
+
 // ConfigCompatError is raised if the locally-stored blockchain is initialised with a
 // ChainConfig that would alter the past.
 type ConfigCompatError struct {
@@ -632,7 +970,7 @@ type Rules struct {
 	ChainID                                                 *big.Int
 	IsHomestead, IsEIP150, IsEIP155, IsEIP158               bool
 	IsByzantium, IsConstantinople, IsPetersburg, IsIstanbul bool
-	IsYoloV2                                                bool
+	IsYoloV2, IsLondon, IsSponsor                           bool
 }
 
 // Rules ensures c's ChainID is not nil.
@@ -652,5 +990,7 @@ func (c *ChainConfig) Rules(num *big.Int) Rules {
 		IsPetersburg:     c.IsPetersburg(num),
 		IsIstanbul:       c.IsIstanbul(num),
 		IsYoloV2:         c.IsYoloV2(num),
+		IsLondon:         c.IsLondon(num),
+		IsSponsor:        c.IsSponsor(num),
 	}
 }
@@ -17,6 +17,7 @@
 package params
 
 import (
+	"encoding/json"
 	"math/big"
 	"reflect"
 	"testing"
@@ -87,6 +88,79 @@ func TestCheckCompatible(t *testing.T) {
 				RewindTo:     30,
 			},
 		},
+		{
+			stored:  &ChainConfig{Hayekash: &HayekashConfig{Rewards: []HayekashReward{{Block: big.NewInt(10), Reward: big.NewInt(5)}}}},
+			new:     &ChainConfig{Hayekash: &HayekashConfig{Rewards: []HayekashReward{{Block: big.NewInt(10), Reward: big.NewInt(5)}, {Block: big.NewInt(50), Reward: big.NewInt(2)}}}},
+			head:    40,
+			wantErr: nil,
+		},
+		{
+			stored: &ChainConfig{Hayekash: &HayekashConfig{Rewards: []HayekashReward{{Block: big.NewInt(10), Reward: big.NewInt(5)}}}},
+			new:    &ChainConfig{Hayekash: &HayekashConfig{Rewards: []HayekashReward{{Block: big.NewInt(10), Reward: big.NewInt(5), HalvingInterval: 100}}}},
+			head:   40,
+			wantErr: &ConfigCompatError{
+				What:         "hykash reward schedule",
+				StoredConfig: big.NewInt(10),
+				NewConfig:    big.NewInt(10),
+				RewindTo:     9,
+			},
+		},
+		{
+			stored: &ChainConfig{Hayekash: new(HayekashConfig)},
+			new:    &ChainConfig{Hayekash: &HayekashConfig{Uncles: []HayekashUncleRule{{Block: big.NewInt(20), MaxUncles: 0}}}},
+			head:   40,
+			wantErr: &ConfigCompatError{
+				What:         "hykash uncle rules",
+				StoredConfig: nil,
+				NewConfig:    big.NewInt(20),
+				RewindTo:     19,
+			},
+		},
+		{
+			stored: &ChainConfig{Hayekash: &HayekashConfig{Retargets: []HayekashRetarget{{Block: big.NewInt(30), Algorithm: RetargetLWMA, BlockTime: 10, Window: 60}}}},
+			new:    &ChainConfig{Hayekash: &HayekashConfig{Retargets: []HayekashRetarget{{Block: big.NewInt(30), Algorithm: RetargetLWMA, BlockTime: 15, Window: 60}}}},
+			head:   40,
+			wantErr: &ConfigCompatError{
+				What:         "hykash retarget rules",
+				StoredConfig: big.NewInt(30),
+				NewConfig:    big.NewInt(30),
+				RewindTo:     29,
+			},
+		},
+		{
+			stored:  &ChainConfig{Hayekash: &HayekashConfig{Retargets: []HayekashRetarget{{Block: big.NewInt(30), Algorithm: RetargetLWMA, BlockTime: 10, Window: 60}}}},
+			new:     &ChainConfig{Hayekash: &HayekashConfig{Retargets: []HayekashRetarget{{Block: big.NewInt(50), Algorithm: RetargetLWMA, BlockTime: 10, Window: 60}}}},
+			head:    20,
+			wantErr: nil,
+		},
+		{
+			stored: &ChainConfig{Hayekash: &HayekashConfig{Algorithms: []HayekashAlgorithm{{Block: big.NewInt(30), Algorithm: PowSHA256d}}}},
+			new:    &ChainConfig{Hayekash: &HayekashConfig{Algorithms: []HayekashAlgorithm{{Block: big.NewInt(60), Algorithm: PowSHA256d}}}},
+			head:   40,
+			wantErr: &ConfigCompatError{
+				What:         "hykash algorithm switches",
+				StoredConfig: big.NewInt(30),
+				NewConfig:    big.NewInt(60),
+				RewindTo:     29,
+			},
+		},
+		{
+			stored: &ChainConfig{Hayekash: &HayekashConfig{AuxPowBlock: big.NewInt(30), AuxPowChainID: 7}},
+			new:    &ChainConfig{Hayekash: &HayekashConfig{AuxPowBlock: big.NewInt(30), AuxPowChainID: 8}},
+			head:   40,
+			wantErr: &ConfigCompatError{
+				What:         "hykash auxpow chain id",
+				StoredConfig: big.NewInt(30),
+				NewConfig:    big.NewInt(30),
+				RewindTo:     29,
+			},
+		},
+		{
+			stored:  &ChainConfig{Hayekash: &HayekashConfig{AuxPowBlock: big.NewInt(30), AuxPowChainID: 7}},
+			new:     &ChainConfig{Hayekash: &HayekashConfig{AuxPowBlock: big.NewInt(50), AuxPowChainID: 8}},
+			head:    20,
+			wantErr: nil,
+		},
 	}
 
 	for _, test := range tests {
@@ -96,3 +170,132 @@ func TestCheckCompatible(t *testing.T) {
 		}
 	}
 }
+
+// Tests that London requires the YOLOv2 access list fork and may not precede
+// the forks it builds on.
+func TestCheckConfigForkOrderLondon(t *testing.T) {
+	config := &ChainConfig{
+		ChainID:             big.NewInt(1),
+		HomesteadBlock:      big.NewInt(0),
+		EIP150Block:         big.NewInt(0),
+		EIP155Block:         big.NewInt(0),
+		EIP158Block:         big.NewInt(0),
+		ByzantiumBlock:      big.NewInt(0),
+		ConstantinopleBlock: big.NewInt(0),
+		PetersburgBlock:     big.NewInt(0),
+		IstanbulBlock:       big.NewInt(0),
+		MuirGlacierBlock:    big.NewInt(10),
+		LondonBlock:         big.NewInt(20),
+	}
+	if err := config.CheckConfigForkOrder(); err == nil {
+		t.Fatalf("london without yolov2 accepted")
+	}
+	yolo := *config
+	yolo.YoloV2Block = big.NewInt(15)
+	if err := yolo.CheckConfigForkOrder(); err != nil {
+		t.Fatalf("london after yolov2 rejected: %v", err)
+	}
+	early := yolo
+	early.YoloV2Block, early.LondonBlock = big.NewInt(5), big.NewInt(5)
+	if err := early.CheckConfigForkOrder(); err == nil {
+		t.Fatalf("london before muir glacier accepted")
+	}
+	yolo.YoloV2Block = big.NewInt(25)
+	if err := yolo.CheckConfigForkOrder(); err == nil {
+		t.Fatalf("london before yolov2 accepted")
+	}
+}
+
+// Tests that the hykash rules survive a round trip through their JSON encoding.
+func TestHayekashConfigJSON(t *testing.T) {
+	reward, _ := new(big.Int).SetString("50000000000000000000", 10)
+	config := &ChainConfig{
+		ChainID: big.NewInt(1),
+		Hayekash: &HayekashConfig{
+			Rewards: []HayekashReward{
+				{Block: big.NewInt(0), Reward: reward, HalvingInterval: 210000},
+				{Block: big.NewInt(1000000), Reward: big.NewInt(1e18)},
+			},
+			Uncles: []HayekashUncleRule{
+				{Block: big.NewInt(500), MaxUncles: 0},
+			},
+			Retargets: []HayekashRetarget{
+				{Block: big.NewInt(100), Algorithm: RetargetDigiShield, BlockTime: 60, Window: 17},
+			},
+			Algorithms: []HayekashAlgorithm{
+				{Block: big.NewInt(100), Algorithm: PowSHA256d},
+			},
+			AuxPowBlock:   big.NewInt(200),
+			AuxPowChainID: 7,
+		},
+	}
+	blob, err := json.Marshal(config)
+	if err != nil {
+		t.Fatalf("failed to encode config: %v", err)
+	}
+	decoded := new(ChainConfig)
+	if err := json.Unmarshal(blob, decoded); err != nil {
+		t.Fatalf("failed to decode config: %v", err)
+	}
+	if err := config.CheckCompatible(decoded, 2000000); err != nil {
+		t.Errorf("decoded config mismatch: %v", err)
+	}
+	if err := decoded.CheckConfigForkOrder(); err != nil {
+		t.Errorf("decoded config rejected: %v", err)
+	}
+}
+
+// Tests that the active reward step and uncle limits follow the activation blocks.
+func TestHayekashRules(t *testing.T) {
+	config := &HayekashConfig{
+		Rewards: []HayekashReward{
+			{Block: big.NewInt(10), Reward: big.NewInt(100)},
+			{Block: big.NewInt(20), Reward: big.NewInt(50)},
+		},
+		Uncles: []HayekashUncleRule{
+			{Block: big.NewInt(15), MaxUncles: 1, MaxDepth: 3},
+			{Block: big.NewInt(30), MaxUncles: 0, MaxDepth: 0},
+		},
+		Algorithms: []HayekashAlgorithm{
+			{Block: big.NewInt(20), Algorithm: PowSHA256d},
+		},
+	}
+	tests := []struct {
+		number    int64
+		reward    int64 // -1 = stock rewards
+		maxUncles uint64
+		maxDepth  uint64
+		algorithm string
+	}{
+		{0, -1, HayekashMaxUncles, HayekashMaxUncleDepth, ""},
+		{10, 100, HayekashMaxUncles, HayekashMaxUncleDepth, ""},
+		{15, 100, 1, 3, ""},
+		{20, 50, 1, 3, PowSHA256d},
+		{30, 50, 0, 0, PowSHA256d},
+	}
+	for i, tt := range tests {
+		num := big.NewInt(tt.number)
+		step := config.Reward(num)
+		if (step == nil) != (tt.reward < 0) || (step != nil && step.Reward.Int64() != tt.reward) {
+			t.Errorf("test %d: reward step mismatch: have %+v, want %d", i, step, tt.reward)
+		}
+		if maxUncles, maxDepth := config.UncleLimits(num); maxUncles != tt.maxUncles || maxDepth != tt.maxDepth {
+			t.Errorf("test %d: uncle limits mismatch: have %d/%d, want %d/%d", i, maxUncles, maxDepth, tt.maxUncles, tt.maxDepth)
+		}
+		if algorithm := config.Algorithm(num); algorithm != tt.algorithm {
+			t.Errorf("test %d: algorithm mismatch: have %q, want %q", i, algorithm, tt.algorithm)
+		}
+	}
+	if err := config.CheckRules(); err != nil {
+		t.Errorf("valid rules rejected: %v", err)
+	}
+	config.Uncles[0].MaxDepth = HayekashMaxUncleDepth + 1
+	if err := config.CheckRules(); err == nil {
+		t.Errorf("too deep uncle rule accepted")
+	}
+	config.Uncles[0].MaxDepth = 3
+	config.Algorithms[0].Algorithm = "ethash"
+	if err := config.CheckRules(); err == nil {
+		t.Errorf("unknown algorithm accepted")
+	}
+}
@@ -357,6 +357,33 @@ type tailPrivateFields struct {
 	x, y bool   //lint:ignore U1000 unused fields required for testing purposes.
 }
 
+type optionalFields struct {
+	A uint
+	B uint `rlp:"optional"`
+	C uint `rlp:"optional"`
+}
+
+type optionalAndTailField struct {
+	A    uint
+	B    uint   `rlp:"optional"`
+	Tail []uint `rlp:"tail"`
+}
+
+type optionalPtrField struct {
+	A uint
+	B *[3]byte `rlp:"optional"`
+}
+
+type nonOptionalPtrField struct {
+	A uint
+	B *[3]byte
+}
+
+type invalidOptionalField1 struct {
+	A uint `rlp:"optional"`
+	B uint
+}
+
 type nilListUint struct {
 	X *uint `rlp:"nilList"`
 }
@@ -548,6 +575,58 @@ var decodeTests = []decodeTest{
 		error: `rlp: invalid struct tag "tail" for rlp.invalidTail2.B (field type is not slice)`,
 	},
 
+	// struct tag "optional"
+	{
+		input: "C101",
+		ptr:   new(optionalFields),
+		value: optionalFields{1, 0, 0},
+	},
+	{
+		input: "C20102",
+		ptr:   new(optionalFields),
+		value: optionalFields{1, 2, 0},
+	},
+	{
+		input: "C3010203",
+		ptr:   new(optionalFields),
+		value: optionalFields{1, 2, 3},
+	},
+	{
+		input: "C401020304",
+		ptr:   new(optionalFields),
+		error: "rlp: input list has too many elements for rlp.optionalFields",
+	},
+	{
+		input: "C101",
+		ptr:   new(optionalAndTailField),
+		value: optionalAndTailField{A: 1},
+	},
+	{
+		input: "C3010203",
+		ptr:   new(optionalAndTailField),
+		value: optionalAndTailField{A: 1, B: 2, Tail: []uint{3}},
+	},
+	{
+		input: "C101",
+		ptr:   new(optionalPtrField),
+		value: optionalPtrField{A: 1},
+	},
+	{
+		input: "C50183010203",
+		ptr:   new(optionalPtrField),
+		value: optionalPtrField{A: 1, B: &[3]byte{1, 2, 3}},
+	},
+	{
+		input: "C101",
+		ptr:   new(nonOptionalPtrField),
+		error: "rlp: too few elements for rlp.nonOptionalPtrField",
+	},
+	{
+		input: "C0",
+		ptr:   new(invalidOptionalField1),
+		error: `rlp: struct field rlp.invalidOptionalField1.B needs "optional" tag`,
+	},
+
 	// struct tag "-"
 	{
 		input: "C20102",
@@ -102,10 +102,16 @@ Signed integers, floating point numbers, maps, channels and functions cannot be
 
 Struct Tags
 
-Package rlp honours certain struct tags: "-", "tail", "nil", "nilList" and "nilString".
+Package rlp honours certain struct tags: "-", "tail", "nil", "nilList", "nilString" and
+"optional".
 
 The "-" tag ignores fields.
 
+The "optional" tag allows a struct field to be missing from the end of the input list. If
+the field is absent, it is left at its zero value when decoding, and trailing optional
+fields holding zero values are omitted when encoding. Once a field is tagged "optional",
+all subsequent public fields must also be tagged "optional" (or "tail").
+
 The "tail" tag, which may only be used on the last exported struct field, allows slurping
 up any excess list elements into a slice. See examples for more details.
 
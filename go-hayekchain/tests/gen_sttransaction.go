@@ -8,6 +8,7 @@ import (
 
 	"github.com/hayekchain/go-hayekchain/common/hexutil"
 	"github.com/hayekchain/go-hayekchain/common/math"
+	"github.com/hayekchain/go-hayekchain/core/types"
 )
 
 var _ = (*stTransactionMarshaling)(nil)
@@ -17,16 +18,18 @@ func (s stTransaction) MarshalJSON() ([]byte, error) {
 		GasPrice   *math.HexOrDecimal256 `json:"gasPrice"`
 		Nonce      math.HexOrDecimal64   `json:"nonce"`
 		To         string                `json:"to"`
-		Data       []string              `json:"data"`
-		GasLimit   []math.HexOrDecimal64 `json:"gasLimit"`
-		Value      []string              `json:"value"`
-		PrivateKey hexutil.Bytes         `json:"secretKey"`
+		Data        []string              `json:"data"`
+		AccessLists []*types.AccessList   `json:"accessLists,omitempty"`
+		GasLimit    []math.HexOrDecimal64 `json:"gasLimit"`
+		Value       []string              `json:"value"`
+		PrivateKey  hexutil.Bytes         `json:"secretKey"`
 	}
 	var enc stTransaction
 	enc.GasPrice = (*math.HexOrDecimal256)(s.GasPrice)
 	enc.Nonce = math.HexOrDecimal64(s.Nonce)
 	enc.To = s.To
 	enc.Data = s.Data
+	enc.AccessLists = s.AccessLists
 	if s.GasLimit != nil {
 		enc.GasLimit = make([]math.HexOrDecimal64, len(s.GasLimit))
 		for k, v := range s.GasLimit {
@@ -43,10 +46,11 @@ func (s *stTransaction) UnmarshalJSON(input []byte) error {
 		GasPrice   *math.HexOrDecimal256 `json:"gasPrice"`
 		Nonce      *math.HexOrDecimal64  `json:"nonce"`
 		To         *string               `json:"to"`
-		Data       []string              `json:"data"`
-		GasLimit   []math.HexOrDecimal64 `json:"gasLimit"`
-		Value      []string              `json:"value"`
-		PrivateKey *hexutil.Bytes        `json:"secretKey"`
+		Data        []string              `json:"data"`
+		AccessLists []*types.AccessList   `json:"accessLists,omitempty"`
+		GasLimit    []math.HexOrDecimal64 `json:"gasLimit"`
+		Value       []string              `json:"value"`
+		PrivateKey  *hexutil.Bytes        `json:"secretKey"`
 	}
 	var dec stTransaction
 	if err := json.Unmarshal(input, &dec); err != nil {
@@ -64,6 +68,9 @@ func (s *stTransaction) UnmarshalJSON(input []byte) error {
 	if dec.Data != nil {
 		s.Data = dec.Data
 	}
+	if dec.AccessLists != nil {
+		s.AccessLists = dec.AccessLists
+	}
 	if dec.GasLimit != nil {
 		s.GasLimit = make([]uint64, len(dec.GasLimit))
 		for k, v := range dec.GasLimit {
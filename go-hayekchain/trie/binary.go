@@ -0,0 +1,639 @@
+// Copyright 2021 The go-hayekchain Authors
+// This file is part of the go-hayekchain library.
+//
+// The go-hayekchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hayekchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hayekchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package trie
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+
+	"github.com/hayekchain/go-hayekchain/common"
+	"github.com/hayekchain/go-hayekchain/hykdb"
+	"github.com/hayekchain/go-hayekchain/log"
+)
+
+const (
+	binaryLeafPrefix   = 0x00 // Prefix of encoded leaf nodes: key + value
+	binaryBranchPrefix = 0x01 // Prefix of encoded branch nodes: left hash + right hash
+)
+
+// errInvalidBinaryNode is returned when decoding a malformed binary trie node.
+var errInvalidBinaryNode = errors.New("invalid binary trie node")
+
+// binaryNode is a node of a binary trie, one of *binaryBranch, *binaryLeaf,
+// binaryHashNode or nil for empty subtries.
+type binaryNode interface{}
+
+// binaryBranch is an inner node of a binary trie, splitting the keys below it
+// on the bit at its depth.
+type binaryBranch struct {
+	left, right binaryNode
+	hash        common.Hash // Cached hash of the node, zero if not yet hashed
+	dirty       bool        // Whether the node is not yet stored in the database
+}
+
+// binaryLeaf is a key-value pair, stored in the trie at the shallowest depth at
+// which its key has no common prefix with any other key.
+type binaryLeaf struct {
+	key   []byte
+	value []byte
+	hash  common.Hash // Cached hash of the node, zero if not yet hashed
+	dirty bool        // Whether the node is not yet stored in the database
+}
+
+// binaryHashNode is a reference to a binary trie node not yet loaded from the
+// database.
+type binaryHashNode common.Hash
+
+// BinaryTrie is an experimental binary Merkle trie with the same key hashing as
+// a SecureTrie. Nodes are hashed with SHA256: leaves commit to their key and
+// value, branches to the hashes of their two children. Subtries with a single
+// key are collapsed into a leaf, keeping proofs logarithmic in the number of
+// keys. The hash of the empty trie is the same as for hexary tries.
+//
+// BinaryTrie is not safe for concurrent use.
+type BinaryTrie struct {
+	db   *Database
+	root binaryNode
+
+	hashKeyBuf       [common.HashLength]byte
+	secKeyCache      map[string][]byte
+	secKeyCacheOwner *BinaryTrie // Pointer to self, replace the key cache on mismatch
+}
+
+// NewBinary creates a binary trie with an existing root node from a backing
+// database. If root is the zero hash or the empty root hash, the trie is
+// initially empty, otherwise MissingNodeError is returned if the root node
+// cannot be found.
+func NewBinary(root common.Hash, db *Database) (*BinaryTrie, error) {
+	if db == nil {
+		panic("trie.NewBinary called without a database")
+	}
+	trie := &BinaryTrie{db: db}
+	if root != (common.Hash{}) && root != emptyRoot {
+		node, err := trie.resolve(root, nil)
+		if err != nil {
+			return nil, err
+		}
+		trie.root = node
+	}
+	return trie, nil
+}
+
+// Get returns the value for key stored in the trie.
+// The value bytes must not be modified by the caller.
+func (t *BinaryTrie) Get(key []byte) []byte {
+	res, err := t.TryGet(key)
+	if err != nil {
+		log.Error(fmt.Sprintf("Unhandled trie error: %v", err))
+	}
+	return res
+}
+
+// TryGet returns the value for key stored in the trie.
+// The value bytes must not be modified by the caller.
+// If a node was not found in the database, a MissingNodeError is returned.
+func (t *BinaryTrie) TryGet(key []byte) ([]byte, error) {
+	value, newroot, didResolve, err := t.get(t.root, t.hashKey(key), 0)
+	if err == nil && didResolve {
+		t.root = newroot
+	}
+	return value, err
+}
+
+func (t *BinaryTrie) get(n binaryNode, key []byte, depth int) ([]byte, binaryNode, bool, error) {
+	switch n := n.(type) {
+	case nil:
+		return nil, nil, false, nil
+	case *binaryLeaf:
+		if !bytes.Equal(n.key, key) {
+			return nil, n, false, nil
+		}
+		return n.value, n, false, nil
+	case *binaryBranch:
+		child := n.left
+		if binaryBit(key, depth) == 1 {
+			child = n.right
+		}
+		value, newnode, didResolve, err := t.get(child, key, depth+1)
+		if err == nil && didResolve {
+			n = n.withChild(binaryBit(key, depth), newnode, false)
+		}
+		return value, n, didResolve, err
+	case binaryHashNode:
+		child, err := t.resolve(common.Hash(n), binaryPath(key, depth))
+		if err != nil {
+			return nil, n, true, err
+		}
+		value, newnode, _, err := t.get(child, key, depth)
+		return value, newnode, true, err
+	default:
+		panic(fmt.Sprintf("%T: invalid node: %v", n, n))
+	}
+}
+
+// Update associates key with value in the trie. Subsequent calls to
+// Get will return value. If value has length zero, any existing value
+// is deleted from the trie and calls to Get will return nil.
+//
+// The value bytes must not be modified by the caller while they are
+// stored in the trie.
+func (t *BinaryTrie) Update(key, value []byte) {
+	if err := t.TryUpdate(key, value); err != nil {
+		log.Error(fmt.Sprintf("Unhandled trie error: %v", err))
+	}
+}
+
+// TryUpdate associates key with value in the trie. Subsequent calls to
+// Get will return value. If value has length zero, any existing value
+// is deleted from the trie and calls to Get will return nil.
+//
+// The value bytes must not be modified by the caller while they are
+// stored in the trie.
+//
+// If a node was not found in the database, a MissingNodeError is returned.
+func (t *BinaryTrie) TryUpdate(key, value []byte) error {
+	if len(value) == 0 {
+		return t.TryDelete(key)
+	}
+	hk := common.CopyBytes(t.hashKey(key))
+	n, err := t.insert(t.root, hk, value, 0)
+	if err != nil {
+		return err
+	}
+	t.root = n
+	t.getSecKeyCache()[string(hk)] = common.CopyBytes(key)
+	return nil
+}
+
+func (t *BinaryTrie) insert(n binaryNode, key, value []byte, depth int) (binaryNode, error) {
+	switch n := n.(type) {
+	case nil:
+		return &binaryLeaf{key: key, value: value, dirty: true}, nil
+	case *binaryLeaf:
+		if bytes.Equal(n.key, key) {
+			if bytes.Equal(n.value, value) {
+				return n, nil
+			}
+			return &binaryLeaf{key: key, value: value, dirty: true}, nil
+		}
+		// The keys diverge below this depth, split the leaf into a branch
+		branch := new(binaryBranch).withChild(binaryBit(n.key, depth), n, true)
+		return t.insert(branch, key, value, depth)
+	case *binaryBranch:
+		bit := binaryBit(key, depth)
+		child := n.left
+		if bit == 1 {
+			child = n.right
+		}
+		newnode, err := t.insert(child, key, value, depth+1)
+		if err != nil {
+			return n, err
+		}
+		if newnode == child {
+			return n, nil
+		}
+		return n.withChild(bit, newnode, true), nil
+	case binaryHashNode:
+		child, err := t.resolve(common.Hash(n), binaryPath(key, depth))
+		if err != nil {
+			return n, err
+		}
+		newnode, err := t.insert(child, key, value, depth)
+		if err != nil {
+			return n, err
+		}
+		if newnode == child {
+			return n, nil
+		}
+		return newnode, nil
+	default:
+		panic(fmt.Sprintf("%T: invalid node: %v", n, n))
+	}
+}
+
+// Delete removes any existing value for key from the trie.
+func (t *BinaryTrie) Delete(key []byte) {
+	if err := t.TryDelete(key); err != nil {
+		log.Error(fmt.Sprintf("Unhandled trie error: %v", err))
+	}
+}
+
+// TryDelete removes any existing value for key from the trie.
+// If a node was not found in the database, a MissingNodeError is returned.
+func (t *BinaryTrie) TryDelete(key []byte) error {
+	hk := t.hashKey(key)
+	delete(t.getSecKeyCache(), string(hk))
+
+	n, err := t.delete(t.root, hk, 0)
+	if err != nil {
+		return err
+	}
+	t.root = n
+	return nil
+}
+
+func (t *BinaryTrie) delete(n binaryNode, key []byte, depth int) (binaryNode, error) {
+	switch n := n.(type) {
+	case nil:
+		return nil, nil
+	case *binaryLeaf:
+		if !bytes.Equal(n.key, key) {
+			return n, nil
+		}
+		return nil, nil
+	case *binaryBranch:
+		bit := binaryBit(key, depth)
+		child, sibling := n.left, n.right
+		if bit == 1 {
+			child, sibling = n.right, n.left
+		}
+		newnode, err := t.delete(child, key, depth+1)
+		if err != nil {
+			return n, err
+		}
+		if newnode == child {
+			return n, nil
+		}
+		// If only a single leaf remains below the branch, collapse it upwards
+		switch {
+		case newnode == nil:
+			if hash, ok := sibling.(binaryHashNode); ok {
+				if sibling, err = t.resolve(common.Hash(hash), append(binaryPath(key, depth), 1-bit)); err != nil {
+					return n, err
+				}
+			}
+			if sibling == nil {
+				return nil, nil
+			}
+			if leaf, ok := sibling.(*binaryLeaf); ok {
+				return leaf, nil
+			}
+		case sibling == nil:
+			if leaf, ok := newnode.(*binaryLeaf); ok {
+				return leaf, nil
+			}
+		}
+		return n.withChild(bit, newnode, true), nil
+	case binaryHashNode:
+		child, err := t.resolve(common.Hash(n), binaryPath(key, depth))
+		if err != nil {
+			return n, err
+		}
+		newnode, err := t.delete(child, key, depth)
+		if err != nil {
+			return n, err
+		}
+		if newnode == child {
+			return n, nil
+		}
+		return newnode, nil
+	default:
+		panic(fmt.Sprintf("%T: invalid node: %v", n, n))
+	}
+}
+
+// GetKey returns the sha3 preimage of a hashed key that was
+// previously used to store a value.
+func (t *BinaryTrie) GetKey(shaKey []byte) []byte {
+	if key, ok := t.getSecKeyCache()[string(shaKey)]; ok {
+		return key
+	}
+	return t.db.preimage(common.BytesToHash(shaKey))
+}
+
+// Hash returns the root hash of the trie. It does not write to the
+// database and can be used even if the trie doesn't have one.
+func (t *BinaryTrie) Hash() common.Hash {
+	if t.root == nil {
+		return emptyRoot
+	}
+	hash, cached := binaryHash(t.root)
+	t.root = cached
+	return hash
+}
+
+// Commit writes all nodes and the secure hash pre-images to the trie's database.
+// Nodes are stored with their sha256 hash as the key.
+//
+// Committing flushes nodes from memory. Subsequent Get calls will load nodes
+// from the database.
+func (t *BinaryTrie) Commit(onleaf LeafCallback) (root common.Hash, err error) {
+	if t.db == nil {
+		panic("commit called on trie with nil database")
+	}
+	// Write all the pre-images to the actual disk database
+	if len(t.getSecKeyCache()) > 0 {
+		if t.db.preimages != nil { // Ugly direct check but avoids the below write lock
+			t.db.lock.Lock()
+			for hk, key := range t.secKeyCache {
+				t.db.insertPreimage(common.BytesToHash([]byte(hk)), key)
+			}
+			t.db.lock.Unlock()
+		}
+		t.secKeyCache = make(map[string][]byte)
+	}
+	if t.root == nil {
+		return emptyRoot, nil
+	}
+	root = t.Hash()
+	if err := t.commit(t.root, onleaf); err != nil {
+		return common.Hash{}, err
+	}
+	t.root = binaryHashNode(root)
+	return root, nil
+}
+
+// commit inserts the dirty nodes of a hashed subtrie into the database, children
+// first, referencing them from their parents.
+func (t *BinaryTrie) commit(n binaryNode, onleaf LeafCallback) error {
+	switch n := n.(type) {
+	case *binaryLeaf:
+		if !n.dirty {
+			return nil
+		}
+		blob := n.encode()
+		t.db.lock.Lock()
+		t.db.insert(n.hash, len(blob), rawNode(blob))
+		t.db.lock.Unlock()
+
+		if onleaf != nil {
+			return onleaf(nil, n.value, n.hash)
+		}
+	case *binaryBranch:
+		if !n.dirty {
+			return nil
+		}
+		if err := t.commit(n.left, onleaf); err != nil {
+			return err
+		}
+		if err := t.commit(n.right, onleaf); err != nil {
+			return err
+		}
+		blob := n.encode()
+		t.db.lock.Lock()
+		t.db.insert(n.hash, len(blob), rawNode(blob))
+		for _, child := range []binaryNode{n.left, n.right} {
+			if child != nil {
+				t.db.reference(binaryNodeHash(child), n.hash)
+			}
+		}
+		t.db.lock.Unlock()
+	}
+	return nil
+}
+
+// Copy returns a copy of BinaryTrie.
+func (t *BinaryTrie) Copy() *BinaryTrie {
+	cpy := *t
+	return &cpy
+}
+
+// hashKey returns the hash of key as an ephemeral buffer.
+// The caller must not hold onto the return value because it will become
+// invalid on the next call to hashKey or secKey.
+func (t *BinaryTrie) hashKey(key []byte) []byte {
+	h := newHasher(false)
+	h.sha.Reset()
+	h.sha.Write(key)
+	h.sha.Read(t.hashKeyBuf[:])
+	returnHasherToPool(h)
+	return t.hashKeyBuf[:]
+}
+
+// getSecKeyCache returns the current secure key cache, creating a new one if
+// ownership changed (i.e. the current trie is a copy of another owning the
+// actual cache).
+func (t *BinaryTrie) getSecKeyCache() map[string][]byte {
+	if t != t.secKeyCacheOwner {
+		t.secKeyCacheOwner = t
+		t.secKeyCache = make(map[string][]byte)
+	}
+	return t.secKeyCache
+}
+
+// resolve loads a node from the database.
+func (t *BinaryTrie) resolve(hash common.Hash, path []byte) (binaryNode, error) {
+	blob, err := t.db.Node(hash)
+	if err != nil || len(blob) == 0 {
+		return nil, &MissingNodeError{NodeHash: hash, Path: path}
+	}
+	return decodeBinaryNode(hash, blob)
+}
+
+// withChild returns a copy of the branch with the child on the given side
+// replaced.
+func (n *binaryBranch) withChild(bit byte, child binaryNode, dirty bool) *binaryBranch {
+	cpy := &binaryBranch{left: n.left, right: n.right, hash: n.hash, dirty: n.dirty}
+	if bit == 0 {
+		cpy.left = child
+	} else {
+		cpy.right = child
+	}
+	if dirty {
+		cpy.hash, cpy.dirty = common.Hash{}, true
+	}
+	return cpy
+}
+
+// encode returns the database encoding of a leaf.
+func (n *binaryLeaf) encode() []byte {
+	blob := make([]byte, 0, 1+len(n.key)+len(n.value))
+	blob = append(blob, binaryLeafPrefix)
+	blob = append(blob, n.key...)
+	return append(blob, n.value...)
+}
+
+// encode returns the database encoding of a hashed branch.
+func (n *binaryBranch) encode() []byte {
+	blob := make([]byte, 1+2*common.HashLength)
+	blob[0] = binaryBranchPrefix
+	copy(blob[1:], binaryNodeHash(n.left).Bytes())
+	copy(blob[1+common.HashLength:], binaryNodeHash(n.right).Bytes())
+	return blob
+}
+
+// decodeBinaryNode decodes the database encoding of a binary trie node.
+func decodeBinaryNode(hash common.Hash, blob []byte) (binaryNode, error) {
+	switch {
+	case len(blob) > 1+common.HashLength && blob[0] == binaryLeafPrefix:
+		return &binaryLeaf{
+			key:   common.CopyBytes(blob[1 : 1+common.HashLength]),
+			value: common.CopyBytes(blob[1+common.HashLength:]),
+			hash:  hash,
+		}, nil
+	case len(blob) == 1+2*common.HashLength && blob[0] == binaryBranchPrefix:
+		branch := &binaryBranch{hash: hash}
+		if left := common.BytesToHash(blob[1 : 1+common.HashLength]); left != (common.Hash{}) {
+			branch.left = binaryHashNode(left)
+		}
+		if right := common.BytesToHash(blob[1+common.HashLength:]); right != (common.Hash{}) {
+			branch.right = binaryHashNode(right)
+		}
+		return branch, nil
+	default:
+		return nil, fmt.Errorf("%w: %x", errInvalidBinaryNode, hash)
+	}
+}
+
+// binaryHash hashes a subtrie, returning its hash and the subtrie with all the
+// hashes cached. Empty subtries hash to the zero hash.
+func binaryHash(n binaryNode) (common.Hash, binaryNode) {
+	switch n := n.(type) {
+	case nil:
+		return common.Hash{}, nil
+	case binaryHashNode:
+		return common.Hash(n), n
+	case *binaryLeaf:
+		if n.hash != (common.Hash{}) {
+			return n.hash, n
+		}
+		cpy := *n
+		cpy.hash = sha256.Sum256(n.encode())
+		return cpy.hash, &cpy
+	case *binaryBranch:
+		if n.hash != (common.Hash{}) {
+			return n.hash, n
+		}
+		cpy := *n
+		_, cpy.left = binaryHash(n.left)
+		_, cpy.right = binaryHash(n.right)
+		cpy.hash = sha256.Sum256(cpy.encode())
+		return cpy.hash, &cpy
+	default:
+		panic(fmt.Sprintf("%T: invalid node: %v", n, n))
+	}
+}
+
+// binaryNodeHash returns the cached hash of a hashed node.
+func binaryNodeHash(n binaryNode) common.Hash {
+	switch n := n.(type) {
+	case nil:
+		return common.Hash{}
+	case binaryHashNode:
+		return common.Hash(n)
+	case *binaryLeaf:
+		return n.hash
+	case *binaryBranch:
+		return n.hash
+	default:
+		panic(fmt.Sprintf("%T: invalid node: %v", n, n))
+	}
+}
+
+// binaryBit returns the bit of key at the given depth.
+func binaryBit(key []byte, depth int) byte {
+	return (key[depth/8] >> (7 - uint(depth%8))) & 1
+}
+
+// binaryPath returns the path of bits leading to the given depth of key.
+func binaryPath(key []byte, depth int) []byte {
+	path := make([]byte, depth, depth+1)
+	for i := 0; i < depth; i++ {
+		path[i] = binaryBit(key, i)
+	}
+	return path
+}
+
+// Prove constructs a merkle proof for key. The result contains all encoded nodes
+// on the path to the value at key. The value itself is also included in the last
+// node and can be retrieved by verifying the proof.
+//
+// If the trie does not contain a value for key, the returned proof contains all
+// nodes of the longest existing prefix of the key (at least the root), ending
+// with the node that proves the absence of the key.
+func (t *BinaryTrie) Prove(key []byte, fromLevel uint, proofDb hykdb.KeyValueWriter) error {
+	if t.root == nil {
+		return nil
+	}
+	t.Hash()
+
+	var (
+		n     = t.root
+		depth int
+	)
+	for n != nil {
+		if hash, ok := n.(binaryHashNode); ok {
+			resolved, err := t.resolve(common.Hash(hash), binaryPath(key, depth))
+			if err != nil {
+				log.Error(fmt.Sprintf("Unhandled trie error: %v", err))
+				return err
+			}
+			n = resolved
+		}
+		var (
+			blob []byte
+			next binaryNode
+		)
+		switch node := n.(type) {
+		case *binaryLeaf:
+			blob = node.encode()
+		case *binaryBranch:
+			blob, next = node.encode(), node.left
+			if binaryBit(key, depth) == 1 {
+				next = node.right
+			}
+		}
+		if fromLevel > 0 {
+			fromLevel--
+		} else {
+			proofDb.Put(binaryNodeHash(n).Bytes(), blob)
+		}
+		n, depth = next, depth+1
+	}
+	return nil
+}
+
+// VerifyBinaryProof checks merkle proofs of a binary trie. The given proof must
+// contain the value for key in a trie with the given root hash. VerifyBinaryProof
+// returns an error if the proof contains invalid trie nodes or the wrong value.
+func VerifyBinaryProof(rootHash common.Hash, key []byte, proofDb hykdb.KeyValueReader) (value []byte, err error) {
+	if rootHash == emptyRoot {
+		return nil, nil
+	}
+	wantHash := rootHash
+	for depth := 0; ; depth++ {
+		blob, _ := proofDb.Get(wantHash[:])
+		if blob == nil {
+			return nil, fmt.Errorf("proof node %d (hash %064x) missing", depth, wantHash)
+		}
+		if sha256.Sum256(blob) != wantHash {
+			return nil, fmt.Errorf("bad proof node %d: hash mismatch", depth)
+		}
+		n, err := decodeBinaryNode(wantHash, blob)
+		if err != nil {
+			return nil, fmt.Errorf("bad proof node %d: %v", depth, err)
+		}
+		switch n := n.(type) {
+		case *binaryLeaf:
+			if !bytes.Equal(n.key, key) {
+				return nil, nil
+			}
+			return n.value, nil
+		case *binaryBranch:
+			child := n.left
+			if binaryBit(key, depth) == 1 {
+				child = n.right
+			}
+			if child == nil {
+				return nil, nil
+			}
+			wantHash = binaryNodeHash(child)
+		}
+	}
+}
@@ -0,0 +1,234 @@
+// Copyright 2021 The go-hayekchain Authors
+// This file is part of the go-hayekchain library.
+//
+// The go-hayekchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hayekchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hayekchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package trie
+
+import (
+	"bytes"
+
+	"github.com/hayekchain/go-hayekchain/common"
+)
+
+// binaryIteratorState represents the iteration state at one particular node of
+// a binary trie. Leaves are followed by a separate value element, mirroring the
+// value nodes of hexary tries.
+type binaryIteratorState struct {
+	hash    common.Hash // Hash of the node, zero for value elements
+	node    binaryNode  // Node being iterated, nil for value elements
+	leaf    *binaryLeaf // Leaf whose value is iterated, nil for nodes
+	parent  common.Hash // Hash of the parent node (zero if current is the root)
+	index   int         // Child to be processed next
+	pathlen int         // Length of the path to this node
+}
+
+// binaryNodeIterator is a NodeIterator over the nodes of a binary trie in pre
+// order. Paths are expressed in bits instead of nibbles.
+type binaryNodeIterator struct {
+	trie    *BinaryTrie
+	stack   []*binaryIteratorState
+	path    []byte
+	start   []byte // Bits of the start key, subtries entirely before are skipped
+	key     []byte // Start key, leaves before it are skipped
+	started bool
+	err     error
+}
+
+// NodeIterator returns an iterator that returns nodes of the trie. Iteration
+// starts at the key after the given start key.
+func (t *BinaryTrie) NodeIterator(start []byte) NodeIterator {
+	t.Hash()
+	it := &binaryNodeIterator{trie: t, key: start}
+	if len(start) > 0 {
+		it.start = binaryPath(start, 8*len(start))
+	}
+	return it
+}
+
+// Next moves the iterator to the next node. If the parameter is false, any child
+// nodes will be skipped.
+func (it *binaryNodeIterator) Next(descend bool) bool {
+	if it.err != nil {
+		return false
+	}
+	if !it.started {
+		it.started = true
+		if it.trie.root == nil {
+			return false
+		}
+		root, err := it.resolve(it.trie.root)
+		if err != nil {
+			it.err = err
+			return false
+		}
+		it.push(&binaryIteratorState{hash: binaryNodeHash(root), node: root}, nil)
+		return true
+	}
+	if !descend && len(it.stack) > 0 {
+		it.pop()
+	}
+	for len(it.stack) > 0 {
+		state, bit, ok, err := it.nextChild(it.stack[len(it.stack)-1])
+		if err != nil {
+			it.err = err
+			return false
+		}
+		if !ok {
+			it.pop()
+			continue
+		}
+		path := it.path
+		if bit != nil {
+			path = append(path, *bit)
+		}
+		if it.before(path, state.leaf) {
+			continue
+		}
+		it.push(state, bit)
+		return true
+	}
+	return false
+}
+
+// nextChild returns the next child of the given iteration state, if any. For
+// branches, the bit leading to the child is returned too.
+func (it *binaryNodeIterator) nextChild(parent *binaryIteratorState) (*binaryIteratorState, *byte, bool, error) {
+	switch n := parent.node.(type) {
+	case *binaryBranch:
+		for parent.index < 2 {
+			child, bit := n.left, byte(parent.index)
+			if bit == 1 {
+				child = n.right
+			}
+			parent.index++
+			if child == nil {
+				continue
+			}
+			resolved, err := it.resolve(child)
+			if err != nil {
+				parent.index--
+				return nil, nil, false, err
+			}
+			return &binaryIteratorState{hash: binaryNodeHash(resolved), node: resolved, parent: parent.hash}, &bit, true, nil
+		}
+	case *binaryLeaf:
+		if parent.index == 0 {
+			parent.index++
+			return &binaryIteratorState{leaf: n, parent: parent.hash}, nil, true, nil
+		}
+	}
+	return nil, nil, false, nil
+}
+
+// before reports whether all keys below the given path, or the given leaf, are
+// before the start key of the iterator.
+func (it *binaryNodeIterator) before(path []byte, leaf *binaryLeaf) bool {
+	if leaf != nil {
+		return bytes.Compare(leaf.key, it.key) < 0
+	}
+	if len(path) > len(it.start) {
+		path = path[:len(it.start)]
+	}
+	return bytes.Compare(path, it.start[:len(path)]) < 0
+}
+
+// resolve loads a node from the database if it's not yet in memory.
+func (it *binaryNodeIterator) resolve(n binaryNode) (binaryNode, error) {
+	if hash, ok := n.(binaryHashNode); ok {
+		return it.trie.resolve(common.Hash(hash), it.path)
+	}
+	return n, nil
+}
+
+func (it *binaryNodeIterator) push(state *binaryIteratorState, bit *byte) {
+	state.pathlen = len(it.path)
+	if bit != nil {
+		it.path = append(it.path, *bit)
+	}
+	it.stack = append(it.stack, state)
+}
+
+func (it *binaryNodeIterator) pop() {
+	state := it.stack[len(it.stack)-1]
+	it.path = it.path[:state.pathlen]
+	it.stack = it.stack[:len(it.stack)-1]
+}
+
+// Error returns the error status of the iterator.
+func (it *binaryNodeIterator) Error() error {
+	return it.err
+}
+
+// Hash returns the hash of the current node.
+func (it *binaryNodeIterator) Hash() common.Hash {
+	if len(it.stack) == 0 {
+		return common.Hash{}
+	}
+	return it.stack[len(it.stack)-1].hash
+}
+
+// Parent returns the hash of the parent of the current node.
+func (it *binaryNodeIterator) Parent() common.Hash {
+	if len(it.stack) == 0 {
+		return common.Hash{}
+	}
+	return it.stack[len(it.stack)-1].parent
+}
+
+// Path returns the bit path to the current node.
+func (it *binaryNodeIterator) Path() []byte {
+	return it.path
+}
+
+// Leaf returns true iff the current node is a leaf value.
+func (it *binaryNodeIterator) Leaf() bool {
+	return len(it.stack) > 0 && it.stack[len(it.stack)-1].leaf != nil
+}
+
+// LeafKey returns the key of the leaf. The method panics if the iterator is not
+// positioned at a leaf.
+func (it *binaryNodeIterator) LeafKey() []byte {
+	if it.Leaf() {
+		return it.stack[len(it.stack)-1].leaf.key
+	}
+	panic("not at leaf")
+}
+
+// LeafBlob returns the content of the leaf. The method panics if the iterator
+// is not positioned at a leaf.
+func (it *binaryNodeIterator) LeafBlob() []byte {
+	if it.Leaf() {
+		return it.stack[len(it.stack)-1].leaf.value
+	}
+	panic("not at leaf")
+}
+
+// LeafProof returns the encoded nodes on the path to the leaf. The method panics
+// if the iterator is not positioned at a leaf.
+func (it *binaryNodeIterator) LeafProof() [][]byte {
+	if !it.Leaf() {
+		panic("not at leaf")
+	}
+	proofs := make([][]byte, 0, len(it.stack))
+	for _, state := range it.stack[:len(it.stack)-1] {
+		switch n := state.node.(type) {
+		case *binaryBranch:
+			proofs = append(proofs, n.encode())
+		case *binaryLeaf:
+			proofs = append(proofs, n.encode())
+		}
+	}
+	return proofs
+}
@@ -0,0 +1,278 @@
+// Copyright 2021 The go-hayekchain Authors
+// This file is part of the go-hayekchain library.
+//
+// The go-hayekchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-hayekchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-hayekchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package trie
+
+import (
+	"bytes"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/hayekchain/go-hayekchain/common"
+	"github.com/hayekchain/go-hayekchain/crypto"
+	"github.com/hayekchain/go-hayekchain/hykdb/memorydb"
+)
+
+// makeTestBinaryTrie creates a binary trie filled with random data, committed
+// to its database.
+func makeTestBinaryTrie(t *testing.T, n int) (*Database, *BinaryTrie, map[string][]byte) {
+	triedb := NewDatabase(memorydb.New())
+	trie, _ := NewBinary(common.Hash{}, triedb)
+
+	content := make(map[string][]byte)
+	for i := 0; i < n; i++ {
+		key, val := common.LeftPadBytes([]byte{byte(i >> 8), byte(i)}, 32), []byte{byte(i), 1}
+		content[string(key)] = val
+		trie.Update(key, val)
+	}
+	root, err := trie.Commit(nil)
+	if err != nil {
+		t.Fatalf("failed to commit trie: %v", err)
+	}
+	if err := triedb.Commit(root, false, nil); err != nil {
+		t.Fatalf("failed to commit trie database: %v", err)
+	}
+	trie, _ = NewBinary(root, triedb)
+	return triedb, trie, content
+}
+
+func TestBinaryTrieEmpty(t *testing.T) {
+	trie, _ := NewBinary(common.Hash{}, NewDatabase(memorydb.New()))
+	if root := trie.Hash(); root != emptyRoot {
+		t.Fatalf("empty root mismatch: have %x, want %x", root, emptyRoot)
+	}
+	if _, err := NewBinary(common.Hash{1}, NewDatabase(memorydb.New())); err == nil {
+		t.Fatalf("opened trie with missing root")
+	}
+}
+
+// Tests that the root of a binary trie only depends on its contents, not on the
+// order of the updates leading to them.
+func TestBinaryTrieDeterminism(t *testing.T) {
+	var (
+		keys   [][]byte
+		values = make(map[string][]byte)
+	)
+	for len(keys) < 500 {
+		key := make([]byte, 1+rand.Intn(40))
+		rand.Read(key)
+		if _, ok := values[string(key)]; ok {
+			continue
+		}
+		keys = append(keys, key)
+		values[string(key)] = []byte{byte(len(keys)), byte(len(keys) >> 8)}
+	}
+	build := func(order []int, deleted map[int]bool) *BinaryTrie {
+		trie, _ := NewBinary(common.Hash{}, NewDatabase(memorydb.New()))
+		for _, i := range order {
+			trie.Update(keys[i], values[string(keys[i])])
+		}
+		for _, i := range order {
+			if deleted[i] {
+				trie.Delete(keys[i])
+			}
+		}
+		return trie
+	}
+	var (
+		ordered  = rand.Perm(len(keys))
+		shuffled = rand.Perm(len(keys))
+		deleted  = make(map[int]bool)
+		kept     []int
+	)
+	sort.Ints(ordered)
+	for i := range keys {
+		if rand.Intn(3) == 0 {
+			deleted[i] = true
+		} else {
+			kept = append(kept, i)
+		}
+	}
+	want := build(kept, nil)
+	if have := build(shuffled, nil).Hash(); have != build(ordered, nil).Hash() {
+		t.Fatalf("root depends on insertion order")
+	}
+	trie := build(shuffled, deleted)
+	if have := trie.Hash(); have != want.Hash() {
+		t.Fatalf("root mismatch after deletions: have %x, want %x", have, want.Hash())
+	}
+	for i, key := range keys {
+		have := trie.Get(key)
+		if deleted[i] && have != nil {
+			t.Errorf("key %x: deleted value found: %x", key, have)
+		}
+		if !deleted[i] && !bytes.Equal(have, values[string(key)]) {
+			t.Errorf("key %x: value mismatch: have %x, want %x", key, have, values[string(key)])
+		}
+	}
+	for _, i := range kept {
+		trie.Delete(keys[i])
+	}
+	if root := trie.Hash(); root != emptyRoot {
+		t.Fatalf("root of emptied trie mismatch: have %x, want %x", root, emptyRoot)
+	}
+}
+
+// Tests that committed binary tries can be reopened and modified from the disk
+// database alone.
+func TestBinaryTrieCommit(t *testing.T) {
+	triedb, trie, content := makeTestBinaryTrie(t, 300)
+	root := trie.Hash()
+
+	trie, err := NewBinary(root, NewDatabase(triedb.DiskDB()))
+	if err != nil {
+		t.Fatalf("failed to reopen trie: %v", err)
+	}
+	for key, val := range content {
+		if have := trie.Get([]byte(key)); !bytes.Equal(have, val) {
+			t.Fatalf("key %x: value mismatch: have %x, want %x", key, have, val)
+		}
+	}
+	// Modify the reopened trie and ensure it matches one built from scratch
+	fresh, _ := NewBinary(common.Hash{}, NewDatabase(memorydb.New()))
+	for key, val := range content {
+		if key[len(key)-1]%2 == 0 {
+			trie.Delete([]byte(key))
+			continue
+		}
+		val = append(val, 2)
+		trie.Update([]byte(key), val)
+		fresh.Update([]byte(key), val)
+	}
+	if have, want := trie.Hash(), fresh.Hash(); have != want {
+		t.Fatalf("root mismatch: have %x, want %x", have, want)
+	}
+	// The preimages of the keys should be available
+	key := common.LeftPadBytes([]byte{0, 1}, 32)
+	if have := trie.GetKey(crypto.Keccak256(key)); !bytes.Equal(have, key) {
+		t.Fatalf("preimage mismatch: have %x, want %x", have, key)
+	}
+}
+
+func TestBinaryTrieProof(t *testing.T) {
+	_, trie, content := makeTestBinaryTrie(t, 300)
+	root := trie.Hash()
+
+	for key, val := range content {
+		proofs := memorydb.New()
+		if err := trie.Prove(crypto.Keccak256([]byte(key)), 0, proofs); err != nil {
+			t.Fatalf("failed to prove key %x: %v", key, err)
+		}
+		have, err := VerifyBinaryProof(root, crypto.Keccak256([]byte(key)), proofs)
+		if err != nil {
+			t.Fatalf("failed to verify proof of key %x: %v", key, err)
+		}
+		if !bytes.Equal(have, val) {
+			t.Fatalf("key %x: proven value mismatch: have %x, want %x", key, have, val)
+		}
+	}
+	// Prove the absence of a missing key
+	missing := crypto.Keccak256([]byte("missing"))
+	proofs := memorydb.New()
+	if err := trie.Prove(missing, 0, proofs); err != nil {
+		t.Fatalf("failed to prove missing key: %v", err)
+	}
+	if have, err := VerifyBinaryProof(root, missing, proofs); err != nil || have != nil {
+		t.Fatalf("absence proof mismatch: have %x, %v", have, err)
+	}
+	// Ensure tampered proofs are rejected
+	blob, _ := proofs.Get(root[:])
+	blob = common.CopyBytes(blob)
+	blob[len(blob)-1] ^= 1
+	proofs.Put(root[:], blob)
+	if _, err := VerifyBinaryProof(root, missing, proofs); err == nil {
+		t.Fatalf("tampered proof accepted")
+	}
+}
+
+func TestBinaryTrieIterator(t *testing.T) {
+	_, trie, content := makeTestBinaryTrie(t, 300)
+
+	var hashes [][]byte
+	for key := range content {
+		hashes = append(hashes, crypto.Keccak256([]byte(key)))
+	}
+	sort.Slice(hashes, func(i, j int) bool { return bytes.Compare(hashes[i], hashes[j]) < 0 })
+
+	it := NewIterator(trie.NodeIterator(nil))
+	for i := 0; it.Next(); i++ {
+		if !bytes.Equal(it.Key, hashes[i]) {
+			t.Fatalf("item %d: key mismatch: have %x, want %x", i, it.Key, hashes[i])
+		}
+		if want := content[string(trie.GetKey(it.Key))]; !bytes.Equal(it.Value, want) {
+			t.Fatalf("item %d: value mismatch: have %x, want %x", i, it.Value, want)
+		}
+		hashes[i] = nil
+	}
+	if it.Err != nil {
+		t.Fatalf("iteration failed: %v", it.Err)
+	}
+	for i, hash := range hashes {
+		if hash != nil {
+			t.Fatalf("item %d: key %x not iterated", i, hash)
+		}
+	}
+	// Ensure iteration can start at an arbitrary key
+	start := crypto.Keccak256([]byte("start"))
+	it = NewIterator(trie.NodeIterator(start))
+	if !it.Next() {
+		t.Fatalf("no items after start key")
+	}
+	for key := range content {
+		hash := crypto.Keccak256([]byte(key))
+		if bytes.Compare(hash, start) >= 0 && bytes.Compare(hash, it.Key) < 0 {
+			t.Fatalf("key %x skipped, first iterated %x", hash, it.Key)
+		}
+	}
+}
+
+// Tests that binary tries can be synced node by node.
+func TestBinaryTrieSync(t *testing.T) {
+	srcDb, srcTrie, content := makeTestBinaryTrie(t, 300)
+
+	diskdb := memorydb.New()
+	sched := NewBinarySync(srcTrie.Hash(), diskdb, nil, NewSyncBloom(1, diskdb))
+
+	nodes, _, _ := sched.Missing(100)
+	for len(nodes) > 0 {
+		for _, hash := range nodes {
+			data, err := srcDb.Node(hash)
+			if err != nil {
+				t.Fatalf("failed to retrieve node data for hash %x: %v", hash, err)
+			}
+			if err := sched.Process(SyncResult{hash, data}); err != nil {
+				t.Fatalf("failed to process result: %v", err)
+			}
+		}
+		batch := diskdb.NewBatch()
+		if err := sched.Commit(batch); err != nil {
+			t.Fatalf("failed to commit data: %v", err)
+		}
+		batch.Write()
+
+		nodes, _, _ = sched.Missing(100)
+	}
+	trie, err := NewBinary(srcTrie.Hash(), NewDatabase(diskdb))
+	if err != nil {
+		t.Fatalf("failed to open synced trie: %v", err)
+	}
+	for key, val := range content {
+		if have := trie.Get([]byte(key)); !bytes.Equal(have, val) {
+			t.Fatalf("key %x: value mismatch: have %x, want %x", key, have, val)
+		}
+	}
+}
@@ -35,7 +35,7 @@ var stPool = sync.Pool{
 	},
 }
 
-func stackTrieFromPool(db hykdb.KeyValueStore) *StackTrie {
+func stackTrieFromPool(db hykdb.KeyValueWriter) *StackTrie {
 	st := stPool.Get().(*StackTrie)
 	st.db = db
 	return st
@@ -56,18 +56,18 @@ type StackTrie struct {
 	keyOffset int            // offset of the key chunk inside a full key
 	children  [16]*StackTrie // list of children (for fullnodes and exts)
 
-	db hykdb.KeyValueStore // Pointer to the commit db, can be nil
+	db hykdb.KeyValueWriter // Pointer to the commit db, can be nil
 }
 
 // NewStackTrie allocates and initializes an empty trie.
-func NewStackTrie(db hykdb.KeyValueStore) *StackTrie {
+func NewStackTrie(db hykdb.KeyValueWriter) *StackTrie {
 	return &StackTrie{
 		nodeType: emptyNode,
 		db:       db,
 	}
 }
 
-func newLeaf(ko int, key, val []byte, db hykdb.KeyValueStore) *StackTrie {
+func newLeaf(ko int, key, val []byte, db hykdb.KeyValueWriter) *StackTrie {
 	st := stackTrieFromPool(db)
 	st.nodeType = leafNode
 	st.keyOffset = ko
@@ -76,7 +76,7 @@ func newLeaf(ko int, key, val []byte, db hykdb.KeyValueStore) *StackTrie {
 	return st
 }
 
-func newExt(ko int, key []byte, child *StackTrie, db hykdb.KeyValueStore) *StackTrie {
+func newExt(ko int, key []byte, child *StackTrie, db hykdb.KeyValueWriter) *StackTrie {
 	st := stackTrieFromPool(db)
 	st.nodeType = extNode
 	st.keyOffset = ko
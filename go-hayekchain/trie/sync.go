@@ -129,10 +129,22 @@ type Sync struct {
 	queue    *prque.Prque             // Priority queue with the pending requests
 	fetches  map[int]int              // Number of active fetches per trie node depth
 	bloom    *SyncBloom               // Bloom filter for fast state existence checks
+	binary   bool                     // Whether the tries are binary instead of hexary
 }
 
 // NewSync creates a new trie data download scheduler.
 func NewSync(root common.Hash, database hykdb.KeyValueReader, callback LeafCallback, bloom *SyncBloom) *Sync {
+	return newSync(root, database, callback, bloom, false)
+}
+
+// NewBinarySync creates a new trie data download scheduler for binary tries.
+// Binary trie nodes are keyed by their SHA256 hash, the results delivered for
+// them must be too.
+func NewBinarySync(root common.Hash, database hykdb.KeyValueReader, callback LeafCallback, bloom *SyncBloom) *Sync {
+	return newSync(root, database, callback, bloom, true)
+}
+
+func newSync(root common.Hash, database hykdb.KeyValueReader, callback LeafCallback, bloom *SyncBloom, binary bool) *Sync {
 	ts := &Sync{
 		database: database,
 		membatch: newSyncMemBatch(),
@@ -141,6 +153,7 @@ func NewSync(root common.Hash, database hykdb.KeyValueReader, callback LeafCallb
 		queue:    prque.New(nil),
 		fetches:  make(map[int]int),
 		bloom:    bloom,
+		binary:   binary,
 	}
 	ts.AddSubTrie(root, nil, common.Hash{}, callback)
 	return ts
@@ -280,17 +293,29 @@ func (s *Sync) Process(result SyncResult) error {
 	// There is an pending node request for this data, fill it.
 	if req := s.nodeReqs[result.Hash]; req != nil && req.data == nil {
 		filled = true
-		// Decode the node data content and update the request
-		node, err := decodeNode(result.Hash[:], result.Data)
-		if err != nil {
-			return err
-		}
-		req.data = result.Data
+		// Decode the node data content and update the request, scheduling a
+		// request for all the children nodes
+		var requests []*request
+		if s.binary {
+			node, err := decodeBinaryNode(result.Hash, result.Data)
+			if err != nil {
+				return err
+			}
+			req.data = result.Data
+
+			if requests, err = s.binaryChildren(req, node); err != nil {
+				return err
+			}
+		} else {
+			node, err := decodeNode(result.Hash[:], result.Data)
+			if err != nil {
+				return err
+			}
+			req.data = result.Data
 
-		// Create and schedule a request for all the children nodes
-		requests, err := s.children(req, node)
-		if err != nil {
-			return err
+			if requests, err = s.children(req, node); err != nil {
+				return err
+			}
 		}
 		if len(requests) == 0 && req.deps == 0 {
 			s.commit(req)
@@ -313,11 +338,15 @@ func (s *Sync) Commit(dbw hykdb.Batch) error {
 	// Dump the membatch into a database dbw
 	for key, value := range s.membatch.nodes {
 		rawdb.WriteTrieNode(dbw, key, value)
-		s.bloom.Add(key[:])
+		if s.bloom != nil {
+			s.bloom.Add(key[:])
+		}
 	}
 	for key, value := range s.membatch.codes {
 		rawdb.WriteCode(dbw, key, value)
-		s.bloom.Add(key[:])
+		if s.bloom != nil {
+			s.bloom.Add(key[:])
+		}
 	}
 	// Drop the membatch data and return
 	s.membatch = newSyncMemBatch()
@@ -428,6 +457,52 @@ func (s *Sync) children(req *request, object node) ([]*request, error) {
 	return requests, nil
 }
 
+// binaryChildren retrieves all the missing children of a binary trie node for
+// future retrieval scheduling. Paths within binary tries are expressed in bits,
+// but leaf callbacks are passed the key of the leaf in nibbles like for hexary
+// tries, prefixing the paths of any subtries.
+func (s *Sync) binaryChildren(req *request, object binaryNode) ([]*request, error) {
+	switch node := object.(type) {
+	case *binaryLeaf:
+		if req.callback != nil {
+			path := keybytesToHex(node.key)
+			if err := req.callback(path[:len(path)-1], node.value, req.hash); err != nil {
+				return nil, err
+			}
+		}
+		return nil, nil
+	case *binaryBranch:
+		var requests []*request
+		for i, child := range []binaryNode{node.left, node.right} {
+			if child == nil {
+				continue
+			}
+			hash := binaryNodeHash(child)
+			if s.membatch.hasNode(hash) {
+				continue
+			}
+			if s.bloom == nil || s.bloom.Contains(hash[:]) {
+				// Bloom filter says this might be a duplicate, double check.
+				if blob := rawdb.ReadTrieNode(s.database, hash); len(blob) > 0 {
+					continue
+				}
+				// False positive, bump fault meter
+				bloomFaultMeter.Mark(1)
+			}
+			// Locally unknown node, schedule for retrieval
+			requests = append(requests, &request{
+				path:     append(append([]byte(nil), req.path...), byte(i)),
+				hash:     hash,
+				parents:  []*request{req},
+				callback: req.callback,
+			})
+		}
+		return requests, nil
+	default:
+		panic(fmt.Sprintf("unknown node: %+v", node))
+	}
+}
+
 // commit finalizes a retrieval request and stores it into the membatch. If any
 // of the referencing parent requests complete due to this commit, they are also
 // committed themselves.